@@ -4,15 +4,21 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/marciniwanicki/craby/internal/api"
 	"github.com/marciniwanicki/craby/internal/client"
+	"github.com/marciniwanicki/craby/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -27,10 +33,24 @@ const (
 )
 
 var (
-	verbose bool
-	quiet   bool
+	verbose    bool
+	trace      bool
+	quiet      bool
+	jsonOutput bool
+	outputFmt  string
+	persona    string
+	promptFile string
+	noTools    bool
+	disabled   []string
+	prefix     string
+	suffix     string
+	teeFile    string
+	planFirst  bool
 )
 
+// maxPromptFileBytes bounds prompt files, well within what the daemon accepts in one request
+const maxPromptFileBytes = 256 * 1024
+
 // Crab logo lines for side-by-side rendering with name
 var crabLines = []string{
 	" ▀▄  ▄▀",
@@ -40,11 +60,15 @@ var crabLines = []string{
 
 func chatCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "chat",
+		Use:   "chat [message]",
 		Short: "Start interactive chat",
-		Long:  "Start an interactive REPL mode for chatting with the AI.",
+		Long: `Start an interactive REPL mode for chatting with the AI.
+
+If a message is provided, it is sent as a one-shot query instead. With --prompt-file, the
+file's contents are sent, after the message if one is given.`,
+		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			c := client.NewClient(port)
+			c := newClient()
 			ctx := context.Background()
 
 			// Determine verbosity
@@ -55,14 +79,68 @@ func chatCmd() *cobra.Command {
 				verbosity = client.VerbosityVerbose
 			}
 
+			output := client.OutputFormat(outputFmt)
+			if output != client.OutputText && output != client.OutputJSON {
+				return fmt.Errorf("invalid --output %q (expected text or json)", outputFmt)
+			}
+
+			// Fail before starting the session if the persona has no template
+			if persona != "" {
+				if _, err := config.LoadPersona(persona); err != nil {
+					return err
+				}
+			}
+
+			message := strings.Join(args, " ")
+			if promptFile != "" {
+				content, err := readPromptFile(promptFile)
+				if err != nil {
+					return err
+				}
+				message = joinPrompt(message, content)
+			}
+
 			opts := client.ChatOptions{
-				Verbosity: verbosity,
+				Verbosity:     verbosity,
+				JSON:          jsonOutput,
+				Raw:           raw,
+				Output:        output,
+				Persona:       persona,
+				NoTools:       noTools,
+				Transcript:    client.NewTranscript(c.SessionID()),
+				DisabledTools: disabled,
+				PromptPrefix:  prefix,
+				PromptSuffix:  suffix,
+			}
+			if trace {
+				opts.Trace = os.Stderr
+			}
+			if planFirst {
+				opts.ApprovePlan = planApprover(&scannerReader{scanner: bufio.NewScanner(os.Stdin)}, os.Stdout)
+			}
+			if teeFile != "" {
+				f, err := os.OpenFile(teeFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+				if err != nil {
+					return fmt.Errorf("failed to open --tee file: %w", err)
+				}
+				defer f.Close()
+				opts.Tee = f
 			}
 
 			// Start daemon if not running
 			if err := ensureDaemonRunning(ctx, c); err != nil {
 				return err
 			}
+			if err := useModelFlag(ctx, cmd, c); err != nil {
+				return err
+			}
+
+			// One-shot mode
+			if message != "" {
+				err := c.Chat(ctx, message, os.Stdout, opts)
+				saveTranscript(opts.Transcript)
+				return err
+			}
 
 			// Interactive REPL mode
 			return runREPL(ctx, c, opts)
@@ -70,16 +148,79 @@ func chatCmd() *cobra.Command {
 	}
 
 	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Show tool call details and results")
+	cmd.Flags().BoolVar(&trace, "trace", false, "Print the full agent loop to stderr: plans, tool arguments, raw tool output and model turns")
 	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only show assistant responses (hide tool info)")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Constrain responses to valid JSON and print them unformatted")
+	cmd.Flags().StringVarP(&outputFmt, "output", "o", "text", "Output format: text, or json for one JSON object per response")
+	cmd.Flags().StringVar(&persona, "persona", "", "Answer as a persona from ~/.craby/templates/<persona>.md")
+	cmd.Flags().StringVar(&promptFile, "prompt-file", "", "Send the contents of this file as the message, after the message argument if given")
+	cmd.Flags().BoolVar(&noTools, "no-tools", false, "Answer without running any tools, including shell commands")
+	cmd.Flags().StringSliceVar(&disabled, "disable-tool", nil, "Don't let the assistant use this tool (repeatable)")
+	cmd.Flags().StringVar(&prefix, "prompt-prefix", "", "Add these instructions before every message, replacing daemon.prompt.prefix")
+	cmd.Flags().StringVar(&suffix, "prompt-suffix", "", "Add these instructions after every message, replacing daemon.prompt.suffix")
+	cmd.Flags().StringVar(&teeFile, "tee", "", "Also append the output to this file, as plain text")
+	cmd.Flags().BoolVar(&planFirst, "plan", false, "Show the shell commands the assistant plans to run and ask before running them")
+	_ = cmd.RegisterFlagCompletionFunc("persona", completePersonas)
 
 	return cmd
 }
 
+// readPromptFile reads a prompt from a file, rejecting missing, empty and oversized files
+func readPromptFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read prompt file: %w", err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("prompt file %s is a directory", path)
+	}
+	if info.Size() > maxPromptFileBytes {
+		return "", fmt.Errorf("prompt file %s is too large (%d bytes, max %d)", path, info.Size(), maxPromptFileBytes)
+	}
+
+	data, err := os.ReadFile(path) //nolint:gosec // G304: path is chosen by the user
+	if err != nil {
+		return "", fmt.Errorf("cannot read prompt file: %w", err)
+	}
+	content := strings.TrimSpace(string(data))
+	if content == "" {
+		return "", fmt.Errorf("prompt file %s is empty", path)
+	}
+	return content, nil
+}
+
+// joinPrompt puts a typed message before file contents, separated by a blank line
+func joinPrompt(message, content string) string {
+	if message == "" {
+		return content
+	}
+	return message + "\n\n" + content
+}
+
+// saveTranscript writes the session to the --transcript file, if one was given
+func saveTranscript(t *client.Transcript) {
+	if transcript == "" || t.Len() == 0 {
+		return
+	}
+	if err := t.Save(transcript); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving transcript: %v\n", err)
+	}
+}
+
 // ensureDaemonRunning starts the daemon in the background if it's not already running.
 // It waits for the daemon to become ready before returning.
 func ensureDaemonRunning(ctx context.Context, c *client.Client) error {
-	if c.IsRunning(ctx) {
-		return nil
+	err := c.Probe(ctx)
+	if err == nil {
+		return checkDaemonVersion(ctx, c)
+	}
+	// Something holds the port, so starting another daemon would fail to bind
+	if errors.Is(err, client.ErrUnresponsive) {
+		return fmt.Errorf("%w on %s; restart it with 'craby terminate' or stop its process", err, daemonAddr())
+	}
+
+	if noAutostart {
+		return fmt.Errorf("daemon is not running (start it with 'craby daemon')")
 	}
 
 	// Get the path to the current executable
@@ -88,8 +229,8 @@ func ensureDaemonRunning(ctx context.Context, c *client.Client) error {
 		return fmt.Errorf("failed to get executable path: %w", err)
 	}
 
-	// Build command with current flags
-	args := []string{"daemon", fmt.Sprintf("--port=%d", port)}
+	// Build command with current flags. The daemon reports readiness on fd 3, the first extra file.
+	args := []string{"daemon", "--listen=" + daemonAddr(), "--ready-fd=3"}
 	if ollamaURL != "" {
 		args = append(args, fmt.Sprintf("--ollama-url=%s", ollamaURL))
 	}
@@ -97,35 +238,105 @@ func ensureDaemonRunning(ctx context.Context, c *client.Client) error {
 		args = append(args, fmt.Sprintf("--model=%s", model))
 	}
 
-	cmd := exec.Command(executable, args...)
+	// Capture daemon output in a file so startup errors can be reported
+	outputPath, err := config.DaemonOutputPath()
+	if err != nil {
+		return fmt.Errorf("failed to get daemon output path: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0750); err != nil {
+		return fmt.Errorf("failed to create logs directory: %w", err)
+	}
+	output, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create daemon output file: %w", err)
+	}
+	defer output.Close()
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create ready pipe: %w", err)
+	}
+	defer readyR.Close()
+
+	cmd := exec.Command(executable, args...) //nolint:gosec // G204: re-executing our own binary
 	// Detach from parent process
 	cmd.Stdin = nil
-	cmd.Stdout = nil
-	cmd.Stderr = nil
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.ExtraFiles = []*os.File{readyW}
 
-	if err := cmd.Start(); err != nil {
+	err = cmd.Start()
+	// Only the daemon writes to the pipe now, so reading it ends when the daemon exits
+	_ = readyW.Close()
+	if err != nil {
 		return fmt.Errorf("failed to start daemon: %w", err)
 	}
 
+	// Notice if the daemon exits before becoming ready
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	ready := make(chan error, 1)
+	go func() {
+		ready <- waitReady(readyR)
+	}()
+
 	// Wait for daemon to become ready
 	timeout := time.After(5 * time.Second)
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
-
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-timeout:
-			return fmt.Errorf("timeout waiting for daemon to start")
-		case <-ticker.C:
-			if c.IsRunning(ctx) {
+		case err := <-ready:
+			if err == nil {
 				return nil
 			}
+			// The pipe closed without a READY line; the exit status explains why
+			ready = nil
+		case err := <-exited:
+			if err == nil {
+				err = fmt.Errorf("exited unexpectedly")
+			}
+			return fmt.Errorf("daemon failed to start: %w%s", err, daemonStartupOutput(outputPath))
+		case <-timeout:
+			return fmt.Errorf("timeout waiting for daemon to start%s", daemonStartupOutput(outputPath))
 		}
 	}
 }
 
+// waitReady blocks until the daemon writes its READY line to r
+func waitReady(r io.Reader) error {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if !strings.HasPrefix(line, "READY ") {
+		if err == nil {
+			err = fmt.Errorf("unexpected ready notification %q", strings.TrimSpace(line))
+		}
+		return fmt.Errorf("daemon did not report ready: %w", err)
+	}
+	return nil
+}
+
+// daemonStartupOutput returns the tail of the daemon's captured output, formatted for an error message
+func daemonStartupOutput(path string) string {
+	data, err := os.ReadFile(path) //nolint:gosec // G304: path is from user's config dir
+	if err != nil {
+		return ""
+	}
+
+	out := strings.TrimSpace(string(data))
+	if out == "" {
+		return ""
+	}
+
+	const maxOutput = 2000
+	if len(out) > maxOutput {
+		out = "..." + out[len(out)-maxOutput:]
+	}
+	return "\n\nDaemon output:\n" + out
+}
+
 func printChatHelp() {
 	fmt.Printf("\n%sAvailable commands:%s\n", colorWhite, colorReset)
 	fmt.Printf("  %s/help%s        Show this help message\n", colorLightYellow, colorReset)
@@ -135,9 +346,14 @@ func printChatHelp() {
 	fmt.Printf("  %s/tool list%s   List all registered LLM tools\n", colorLightYellow, colorReset)
 	fmt.Printf("  %s/tool run <name> key=value ...%s  Run a tool directly\n", colorLightYellow, colorReset)
 	fmt.Printf("  %s/history%s     Show conversation history\n", colorLightYellow, colorReset)
+	fmt.Printf("  %s/model%s       Show the current model\n", colorLightYellow, colorReset)
+	fmt.Printf("  %s/model <name>%s  Switch the model for the following messages\n", colorLightYellow, colorReset)
 	fmt.Printf("  %s/context%s     Show current context\n", colorLightYellow, colorReset)
 	fmt.Printf("  %s/context <text>%s  Set context for the conversation\n", colorLightYellow, colorReset)
 	fmt.Printf("  %s/context clear%s   Clear the context\n", colorLightYellow, colorReset)
+	fmt.Printf("  %s/save <path>%s  Save the conversation (.json for JSON, markdown otherwise)\n", colorLightYellow, colorReset)
+	fmt.Printf("  %s/load <path>%s  Include a file's contents in the next message\n", colorLightYellow, colorReset)
+	fmt.Printf("\n%sEnd a line with \\ to continue on the next one, or wrap a message in \"\"\" to send several lines.%s\n", colorGray, colorReset)
 	fmt.Println()
 }
 
@@ -168,32 +384,93 @@ func runREPL(ctx context.Context, c *client.Client, opts client.ChatOptions) err
 	// Ensure cursor is restored on exit (normal or interrupt)
 	defer fmt.Print(cursorShow)
 
-	// Handle interrupt signal to restore cursor
+	// Scripted output reads one message per line and prints only the results
+	scripted := opts.Output == client.OutputJSON
+
+	var buffer multilineBuffer
+	var answering atomic.Bool
+	var loaded string // File contents from /load, sent with the next message
+
+	// Ctrl+C outside the line editor stops the answer being generated or discards a multi-line message
+	// being entered, otherwise it exits restoring the cursor
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		<-sigChan
-		fmt.Print(cursorShow)
-		os.Exit(0)
+		for sig := range sigChan {
+			if sig == os.Interrupt && answering.Load() {
+				if _, err := c.Cancel(ctx); err == nil {
+					continue
+				}
+			}
+			if sig == os.Interrupt && buffer.Cancel() {
+				fmt.Printf("\n%sMessage discarded.%s\n%s❯%s ", colorGray, colorReset, colorWhite, colorReset)
+				continue
+			}
+			fmt.Print(cursorShow)
+			saveTranscript(opts.Transcript)
+			os.Exit(0)
+		}
 	}()
+	defer saveTranscript(opts.Transcript)
 
-	scanner := bufio.NewScanner(os.Stdin)
-	printBanner(c, ctx)
+	reader := newLineReader(scripted)
+	defer reader.Close()
+	if opts.ApprovePlan != nil {
+		// The line editor owns the terminal, so plans are approved through it too
+		opts.ApprovePlan = planApprover(reader, os.Stdout)
+	}
+	if !scripted {
+		printBanner(c, ctx)
+	}
 
 	for {
-		fmt.Printf("%s❯%s ", colorWhite, colorReset)
-		if !scanner.Scan() {
+		prompt := "❯"
+		if buffer.Pending() {
+			prompt = "…"
+		}
+		promptText := ""
+		if !scripted {
+			promptText = fmt.Sprintf("%s%s%s ", colorWhite, prompt, colorReset)
+		}
+
+		line, err := reader.ReadLine(promptText)
+		if errors.Is(err, errInputInterrupted) {
+			// Ctrl+C discards the message being entered, and leaves at an empty prompt
+			if buffer.Cancel() {
+				fmt.Printf("%sMessage discarded.%s\n", colorGray, colorReset)
+				continue
+			}
+			if line != "" {
+				continue
+			}
 			break
 		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
 
-		input := strings.TrimSpace(scanner.Text())
+		if !scripted {
+			// Reprint the prompt line in gray (move up, clear, reprint)
+			fmt.Printf("\033[F\033[K%s%s%s %s\n", colorGray, prompt, colorReset, line)
+		}
+
+		input := line
+		if !scripted {
+			message, complete := buffer.Add(line)
+			if !complete {
+				continue
+			}
+			input = message
+		}
+
+		input = strings.TrimSpace(input)
 		if input == "" {
 			continue
 		}
 
-		// Reprint the prompt line in gray (move up, clear, reprint)
-		fmt.Printf("\033[F\033[K%s❯%s %s\n", colorGray, colorReset, input)
-
 		if input == "/exit" {
 			fmt.Println("Goodbye!")
 			break
@@ -246,6 +523,28 @@ func runREPL(ctx context.Context, c *client.Client, opts client.ChatOptions) err
 			break
 		}
 
+		if strings.HasPrefix(input, "/save ") {
+			path := strings.TrimSpace(strings.TrimPrefix(input, "/save "))
+			if err := opts.Transcript.Save(path); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			} else {
+				fmt.Printf("%sTranscript saved to %s.%s\n\n", colorGray, path, colorReset)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(input, "/load ") {
+			path := strings.TrimSpace(strings.TrimPrefix(input, "/load "))
+			content, err := readPromptFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			} else {
+				loaded = joinPrompt(loaded, content)
+				fmt.Printf("%sLoaded %s, it will be sent with your next message.%s\n\n", colorGray, path, colorReset)
+			}
+			continue
+		}
+
 		if input == "/tools" {
 			if err := printToolsCompact(); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -253,6 +552,13 @@ func runREPL(ctx context.Context, c *client.Client, opts client.ChatOptions) err
 			continue
 		}
 
+		if input == "/model" || strings.HasPrefix(input, "/model ") {
+			if err := runModelCommand(ctx, c, strings.TrimSpace(strings.TrimPrefix(input, "/model"))); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			continue
+		}
+
 		if input == "/tool list" {
 			if err := printRegisteredTools(ctx, c); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -267,16 +573,158 @@ func runREPL(ctx context.Context, c *client.Client, opts client.ChatOptions) err
 			continue
 		}
 
-		if err := c.Chat(ctx, input, os.Stdout, opts); err != nil {
+		input = joinPrompt(input, loaded)
+		loaded = ""
+
+		answering.Store(true)
+		err = c.Chat(ctx, input, os.Stdout, opts)
+		answering.Store(false)
+		if errors.Is(err, client.ErrCanceled) {
+			fmt.Printf("\n%sCanceled.%s\n", colorGray, colorReset)
+		} else if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			if hint := chatErrorHint(err); hint != "" {
+				fmt.Fprintf(os.Stderr, "%s%s%s\n", colorGray, hint, colorReset)
+			}
 		}
 		fmt.Println()
 	}
 
-	if err := scanner.Err(); err != nil {
+	return nil
+}
+
+// planApprover shows a plan's steps in the order they will run and asks whether to run them.
+// Anything but yes, including Ctrl+C and the end of input, rejects the plan.
+func planApprover(reader lineReader, w io.Writer) func(*api.Plan) bool {
+	return func(plan *api.Plan) bool {
+		fmt.Fprintf(w, "\n%sPlan:%s %s\n", colorWhiteBold, colorReset, plan.Intent)
+		for i, step := range plan.Steps {
+			fmt.Fprintf(w, "  %d. %s\n", i+1, formatPlanStep(step))
+		}
+		question := "Run this step? [y/N] "
+		if len(plan.Steps) > 1 {
+			question = fmt.Sprintf("Run these %d steps? [y/N] ", len(plan.Steps))
+		}
+		answer, err := reader.ReadLine(question)
+		if err != nil {
+			fmt.Fprintln(w)
+			return false
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true
+		}
+		return false
+	}
+}
+
+// formatPlanStep describes a plan step: the command of a shell step, the tool and its arguments otherwise
+func formatPlanStep(step *api.PlanStep) string {
+	var args map[string]any
+	_ = json.Unmarshal([]byte(step.Arguments), &args)
+
+	purpose := ""
+	if step.Purpose != "" {
+		purpose = fmt.Sprintf(" %s# %s%s", colorGray, step.Purpose, colorReset)
+	}
+	if command, ok := args["command"].(string); ok && step.Tool == "shell" {
+		return fmt.Sprintf("%s$ %s%s%s", colorLightYellow, command, colorReset, purpose)
+	}
+	return fmt.Sprintf("%s %s%s", step.Tool, step.Arguments, purpose)
+}
+
+// useModelFlag switches an already running daemon to the model given with --model, so the flag
+// isn't silently ignored when the daemon was started with another one. The switch applies to all sessions.
+func useModelFlag(ctx context.Context, cmd *cobra.Command, c *client.Client) error {
+	if !cmd.Root().PersistentFlags().Changed("model") {
+		return nil
+	}
+	current, err := c.Model(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get the daemon's model: %w", err)
+	}
+	if current == model {
+		return nil
+	}
+
+	resp, err := c.SetModel(ctx, model)
+	var unknown *client.UnknownModelError
+	if errors.As(err, &unknown) {
+		return fmt.Errorf("%w; pull it with 'ollama pull %s'", err, model)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to switch the daemon to %s: %w", model, err)
+	}
+	if resp.Previous != resp.Model {
+		fmt.Fprintf(os.Stderr, "%sThe daemon was running %s, switched it to %s for all sessions.%s\n", colorGray, resp.Previous, resp.Model, colorReset)
+	}
+	return nil
+}
+
+// checkDaemonVersion warns when the running daemon is a different build than this client,
+// and fails when it speaks an incompatible protocol. Skipped with --skip-version-check.
+func checkDaemonVersion(ctx context.Context, c *client.Client) error {
+	if skipVersionCheck {
+		return nil
+	}
+	daemonVersion, err := c.Version(ctx)
+	if err != nil {
+		// Not worth failing over, the chat reports a daemon that really can't answer
+		return nil
+	}
+	warning, err := client.CheckVersion(daemonVersion)
+	if err != nil {
+		return fmt.Errorf("%w; restart the daemon with 'craby terminate' (or pass --skip-version-check)", err)
+	}
+	if warning != "" {
+		fmt.Fprintf(os.Stderr, "%sWarning: %s. Restart the daemon with 'craby terminate' to match.%s\n", colorLightYellow, warning, colorReset)
+	}
+	return nil
+}
+
+// chatErrorHint suggests what to do about a failed chat, or returns empty if there's nothing to suggest
+func chatErrorHint(err error) string {
+	switch {
+	case errors.Is(err, client.ErrRateLimited), errors.Is(err, client.ErrServerBusy):
+		return "Wait a moment, then send the message again."
+	case errors.Is(err, client.ErrConnection):
+		return "Is the daemon running? Check with 'craby status'."
+	case errors.Is(err, client.ErrModel):
+		return "Run 'craby doctor' to check Ollama and the model."
+	}
+	return ""
+}
+
+// runModelCommand prints the daemon's chat model, or switches it when a model is given
+func runModelCommand(ctx context.Context, c *client.Client, model string) error {
+	if model == "" {
+		current, err := c.Model(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%sModel: %s%s\n\n", colorGray, current, colorReset)
+		return nil
+	}
+
+	resp, err := c.SetModel(ctx, model)
+	var unknown *client.UnknownModelError
+	if errors.As(err, &unknown) {
+		fmt.Printf("%sModel %s is not pulled. Available models:%s\n", colorGray, model, colorReset)
+		for _, name := range unknown.Available {
+			fmt.Printf("  %s•%s %s\n", colorLightYellow, colorReset, name)
+		}
+		fmt.Printf("%sPull it with: ollama pull %s%s\n\n", colorGray, model, colorReset)
+		return nil
+	}
+	if err != nil {
 		return err
 	}
 
+	if resp.Previous == resp.Model {
+		fmt.Printf("%sAlready using %s.%s\n\n", colorGray, resp.Model, colorReset)
+		return nil
+	}
+	fmt.Printf("%sSwitched model from %s to %s.%s\n\n", colorGray, resp.Previous, resp.Model, colorReset)
 	return nil
 }
 
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marciniwanicki/craby/internal/api"
+	"github.com/marciniwanicki/craby/internal/client"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestChatErrorHint(t *testing.T) {
+	busy := &client.ChatError{Code: api.ErrorCode_ERROR_SERVER_BUSY, Message: "server busy"}
+	if hint := chatErrorHint(busy); !strings.Contains(hint, "Wait a moment") {
+		t.Errorf("expected a back-off hint for a busy daemon, got %q", hint)
+	}
+
+	model := fmt.Errorf("chat: %w", &client.ChatError{Code: api.ErrorCode_ERROR_MODEL, Message: "model failed"})
+	if hint := chatErrorHint(model); !strings.Contains(hint, "craby doctor") {
+		t.Errorf("expected a doctor hint for a model error, got %q", hint)
+	}
+
+	tool := &client.ChatError{Code: api.ErrorCode_ERROR_TOOL, Message: "tool failed"}
+	if hint := chatErrorHint(tool); hint != "" {
+		t.Errorf("expected no hint for a tool error, got %q", hint)
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	if err := waitReady(strings.NewReader("READY 127.0.0.1:8787\n")); err != nil {
+		t.Errorf("expected ready, got %v", err)
+	}
+	// The daemon exited without reporting ready
+	if err := waitReady(strings.NewReader("")); err == nil {
+		t.Error("expected an error when the pipe closes without a READY line")
+	}
+	if err := waitReady(strings.NewReader("garbage\n")); err == nil {
+		t.Error("expected an error for an unexpected notification")
+	}
+}
+
+func TestReadPromptFile(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "prompt.txt")
+	if err := os.WriteFile(path, []byte("Summarize this log\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	content, err := readPromptFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content != "Summarize this log" {
+		t.Errorf("expected trimmed contents, got %q", content)
+	}
+
+	if _, err := readPromptFile(filepath.Join(dir, "missing.txt")); err == nil || !os.IsNotExist(errors.Unwrap(err)) {
+		t.Errorf("expected a not-found error, got %v", err)
+	}
+
+	large := filepath.Join(dir, "large.txt")
+	if err := os.WriteFile(large, make([]byte, maxPromptFileBytes+1), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readPromptFile(large); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("expected a too-large error, got %v", err)
+	}
+
+	empty := filepath.Join(dir, "empty.txt")
+	if err := os.WriteFile(empty, []byte(" \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readPromptFile(empty); err == nil {
+		t.Error("expected an error for an empty file")
+	}
+}
+
+func TestJoinPrompt(t *testing.T) {
+	if got := joinPrompt("", "contents"); got != "contents" {
+		t.Errorf("expected the contents alone, got %q", got)
+	}
+	if got := joinPrompt("Review this:", "contents"); got != "Review this:\n\ncontents" {
+		t.Errorf("expected the message before the contents, got %q", got)
+	}
+}
+
+func TestUseModelFlag(t *testing.T) {
+	defer func(previous string) { model = previous }(model)
+	current, switches := "qwen2.5:14b", 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := &api.ModelResponse{Model: current}
+		if r.Method == http.MethodPost {
+			data, _ := io.ReadAll(r.Body)
+			var req api.ModelRequest
+			_ = proto.Unmarshal(data, &req)
+			resp = &api.ModelResponse{Model: req.Model, Previous: current}
+			current = req.Model
+			switches++
+		}
+		data, _ := proto.Marshal(resp)
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+	c := client.NewClient(strings.TrimPrefix(server.URL, "http://"))
+
+	root := &cobra.Command{Use: "craby"}
+	root.PersistentFlags().StringVar(&model, "model", "qwen2.5:14b", "")
+	chat := &cobra.Command{Use: "chat"}
+	root.AddCommand(chat)
+	ctx := context.Background()
+
+	// The default doesn't override whatever the daemon was started with
+	model = "llama3.2"
+	if err := useModelFlag(ctx, chat, c); err != nil || switches != 0 {
+		t.Fatalf("expected no switch without --model, got %d switches, %v", switches, err)
+	}
+
+	if err := root.PersistentFlags().Set("model", "llama3.2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := useModelFlag(ctx, chat, c); err != nil || switches != 1 || current != "llama3.2" {
+		t.Fatalf("expected the daemon switched to llama3.2, got %q after %d switches, %v", current, switches, err)
+	}
+
+	// Already running the requested model
+	if err := useModelFlag(ctx, chat, c); err != nil || switches != 1 {
+		t.Errorf("expected no second switch, got %d switches, %v", switches, err)
+	}
+}
+
+func TestPlanApprover(t *testing.T) {
+	plan := &api.Plan{Intent: "clean up", Steps: []*api.PlanStep{
+		{Id: "step_1", Tool: "shell", Purpose: "remove the build", Arguments: `{"command":"rm -r build"}`},
+		{Id: "step_2", Tool: "read_file", Arguments: `{"path":"notes.md"}`},
+	}}
+
+	for input, want := range map[string]bool{"y\n": true, " YES \n": true, "n\n": false, "\n": false, "": false} {
+		var out bytes.Buffer
+		approve := planApprover(&scannerReader{scanner: bufio.NewScanner(strings.NewReader(input))}, &out)
+		if got := approve(plan); got != want {
+			t.Errorf("answer %q: expected approved=%v, got %v", input, want, got)
+		}
+		if !strings.Contains(out.String(), "1. \x1b[93m$ rm -r build") || !strings.Contains(out.String(), `2. read_file {"path":"notes.md"}`) {
+			t.Errorf("expected the steps listed in order, got %q", out.String())
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/marciniwanicki/craby/internal/config"
+	"github.com/marciniwanicki/craby/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+// completionTimeout bounds how long a completion waits for Ollama, so a stopped Ollama doesn't stall the shell
+const completionTimeout = 2 * time.Second
+
+func completionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion [bash|zsh|fish|powershell]",
+		Short: "Generate a shell completion script",
+		Long: `Generate a shell completion script for craby.
+
+Bash (requires the bash-completion package):
+
+  # Current session
+  source <(craby completion bash)
+
+  # Every session, Linux
+  craby completion bash > /etc/bash_completion.d/craby
+
+  # Every session, macOS (Homebrew)
+  craby completion bash > $(brew --prefix)/etc/bash_completion.d/craby
+
+Zsh:
+
+  # Enable completion once, if not already done
+  echo "autoload -U compinit; compinit" >> ~/.zshrc
+
+  # Every session
+  craby completion zsh > "${fpath[1]}/_craby"
+
+Fish:
+
+  # Current session
+  craby completion fish | source
+
+  # Every session
+  craby completion fish > ~/.config/fish/completions/craby.fish
+
+PowerShell:
+
+  # Current session
+  craby completion powershell | Out-String | Invoke-Expression
+
+  # Every session: add the line above to your $PROFILE
+
+Start a new shell after installing the script. Model names for --model are
+completed from the models pulled into Ollama.`,
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletionV2(os.Stdout, true)
+			case "zsh":
+				return root.GenZshCompletion(os.Stdout)
+			case "fish":
+				return root.GenFishCompletion(os.Stdout, true)
+			case "powershell":
+				return root.GenPowerShellCompletionWithDesc(os.Stdout)
+			}
+			return fmt.Errorf("unsupported shell %q", args[0])
+		},
+	}
+}
+
+// completeModels completes model names from the models pulled into Ollama
+func completeModels(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+
+	ollama := daemon.NewOllamaClient(ollamaURL, model, nil)
+	if settings, err := config.Load(); err == nil {
+		if headers := settings.Ollama.ResolvedHeaders(); len(headers) > 0 {
+			ollama.SetHeaders(headers)
+		}
+	}
+
+	names, err := ollama.ModelNames(ctx)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var matches []string
+	for _, name := range names {
+		if strings.HasPrefix(name, toComplete) {
+			matches = append(matches, name)
+		}
+	}
+	return matches, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePersonas completes persona names from the templates in ~/.craby/templates
+func completePersonas(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	names, err := config.ListPersonas()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var matches []string
+	for _, name := range names {
+		if strings.HasPrefix(name, toComplete) {
+			matches = append(matches, name)
+		}
+	}
+	return matches, cobra.ShellCompDirectiveNoFileComp
+}
@@ -1,18 +1,80 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/marciniwanicki/craby/internal/config"
 	"github.com/marciniwanicki/craby/internal/daemon"
 	"github.com/spf13/cobra"
 )
 
 func daemonCmd() *cobra.Command {
-	return &cobra.Command{
+	var (
+		keepAlive      string
+		keepWarm       time.Duration
+		fallbackModels []string
+		embedModel     string
+		readyFD        int
+		warmup         bool
+		recheck        bool
+		contextTurns   int
+		genTimeout     time.Duration
+		openAICompat   bool
+		once           bool
+	)
+
+	cmd := &cobra.Command{
 		Use:   "daemon",
 		Short: "Start the daemon server",
 		Long:  "Start the craby daemon server in the foreground. The daemon handles chat requests and communicates with Ollama.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			server := daemon.NewServer(port, ollamaURL, model)
+			if recheck {
+				if err := config.ClearToolStatusCache(); err != nil {
+					return fmt.Errorf("failed to clear tool status cache: %w", err)
+				}
+			}
+			server := daemon.NewServer(daemonAddr(), ollamaURL, model)
+			if cmd.Flags().Changed("keep-alive") {
+				server.SetKeepAlive(keepAlive)
+			}
+			server.SetKeepWarm(keepWarm)
+			server.SetWarmup(warmup)
+			server.SetOpenAICompat(openAICompat)
+			server.SetOnce(once)
+			if cmd.Flags().Changed("fallback-model") {
+				server.SetFallbackModels(fallbackModels)
+			}
+			if cmd.Flags().Changed("embed-model") {
+				server.SetEmbeddingModel(embedModel)
+			}
+			if cmd.Flags().Changed("generation-timeout") {
+				server.SetGenerationTimeout(genTimeout)
+			}
+			if cmd.Flags().Changed("context-turns") {
+				server.SetContextTurns(contextTurns)
+			}
+			if readyFD > 0 {
+				server.SetReadyNotify(os.NewFile(uintptr(readyFD), "ready")) //nolint:gosec // G115: fd numbers are small
+			}
 			return server.Run()
 		},
 	}
+
+	cmd.Flags().StringVar(&keepAlive, "keep-alive", "", `How long Ollama keeps the model loaded, e.g. "30m" or "-1" for always ("0" unloads after each request)`)
+	cmd.Flags().StringSliceVar(&fallbackModels, "fallback-model", nil, "Model to use when the primary is unavailable (repeatable, tried in order)")
+	cmd.Flags().StringVar(&embedModel, "embed-model", "", "Model to use for embeddings, separate from --model (default from settings, "+config.DefaultEmbeddingModel+")")
+	cmd.Flags().IntVar(&contextTurns, "context-turns", -1, "Earlier turns sent with each message (-1 = as many as fit the history limit, 0 = none)")
+	cmd.Flags().DurationVar(&genTimeout, "generation-timeout", 0, "Cut off a model request that takes longer than this, e.g. 10m (default from settings, 30m; 0 = unbounded)")
+	cmd.Flags().IntVar(&readyFD, "ready-fd", 0, "Write \"READY <addr>\" to this file descriptor once accepting connections, e.g. 1 for stdout")
+	cmd.Flags().BoolVar(&recheck, "recheck", false, "Ignore cached external tool availability and re-run every check")
+	cmd.Flags().BoolVar(&openAICompat, "openai-compat", false, "Also serve an OpenAI-compatible API at /v1/chat/completions for existing clients and editor plugins")
+	cmd.Flags().BoolVar(&warmup, "warmup", false, "Load the model before accepting connections so the first chat is fast")
+	cmd.Flags().BoolVar(&once, "once", false, "Serve a single chat, then shut down; for CI and sandboxed one-shot tasks")
+	cmd.Flags().DurationVar(&keepWarm, "keep-warm", 0, "Ping Ollama at this interval to keep the model loaded, e.g. 4m (0 disables)")
+	_ = cmd.RegisterFlagCompletionFunc("fallback-model", completeModels)
+	_ = cmd.RegisterFlagCompletionFunc("embed-model", completeModels)
+
+	return cmd
 }
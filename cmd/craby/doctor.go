@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/marciniwanicki/craby/internal/client"
+	"github.com/marciniwanicki/craby/internal/config"
+	"github.com/marciniwanicki/craby/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+// doctorTimeout bounds each network check
+const doctorTimeout = 5 * time.Second
+
+// checkLevel is the outcome of a doctor check
+type checkLevel int
+
+const (
+	checkPass checkLevel = iota
+	checkWarn            // Works, but something is worth fixing
+	checkFail            // Critical, craby won't work until it's fixed
+)
+
+// checkResult is one line of the doctor checklist
+type checkResult struct {
+	name   string
+	level  checkLevel
+	detail string
+	fix    string
+}
+
+func doctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that craby is set up correctly",
+		Long: `Check the configuration, Ollama, the model, the daemon, the shell, external tools
+and the logs directory, and suggest a fix for each problem.
+
+Exits with a non-zero status if a critical check fails.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// A failed check is reported by the checklist, not the usage text
+			cmd.SilenceUsage = true
+
+			settings, result := checkSettings()
+			results := []checkResult{result}
+			results = append(results, checkOllama(settings)...)
+			results = append(results, checkDaemon())
+			results = append(results, checkShell(settings))
+			results = append(results, checkTools(settings)...)
+			results = append(results, checkLogsDir())
+
+			failed := 0
+			for _, r := range results {
+				printCheck(r)
+				if r.level == checkFail {
+					failed++
+				}
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d critical check(s) failed", failed)
+			}
+			return nil
+		},
+	}
+}
+
+// printCheck prints a checklist line and, for problems, how to fix it
+func printCheck(r checkResult) {
+	icon := "\033[32m✓"
+	switch r.level {
+	case checkWarn:
+		icon = colorLightYellow + "!"
+	case checkFail:
+		icon = colorRed + "✗"
+	}
+
+	fmt.Printf("%s%s %s%s%s", icon, colorReset, colorWhite, r.name, colorReset)
+	if r.detail != "" {
+		fmt.Printf(" %s%s%s", colorGray, r.detail, colorReset)
+	}
+	fmt.Println()
+	if r.level != checkPass && r.fix != "" {
+		fmt.Printf("  %s→ %s%s\n", colorGray, r.fix, colorReset)
+	}
+}
+
+// checkSettings loads settings.json, falling back to the defaults for the remaining checks if it's broken
+func checkSettings() (*config.Settings, checkResult) {
+	path, err := config.SettingsPath()
+	if err != nil {
+		return config.DefaultSettings(), checkResult{name: "Settings", level: checkFail, detail: err.Error()}
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return config.DefaultSettings(), checkResult{name: "Settings", level: checkPass, detail: "using defaults, " + path + " will be created on first run"}
+	}
+
+	settings, err := config.Load()
+	if err != nil {
+		return config.DefaultSettings(), checkResult{
+			name:   "Settings",
+			level:  checkFail,
+			detail: fmt.Sprintf("%s: %v", path, err),
+			fix:    "Fix the JSON in " + path + ", or delete it to recreate the defaults",
+		}
+	}
+	return settings, checkResult{name: "Settings", level: checkPass, detail: path}
+}
+
+// checkOllama checks that Ollama answers and has the configured models pulled
+func checkOllama(settings *config.Settings) []checkResult {
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+
+	ollama := daemon.NewOllamaClient(ollamaURL, model, nil)
+	ollama.SetFallbackModels(settings.Ollama.FallbackModels)
+	ollama.SetEmbeddingModel(settings.Ollama.EmbeddingModel)
+	if headers := settings.Ollama.ResolvedHeaders(); len(headers) > 0 {
+		ollama.SetHeaders(headers)
+	}
+
+	availability, err := ollama.ModelAvailability(ctx)
+	if err != nil {
+		return []checkResult{{
+			name:   "Ollama",
+			level:  checkFail,
+			detail: fmt.Sprintf("not reachable at %s: %v", ollama.BaseURL(), err),
+			fix:    "Start Ollama with 'ollama serve', or point --ollama-url at a running instance",
+		}}
+	}
+
+	results := []checkResult{{name: "Ollama", level: checkPass, detail: ollama.BaseURL()}}
+	for i, m := range ollama.Models() {
+		r := checkResult{name: "Model " + m, level: checkPass, detail: "pulled"}
+		if i > 0 {
+			r.name = "Fallback model " + m
+		}
+		if !availability[m] {
+			r.detail = "not pulled"
+			r.fix = "Run: ollama pull " + m
+			// Only the primary model is required, a missing fallback is skipped
+			r.level = checkFail
+			if i > 0 {
+				r.level = checkWarn
+			}
+		}
+		results = append(results, r)
+	}
+
+	// Only craby embed needs the embedding model, chats work without it
+	if embed := ollama.EmbeddingModel(); embed != model {
+		r := checkResult{name: "Embedding model " + embed, level: checkPass, detail: "pulled"}
+		if !availability[embed] {
+			r.level = checkWarn
+			r.detail = "not pulled, needed for craby embed"
+			r.fix = "Run: ollama pull " + embed
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// checkDaemon checks whether the daemon is running and reports itself healthy
+func checkDaemon() checkResult {
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+
+	addr := daemonAddr()
+	c := newClient()
+	if err := c.Probe(ctx); errors.Is(err, client.ErrUnresponsive) {
+		return checkResult{
+			name:   "Daemon",
+			level:  checkFail,
+			detail: fmt.Sprintf("listening on %s but %v", addr, err),
+			fix:    "Stop the daemon's process and start it again; 'craby logs' may show where it got stuck",
+		}
+	} else if err != nil {
+		return checkResult{
+			name:   "Daemon",
+			level:  checkWarn,
+			detail: "not running on " + addr,
+			fix:    "Chat commands start it automatically, or run 'craby daemon' to start it in the foreground",
+		}
+	}
+
+	status, err := c.Status(ctx)
+	if err != nil {
+		return checkResult{
+			name:   "Daemon",
+			level:  checkFail,
+			detail: fmt.Sprintf("running on %s but not answering: %v", addr, err),
+			fix:    "Restart it with 'craby terminate' and check 'craby logs'",
+		}
+	}
+	if !status.Healthy {
+		return checkResult{
+			name:   "Daemon",
+			level:  checkFail,
+			detail: fmt.Sprintf("running on %s but can't use model %s at %s", addr, status.Model, status.OllamaUrl),
+			fix:    "Check that the daemon was started with the right --model and --ollama-url, see 'craby status'",
+		}
+	}
+	return checkResult{name: "Daemon", level: checkPass, detail: fmt.Sprintf("running on %s, version %s", addr, status.Version)}
+}
+
+// checkTools checks each external tool's availability. Unavailable tools are warnings, the rest of craby works without them.
+func checkTools(settings *config.Settings) []checkResult {
+	tools, statuses, err := config.LoadAndCheckTools(settings.Tools.External.ToolStatusCache())
+	if err != nil {
+		return []checkResult{{name: "External tools", level: checkWarn, detail: err.Error()}}
+	}
+	if len(tools) == 0 {
+		return []checkResult{{name: "External tools", level: checkPass, detail: "none configured"}}
+	}
+
+	var results []checkResult
+	for _, tool := range tools {
+		r := checkResult{name: "Tool " + tool.Name, level: checkPass, detail: "available"}
+		if status := statuses[tool.Name]; !status.Available {
+			r.level = checkWarn
+			r.detail = status.Message
+			r.fix = "Fix the tool's check in " + tool.Name + ".yaml or install what it needs, then run 'craby tools --recheck'"
+			if status.TimedOut() {
+				r.fix = "Raise check.timeout_seconds in " + tool.Name + ".yaml if the check is just slow, then run 'craby tools --recheck'"
+			}
+			if status.Failure == config.CheckFailedEnv {
+				r.fix = "Export the variable before starting the daemon, or change env.set in " + tool.Name + ".yaml"
+			}
+			if status.Kept {
+				r.detail += " (kept unverified)"
+			}
+		} else if err := tool.Validate(); err != nil {
+			r.level = checkWarn
+			r.detail = err.Error()
+			r.fix = "Fix " + tool.Name + ".yaml; invalid discovery limits fall back to the defaults"
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// checkShell checks that the shell commands run through is installed
+func checkShell(settings *config.Settings) checkResult {
+	path, err := settings.Tools.Shell.ResolveShell()
+	if err == nil {
+		return checkResult{name: "Shell", level: checkPass, detail: path}
+	}
+
+	// Without a shell only discovery breaks when the shell tool is disabled
+	level := checkWarn
+	if settings.Tools.Shell.Enabled {
+		level = checkFail
+	}
+	return checkResult{
+		name:   "Shell",
+		level:  level,
+		detail: fmt.Sprintf("%q not found", settings.Tools.Shell.ShellBinary()),
+		fix:    "Install it, or set tools.shell.binary in settings.json to an installed shell, e.g. bash or a full path",
+	}
+}
+
+// checkLogsDir checks that the daemon can write its logs
+func checkLogsDir() checkResult {
+	dir, err := config.LogsDir()
+	if err == nil {
+		err = os.MkdirAll(dir, 0750)
+	}
+	var f *os.File
+	if err == nil {
+		f, err = os.CreateTemp(dir, ".doctor-*")
+	}
+	if err != nil {
+		return checkResult{
+			name:   "Logs directory",
+			level:  checkFail,
+			detail: err.Error(),
+			fix:    "Make sure " + dir + " exists and is writable by your user",
+		}
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+	return checkResult{name: "Logs directory", level: checkPass, detail: dir}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func embedCmd() *cobra.Command {
+	var embedModel string
+
+	cmd := &cobra.Command{
+		Use:   "embed <text>",
+		Short: "Compute an embedding vector",
+		Long:  "Compute an embedding vector for the given text using the daemon's embedding model and print it as JSON.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := newClient()
+			ctx := context.Background()
+
+			// Start daemon if not running
+			if err := ensureDaemonRunning(ctx, c); err != nil {
+				return err
+			}
+
+			resp, err := c.Embed(ctx, strings.Join(args, " "), embedModel)
+			if err != nil {
+				return fmt.Errorf("failed to compute embedding: %w", err)
+			}
+
+			data, err := json.Marshal(resp.Embedding)
+			if err != nil {
+				return fmt.Errorf("failed to encode embedding: %w", err)
+			}
+
+			fmt.Println(string(data))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&embedModel, "embedding-model", "", "Override the daemon's embedding model")
+
+	return cmd
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/chzyer/readline"
+	"github.com/marciniwanicki/craby/internal/config"
+	"golang.org/x/term"
+)
+
+// replHistoryLimit is how many entries the REPL input history keeps
+const replHistoryLimit = 1000
+
+// errInputInterrupted is returned by a lineReader when Ctrl+C is pressed at the prompt
+var errInputInterrupted = errors.New("input interrupted")
+
+// lineReader reads REPL input one line at a time, returning io.EOF at the end of input
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+	Close() error
+}
+
+// newLineReader returns a line editor with persistent history when the REPL runs in a terminal,
+// and a plain line scanner for piped input and scripted output
+func newLineReader(scripted bool) lineReader {
+	interactive := term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())) //nolint:gosec // G115: file descriptors fit in int
+	if scripted || !interactive {
+		return &scannerReader{scanner: bufio.NewScanner(os.Stdin)}
+	}
+
+	reader, err := newReadlineReader()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sLine editing unavailable: %v%s\n", colorGray, err, colorReset)
+		return &scannerReader{scanner: bufio.NewScanner(os.Stdin)}
+	}
+	return reader
+}
+
+// scannerReader reads plain lines, printing the prompt itself
+type scannerReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *scannerReader) ReadLine(prompt string) (string, error) {
+	fmt.Print(prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+func (r *scannerReader) Close() error {
+	return nil
+}
+
+// readlineReader reads lines with in-line editing, up/down history and Ctrl+R search,
+// saving the history to ~/.craby/repl_history
+type readlineReader struct {
+	rl *readline.Instance
+}
+
+func newReadlineReader() (*readlineReader, error) {
+	historyPath, err := config.REPLHistoryPath()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the history private to the user before readline creates it world-readable
+	if err := os.MkdirAll(filepath.Dir(historyPath), 0750); err != nil {
+		return nil, err
+	}
+	//nolint:gosec // History file in user's config directory
+	f, err := os.OpenFile(historyPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	_ = f.Close()
+
+	rl, err := readline.NewEx(&readline.Config{
+		HistoryFile:       historyPath,
+		HistoryLimit:      replHistoryLimit,
+		HistorySearchFold: true,
+		InterruptPrompt:   "^C",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &readlineReader{rl: rl}, nil
+}
+
+func (r *readlineReader) ReadLine(prompt string) (string, error) {
+	r.rl.SetPrompt(prompt)
+	line, err := r.rl.Readline()
+	if errors.Is(err, readline.ErrInterrupt) {
+		return line, errInputInterrupted
+	}
+	return line, err
+}
+
+func (r *readlineReader) Close() error {
+	return r.rl.Close()
+}
+
+// multilineFence opens and closes a multi-line message in the REPL
+const multilineFence = `"""`
+
+// multilineBuffer accumulates REPL lines until a message is complete. A line ending
+// in a backslash continues on the next line, and a """ fence collects every line up
+// to the closing """ verbatim.
+type multilineBuffer struct {
+	mu     sync.Mutex
+	lines  []string
+	fenced bool
+}
+
+// Add adds an input line and returns the message once it's complete
+func (b *multilineBuffer) Add(line string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.fenced {
+		if before, ok := strings.CutSuffix(strings.TrimRight(line, " \t"), multilineFence); ok {
+			if before != "" {
+				b.lines = append(b.lines, before)
+			}
+			return b.take(), true
+		}
+		b.lines = append(b.lines, line)
+		return "", false
+	}
+
+	if len(b.lines) == 0 {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), multilineFence); ok {
+			// """on one line""" is complete as is
+			if inner, ok := strings.CutSuffix(rest, multilineFence); ok {
+				return inner, true
+			}
+			b.fenced = true
+			if rest != "" {
+				b.lines = append(b.lines, rest)
+			}
+			return "", false
+		}
+	}
+
+	if before, ok := strings.CutSuffix(strings.TrimRight(line, " \t"), `\`); ok {
+		b.lines = append(b.lines, before)
+		return "", false
+	}
+
+	b.lines = append(b.lines, line)
+	return b.take(), true
+}
+
+// Pending reports whether a multi-line message is being entered
+func (b *multilineBuffer) Pending() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.fenced || len(b.lines) > 0
+}
+
+// Cancel discards a message being entered, reporting whether there was one
+func (b *multilineBuffer) Cancel() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	pending := b.fenced || len(b.lines) > 0
+	b.lines, b.fenced = nil, false
+	return pending
+}
+
+// take returns the accumulated message and resets the buffer. The caller holds mu.
+func (b *multilineBuffer) take() string {
+	message := strings.Join(b.lines, "\n")
+	b.lines, b.fenced = nil, false
+	return message
+}
@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestMultilineBuffer(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"single line", []string{"hello"}, "hello"},
+		{"backslash continuation", []string{`first \`, `second\`, "third"}, "first \nsecond\nthird"},
+		{"fence", []string{`"""`, "  indented", "", `"""`}, "  indented\n"},
+		{"fence with text on fence lines", []string{`"""start`, "middle", `end"""`}, "start\nmiddle\nend"},
+		{"fence on one line", []string{`"""inline"""`}, "inline"},
+		{"backslash inside fence is kept", []string{`"""`, `a \`, `"""`}, `a \`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b multilineBuffer
+			for i, line := range tt.lines {
+				got, complete := b.Add(line)
+				last := i == len(tt.lines)-1
+				if complete != last {
+					t.Fatalf("line %d: expected complete=%v", i, last)
+				}
+				if last && got != tt.want {
+					t.Errorf("expected %q, got %q", tt.want, got)
+				}
+			}
+			if b.Pending() {
+				t.Error("expected buffer to be empty after a complete message")
+			}
+		})
+	}
+}
+
+func TestMultilineBuffer_Cancel(t *testing.T) {
+	var b multilineBuffer
+	if b.Cancel() {
+		t.Error("expected nothing to cancel")
+	}
+
+	b.Add(`"""`)
+	b.Add("draft")
+	if !b.Cancel() {
+		t.Error("expected pending message to be cancelled")
+	}
+	if got, complete := b.Add("next"); !complete || got != "next" {
+		t.Errorf("expected fresh message after cancel, got %q (complete=%v)", got, complete)
+	}
+}
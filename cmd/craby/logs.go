@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/marciniwanicki/craby/internal/config"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// logFollowInterval is how often logs -f checks the log for new lines
+const logFollowInterval = 250 * time.Millisecond
+
+func logsCmd() *cobra.Command {
+	var (
+		follow    bool
+		lines     int
+		level     string
+		showPath  bool
+		jsonLines bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Show the daemon log",
+		Long: `Show the last lines of the daemon log, ~/.craby/logs/craby.log.
+
+Rotated backups, including compressed ones, are read when the current log has
+fewer lines than requested.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logPath, err := config.LogPath()
+			if err != nil {
+				return fmt.Errorf("failed to get log path: %w", err)
+			}
+			if showPath {
+				fmt.Println(logPath)
+				return nil
+			}
+			if lines < 0 {
+				return fmt.Errorf("invalid --lines %d: must not be negative", lines)
+			}
+
+			match, err := logLevelFilter(level)
+			if err != nil {
+				return err
+			}
+			printLine := logPrinter(os.Stdout, jsonLines)
+
+			files, err := config.LogFiles()
+			if err != nil {
+				return fmt.Errorf("failed to list log files: %w", err)
+			}
+			if len(files) == 0 && !follow {
+				return fmt.Errorf("no log at %s, has the daemon been started?", logPath)
+			}
+
+			tail, err := config.TailLog(files, lines, match)
+			if err != nil {
+				return err
+			}
+			for _, line := range tail {
+				printLine(line)
+			}
+
+			if !follow {
+				return nil
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			return followLog(ctx, logPath, match, printLine)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Keep printing lines as they are written")
+	cmd.Flags().IntVarP(&lines, "lines", "n", 50, "Number of lines to show")
+	cmd.Flags().StringVar(&level, "level", "", "Only show entries at or above this level (trace, debug, info, warn, error)")
+	cmd.Flags().BoolVar(&showPath, "path", false, "Print the log path and exit")
+	cmd.Flags().BoolVar(&jsonLines, "json", false, "Print the raw JSON lines")
+
+	return cmd
+}
+
+// logLevelFilter returns a filter keeping lines at or above level, or nil when level is empty
+func logLevelFilter(level string) (func(line []byte) bool, error) {
+	if level == "" {
+		return nil, nil
+	}
+	minLevel, err := zerolog.ParseLevel(strings.ToLower(level))
+	if err != nil || minLevel == zerolog.NoLevel {
+		return nil, fmt.Errorf("invalid --level %q: use trace, debug, info, warn, error, fatal or panic", level)
+	}
+	return func(line []byte) bool {
+		lineLevel, ok := config.LogLineLevel(line)
+		return ok && lineLevel >= minLevel
+	}, nil
+}
+
+// logPrinter returns a function printing a log line, formatted like the daemon's console output
+// unless raw JSON is requested
+func logPrinter(out *os.File, jsonLines bool) func(line string) {
+	if jsonLines {
+		return func(line string) { fmt.Fprintln(out, line) }
+	}
+
+	noColor := os.Getenv("NO_COLOR") != "" || !term.IsTerminal(int(out.Fd())) //nolint:gosec // G115: file descriptors fit in int
+	console := zerolog.ConsoleWriter{Out: out, TimeFormat: "15:04:05", NoColor: noColor}
+	return func(line string) {
+		if _, err := console.Write([]byte(line)); err != nil {
+			// Not a JSON entry, print as is
+			fmt.Fprintln(out, line)
+		}
+	}
+}
+
+// followLog prints lines appended to the log until ctx is done. A log that is
+// rotated or recreated by a daemon restart is reopened from the start.
+func followLog(ctx context.Context, path string, match func(line []byte) bool, printLine func(line string)) error {
+	var (
+		f       *os.File
+		reader  *bufio.Reader
+		partial string
+	)
+	defer func() {
+		if f != nil {
+			_ = f.Close()
+		}
+	}()
+
+	open := func(fromEnd bool) error {
+		//nolint:gosec // Log file in user's config directory
+		next, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		if fromEnd {
+			if _, err := next.Seek(0, io.SeekEnd); err != nil {
+				_ = next.Close()
+				return err
+			}
+		}
+		if f != nil {
+			_ = f.Close()
+		}
+		f, reader, partial = next, bufio.NewReader(next), ""
+		return nil
+	}
+
+	// Lines already in the log were printed by the tail
+	if err := open(true); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	ticker := time.NewTicker(logFollowInterval)
+	defer ticker.Stop()
+
+	for {
+		if f != nil {
+			for {
+				chunk, err := reader.ReadString('\n')
+				partial += chunk
+				if err != nil {
+					break // Incomplete line, wait for the rest
+				}
+				line := strings.TrimRight(partial, "\r\n")
+				partial = ""
+				if match == nil || match([]byte(line)) {
+					printLine(line)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if logReplaced(f, path) {
+			if err := open(false); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+}
+
+// logReplaced reports whether path no longer refers to the open file f or was truncated
+func logReplaced(f *os.File, path string) bool {
+	current, err := os.Stat(path)
+	if err != nil {
+		return false // Mid-rotation, keep reading the old file
+	}
+	if f == nil {
+		return true
+	}
+	opened, err := f.Stat()
+	if err != nil || !os.SameFile(opened, current) {
+		return true
+	}
+	offset, err := f.Seek(0, io.SeekCurrent)
+	return err == nil && current.Size() < offset
+}
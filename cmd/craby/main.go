@@ -2,20 +2,50 @@ package main
 
 import (
 	"context"
+	"net"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/marciniwanicki/craby/internal/client"
+	"github.com/marciniwanicki/craby/internal/version"
 	"github.com/spf13/cobra"
 )
 
 var (
 	// Global flags
-	port      int
-	ollamaURL string
-	model     string
+	listen      string
+	port        int
+	ollamaURL   string
+	model       string
+	noAutostart bool
+	raw         bool
+	transcript  string
+
+	healthTimeout    time.Duration
+	skipVersionCheck bool
 )
 
+// daemonAddr returns the daemon address from --listen, with the port replaced by --port if set
+func daemonAddr() string {
+	if port == 0 {
+		return listen
+	}
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// newClient creates a client for the daemon at daemonAddr, applying --health-timeout
+func newClient() *client.Client {
+	c := client.NewClient(daemonAddr())
+	c.SetTimeouts(healthTimeout, 0)
+	return c
+}
+
 func main() {
 	// Create chat command first so we can reference it
 	chat := chatCmd()
@@ -29,10 +59,11 @@ If a message is provided, it will be sent as a one-shot query.
 Example: craby "What is the weather today?"
 
 Without arguments, starts interactive chat.`,
+		Version: version.Get().String(),
 		// Allow arbitrary args so we can treat them as chat messages
 		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			c := client.NewClient(port)
+			c := newClient()
 			ctx := context.Background()
 
 			// Start daemon if not running
@@ -42,8 +73,14 @@ Without arguments, starts interactive chat.`,
 
 			// If args provided, send as one-shot message
 			if len(args) > 0 {
+				if err := useModelFlag(ctx, cmd, c); err != nil {
+					return err
+				}
 				message := strings.Join(args, " ")
-				return c.Chat(ctx, message, os.Stdout, client.ChatOptions{})
+				opts := client.ChatOptions{Raw: raw, Transcript: client.NewTranscript(c.SessionID())}
+				err := c.Chat(ctx, message, os.Stdout, opts)
+				saveTranscript(opts.Transcript)
+				return err
 			}
 
 			// No args, start interactive chat
@@ -52,9 +89,21 @@ Without arguments, starts interactive chat.`,
 	}
 
 	// Global flags
-	rootCmd.PersistentFlags().IntVar(&port, "port", 8787, "Daemon listen port")
+	rootCmd.PersistentFlags().StringVar(&listen, "listen", "127.0.0.1:8787", "Daemon listen address (host:port, or unix:/path/to/socket)")
+	rootCmd.PersistentFlags().IntVar(&port, "port", 0, "Daemon listen port, overriding the port in --listen")
 	rootCmd.PersistentFlags().StringVar(&ollamaURL, "ollama-url", "http://localhost:11434", "Ollama API endpoint")
-	rootCmd.PersistentFlags().StringVar(&model, "model", "qwen2.5:14b", "Model to use for chat")
+	rootCmd.PersistentFlags().StringVar(&model, "model", "qwen2.5:14b", "Model to use for chat, switching an already running daemon to it when given")
+	rootCmd.PersistentFlags().BoolVar(&raw, "raw", false, "Print answers as plain text instead of rendering markdown")
+	rootCmd.PersistentFlags().StringVar(&transcript, "transcript", "", "Save the conversation to this file when the chat ends (.json for JSON, markdown otherwise)")
+	rootCmd.PersistentFlags().DurationVar(&healthTimeout, "health-timeout", client.DefaultHealthTimeout, "How long to wait for the daemon to answer a health check before treating it as unresponsive")
+	rootCmd.PersistentFlags().BoolVar(&noAutostart, "no-autostart", false, "Don't start the daemon automatically if it isn't running")
+	rootCmd.PersistentFlags().BoolVar(&skipVersionCheck, "skip-version-check", false, "Don't compare the running daemon's version with this client's")
+
+	rootCmd.SetVersionTemplate("craby {{.Version}}\n")
+	_ = rootCmd.RegisterFlagCompletionFunc("model", completeModels)
+
+	// Replace cobra's default completion command with one documenting installation
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
 
 	// Add subcommands
 	rootCmd.AddCommand(daemonCmd())
@@ -62,6 +111,11 @@ Without arguments, starts interactive chat.`,
 	rootCmd.AddCommand(statusCmd())
 	rootCmd.AddCommand(terminateCmd())
 	rootCmd.AddCommand(toolsCmd())
+	rootCmd.AddCommand(embedCmd())
+	rootCmd.AddCommand(logsCmd())
+	rootCmd.AddCommand(doctorCmd())
+	rootCmd.AddCommand(completionCmd())
+	rootCmd.AddCommand(versionCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
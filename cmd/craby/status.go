@@ -2,22 +2,31 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/marciniwanicki/craby/internal/api"
 	"github.com/marciniwanicki/craby/internal/client"
+	"github.com/marciniwanicki/craby/internal/version"
 	"github.com/spf13/cobra"
 )
 
 func statusCmd() *cobra.Command {
-	return &cobra.Command{
+	var showModels bool
+
+	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Check if daemon is running",
 		Long:  "Check the status of the craby daemon and display information about the connected model.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			c := client.NewClient(port)
+			c := newClient()
 			ctx := context.Background()
 
-			if !c.IsRunning(ctx) {
+			if err := c.Probe(ctx); err != nil {
+				if errors.Is(err, client.ErrUnresponsive) {
+					return err
+				}
 				fmt.Println("Daemon is not running")
 				return nil
 			}
@@ -27,16 +36,114 @@ func statusCmd() *cobra.Command {
 				return fmt.Errorf("failed to get status: %w", err)
 			}
 
+			uptime := time.Duration(status.UptimeSeconds) * time.Second
+
 			fmt.Printf("Daemon: running\n")
-			fmt.Printf("Version: %s\n", status.Version)
-			fmt.Printf("Model: %s\n", status.Model)
-			if status.Healthy {
-				fmt.Printf("Ollama: healthy\n")
+			fmt.Printf("Version: %s\n", version.Format(status.Version, status.Commit, status.BuildDate))
+			if status.Version != version.Version || status.Commit != version.Commit {
+				fmt.Printf("  CLI version: %s (restart the daemon to match)\n", version.String())
+			}
+			fmt.Printf("Uptime: %s\n", uptime)
+			fmt.Printf("Connections: %d active\n", status.ActiveConnections)
+			fmt.Printf("Chats served: %d\n", status.ChatsServed)
+			if status.ChatsQueued > 0 {
+				fmt.Printf("Chats queued: %d\n", status.ChatsQueued)
+			}
+			fmt.Printf("Commands running: %d\n", status.CommandsInFlight)
+			if status.ToolCallCapacity > 0 {
+				fmt.Printf("Tool calls running: %d of %d\n", status.ToolCallsInFlight, status.ToolCallCapacity)
 			} else {
-				fmt.Printf("Ollama: not responding\n")
+				fmt.Printf("Tool calls running: %d\n", status.ToolCallsInFlight)
+			}
+			if status.ToolCallsWaiting > 0 {
+				fmt.Printf("Tool calls waiting: %d\n", status.ToolCallsWaiting)
+			}
+			if len(status.ToolStats) > 0 {
+				var calls, errs int64
+				for _, stat := range status.ToolStats {
+					calls += stat.Calls
+					errs += stat.Errors
+				}
+				fmt.Printf("Tool calls made: %d, %d failed (craby tools --stats for details)\n", calls, errs)
+			}
+			fmt.Printf("Model: %s\n", status.Model)
+			for _, m := range status.Models {
+				availability := "available"
+				if !m.Available {
+					availability = "not installed"
+				}
+				fmt.Printf("  %s: %s\n", m.Name, availability)
+			}
+			if embed := status.EmbeddingModel; embed != nil && embed.Name != status.Model {
+				availability := "available"
+				if !embed.Available {
+					availability = "not installed, needed for craby embed"
+				}
+				fmt.Printf("Embedding model: %s (%s)\n", embed.Name, availability)
+			}
+			switch {
+			case status.Healthy:
+				fmt.Printf("Ollama: healthy (%s)\n", status.OllamaUrl)
+			case status.OllamaReachable && !status.ModelPresent:
+				fmt.Printf("Ollama: reachable, model not pulled (%s)\n", status.OllamaUrl)
+				fmt.Printf("  Run: ollama pull %s\n", status.Model)
+			default:
+				fmt.Printf("Ollama: not responding (%s)\n", status.OllamaUrl)
+			}
+
+			if showModels {
+				running, err := c.RunningModels(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to list loaded models: %w", err)
+				}
+				printRunningModels(running.Models, time.Now())
 			}
 
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&showModels, "models", false, "Show models currently loaded in Ollama with their memory usage")
+
+	return cmd
+}
+
+// printRunningModels prints loaded models with their size, GPU share and unload time
+func printRunningModels(models []*api.RunningModel, now time.Time) {
+	if len(models) == 0 {
+		fmt.Println("Loaded models: none")
+		return
+	}
+
+	fmt.Println("Loaded models:")
+	for _, m := range models {
+		fmt.Printf("  %s: %s (%s in VRAM), %s\n", m.Name, formatBytes(m.SizeBytes), formatBytes(m.VramBytes), formatExpiry(m.ExpiresAtUnix, now))
+	}
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. "9.3 GiB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatExpiry describes when a model will be unloaded
+func formatExpiry(expiresAtUnix int64, now time.Time) string {
+	remaining := time.Unix(expiresAtUnix, 0).Sub(now)
+	switch {
+	case remaining <= 0:
+		return "unloading"
+	case remaining > 365*24*time.Hour:
+		return "kept loaded"
+	default:
+		return "unloads in " + remaining.Round(time.Second).String()
+	}
 }
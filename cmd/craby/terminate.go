@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/marciniwanicki/craby/internal/client"
@@ -14,10 +15,13 @@ func terminateCmd() *cobra.Command {
 		Short: "Stop the daemon",
 		Long:  "Stop the running crabby daemon gracefully.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			c := client.NewClient(port)
+			c := newClient()
 			ctx := context.Background()
 
-			if !c.IsRunning(ctx) {
+			if err := c.Probe(ctx); err != nil {
+				if errors.Is(err, client.ErrUnresponsive) {
+					return fmt.Errorf("%w, stop its process instead", err)
+				}
 				fmt.Println("Daemon is not running")
 				return nil
 			}
@@ -1,25 +1,235 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/marciniwanicki/craby/internal/api"
+	"github.com/marciniwanicki/craby/internal/client"
 	"github.com/marciniwanicki/craby/internal/config"
 	"github.com/spf13/cobra"
 )
 
 func toolsCmd() *cobra.Command {
-	return &cobra.Command{
+	var (
+		recheck  bool
+		jsonMode bool
+		stats    bool
+	)
+
+	cmd := &cobra.Command{
 		Use:   "tools",
 		Short: "List loaded external tools",
 		Long:  "Display all external tools loaded from ~/.craby/tools/ with their status and descriptions.",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if stats {
+				return showToolStats(jsonMode)
+			}
+			if recheck {
+				if err := config.ClearToolStatusCache(); err != nil {
+					return fmt.Errorf("failed to clear tool status cache: %w", err)
+				}
+			}
+			if jsonMode {
+				return printToolsJSON()
+			}
 			return printTools()
 		},
 	}
+
+	cmd.Flags().BoolVar(&recheck, "recheck", false, "Ignore cached availability and re-run every tool's check")
+	cmd.Flags().BoolVar(&jsonMode, "json", false, "Print each tool and its check status as JSON")
+	cmd.Flags().BoolVar(&stats, "stats", false, "Show how often each tool was called by the running daemon, and how it performed")
+	cmd.AddCommand(toolsPromptCmd())
+
+	return cmd
 }
 
+func toolsPromptCmd() *cobra.Command {
+	var jsonMode bool
+
+	cmd := &cobra.Command{
+		Use:   "prompt",
+		Short: "Show the external tools prompt the daemon injects",
+		Long:  "Print the external tools section the running daemon adds to the system prompt, exactly as the model sees it, and the commands whose schemas it has discovered and cached.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := newClient()
+			ctx := context.Background()
+			if err := c.Probe(ctx); err != nil {
+				if errors.Is(err, client.ErrUnresponsive) {
+					return err
+				}
+				return errors.New("daemon is not running, the prompt is built by the daemon")
+			}
+
+			prompt, err := c.ToolPrompt(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get tools prompt: %w", err)
+			}
+			if jsonMode {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(prompt)
+			}
+			printToolPrompt(os.Stdout, prompt, time.Now())
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonMode, "json", false, "Print the prompt and cached schemas as JSON")
+
+	return cmd
+}
+
+// printToolPrompt prints the external tools prompt followed by a table of cached schemas
+func printToolPrompt(w io.Writer, prompt *api.ToolPromptResponse, now time.Time) {
+	if prompt.Prompt == "" {
+		fmt.Fprintf(w, "%sNo external tools prompt: the shell tool is disabled or no external tools are loaded%s\n", colorGray, colorReset)
+	} else {
+		fmt.Fprintln(w, strings.Trim(prompt.Prompt, "\n"))
+	}
+
+	fmt.Fprintln(w)
+	if len(prompt.CachedSchemas) == 0 {
+		fmt.Fprintf(w, "%sNo cached command schemas%s\n", colorGray, colorReset)
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CACHED SCHEMA\tDISCOVERED\tCONTENTS")
+	for _, schema := range prompt.CachedSchemas {
+		discovered := formatAge(now.Sub(time.Unix(schema.GeneratedAtUnix, 0))) + " ago"
+		if schema.Expired {
+			discovered += " (expired)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", schema.Command, discovered, schema.Summary)
+	}
+	_ = tw.Flush()
+}
+
+// formatAge rounds an age down to whole days, hours or minutes, e.g. "3d"
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+}
+
+// showToolStats prints the running daemon's per-tool call statistics
+func showToolStats(jsonMode bool) error {
+	c := newClient()
+	ctx := context.Background()
+	if err := c.Probe(ctx); err != nil {
+		if errors.Is(err, client.ErrUnresponsive) {
+			return err
+		}
+		return errors.New("daemon is not running, tool statistics are kept by the daemon")
+	}
+
+	status, err := c.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get status: %w", err)
+	}
+	if jsonMode {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(status.ToolStats)
+	}
+	printToolStats(os.Stdout, status.ToolStats, time.Duration(status.UptimeSeconds)*time.Second)
+	return nil
+}
+
+// printToolStats prints a table of tool statistics, most called first
+func printToolStats(w io.Writer, stats []*api.ToolStats, uptime time.Duration) {
+	stats = slices.Clone(stats)
+	slices.SortStableFunc(stats, func(a, b *api.ToolStats) int {
+		return cmp.Compare(b.Calls, a.Calls)
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOOL\tCALLS\tERRORS\tAVG\tMAX")
+	unused := 0
+	for _, stat := range stats {
+		if stat.Calls == 0 {
+			unused++
+			fmt.Fprintf(tw, "%s\t0\t-\t-\t-\n", stat.Name)
+			continue
+		}
+		avg := time.Duration(stat.TotalDurationMs/stat.Calls) * time.Millisecond
+		longest := time.Duration(stat.MaxDurationMs) * time.Millisecond
+		fmt.Fprintf(tw, "%s\t%d\t%d (%.0f%%)\t%s\t%s\n",
+			stat.Name, stat.Calls, stat.Errors, 100*float64(stat.Errors)/float64(stat.Calls), avg, longest)
+	}
+	_ = tw.Flush()
+
+	fmt.Fprintf(w, "\n%s%d/%d tools called since the daemon started %s ago%s\n",
+		colorGray, len(stats)-unused, len(stats), uptime, colorReset)
+}
+
+// toolStatusCache returns the availability cache configured in settings
+func toolStatusCache() *config.ToolStatusCache {
+	settings, err := config.Load()
+	if err != nil {
+		settings = config.DefaultSettings()
+	}
+	return settings.Tools.External.ToolStatusCache()
+}
+
+// toolReport is a tool and its check status as printed by tools --json
+type toolReport struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	WhenToUse   string             `json:"when_to_use,omitempty"`
+	Command     string             `json:"command,omitempty"`
+	Status      *config.ToolStatus `json:"status,omitempty"`
+}
+
+// printToolsJSON prints every loaded tool with its raw check status as a JSON array
+func printToolsJSON() error {
+	_, statuses, err := config.LoadAndCheckTools(toolStatusCache())
+	if err != nil {
+		return fmt.Errorf("failed to load tools: %w", err)
+	}
+
+	allTools, _ := config.LoadExternalTools()
+
+	reports := make([]toolReport, 0, len(allTools))
+	for _, tool := range allTools {
+		report := toolReport{
+			Name:        tool.Name,
+			Description: tool.Description,
+			WhenToUse:   tool.WhenToUse,
+			Command:     tool.Access.Command,
+		}
+		if status, ok := statuses[tool.Name]; ok {
+			report.Status = &status
+		}
+		reports = append(reports, report)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
+
+// maxStatusOutputLines caps how much of a failed check's output is shown per tool
+const maxStatusOutputLines = 5
+
 func printTools() error {
-	tools, statuses, err := config.LoadAndCheckTools()
+	tools, statuses, err := config.LoadAndCheckTools(toolStatusCache())
 	if err != nil {
 		return fmt.Errorf("failed to load tools: %w", err)
 	}
@@ -44,6 +254,9 @@ func printTools() error {
 		if hasStatus && status.Available {
 			statusIcon = "●"
 			statusColor = "\033[32m" // Green
+		} else if hasStatus && status.Kept {
+			statusIcon = "◐"
+			statusColor = "\033[33m" // Yellow
 		} else {
 			statusIcon = "○"
 			statusColor = "\033[31m" // Red
@@ -76,9 +289,32 @@ func printTools() error {
 
 		// Status message if not available
 		if hasStatus && !status.Available {
-			fmt.Printf("%s│%s     %sStatus: %s%s\n",
+			cached := ""
+			if status.Cached {
+				cached = " (cached, use --recheck to refresh)"
+			}
+			kept := ""
+			if status.Kept {
+				kept = " (kept unverified, check.allow_inconclusive)"
+			}
+			fmt.Printf("%s│%s     %sStatus: %s%s%s%s\n",
 				colorGray, colorReset,
-				"\033[31m", status.Message, colorReset)
+				statusColor, status.Message, kept, cached, colorReset)
+			if status.ExitCode != 0 {
+				fmt.Printf("%s│%s     %sExit code: %d%s\n",
+					colorGray, colorReset,
+					colorGray, status.ExitCode, colorReset)
+			}
+			if status.Stderr != "" {
+				fmt.Printf("%s│%s     %sStderr:%s\n", colorGray, colorReset, colorGray, colorReset)
+				lines := strings.Split(status.Stderr, "\n")
+				if len(lines) > maxStatusOutputLines {
+					lines = append(lines[:maxStatusOutputLines], "...")
+				}
+				for _, line := range lines {
+					fmt.Printf("%s│%s       %s%s%s\n", colorGray, colorReset, colorGray, line, colorReset)
+				}
+			}
 		}
 
 		fmt.Printf("%s│%s\n", colorGray, colorReset)
@@ -104,7 +340,7 @@ func printTools() error {
 
 // printToolsCompact prints a compact version for use in chat
 func printToolsCompact() error {
-	tools, statuses, err := config.LoadAndCheckTools()
+	tools, statuses, err := config.LoadAndCheckTools(toolStatusCache())
 	if err != nil {
 		return fmt.Errorf("failed to load tools: %w", err)
 	}
@@ -126,6 +362,9 @@ func printToolsCompact() error {
 		if hasStatus && status.Available {
 			statusIcon = "●"
 			statusColor = "\033[32m"
+		} else if hasStatus && status.Kept {
+			statusIcon = "◐"
+			statusColor = "\033[33m"
 		} else {
 			statusIcon = "○"
 			statusColor = "\033[31m"
@@ -137,7 +376,7 @@ func printToolsCompact() error {
 			tool.Description)
 
 		if hasStatus && !status.Available {
-			fmt.Printf(" %s(%s)%s", "\033[31m", status.Message, colorReset)
+			fmt.Printf(" %s(%s)%s", statusColor, status.Message, colorReset)
 		}
 		fmt.Println()
 	}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marciniwanicki/craby/internal/api"
+)
+
+func TestPrintToolStats(t *testing.T) {
+	var buf bytes.Buffer
+	printToolStats(&buf, []*api.ToolStats{
+		{Name: "calculator", Calls: 2, Errors: 1, TotalDurationMs: 10, MaxDurationMs: 8},
+		{Name: "read_file"},
+		{Name: "shell", Calls: 4, TotalDurationMs: 400, MaxDurationMs: 250},
+	}, time.Hour)
+
+	lines := strings.Split(buf.String(), "\n")
+	if !strings.HasPrefix(lines[1], "shell ") || !strings.HasPrefix(lines[2], "calculator ") || !strings.HasPrefix(lines[3], "read_file ") {
+		t.Fatalf("expected tools ordered by calls, got:\n%s", buf.String())
+	}
+	for _, want := range []string{"1 (50%)", "100ms", "250ms", "2/3 tools called"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in the table, got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestPrintToolPrompt(t *testing.T) {
+	now := time.Now()
+	var buf bytes.Buffer
+	printToolPrompt(&buf, &api.ToolPromptResponse{
+		Prompt: "\n## Available External Tools\n\n- **tfl**: London transport\n",
+		CachedSchemas: []*api.CachedSchemaInfo{
+			{Command: "git", GeneratedAtUnix: now.Add(-3 * time.Hour).Unix(), Summary: "12 flags"},
+			{Command: "tfl", GeneratedAtUnix: now.Add(-8 * 24 * time.Hour).Unix(), Expired: true, Summary: "2 subcommands"},
+		},
+	}, now)
+
+	got := buf.String()
+	if !strings.HasPrefix(got, "## Available External Tools\n\n- **tfl**: London transport\n") {
+		t.Errorf("expected the prompt printed as is, got:\n%s", got)
+	}
+	for _, want := range []string{"git  ", "3h ago", "12 flags", "8d ago (expired)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in the output, got:\n%s", want, got)
+		}
+	}
+
+	buf.Reset()
+	printToolPrompt(&buf, &api.ToolPromptResponse{}, now)
+	if !strings.Contains(buf.String(), "No external tools prompt") || !strings.Contains(buf.String(), "No cached command schemas") {
+		t.Errorf("expected the empty state explained, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/marciniwanicki/craby/internal/version"
+	"github.com/spf13/cobra"
+)
+
+func versionCmd() *cobra.Command {
+	var jsonMode bool
+
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Show the craby version",
+		Long:  "Show the version, commit and build date of this craby binary, with the Go version and platform it was built for. The same as --version.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info := version.Get()
+			if jsonMode {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(info)
+			}
+			fmt.Printf("craby %s\n", info)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonMode, "json", false, "Print the version details as JSON")
+
+	return cmd
+}
@@ -4,9 +4,11 @@ go 1.24
 
 require (
 	github.com/charmbracelet/glamour v0.10.0
+	github.com/chzyer/readline v1.5.1
 	github.com/gorilla/websocket v1.5.1
 	github.com/rs/zerolog v1.32.0
 	github.com/spf13/cobra v1.8.0
+	golang.org/x/term v0.31.0
 	google.golang.org/protobuf v1.32.0
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1
 	gopkg.in/yaml.v3 v3.0.1
@@ -39,6 +41,5 @@ require (
 	github.com/yuin/goldmark-emoji v1.0.5 // indirect
 	golang.org/x/net v0.33.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
-	golang.org/x/term v0.31.0 // indirect
 	golang.org/x/text v0.24.0 // indirect
 )
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/marciniwanicki/craby/internal/tools"
 	"github.com/rs/zerolog"
@@ -11,6 +13,9 @@ import (
 
 const maxToolIterations = 10
 
+// jsonFormatInstruction is appended to the system prompt when a JSON response is requested
+const jsonFormatInstruction = "Respond only with valid JSON. Do not wrap it in markdown code blocks or add any other text."
+
 // EventType represents the type of event
 type EventType int
 
@@ -21,6 +26,10 @@ const (
 	EventShellCommand  // A shell command is being executed
 	EventPlanGenerated // A plan was generated (pipeline mode)
 	EventStepStarted   // A plan step is starting (pipeline mode)
+	EventThinking      // Model reasoning, kept separate from the answer text
+	EventShellOutput   // A line of output from a running shell command
+	EventDiscoveryStep // A step of command discovery finished
+	EventPlanApproval  // A plan's steps wait for RunOptions.ApprovePlan (pipeline mode)
 )
 
 // Role represents the message role
@@ -47,14 +56,22 @@ type Event struct {
 	ToolArgs string // JSON string
 
 	// For EventToolResult
-	ToolOutput  string
-	ToolSuccess bool
+	ToolOutput   string
+	ToolSuccess  bool
+	ToolDuration time.Duration
 
 	// For EventShellCommand
 	ShellCommand string
 	IsDiscovery  bool // True if this is a discovery command (e.g., --help)
 
-	// For EventPlanGenerated
+	// For EventShellOutput
+	ShellOutput string
+
+	// For EventDiscoveryStep
+	DiscoveryCommand string // Empty for steps that don't run a command
+	DiscoverySummary string
+
+	// For EventPlanGenerated, and EventPlanApproval with the steps in execution order
 	Plan *Plan
 }
 
@@ -95,6 +112,7 @@ type Agent struct {
 	registry     *tools.Registry
 	logger       zerolog.Logger
 	systemPrompt string
+	redactor     *tools.Redactor // Masks secrets in logged tool output (nil = disabled)
 }
 
 // NewAgent creates a new agent with the given system prompt
@@ -107,6 +125,11 @@ func NewAgent(llm LLMClient, registry *tools.Registry, logger zerolog.Logger, sy
 	}
 }
 
+// SetRedactor sets the redactor that masks secrets in logged tool output
+func (a *Agent) SetRedactor(redactor *tools.Redactor) {
+	a.redactor = redactor
+}
+
 // SystemPrompt returns the base system prompt
 func (a *Agent) SystemPrompt() string {
 	return a.systemPrompt
@@ -114,8 +137,25 @@ func (a *Agent) SystemPrompt() string {
 
 // RunOptions contains optional parameters for the agent run
 type RunOptions struct {
-	History []Message
-	Context string
+	History  []Message
+	Context  string
+	Format   string // Constrain the final answer: "json" or a JSON schema (empty = free text)
+	Identity string // Replaces the identity template for this run (empty = default identity)
+	NoTools  bool   // Answer without offering or running any tools
+
+	DisabledTools []string // Tools hidden from this run
+
+	// ApprovePlan is asked before a plan that runs shell commands executes, and a rejected plan
+	// aborts the run (nil = plans run without approval). Only the pipeline plans ahead.
+	ApprovePlan PlanApprover
+}
+
+// PlanApprover decides whether a plan may run, given its steps in execution order
+type PlanApprover func(ctx context.Context, steps []PlanStep) (bool, error)
+
+// ToolEnabled reports whether the run may use the named tool
+func (o RunOptions) ToolEnabled(name string) bool {
+	return !o.NoTools && !slices.Contains(o.DisabledTools, name)
 }
 
 // Run executes the agent loop with the given user message and options
@@ -134,9 +174,16 @@ func (a *Agent) Run(ctx context.Context, userMessage string, opts RunOptions, ev
 
 	// Build system prompt, optionally with context
 	systemPrompt := a.systemPrompt
+	if opts.Identity != "" {
+		systemPrompt = opts.Identity
+	}
 	if opts.Context != "" {
 		systemPrompt = systemPrompt + "\n\n<context>\n" + opts.Context + "\n</context>"
 	}
+	if opts.Format != "" {
+		systemPrompt += "\n\n" + jsonFormatInstruction
+		ctx = WithResponseFormat(ctx, opts.Format)
+	}
 
 	// Build messages: system prompt + history + new user message
 	messages := []Message{
@@ -145,10 +192,11 @@ func (a *Agent) Run(ctx context.Context, userMessage string, opts RunOptions, ev
 	messages = append(messages, opts.History...)
 	messages = append(messages, Message{Role: "user", Content: userMessage})
 
-	toolDefMaps := a.registry.Definitions()
-	toolDefs := make([]any, len(toolDefMaps))
-	for i, def := range toolDefMaps {
-		toolDefs[i] = def
+	var toolDefs []any
+	for _, t := range a.registry.List() {
+		if opts.ToolEnabled(t.Name()) {
+			toolDefs = append(toolDefs, tools.Definition(t))
+		}
 	}
 
 	a.logger.Debug().
@@ -195,15 +243,13 @@ func (a *Agent) Run(ctx context.Context, userMessage string, opts RunOptions, ev
 					Int("tokens", len(bufferedTokens)).
 					Int("content_len", len(result.Content)).
 					Msg("streaming final answer")
+				var splitter ThinkingSplitter
 				for _, token := range bufferedTokens {
-					eventChan <- Event{
-						Type: EventText,
-						Text: token,
-						Role: RoleAssistant,
-					}
+					emitSegments(eventChan, splitter.Feed(token))
 				}
+				emitSegments(eventChan, splitter.Flush())
 				// Add final assistant message and return history (excluding system prompt)
-				messages = append(messages, Message{Role: "assistant", Content: result.Content})
+				messages = append(messages, Message{Role: "assistant", Content: StripThinking(result.Content)})
 				a.logger.Debug().Int("final_history_len", len(messages)-1).Msg("agent run complete")
 				return messages[1:], nil // Skip system prompt
 			}
@@ -239,7 +285,15 @@ func (a *Agent) Run(ctx context.Context, userMessage string, opts RunOptions, ev
 					Interface("args", tc.Function.Arguments).
 					Msg("executing tool")
 
-				output, err := a.registry.Execute(tc.Function.Name, tc.Function.Arguments)
+				startTime := time.Now()
+				var output string
+				var err error
+				if opts.ToolEnabled(tc.Function.Name) {
+					output, err = a.registry.ExecuteContext(ctx, tc.Function.Name, tc.Function.Arguments)
+				} else {
+					err = fmt.Errorf("tool %q is disabled for this chat", tc.Function.Name)
+				}
+				execDuration := time.Since(startTime)
 				success := err == nil
 				if err != nil {
 					a.logger.Warn().Err(err).Str("tool", tc.Function.Name).Msg("tool execution failed")
@@ -248,14 +302,15 @@ func (a *Agent) Run(ctx context.Context, userMessage string, opts RunOptions, ev
 
 				// Emit tool result event immediately
 				eventChan <- Event{
-					Type:        EventToolResult,
-					ToolID:      tc.ID,
-					ToolName:    tc.Function.Name,
-					ToolOutput:  output,
-					ToolSuccess: success,
+					Type:         EventToolResult,
+					ToolID:       tc.ID,
+					ToolName:     tc.Function.Name,
+					ToolOutput:   output,
+					ToolSuccess:  success,
+					ToolDuration: execDuration,
 				}
 
-				a.logger.Debug().Str("tool", tc.Function.Name).Str("output", output).Msg("tool result")
+				a.logger.Debug().Str("tool", tc.Function.Name).Str("output", a.redactor.Redact(output)).Msg("tool result")
 
 				// Add tool result message
 				messages = append(messages, Message{
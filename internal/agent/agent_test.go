@@ -17,6 +17,7 @@ type mockLLMClient struct {
 	responses []ChatResult
 	callCount int
 	messages  [][]Message
+	toolDefs  [][]any // Tools offered on each call
 }
 
 func (m *mockLLMClient) ChatWithTools(ctx context.Context, messages []Message, toolDefs []any, tokenChan chan<- string) (*ChatResult, error) {
@@ -26,6 +27,7 @@ func (m *mockLLMClient) ChatWithTools(ctx context.Context, messages []Message, t
 
 	// Store messages for inspection
 	m.messages = append(m.messages, messages)
+	m.toolDefs = append(m.toolDefs, toolDefs)
 
 	if m.callCount >= len(m.responses) {
 		return nil, errors.New("no more mock responses")
@@ -581,3 +583,53 @@ func (t *testTool) Parameters() map[string]any {
 func (t *testTool) Execute(args map[string]any) (string, error) {
 	return t.execFunc(args)
 }
+
+func TestAgent_Run_DisabledTools(t *testing.T) {
+	llm := &mockLLMClient{
+		responses: []ChatResult{
+			{
+				ToolCalls: []ToolCall{
+					{ID: "call_1", Function: FunctionCall{Name: "shell", Arguments: map[string]any{}}},
+				},
+			},
+			{Content: "I can't run commands here.", Done: true},
+		},
+	}
+
+	executed := false
+	registry := tools.NewRegistry()
+	registry.Register(&testTool{name: "shell", execFunc: func(args map[string]any) (string, error) {
+		executed = true
+		return "", nil
+	}})
+	registry.Register(&testTool{name: "test_tool"})
+
+	agent := NewAgent(llm, registry, testLogger(), "You are a test assistant.")
+	eventChan := make(chan Event, 20)
+
+	if _, err := agent.Run(context.Background(), "List files", RunOptions{DisabledTools: []string{"shell"}}, eventChan); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for range eventChan {
+	}
+
+	if len(llm.toolDefs[0]) != 1 {
+		t.Errorf("expected only the enabled tool to be offered, got %d", len(llm.toolDefs[0]))
+	}
+	if executed {
+		t.Error("expected the disabled tool not to run")
+	}
+	if result := llm.messages[1][len(llm.messages[1])-1]; !strings.Contains(result.Content, "disabled") {
+		t.Errorf("expected the model to be told the tool is disabled, got %q", result.Content)
+	}
+}
+
+func TestRunOptions_ToolEnabled(t *testing.T) {
+	opts := RunOptions{DisabledTools: []string{"shell"}}
+	if opts.ToolEnabled("shell") || !opts.ToolEnabled("test_tool") {
+		t.Error("expected only the listed tool to be disabled")
+	}
+	if (RunOptions{NoTools: true}).ToolEnabled("test_tool") {
+		t.Error("expected no tools to be enabled with NoTools")
+	}
+}
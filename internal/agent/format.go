@@ -0,0 +1,21 @@
+package agent
+
+import "context"
+
+// responseFormatKey is the context key for the requested response format
+type responseFormatKey struct{}
+
+// WithResponseFormat returns a context asking the LLM client to constrain its output.
+// The format is "json" or a JSON schema; an empty format leaves ctx unchanged.
+func WithResponseFormat(ctx context.Context, format string) context.Context {
+	if format == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, responseFormatKey{}, format)
+}
+
+// ResponseFormat returns the response format requested via WithResponseFormat, if any
+func ResponseFormat(ctx context.Context) string {
+	format, _ := ctx.Value(responseFormatKey{}).(string)
+	return format
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// servedModelKey is the context key for the served model recorder
+type servedModelKey struct{}
+
+// ServedModel records which model answered a request, which may be a fallback
+// when the primary model is unavailable
+type ServedModel struct {
+	mu       sync.Mutex
+	name     string
+	fallback bool
+}
+
+// Set records the model that served the latest LLM call
+func (s *ServedModel) Set(name string, fallback bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+	s.fallback = fallback
+}
+
+// Get returns the recorded model and whether it was a fallback
+func (s *ServedModel) Get() (name string, fallback bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.name, s.fallback
+}
+
+// WithServedModel returns a context that LLM clients report the serving model to
+func WithServedModel(ctx context.Context, served *ServedModel) context.Context {
+	return context.WithValue(ctx, servedModelKey{}, served)
+}
+
+// RecordServedModel reports the model that served a call, if ctx carries a recorder
+func RecordServedModel(ctx context.Context, name string, fallback bool) {
+	if served, ok := ctx.Value(servedModelKey{}).(*ServedModel); ok && served != nil {
+		served.Set(name, fallback)
+	}
+}
+
+// usageKey is the context key for the token usage recorder
+type usageKey struct{}
+
+// Usage adds up tokens and generation time across the LLM calls made for one request
+type Usage struct {
+	mu               sync.Mutex
+	promptTokens     int
+	completionTokens int
+	evalDuration     time.Duration
+}
+
+// Add records the tokens of one LLM call and the time spent generating its completion tokens
+func (u *Usage) Add(promptTokens, completionTokens int, evalDuration time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.promptTokens += promptTokens
+	u.completionTokens += completionTokens
+	u.evalDuration += evalDuration
+}
+
+// Totals returns the prompt and completion tokens recorded so far
+func (u *Usage) Totals() (promptTokens, completionTokens int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.promptTokens, u.completionTokens
+}
+
+// EvalDuration returns the time spent generating completion tokens so far
+func (u *Usage) EvalDuration() time.Duration {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.evalDuration
+}
+
+// WithUsage returns a context that LLM clients report token usage to
+func WithUsage(ctx context.Context, usage *Usage) context.Context {
+	return context.WithValue(ctx, usageKey{}, usage)
+}
+
+// RecordUsage reports the tokens and generation time of one LLM call, if ctx carries a recorder
+func RecordUsage(ctx context.Context, promptTokens, completionTokens int, evalDuration time.Duration) {
+	if usage, ok := ctx.Value(usageKey{}).(*Usage); ok && usage != nil {
+		usage.Add(promptTokens, completionTokens, evalDuration)
+	}
+}
@@ -3,7 +3,9 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -60,6 +62,12 @@ type ExecutionStepLog struct {
 	DurationMs int64
 }
 
+// ErrToolExecution is returned by Run when the plan's tool steps couldn't be executed
+var ErrToolExecution = errors.New("tool execution failed")
+
+// ErrPlanRejected is returned by Run when RunOptions.ApprovePlan turned a plan down
+var ErrPlanRejected = errors.New("plan rejected")
+
 // PipelineTemplates holds the templates needed for the pipeline
 type PipelineTemplates struct {
 	Planning  string
@@ -86,6 +94,8 @@ type Pipeline struct {
 	templates     PipelineTemplates
 	externalTools map[string]bool    // Set of external tool/command names
 	stepLogger    PipelineStepLogger // Optional step logger for debugging
+	retained      int                // Earlier step results shown when planning the next step (0 = all)
+	redactor      *tools.Redactor    // Masks secrets in logged step output (nil = disabled)
 }
 
 // NewPipeline creates a new pipeline executor
@@ -119,6 +129,18 @@ func (p *Pipeline) SetStepLogger(stepLogger PipelineStepLogger) {
 	p.stepLogger = stepLogger
 }
 
+// SetRedactor sets the redactor that masks secrets in step output written to the step logger
+func (p *Pipeline) SetRedactor(redactor *tools.Redactor) {
+	p.redactor = redactor
+}
+
+// SetRetainedResults limits how many earlier step results are shown to the model when it plans the
+// next step: the first schema discovered and then the most recent ones. 0 shows them all.
+// Synthesis always sees every result.
+func (p *Pipeline) SetRetainedResults(n int) {
+	p.retained = n
+}
+
 // MaxIterations is the maximum number of plan-execute cycles to prevent infinite loops
 const MaxIterations = 10
 
@@ -139,7 +161,13 @@ func (p *Pipeline) Run(ctx context.Context, userMessage string, opts RunOptions,
 	// Accumulated results from all iterations
 	var allResults []StepResult
 
-	for iteration := 0; iteration < MaxIterations; iteration++ {
+	// Without tools there is nothing to plan, so answer directly
+	iterations := MaxIterations
+	if opts.NoTools {
+		iterations = 0
+	}
+
+	for iteration := 0; iteration < iterations; iteration++ {
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
@@ -189,15 +217,19 @@ func (p *Pipeline) Run(ctx context.Context, userMessage string, opts RunOptions,
 
 		// Validate the plan
 		if plan.NeedsTools && len(plan.Steps) > 0 {
-			if err := p.validate(plan); err != nil {
+			if err := p.validate(plan, opts); err != nil {
 				return nil, fmt.Errorf("validation failed (iteration %d): %w", iteration, err)
 			}
 			p.logger.Debug().Msg("plan validated successfully")
 
+			if err := p.approve(ctx, plan, opts, eventChan); err != nil {
+				return nil, err
+			}
+
 			// Execute steps
 			results, err := p.execute(ctx, plan, eventChan)
 			if err != nil {
-				return nil, fmt.Errorf("execution failed (iteration %d): %w", iteration, err)
+				return nil, fmt.Errorf("%w (iteration %d): %w", ErrToolExecution, iteration, err)
 			}
 
 			// Accumulate results
@@ -268,7 +300,7 @@ func (p *Pipeline) planWithResults(ctx context.Context, userMessage string, opts
 			Int("attempt", attempt+1).
 			Msg("received planning response")
 
-		plan, err := ParsePlan(response)
+		plan, err := ParsePlan(StripThinking(response))
 		if err != nil {
 			lastErr = err
 			p.logger.Warn().
@@ -294,13 +326,16 @@ func truncateString(s string, maxLen int) string {
 	return s[:maxLen] + "..."
 }
 
-// validate checks that all tools exist and arguments are valid
-func (p *Pipeline) validate(plan *Plan) error {
+// validate checks that all tools exist, are enabled for the run and arguments are valid
+func (p *Pipeline) validate(plan *Plan, opts RunOptions) error {
 	for _, step := range plan.Steps {
 		_, ok := p.registry.Get(step.Tool)
 		if !ok {
 			return fmt.Errorf("step %s: unknown tool %q", step.ID, step.Tool)
 		}
+		if !opts.ToolEnabled(step.Tool) {
+			return fmt.Errorf("step %s: tool %q is disabled for this chat", step.ID, step.Tool)
+		}
 
 		// Validate dependencies exist within this plan iteration
 		if step.DependsOn != "" {
@@ -319,6 +354,42 @@ func (p *Pipeline) validate(plan *Plan) error {
 	return nil
 }
 
+// approve asks RunOptions.ApprovePlan to approve a plan that runs shell commands, showing all its steps
+func (p *Pipeline) approve(ctx context.Context, plan *Plan, opts RunOptions, eventChan chan<- Event) error {
+	if opts.ApprovePlan == nil || !p.runsShell(plan) {
+		return nil
+	}
+	ordered, err := p.executionOrder(plan.Steps)
+	if err != nil {
+		return err
+	}
+
+	eventChan <- Event{
+		Type: EventPlanApproval,
+		Plan: &Plan{Intent: plan.Intent, Complexity: plan.Complexity, NeedsTools: true, Steps: ordered},
+	}
+	approved, err := opts.ApprovePlan(ctx, ordered)
+	if err != nil {
+		return fmt.Errorf("plan approval failed: %w", err)
+	}
+	if !approved {
+		p.logger.Info().Str("intent", plan.Intent).Int("steps", len(ordered)).Msg("plan rejected")
+		return ErrPlanRejected
+	}
+	p.logger.Info().Str("intent", plan.Intent).Int("steps", len(ordered)).Msg("plan approved")
+	return nil
+}
+
+// runsShell reports whether any step of the plan runs the shell tool
+func (p *Pipeline) runsShell(plan *Plan) bool {
+	for _, step := range plan.Steps {
+		if tool, ok := p.registry.Get(step.Tool); ok && tool.Name() == "shell" {
+			return true
+		}
+	}
+	return false
+}
+
 // execute runs the plan steps in dependency order
 func (p *Pipeline) execute(ctx context.Context, plan *Plan, eventChan chan<- Event) ([]StepResult, error) {
 	// Get execution order via topological sort
@@ -362,7 +433,7 @@ func (p *Pipeline) execute(ctx context.Context, plan *Plan, eventChan chan<- Eve
 			Msg("executing step")
 
 		startTime := time.Now()
-		output, err := p.registry.Execute(step.Tool, args)
+		output, err := p.registry.ExecuteContext(ctx, step.Tool, args)
 		execDuration := time.Since(startTime)
 		success := err == nil
 		errorMsg := ""
@@ -377,11 +448,12 @@ func (p *Pipeline) execute(ctx context.Context, plan *Plan, eventChan chan<- Eve
 
 		// Emit tool result event
 		eventChan <- Event{
-			Type:        EventToolResult,
-			ToolID:      step.ID,
-			ToolName:    step.Tool,
-			ToolOutput:  output,
-			ToolSuccess: success,
+			Type:         EventToolResult,
+			ToolID:       step.ID,
+			ToolName:     step.Tool,
+			ToolOutput:   output,
+			ToolSuccess:  success,
+			ToolDuration: execDuration,
 		}
 
 		results = append(results, StepResult{
@@ -458,6 +530,11 @@ func (p *Pipeline) executionOrder(steps []PlanStep) ([]PlanStep, error) {
 // synthesize generates the final answer from the plan and tool results
 func (p *Pipeline) synthesize(ctx context.Context, userMessage string, plan *Plan, results []StepResult, opts RunOptions, eventChan chan<- Event) (string, error) {
 	prompt := p.renderSynthesisPrompt(userMessage, plan, results, opts)
+	if opts.Format != "" {
+		// Only the final answer is constrained; planning has its own output format
+		prompt += "\n\n" + jsonFormatInstruction
+		ctx = WithResponseFormat(ctx, opts.Format)
+	}
 
 	messages := []Message{
 		{Role: "system", Content: prompt},
@@ -480,23 +557,19 @@ func (p *Pipeline) synthesize(ctx context.Context, userMessage string, plan *Pla
 		resultChan <- response
 	}()
 
-	// Stream tokens to the event channel
-	var contentBuilder strings.Builder
+	// Stream tokens to the event channel, keeping reasoning apart from the answer
+	var splitter ThinkingSplitter
 	for token := range tokenChan {
-		contentBuilder.WriteString(token)
-		eventChan <- Event{
-			Type: EventText,
-			Text: token,
-			Role: RoleAssistant,
-		}
+		emitSegments(eventChan, splitter.Feed(token))
 	}
+	emitSegments(eventChan, splitter.Flush())
 
 	// Check for errors or get result
 	select {
 	case err := <-errChan:
 		return "", err
 	case response := <-resultChan:
-		return response, nil
+		return StripThinking(response), nil
 	}
 }
 
@@ -509,7 +582,7 @@ func (p *Pipeline) renderPlanningPromptWithResults(userMessage string, opts RunO
 	prompt = strings.ReplaceAll(prompt, "{{HISTORY}}", historyStr)
 
 	// Format tools
-	toolsStr := p.formatTools()
+	toolsStr := p.formatTools(opts)
 	prompt = strings.ReplaceAll(prompt, "{{TOOLS}}", toolsStr)
 
 	// User hints (context)
@@ -520,7 +593,7 @@ func (p *Pipeline) renderPlanningPromptWithResults(userMessage string, opts RunO
 	prompt = strings.ReplaceAll(prompt, "{{USER_HINTS}}", userHints)
 
 	// Format previous tool results for iterative planning
-	toolResultsStr := p.formatToolResults(previousResults)
+	toolResultsStr := p.formatRetainedResults(previousResults)
 	prompt = strings.ReplaceAll(prompt, "{{TOOL_RESULTS}}", toolResultsStr)
 
 	return prompt
@@ -531,7 +604,11 @@ func (p *Pipeline) renderSynthesisPrompt(userMessage string, plan *Plan, results
 	prompt := p.templates.Synthesis
 
 	// Identity
-	prompt = strings.ReplaceAll(prompt, "{{IDENTITY}}", p.templates.Identity)
+	identity := p.templates.Identity
+	if opts.Identity != "" {
+		identity = opts.Identity
+	}
+	prompt = strings.ReplaceAll(prompt, "{{IDENTITY}}", identity)
 
 	// User profile
 	prompt = strings.ReplaceAll(prompt, "{{USER}}", p.templates.User)
@@ -560,6 +637,8 @@ func (p *Pipeline) formatHistory(history []Message) string {
 			sb.WriteString("User: ")
 		case "assistant":
 			sb.WriteString("Assistant: ")
+		case "system":
+			// Notes such as a summary of trimmed earlier turns are included verbatim
 		default:
 			continue
 		}
@@ -569,9 +648,14 @@ func (p *Pipeline) formatHistory(history []Message) string {
 	return sb.String()
 }
 
-// formatTools formats available tools for the planning prompt
-func (p *Pipeline) formatTools() string {
-	toolList := p.registry.List()
+// formatTools formats the tools enabled for the run for the planning prompt
+func (p *Pipeline) formatTools(opts RunOptions) string {
+	var toolList []tools.Tool
+	for _, t := range p.registry.List() {
+		if opts.ToolEnabled(t.Name()) {
+			toolList = append(toolList, t)
+		}
+	}
 	if len(toolList) == 0 {
 		return "(No tools available)"
 	}
@@ -612,6 +696,50 @@ func (p *Pipeline) formatToolResults(results []StepResult) string {
 	return sb.String()
 }
 
+// formatRetainedResults formats the results kept for planning, noting the steps left out between them
+func (p *Pipeline) formatRetainedResults(results []StepResult) string {
+	first, recent, omitted := retainResults(results, p.retained)
+	if len(omitted) == 0 {
+		return p.formatToolResults(results)
+	}
+
+	ids := make([]string, 0, len(omitted))
+	for _, r := range omitted {
+		ids = append(ids, r.StepID)
+	}
+	var sb strings.Builder
+	sb.WriteString(p.formatToolResults(first))
+	sb.WriteString(fmt.Sprintf("(%d earlier steps omitted: %s)\n\n", len(omitted), strings.Join(ids, ", ")))
+	sb.WriteString(p.formatToolResults(recent))
+	return sb.String()
+}
+
+// retainResults picks at most limit results to show: the first schema discovered, or the first
+// result if none, since it usually holds the top-level help, followed by the most recent ones.
+// A limit of 0 or less keeps every result.
+func retainResults(results []StepResult, limit int) (first, recent, omitted []StepResult) {
+	if limit <= 0 || len(results) <= limit {
+		return nil, results, nil
+	}
+
+	anchor := 0
+	for i, r := range results {
+		if r.Tool == "get_command_schema" && r.Success {
+			anchor = i
+			break
+		}
+	}
+
+	// Keep the anchor, then fill the rest of the limit from the end
+	start := len(results) - (limit - 1)
+	if anchor >= start {
+		// Already among the most recent
+		return nil, results[len(results)-limit:], results[:len(results)-limit]
+	}
+	omitted = slices.Concat(results[:anchor], results[anchor+1:start])
+	return results[anchor : anchor+1], results[start:], omitted
+}
+
 func mustMarshalJSON(v any) string {
 	data, _ := json.Marshal(v)
 	return string(data)
@@ -660,7 +788,7 @@ func (p *Pipeline) logExecution(stepID, tool, purpose string, args map[string]an
 		Tool:       tool,
 		Purpose:    purpose,
 		Args:       args,
-		Output:     output,
+		Output:     p.redactor.Redact(output),
 		Success:    success,
 		Error:      errMsg,
 		DurationMs: duration.Milliseconds(),
@@ -3,10 +3,12 @@ package agent
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"strings"
 	"testing"
 
+	"github.com/marciniwanicki/craby/internal/config"
 	"github.com/marciniwanicki/craby/internal/tools"
 	"github.com/rs/zerolog"
 )
@@ -16,6 +18,7 @@ type mockPipelineLLMClient struct {
 	chatMessagesResponses []string
 	chatMessagesCount     int
 	messages              [][]Message
+	formats               []string // Response format requested for each call
 }
 
 func (m *mockPipelineLLMClient) ChatWithTools(ctx context.Context, messages []Message, toolDefs []any, tokenChan chan<- string) (*ChatResult, error) {
@@ -27,6 +30,7 @@ func (m *mockPipelineLLMClient) ChatWithTools(ctx context.Context, messages []Me
 
 func (m *mockPipelineLLMClient) ChatMessages(ctx context.Context, messages []Message, tokenChan chan<- string) (string, error) {
 	m.messages = append(m.messages, messages)
+	m.formats = append(m.formats, ResponseFormat(ctx))
 
 	if m.chatMessagesCount >= len(m.chatMessagesResponses) {
 		if tokenChan != nil {
@@ -881,3 +885,339 @@ func TestPipeline_ToolResultsInPlanningPrompt(t *testing.T) {
 		t.Error("second planning prompt should contain tool output from first iteration")
 	}
 }
+
+func TestPipeline_JSONFormat(t *testing.T) {
+	llm := &mockPipelineLLMClient{
+		chatMessagesResponses: []string{
+			`<plan>
+  <intent>Answer with JSON</intent>
+  <complexity>simple</complexity>
+  <needs_tools>false</needs_tools>
+  <ready_to_answer>true</ready_to_answer>
+  <steps></steps>
+</plan>`,
+			`{"answer": 4}`,
+		},
+	}
+
+	templates := PipelineTemplates{
+		Planning:  "You are in planning mode. {{TOOLS}} {{HISTORY}} {{USER_HINTS}} {{TOOL_RESULTS}}",
+		Synthesis: "{{IDENTITY}} {{USER}} {{HISTORY}} {{TOOL_RESULTS}}",
+	}
+	pipeline := NewPipeline(llm, tools.NewRegistry(), pipelineTestLogger(), templates)
+	eventChan := make(chan Event, 100)
+
+	if _, err := pipeline.Run(context.Background(), "What is 2+2?", RunOptions{Format: "json"}, eventChan); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(llm.formats) != 2 {
+		t.Fatalf("expected 2 LLM calls, got %d", len(llm.formats))
+	}
+	if llm.formats[0] != "" {
+		t.Errorf("expected planning call to be unconstrained, got %q", llm.formats[0])
+	}
+	if llm.formats[1] != "json" {
+		t.Errorf("expected synthesis call to request json, got %q", llm.formats[1])
+	}
+	if !strings.Contains(llm.messages[1][0].Content, jsonFormatInstruction) {
+		t.Error("expected synthesis prompt to ask for JSON")
+	}
+}
+
+func TestPipeline_SeparatesThinking(t *testing.T) {
+	llm := &mockPipelineLLMClient{
+		chatMessagesResponses: []string{
+			`<think>planning thoughts</think><plan>
+  <intent>Answer</intent>
+  <complexity>simple</complexity>
+  <needs_tools>false</needs_tools>
+  <ready_to_answer>true</ready_to_answer>
+  <steps></steps>
+</plan>`,
+			"<think>reasoning</think>The answer is 4.",
+		},
+	}
+
+	templates := PipelineTemplates{
+		Planning:  "You are in planning mode. {{TOOLS}} {{HISTORY}} {{USER_HINTS}} {{TOOL_RESULTS}}",
+		Synthesis: "{{IDENTITY}} {{USER}} {{HISTORY}} {{TOOL_RESULTS}}",
+	}
+	pipeline := NewPipeline(llm, tools.NewRegistry(), pipelineTestLogger(), templates)
+	eventChan := make(chan Event, 100)
+
+	history, err := pipeline.Run(context.Background(), "What is 2+2?", RunOptions{}, eventChan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var text, thinking strings.Builder
+	for event := range eventChan {
+		switch event.Type {
+		case EventText:
+			text.WriteString(event.Text)
+		case EventThinking:
+			thinking.WriteString(event.Text)
+		}
+	}
+
+	if text.String() != "The answer is 4." {
+		t.Errorf("expected answer text only, got %q", text.String())
+	}
+	if thinking.String() != "reasoning" {
+		t.Errorf("expected thinking event, got %q", thinking.String())
+	}
+	if history[1].Content != "The answer is 4." {
+		t.Errorf("expected thinking to be stripped from history, got %q", history[1].Content)
+	}
+}
+
+func TestPipeline_RenderSynthesisPrompt_IdentityOverride(t *testing.T) {
+	pipeline := NewPipeline(&mockPipelineLLMClient{}, tools.NewRegistry(), pipelineTestLogger(), PipelineTemplates{
+		Synthesis: "{{IDENTITY}} | {{USER}}",
+		Identity:  "You are Craby.",
+		User:      "User profile here.",
+	})
+
+	if got := pipeline.renderSynthesisPrompt("hi", nil, nil, RunOptions{}); got != "You are Craby. | User profile here." {
+		t.Errorf("expected default identity, got %q", got)
+	}
+	if got := pipeline.renderSynthesisPrompt("hi", nil, nil, RunOptions{Identity: "You are an editor."}); got != "You are an editor. | User profile here." {
+		t.Errorf("expected persona identity, got %q", got)
+	}
+}
+
+func TestRetainResults(t *testing.T) {
+	results := func(tools ...string) []StepResult {
+		var rs []StepResult
+		for i, tool := range tools {
+			rs = append(rs, StepResult{StepID: fmt.Sprintf("step_%d", i+1), Tool: tool, Success: true})
+		}
+		return rs
+	}
+	ids := func(rs []StepResult) string {
+		var out []string
+		for _, r := range rs {
+			out = append(out, r.StepID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	tests := []struct {
+		name                   string
+		results                []StepResult
+		limit                  int
+		first, recent, omitted string
+	}{
+		{"unlimited", results("shell", "shell", "shell"), 0, "", "step_1,step_2,step_3", ""},
+		{"within limit", results("shell", "shell"), 2, "", "step_1,step_2", ""},
+		{"first help and most recent", results("get_command_schema", "get_command_schema", "get_command_schema", "get_command_schema", "get_command_schema"), 3, "step_1", "step_4,step_5", "step_2,step_3"},
+		{"first schema after other steps", results("shell", "get_command_schema", "shell", "shell", "shell"), 3, "step_2", "step_4,step_5", "step_1,step_3"},
+		{"first schema already recent", results("shell", "shell", "shell", "get_command_schema"), 2, "", "step_3,step_4", "step_1,step_2"},
+		{"no schema keeps the first result", results("shell", "shell", "shell", "shell"), 2, "step_1", "step_4", "step_2,step_3"},
+	}
+	for _, tt := range tests {
+		first, recent, omitted := retainResults(tt.results, tt.limit)
+		if ids(first) != tt.first || ids(recent) != tt.recent || ids(omitted) != tt.omitted {
+			t.Errorf("%s: got first %q, recent %q, omitted %q", tt.name, ids(first), ids(recent), ids(omitted))
+		}
+	}
+}
+
+func TestPipeline_RenderPlanningPrompt_RetainedResults(t *testing.T) {
+	pipeline := NewPipeline(&mockPipelineLLMClient{}, tools.NewRegistry(), pipelineTestLogger(), PipelineTemplates{
+		Planning: "{{TOOL_RESULTS}}",
+	})
+	var results []StepResult
+	for i := 1; i <= 5; i++ {
+		results = append(results, StepResult{StepID: fmt.Sprintf("step_%d", i), Tool: "get_command_schema", Output: fmt.Sprintf("help %d", i), Success: true})
+	}
+
+	if got := pipeline.renderPlanningPromptWithResults("hi", RunOptions{}, results); strings.Contains(got, "omitted") || !strings.Contains(got, "help 3") {
+		t.Errorf("expected every result by default, got:\n%s", got)
+	}
+
+	pipeline.SetRetainedResults(3)
+	got := pipeline.renderPlanningPromptWithResults("hi", RunOptions{}, results)
+	for _, want := range []string{"help 1", "(2 earlier steps omitted: step_2, step_3)", "help 4", "help 5"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in the prompt, got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "help 2") || strings.Index(got, "help 1") > strings.Index(got, "omitted") || strings.Index(got, "omitted") > strings.Index(got, "help 4") {
+		t.Errorf("expected the first result, the note, then the most recent, got:\n%s", got)
+	}
+}
+
+func TestPipeline_NoTools(t *testing.T) {
+	llm := &mockPipelineLLMClient{
+		chatMessagesResponses: []string{"Hello!"},
+	}
+
+	templates := PipelineTemplates{
+		Planning:  "You are in planning mode. {{TOOLS}} {{HISTORY}} {{USER_HINTS}} {{TOOL_RESULTS}}",
+		Synthesis: "{{IDENTITY}} {{USER}} {{HISTORY}} {{TOOL_RESULTS}}",
+	}
+	pipeline := NewPipeline(llm, tools.NewRegistry(), pipelineTestLogger(), templates)
+	eventChan := make(chan Event, 100)
+
+	history, err := pipeline.Run(context.Background(), "Hi", RunOptions{NoTools: true}, eventChan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for range eventChan {
+	}
+
+	if len(llm.messages) != 1 {
+		t.Fatalf("expected only the synthesis call without planning, got %d calls", len(llm.messages))
+	}
+	if history[1].Content != "Hello!" {
+		t.Errorf("unexpected answer %q", history[1].Content)
+	}
+}
+
+func TestPipeline_DisabledTools(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(&testTool{name: "shell"})
+	registry.Register(&testTool{name: "test_tool"})
+	pipeline := NewPipeline(&mockPipelineLLMClient{}, registry, pipelineTestLogger(), PipelineTemplates{})
+	opts := RunOptions{DisabledTools: []string{"shell"}}
+
+	listed := pipeline.formatTools(opts)
+	if strings.Contains(listed, "**shell**") || !strings.Contains(listed, "**test_tool**") {
+		t.Errorf("expected only enabled tools in the planning prompt, got %q", listed)
+	}
+
+	plan := &Plan{Steps: []PlanStep{{ID: "step_1", Tool: "shell"}}}
+	if err := pipeline.validate(plan, opts); err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Errorf("expected a disabled tool error, got %v", err)
+	}
+}
+
+func TestPipeline_ApprovePlan(t *testing.T) {
+	shellPlan := `<plan>
+  <intent>List files</intent>
+  <complexity>tool</complexity>
+  <needs_tools>true</needs_tools>
+  <ready_to_answer>false</ready_to_answer>
+  <steps>
+    <step id="step_2" depends_on="step_1">
+      <tool>shell</tool>
+      <purpose>Count them</purpose>
+      <args><arg name="command">ls | wc -l</arg></args>
+    </step>
+    <step id="step_1">
+      <tool>shell</tool>
+      <purpose>List them</purpose>
+      <args><arg name="command">ls</arg></args>
+    </step>
+  </steps>
+</plan>`
+	donePlan := `<plan>
+  <intent>List files</intent>
+  <complexity>tool</complexity>
+  <needs_tools>true</needs_tools>
+  <ready_to_answer>true</ready_to_answer>
+  <steps></steps>
+</plan>`
+	templates := PipelineTemplates{Planning: "{{TOOLS}}", Synthesis: "{{TOOL_RESULTS}}"}
+
+	for _, approved := range []bool{true, false} {
+		var executed []string
+		registry := tools.NewRegistry()
+		registry.Register(&testTool{
+			name: "shell",
+			execFunc: func(args map[string]any) (string, error) {
+				executed = append(executed, args["command"].(string))
+				return "ok", nil
+			},
+		})
+		llm := &mockPipelineLLMClient{chatMessagesResponses: []string{shellPlan, donePlan, "Two files."}}
+		pipeline := NewPipeline(llm, registry, pipelineTestLogger(), templates)
+
+		var asked []string
+		opts := RunOptions{ApprovePlan: func(ctx context.Context, steps []PlanStep) (bool, error) {
+			for _, step := range steps {
+				asked = append(asked, step.ID)
+			}
+			return approved, nil
+		}}
+		eventChan := make(chan Event, 100)
+		_, err := pipeline.Run(context.Background(), "how many files?", opts, eventChan)
+
+		var approvals int
+		for event := range eventChan {
+			if event.Type == EventPlanApproval {
+				approvals++
+			}
+		}
+		if approvals != 1 || strings.Join(asked, ",") != "step_1,step_2" {
+			t.Errorf("expected one approval of the steps in execution order, got %d events and %v", approvals, asked)
+		}
+		if approved && (err != nil || len(executed) != 2) {
+			t.Errorf("expected an approved plan to run, got %v and %v", err, executed)
+		}
+		if !approved && (!errors.Is(err, ErrPlanRejected) || len(executed) != 0) {
+			t.Errorf("expected a rejected plan to abort before running, got %v and %v", err, executed)
+		}
+	}
+}
+
+// recordingStepLogger keeps the execution steps it's asked to log
+type recordingStepLogger struct {
+	executions []ExecutionStepLog
+}
+
+func (l *recordingStepLogger) Reset()                      {}
+func (l *recordingStepLogger) LogPlan(_ PlanStepLog) error { return nil }
+func (l *recordingStepLogger) LogExecution(log ExecutionStepLog) error {
+	l.executions = append(l.executions, log)
+	return nil
+}
+
+func TestPipeline_RedactsLoggedOutput(t *testing.T) {
+	plan := `<plan>
+  <intent>Show config</intent>
+  <complexity>tool</complexity>
+  <needs_tools>true</needs_tools>
+  <ready_to_answer>false</ready_to_answer>
+  <steps>
+    <step id="step_1">
+      <tool>test_tool</tool>
+      <purpose>Read it</purpose>
+      <args><arg name="input">config</arg></args>
+    </step>
+  </steps>
+</plan>`
+	donePlan := `<plan>
+  <intent>Show config</intent>
+  <complexity>tool</complexity>
+  <needs_tools>true</needs_tools>
+  <ready_to_answer>true</ready_to_answer>
+  <steps></steps>
+</plan>`
+
+	registry := tools.NewRegistry()
+	registry.Register(&testTool{
+		name: "test_tool",
+		execFunc: func(args map[string]any) (string, error) {
+			return "password=hunter2hunter2", nil
+		},
+	})
+	llm := &mockPipelineLLMClient{chatMessagesResponses: []string{plan, donePlan, "Done."}}
+	pipeline := NewPipeline(llm, registry, pipelineTestLogger(), PipelineTemplates{Planning: "{{TOOLS}}", Synthesis: "{{TOOL_RESULTS}}"})
+	stepLogger := &recordingStepLogger{}
+	pipeline.SetStepLogger(stepLogger)
+	pipeline.SetRedactor(tools.NewRedactor(config.DefaultSettings().Redaction, nil))
+
+	eventChan := make(chan Event, 100)
+	if _, err := pipeline.Run(context.Background(), "show config", RunOptions{}, eventChan); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for range eventChan {
+	}
+
+	if len(stepLogger.executions) != 1 || stepLogger.executions[0].Output != "password=[REDACTED]" {
+		t.Errorf("expected the logged output redacted, got %+v", stepLogger.executions)
+	}
+}
@@ -0,0 +1,105 @@
+package agent
+
+import "strings"
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// Segment is a piece of streamed model output classified as answer text or reasoning
+type Segment struct {
+	Text     string
+	Thinking bool
+}
+
+// ThinkingSplitter separates <think>...</think> reasoning from answer text in a token stream.
+// Tags may be split across tokens; partial tags are held back until they can be classified.
+type ThinkingSplitter struct {
+	buf      strings.Builder
+	thinking bool
+}
+
+// Feed adds a token and returns the segments that can be classified so far
+func (s *ThinkingSplitter) Feed(token string) []Segment {
+	s.buf.WriteString(token)
+	pending := s.buf.String()
+	s.buf.Reset()
+
+	var segments []Segment
+	for pending != "" {
+		tag := thinkOpenTag
+		if s.thinking {
+			tag = thinkCloseTag
+		}
+
+		if idx := strings.Index(pending, tag); idx >= 0 {
+			segments = appendSegment(segments, pending[:idx], s.thinking)
+			pending = pending[idx+len(tag):]
+			s.thinking = !s.thinking
+			continue
+		}
+
+		// Hold back a suffix that could be the start of the tag
+		keep := partialTagSuffix(pending, tag)
+		segments = appendSegment(segments, pending[:len(pending)-keep], s.thinking)
+		s.buf.WriteString(pending[len(pending)-keep:])
+		break
+	}
+
+	return segments
+}
+
+// Flush returns any held-back text once the stream has ended
+func (s *ThinkingSplitter) Flush() []Segment {
+	pending := s.buf.String()
+	s.buf.Reset()
+	return appendSegment(nil, pending, s.thinking)
+}
+
+// StripThinking removes <think>...</think> blocks from a complete response
+func StripThinking(text string) string {
+	var splitter ThinkingSplitter
+	var sb strings.Builder
+	for _, seg := range append(splitter.Feed(text), splitter.Flush()...) {
+		if !seg.Thinking {
+			sb.WriteString(seg.Text)
+		}
+	}
+	if sb.Len() == len(text) {
+		return text
+	}
+	return strings.TrimLeft(sb.String(), "\n")
+}
+
+// partialTagSuffix returns the length of the longest suffix of s that is a proper prefix of tag
+func partialTagSuffix(s, tag string) int {
+	for n := min(len(tag)-1, len(s)); n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// emitSegments sends classified output as text or thinking events
+func emitSegments(eventChan chan<- Event, segments []Segment) {
+	for _, seg := range segments {
+		eventType := EventText
+		if seg.Thinking {
+			eventType = EventThinking
+		}
+		eventChan <- Event{
+			Type: eventType,
+			Text: seg.Text,
+			Role: RoleAssistant,
+		}
+	}
+}
+
+func appendSegment(segments []Segment, text string, thinking bool) []Segment {
+	if text == "" {
+		return segments
+	}
+	return append(segments, Segment{Text: text, Thinking: thinking})
+}
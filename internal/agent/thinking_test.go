@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+// splitAll feeds tokens through a splitter and joins answer and thinking text separately
+func splitAll(tokens []string) (answer, thinking string) {
+	var splitter ThinkingSplitter
+	var a, th strings.Builder
+	var segments []Segment
+	for _, token := range tokens {
+		segments = append(segments, splitter.Feed(token)...)
+	}
+	segments = append(segments, splitter.Flush()...)
+	for _, seg := range segments {
+		if seg.Thinking {
+			th.WriteString(seg.Text)
+		} else {
+			a.WriteString(seg.Text)
+		}
+	}
+	return a.String(), th.String()
+}
+
+func TestThinkingSplitter(t *testing.T) {
+	tests := []struct {
+		name         string
+		tokens       []string
+		wantAnswer   string
+		wantThinking string
+	}{
+		{"no thinking", []string{"Hello", " world"}, "Hello world", ""},
+		{"single chunk", []string{"<think>hmm</think>Answer"}, "Answer", "hmm"},
+		{"tags split across chunks", []string{"<th", "ink>let me", " think</thi", "nk>The answer"}, "The answer", "let me think"},
+		{"one char at a time", strings.Split("<think>ab</think>cd", ""), "cd", "ab"},
+		{"unclosed thinking", []string{"<think>still going"}, "", "still going"},
+		{"angle bracket in answer", []string{"a < b", " and <tag>"}, "a < b and <tag>", ""},
+		{"partial tag at end", []string{"ends with <thi"}, "ends with <thi", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, thinking := splitAll(tt.tokens)
+			if answer != tt.wantAnswer {
+				t.Errorf("answer = %q, want %q", answer, tt.wantAnswer)
+			}
+			if thinking != tt.wantThinking {
+				t.Errorf("thinking = %q, want %q", thinking, tt.wantThinking)
+			}
+		})
+	}
+}
+
+func TestStripThinking(t *testing.T) {
+	if got := StripThinking("<think>reasoning</think>\n\nFinal"); got != "Final" {
+		t.Errorf("expected thinking to be stripped, got %q", got)
+	}
+	if got := StripThinking("  plain answer"); got != "  plain answer" {
+		t.Errorf("expected plain text unchanged, got %q", got)
+	}
+}
@@ -21,6 +21,71 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// ErrorCode tells clients why a chat failed, so they can react without parsing the message
+type ErrorCode int32
+
+const (
+	ErrorCode_ERROR_UNKNOWN         ErrorCode = 0
+	ErrorCode_ERROR_INVALID_REQUEST ErrorCode = 1 // The request was malformed or named something that doesn't exist
+	ErrorCode_ERROR_RATE_LIMITED    ErrorCode = 2 // Too many requests, back off and retry later
+	ErrorCode_ERROR_SERVER_BUSY     ErrorCode = 3 // The chat queue is full, retry later
+	ErrorCode_ERROR_MODEL           ErrorCode = 4 // The model failed to answer or is unavailable
+	ErrorCode_ERROR_TOOL            ErrorCode = 5 // A tool failed while answering
+	ErrorCode_ERROR_INTERNAL        ErrorCode = 6 // The daemon failed, e.g. couldn't send the answer
+	ErrorCode_ERROR_CANCELED        ErrorCode = 7 // The client canceled the generation
+)
+
+// Enum value maps for ErrorCode.
+var (
+	ErrorCode_name = map[int32]string{
+		0: "ERROR_UNKNOWN",
+		1: "ERROR_INVALID_REQUEST",
+		2: "ERROR_RATE_LIMITED",
+		3: "ERROR_SERVER_BUSY",
+		4: "ERROR_MODEL",
+		5: "ERROR_TOOL",
+		6: "ERROR_INTERNAL",
+		7: "ERROR_CANCELED",
+	}
+	ErrorCode_value = map[string]int32{
+		"ERROR_UNKNOWN":         0,
+		"ERROR_INVALID_REQUEST": 1,
+		"ERROR_RATE_LIMITED":    2,
+		"ERROR_SERVER_BUSY":     3,
+		"ERROR_MODEL":           4,
+		"ERROR_TOOL":            5,
+		"ERROR_INTERNAL":        6,
+		"ERROR_CANCELED":        7,
+	}
+)
+
+func (x ErrorCode) Enum() *ErrorCode {
+	p := new(ErrorCode)
+	*p = x
+	return p
+}
+
+func (x ErrorCode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ErrorCode) Descriptor() protoreflect.EnumDescriptor {
+	return file_internal_api_messages_proto_enumTypes[0].Descriptor()
+}
+
+func (ErrorCode) Type() protoreflect.EnumType {
+	return &file_internal_api_messages_proto_enumTypes[0]
+}
+
+func (x ErrorCode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ErrorCode.Descriptor instead.
+func (ErrorCode) EnumDescriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{0}
+}
+
 type Role int32
 
 const (
@@ -54,11 +119,11 @@ func (x Role) String() string {
 }
 
 func (Role) Descriptor() protoreflect.EnumDescriptor {
-	return file_internal_api_messages_proto_enumTypes[0].Descriptor()
+	return file_internal_api_messages_proto_enumTypes[1].Descriptor()
 }
 
 func (Role) Type() protoreflect.EnumType {
-	return &file_internal_api_messages_proto_enumTypes[0]
+	return &file_internal_api_messages_proto_enumTypes[1]
 }
 
 func (x Role) Number() protoreflect.EnumNumber {
@@ -67,13 +132,23 @@ func (x Role) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use Role.Descriptor instead.
 func (Role) EnumDescriptor() ([]byte, []int) {
-	return file_internal_api_messages_proto_rawDescGZIP(), []int{0}
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{1}
 }
 
 type ChatRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
-	SessionId     string                 `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"` // Reserved for future use
+	SessionId     string                 `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`             // Reserved for future use
+	Format        string                 `protobuf:"bytes,3,opt,name=format,proto3" json:"format,omitempty"`                                    // Constrain the answer: "json" or a JSON schema (empty = free text)
+	Persona       string                 `protobuf:"bytes,4,opt,name=persona,proto3" json:"persona,omitempty"`                                  // Identity from ~/.craby/templates/<persona>.md (empty = default identity)
+	SystemPrompt  string                 `protobuf:"bytes,5,opt,name=system_prompt,json=systemPrompt,proto3" json:"system_prompt,omitempty"`    // Identity to use instead of a persona template (empty = not set)
+	NoTools       bool                   `protobuf:"varint,6,opt,name=no_tools,json=noTools,proto3" json:"no_tools,omitempty"`                  // Answer without running any tools
+	DisabledTools []string               `protobuf:"bytes,7,rep,name=disabled_tools,json=disabledTools,proto3" json:"disabled_tools,omitempty"` // Tools hidden from this chat
+	PromptPrefix  string                 `protobuf:"bytes,8,opt,name=prompt_prefix,json=promptPrefix,proto3" json:"prompt_prefix,omitempty"`    // Replaces the daemon's daemon.prompt.prefix for this chat (empty = keep it)
+	PromptSuffix  string                 `protobuf:"bytes,9,opt,name=prompt_suffix,json=promptSuffix,proto3" json:"prompt_suffix,omitempty"`    // Replaces the daemon's daemon.prompt.suffix for this chat (empty = keep it)
+	Trace         bool                   `protobuf:"varint,10,opt,name=trace,proto3" json:"trace,omitempty"`                                    // Also stream the model's plans, for debugging the agent loop
+	ApprovePlans  bool                   `protobuf:"varint,11,opt,name=approve_plans,json=approvePlans,proto3" json:"approve_plans,omitempty"`  // Ask the client to approve each plan that runs shell commands
+	PlanDecision  *PlanDecision          `protobuf:"bytes,12,opt,name=plan_decision,json=planDecision,proto3" json:"plan_decision,omitempty"`   // Answers the running chat's plan approval, other fields are ignored
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -122,6 +197,121 @@ func (x *ChatRequest) GetSessionId() string {
 	return ""
 }
 
+func (x *ChatRequest) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetPersona() string {
+	if x != nil {
+		return x.Persona
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetSystemPrompt() string {
+	if x != nil {
+		return x.SystemPrompt
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetNoTools() bool {
+	if x != nil {
+		return x.NoTools
+	}
+	return false
+}
+
+func (x *ChatRequest) GetDisabledTools() []string {
+	if x != nil {
+		return x.DisabledTools
+	}
+	return nil
+}
+
+func (x *ChatRequest) GetPromptPrefix() string {
+	if x != nil {
+		return x.PromptPrefix
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetPromptSuffix() string {
+	if x != nil {
+		return x.PromptSuffix
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetTrace() bool {
+	if x != nil {
+		return x.Trace
+	}
+	return false
+}
+
+func (x *ChatRequest) GetApprovePlans() bool {
+	if x != nil {
+		return x.ApprovePlans
+	}
+	return false
+}
+
+func (x *ChatRequest) GetPlanDecision() *PlanDecision {
+	if x != nil {
+		return x.PlanDecision
+	}
+	return nil
+}
+
+// PlanDecision approves or rejects the plan a chat is waiting on
+type PlanDecision struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Approved      bool                   `protobuf:"varint,1,opt,name=approved,proto3" json:"approved,omitempty"` // False aborts the chat before any step of the plan runs
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlanDecision) Reset() {
+	*x = PlanDecision{}
+	mi := &file_internal_api_messages_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlanDecision) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlanDecision) ProtoMessage() {}
+
+func (x *PlanDecision) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlanDecision.ProtoReflect.Descriptor instead.
+func (*PlanDecision) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PlanDecision) GetApproved() bool {
+	if x != nil {
+		return x.Approved
+	}
+	return false
+}
+
 type ChatResponse struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Types that are valid to be assigned to Payload:
@@ -130,16 +320,31 @@ type ChatResponse struct {
 	//	*ChatResponse_ToolCall
 	//	*ChatResponse_ToolResult
 	//	*ChatResponse_Done
-	//	*ChatResponse_Error
 	//	*ChatResponse_ShellCommand
-	Payload       isChatResponse_Payload `protobuf_oneof:"payload"`
+	//	*ChatResponse_Thinking
+	//	*ChatResponse_ShellOutput
+	//	*ChatResponse_QueuePosition
+	//	*ChatResponse_Error
+	//	*ChatResponse_DiscoveryStep
+	//	*ChatResponse_Plan
+	//	*ChatResponse_PlanApproval
+	Payload            isChatResponse_Payload `protobuf_oneof:"payload"`
+	RateLimitRemaining int32                  `protobuf:"varint,7,opt,name=rate_limit_remaining,json=rateLimitRemaining,proto3" json:"rate_limit_remaining,omitempty"` // Chat requests left in the current rate limit window (-1 = unlimited)
+	Model              string                 `protobuf:"bytes,9,opt,name=model,proto3" json:"model,omitempty"`                                                        // Model that answered, set on done
+	Fallback           bool                   `protobuf:"varint,10,opt,name=fallback,proto3" json:"fallback,omitempty"`                                                // True when a fallback model answered instead of the primary
+	PromptTokens       int32                  `protobuf:"varint,12,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`                    // Prompt tokens evaluated across all LLM calls of the chat, set on done
+	CompletionTokens   int32                  `protobuf:"varint,13,opt,name=completion_tokens,json=completionTokens,proto3" json:"completion_tokens,omitempty"`        // Tokens generated across all LLM calls of the chat, set on done
+	EvalDurationMs     int64                  `protobuf:"varint,14,opt,name=eval_duration_ms,json=evalDurationMs,proto3" json:"eval_duration_ms,omitempty"`            // Time Ollama spent generating the completion tokens, set on done
+	// Copy of error.message sent alongside the structured error. It has the number and type of the
+	// string error that clients predating ChatError read from the payload, so they still show the text.
+	LegacyError   string `protobuf:"bytes,5,opt,name=legacy_error,json=legacyError,proto3" json:"legacy_error,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ChatResponse) Reset() {
 	*x = ChatResponse{}
-	mi := &file_internal_api_messages_proto_msgTypes[1]
+	mi := &file_internal_api_messages_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -151,7 +356,7 @@ func (x *ChatResponse) String() string {
 func (*ChatResponse) ProtoMessage() {}
 
 func (x *ChatResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_api_messages_proto_msgTypes[1]
+	mi := &file_internal_api_messages_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -164,7 +369,7 @@ func (x *ChatResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatResponse.ProtoReflect.Descriptor instead.
 func (*ChatResponse) Descriptor() ([]byte, []int) {
-	return file_internal_api_messages_proto_rawDescGZIP(), []int{1}
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *ChatResponse) GetPayload() isChatResponse_Payload {
@@ -210,24 +415,127 @@ func (x *ChatResponse) GetDone() bool {
 	return false
 }
 
-func (x *ChatResponse) GetError() string {
+func (x *ChatResponse) GetShellCommand() *ShellCommand {
+	if x != nil {
+		if x, ok := x.Payload.(*ChatResponse_ShellCommand); ok {
+			return x.ShellCommand
+		}
+	}
+	return nil
+}
+
+func (x *ChatResponse) GetThinking() string {
+	if x != nil {
+		if x, ok := x.Payload.(*ChatResponse_Thinking); ok {
+			return x.Thinking
+		}
+	}
+	return ""
+}
+
+func (x *ChatResponse) GetShellOutput() string {
+	if x != nil {
+		if x, ok := x.Payload.(*ChatResponse_ShellOutput); ok {
+			return x.ShellOutput
+		}
+	}
+	return ""
+}
+
+func (x *ChatResponse) GetQueuePosition() int32 {
+	if x != nil {
+		if x, ok := x.Payload.(*ChatResponse_QueuePosition); ok {
+			return x.QueuePosition
+		}
+	}
+	return 0
+}
+
+func (x *ChatResponse) GetError() *ChatError {
 	if x != nil {
 		if x, ok := x.Payload.(*ChatResponse_Error); ok {
 			return x.Error
 		}
 	}
-	return ""
+	return nil
 }
 
-func (x *ChatResponse) GetShellCommand() *ShellCommand {
+func (x *ChatResponse) GetDiscoveryStep() *DiscoveryStep {
 	if x != nil {
-		if x, ok := x.Payload.(*ChatResponse_ShellCommand); ok {
-			return x.ShellCommand
+		if x, ok := x.Payload.(*ChatResponse_DiscoveryStep); ok {
+			return x.DiscoveryStep
+		}
+	}
+	return nil
+}
+
+func (x *ChatResponse) GetPlan() *Plan {
+	if x != nil {
+		if x, ok := x.Payload.(*ChatResponse_Plan); ok {
+			return x.Plan
 		}
 	}
 	return nil
 }
 
+func (x *ChatResponse) GetPlanApproval() *Plan {
+	if x != nil {
+		if x, ok := x.Payload.(*ChatResponse_PlanApproval); ok {
+			return x.PlanApproval
+		}
+	}
+	return nil
+}
+
+func (x *ChatResponse) GetRateLimitRemaining() int32 {
+	if x != nil {
+		return x.RateLimitRemaining
+	}
+	return 0
+}
+
+func (x *ChatResponse) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *ChatResponse) GetFallback() bool {
+	if x != nil {
+		return x.Fallback
+	}
+	return false
+}
+
+func (x *ChatResponse) GetPromptTokens() int32 {
+	if x != nil {
+		return x.PromptTokens
+	}
+	return 0
+}
+
+func (x *ChatResponse) GetCompletionTokens() int32 {
+	if x != nil {
+		return x.CompletionTokens
+	}
+	return 0
+}
+
+func (x *ChatResponse) GetEvalDurationMs() int64 {
+	if x != nil {
+		return x.EvalDurationMs
+	}
+	return 0
+}
+
+func (x *ChatResponse) GetLegacyError() string {
+	if x != nil {
+		return x.LegacyError
+	}
+	return ""
+}
+
 type isChatResponse_Payload interface {
 	isChatResponse_Payload()
 }
@@ -248,12 +556,36 @@ type ChatResponse_Done struct {
 	Done bool `protobuf:"varint,4,opt,name=done,proto3,oneof"`
 }
 
+type ChatResponse_ShellCommand struct {
+	ShellCommand *ShellCommand `protobuf:"bytes,6,opt,name=shell_command,json=shellCommand,proto3,oneof"`
+}
+
+type ChatResponse_Thinking struct {
+	Thinking string `protobuf:"bytes,8,opt,name=thinking,proto3,oneof"` // Model reasoning, separate from the answer text
+}
+
+type ChatResponse_ShellOutput struct {
+	ShellOutput string `protobuf:"bytes,11,opt,name=shell_output,json=shellOutput,proto3,oneof"` // A line of output from a running shell command
+}
+
+type ChatResponse_QueuePosition struct {
+	QueuePosition int32 `protobuf:"varint,15,opt,name=queue_position,json=queuePosition,proto3,oneof"` // The chat is waiting for the model, 1 = next in line
+}
+
 type ChatResponse_Error struct {
-	Error string `protobuf:"bytes,5,opt,name=error,proto3,oneof"`
+	Error *ChatError `protobuf:"bytes,16,opt,name=error,proto3,oneof"`
 }
 
-type ChatResponse_ShellCommand struct {
-	ShellCommand *ShellCommand `protobuf:"bytes,6,opt,name=shell_command,json=shellCommand,proto3,oneof"`
+type ChatResponse_DiscoveryStep struct {
+	DiscoveryStep *DiscoveryStep `protobuf:"bytes,17,opt,name=discovery_step,json=discoveryStep,proto3,oneof"` // Progress while learning a command's usage from its help
+}
+
+type ChatResponse_Plan struct {
+	Plan *Plan `protobuf:"bytes,18,opt,name=plan,proto3,oneof"` // The model's plan for its next turn, sent only to traced chats
+}
+
+type ChatResponse_PlanApproval struct {
+	PlanApproval *Plan `protobuf:"bytes,19,opt,name=plan_approval,json=planApproval,proto3,oneof"` // A plan waiting for the client's PlanDecision, steps in execution order
 }
 
 func (*ChatResponse_Text) isChatResponse_Payload() {}
@@ -264,33 +596,46 @@ func (*ChatResponse_ToolResult) isChatResponse_Payload() {}
 
 func (*ChatResponse_Done) isChatResponse_Payload() {}
 
+func (*ChatResponse_ShellCommand) isChatResponse_Payload() {}
+
+func (*ChatResponse_Thinking) isChatResponse_Payload() {}
+
+func (*ChatResponse_ShellOutput) isChatResponse_Payload() {}
+
+func (*ChatResponse_QueuePosition) isChatResponse_Payload() {}
+
 func (*ChatResponse_Error) isChatResponse_Payload() {}
 
-func (*ChatResponse_ShellCommand) isChatResponse_Payload() {}
+func (*ChatResponse_DiscoveryStep) isChatResponse_Payload() {}
 
-type ShellCommand struct {
+func (*ChatResponse_Plan) isChatResponse_Payload() {}
+
+func (*ChatResponse_PlanApproval) isChatResponse_Payload() {}
+
+type ChatError struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Command       string                 `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
-	IsDiscovery   bool                   `protobuf:"varint,2,opt,name=is_discovery,json=isDiscovery,proto3" json:"is_discovery,omitempty"`
+	Code          ErrorCode              `protobuf:"varint,1,opt,name=code,proto3,enum=craby.api.v1.ErrorCode" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"` // Human-readable, for display
+	Detail        string                 `protobuf:"bytes,3,opt,name=detail,proto3" json:"detail,omitempty"`   // Optional extra context, e.g. the model that was unavailable
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ShellCommand) Reset() {
-	*x = ShellCommand{}
-	mi := &file_internal_api_messages_proto_msgTypes[2]
+func (x *ChatError) Reset() {
+	*x = ChatError{}
+	mi := &file_internal_api_messages_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ShellCommand) String() string {
+func (x *ChatError) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ShellCommand) ProtoMessage() {}
+func (*ChatError) ProtoMessage() {}
 
-func (x *ShellCommand) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_api_messages_proto_msgTypes[2]
+func (x *ChatError) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -301,36 +646,147 @@ func (x *ShellCommand) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ShellCommand.ProtoReflect.Descriptor instead.
-func (*ShellCommand) Descriptor() ([]byte, []int) {
-	return file_internal_api_messages_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use ChatError.ProtoReflect.Descriptor instead.
+func (*ChatError) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{3}
 }
 
-func (x *ShellCommand) GetCommand() string {
+func (x *ChatError) GetCode() ErrorCode {
 	if x != nil {
-		return x.Command
+		return x.Code
+	}
+	return ErrorCode_ERROR_UNKNOWN
+}
+
+func (x *ChatError) GetMessage() string {
+	if x != nil {
+		return x.Message
 	}
 	return ""
 }
 
-func (x *ShellCommand) GetIsDiscovery() bool {
+func (x *ChatError) GetDetail() string {
 	if x != nil {
-		return x.IsDiscovery
+		return x.Detail
 	}
-	return false
+	return ""
 }
 
-type TextChunk struct {
+type DiscoveryStep struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Content       string                 `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
-	Role          Role                   `protobuf:"varint,2,opt,name=role,proto3,enum=craby.api.v1.Role" json:"role,omitempty"`
+	Command       string                 `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"` // Command that was run, empty for steps that don't run one
+	Summary       string                 `protobuf:"bytes,2,opt,name=summary,proto3" json:"summary,omitempty"` // One-line outcome, e.g. "42 lines of help"
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *TextChunk) Reset() {
-	*x = TextChunk{}
-	mi := &file_internal_api_messages_proto_msgTypes[3]
+func (x *DiscoveryStep) Reset() {
+	*x = DiscoveryStep{}
+	mi := &file_internal_api_messages_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiscoveryStep) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiscoveryStep) ProtoMessage() {}
+
+func (x *DiscoveryStep) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiscoveryStep.ProtoReflect.Descriptor instead.
+func (*DiscoveryStep) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DiscoveryStep) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *DiscoveryStep) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+type ShellCommand struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Command       string                 `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	IsDiscovery   bool                   `protobuf:"varint,2,opt,name=is_discovery,json=isDiscovery,proto3" json:"is_discovery,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ShellCommand) Reset() {
+	*x = ShellCommand{}
+	mi := &file_internal_api_messages_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShellCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShellCommand) ProtoMessage() {}
+
+func (x *ShellCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShellCommand.ProtoReflect.Descriptor instead.
+func (*ShellCommand) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ShellCommand) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *ShellCommand) GetIsDiscovery() bool {
+	if x != nil {
+		return x.IsDiscovery
+	}
+	return false
+}
+
+type TextChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Content       string                 `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
+	Role          Role                   `protobuf:"varint,2,opt,name=role,proto3,enum=craby.api.v1.Role" json:"role,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TextChunk) Reset() {
+	*x = TextChunk{}
+	mi := &file_internal_api_messages_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -342,7 +798,7 @@ func (x *TextChunk) String() string {
 func (*TextChunk) ProtoMessage() {}
 
 func (x *TextChunk) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_api_messages_proto_msgTypes[3]
+	mi := &file_internal_api_messages_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -355,7 +811,7 @@ func (x *TextChunk) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TextChunk.ProtoReflect.Descriptor instead.
 func (*TextChunk) Descriptor() ([]byte, []int) {
-	return file_internal_api_messages_proto_rawDescGZIP(), []int{3}
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *TextChunk) GetContent() string {
@@ -383,7 +839,7 @@ type ToolCall struct {
 
 func (x *ToolCall) Reset() {
 	*x = ToolCall{}
-	mi := &file_internal_api_messages_proto_msgTypes[4]
+	mi := &file_internal_api_messages_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -395,7 +851,7 @@ func (x *ToolCall) String() string {
 func (*ToolCall) ProtoMessage() {}
 
 func (x *ToolCall) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_api_messages_proto_msgTypes[4]
+	mi := &file_internal_api_messages_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -408,7 +864,7 @@ func (x *ToolCall) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ToolCall.ProtoReflect.Descriptor instead.
 func (*ToolCall) Descriptor() ([]byte, []int) {
-	return file_internal_api_messages_proto_rawDescGZIP(), []int{4}
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *ToolCall) GetId() string {
@@ -432,19 +888,157 @@ func (x *ToolCall) GetArguments() string {
 	return ""
 }
 
+// Plan is a planning turn of the model: what it intends to do before answering
+type Plan struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Intent        string                 `protobuf:"bytes,1,opt,name=intent,proto3" json:"intent,omitempty"`
+	Complexity    string                 `protobuf:"bytes,2,opt,name=complexity,proto3" json:"complexity,omitempty"`
+	ReadyToAnswer bool                   `protobuf:"varint,3,opt,name=ready_to_answer,json=readyToAnswer,proto3" json:"ready_to_answer,omitempty"`
+	Steps         []*PlanStep            `protobuf:"bytes,4,rep,name=steps,proto3" json:"steps,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Plan) Reset() {
+	*x = Plan{}
+	mi := &file_internal_api_messages_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Plan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Plan) ProtoMessage() {}
+
+func (x *Plan) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Plan.ProtoReflect.Descriptor instead.
+func (*Plan) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Plan) GetIntent() string {
+	if x != nil {
+		return x.Intent
+	}
+	return ""
+}
+
+func (x *Plan) GetComplexity() string {
+	if x != nil {
+		return x.Complexity
+	}
+	return ""
+}
+
+func (x *Plan) GetReadyToAnswer() bool {
+	if x != nil {
+		return x.ReadyToAnswer
+	}
+	return false
+}
+
+func (x *Plan) GetSteps() []*PlanStep {
+	if x != nil {
+		return x.Steps
+	}
+	return nil
+}
+
+type PlanStep struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Tool          string                 `protobuf:"bytes,2,opt,name=tool,proto3" json:"tool,omitempty"`
+	Purpose       string                 `protobuf:"bytes,3,opt,name=purpose,proto3" json:"purpose,omitempty"`
+	Arguments     string                 `protobuf:"bytes,4,opt,name=arguments,proto3" json:"arguments,omitempty"` // JSON string
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlanStep) Reset() {
+	*x = PlanStep{}
+	mi := &file_internal_api_messages_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlanStep) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlanStep) ProtoMessage() {}
+
+func (x *PlanStep) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlanStep.ProtoReflect.Descriptor instead.
+func (*PlanStep) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *PlanStep) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *PlanStep) GetTool() string {
+	if x != nil {
+		return x.Tool
+	}
+	return ""
+}
+
+func (x *PlanStep) GetPurpose() string {
+	if x != nil {
+		return x.Purpose
+	}
+	return ""
+}
+
+func (x *PlanStep) GetArguments() string {
+	if x != nil {
+		return x.Arguments
+	}
+	return ""
+}
+
 type ToolResult struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
 	Output        string                 `protobuf:"bytes,3,opt,name=output,proto3" json:"output,omitempty"`
 	Success       bool                   `protobuf:"varint,4,opt,name=success,proto3" json:"success,omitempty"`
+	DurationMs    int64                  `protobuf:"varint,5,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"` // Wall-clock time spent executing the tool
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ToolResult) Reset() {
 	*x = ToolResult{}
-	mi := &file_internal_api_messages_proto_msgTypes[5]
+	mi := &file_internal_api_messages_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -456,7 +1050,7 @@ func (x *ToolResult) String() string {
 func (*ToolResult) ProtoMessage() {}
 
 func (x *ToolResult) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_api_messages_proto_msgTypes[5]
+	mi := &file_internal_api_messages_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -469,58 +1063,933 @@ func (x *ToolResult) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ToolResult.ProtoReflect.Descriptor instead.
 func (*ToolResult) Descriptor() ([]byte, []int) {
-	return file_internal_api_messages_proto_rawDescGZIP(), []int{5}
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ToolResult) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ToolResult) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ToolResult) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *ToolResult) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *ToolResult) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+type StatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusRequest) Reset() {
+	*x = StatusRequest{}
+	mi := &file_internal_api_messages_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusRequest) ProtoMessage() {}
+
+func (x *StatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusRequest.ProtoReflect.Descriptor instead.
+func (*StatusRequest) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{11}
+}
+
+type StatusResponse struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Healthy           bool                   `protobuf:"varint,1,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	Model             string                 `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`
+	Version           string                 `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	UptimeSeconds     int64                  `protobuf:"varint,4,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	ActiveConnections int32                  `protobuf:"varint,5,opt,name=active_connections,json=activeConnections,proto3" json:"active_connections,omitempty"`
+	ChatsServed       int64                  `protobuf:"varint,6,opt,name=chats_served,json=chatsServed,proto3" json:"chats_served,omitempty"`
+	OllamaUrl         string                 `protobuf:"bytes,7,opt,name=ollama_url,json=ollamaUrl,proto3" json:"ollama_url,omitempty"`
+	Models            []*ModelStatus         `protobuf:"bytes,8,rep,name=models,proto3" json:"models,omitempty"`                                                      // Primary model first, then fallbacks
+	OllamaReachable   bool                   `protobuf:"varint,9,opt,name=ollama_reachable,json=ollamaReachable,proto3" json:"ollama_reachable,omitempty"`            // Ollama answered the health check
+	ModelPresent      bool                   `protobuf:"varint,10,opt,name=model_present,json=modelPresent,proto3" json:"model_present,omitempty"`                    // The primary model is pulled (healthy = reachable and present)
+	CommandsInFlight  int32                  `protobuf:"varint,11,opt,name=commands_in_flight,json=commandsInFlight,proto3" json:"commands_in_flight,omitempty"`      // Shell and discovery commands currently running
+	Commit            string                 `protobuf:"bytes,12,opt,name=commit,proto3" json:"commit,omitempty"`                                                     // Git commit the daemon was built from (empty if unknown)
+	BuildDate         string                 `protobuf:"bytes,13,opt,name=build_date,json=buildDate,proto3" json:"build_date,omitempty"`                              // When the daemon was built, RFC 3339 (empty if unknown)
+	ChatsQueued       int32                  `protobuf:"varint,14,opt,name=chats_queued,json=chatsQueued,proto3" json:"chats_queued,omitempty"`                       // Chats waiting for the model
+	EmbeddingModel    *ModelStatus           `protobuf:"bytes,15,opt,name=embedding_model,json=embeddingModel,proto3" json:"embedding_model,omitempty"`               // Model used for embeddings
+	ToolCallsInFlight int32                  `protobuf:"varint,16,opt,name=tool_calls_in_flight,json=toolCallsInFlight,proto3" json:"tool_calls_in_flight,omitempty"` // Tool calls of any tool currently running
+	ToolCallsWaiting  int32                  `protobuf:"varint,17,opt,name=tool_calls_waiting,json=toolCallsWaiting,proto3" json:"tool_calls_waiting,omitempty"`      // Tool calls queued for a free slot
+	ToolCallCapacity  int32                  `protobuf:"varint,18,opt,name=tool_call_capacity,json=toolCallCapacity,proto3" json:"tool_call_capacity,omitempty"`      // Tool calls allowed at once (0 = unlimited)
+	ToolStats         []*ToolStats           `protobuf:"bytes,19,rep,name=tool_stats,json=toolStats,proto3" json:"tool_stats,omitempty"`                              // Per-tool call statistics since the daemon started, sorted by name
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *StatusResponse) Reset() {
+	*x = StatusResponse{}
+	mi := &file_internal_api_messages_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusResponse) ProtoMessage() {}
+
+func (x *StatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusResponse.ProtoReflect.Descriptor instead.
+func (*StatusResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *StatusResponse) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+func (x *StatusResponse) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *StatusResponse) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *StatusResponse) GetUptimeSeconds() int64 {
+	if x != nil {
+		return x.UptimeSeconds
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetActiveConnections() int32 {
+	if x != nil {
+		return x.ActiveConnections
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetChatsServed() int64 {
+	if x != nil {
+		return x.ChatsServed
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetOllamaUrl() string {
+	if x != nil {
+		return x.OllamaUrl
+	}
+	return ""
+}
+
+func (x *StatusResponse) GetModels() []*ModelStatus {
+	if x != nil {
+		return x.Models
+	}
+	return nil
+}
+
+func (x *StatusResponse) GetOllamaReachable() bool {
+	if x != nil {
+		return x.OllamaReachable
+	}
+	return false
+}
+
+func (x *StatusResponse) GetModelPresent() bool {
+	if x != nil {
+		return x.ModelPresent
+	}
+	return false
+}
+
+func (x *StatusResponse) GetCommandsInFlight() int32 {
+	if x != nil {
+		return x.CommandsInFlight
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetCommit() string {
+	if x != nil {
+		return x.Commit
+	}
+	return ""
+}
+
+func (x *StatusResponse) GetBuildDate() string {
+	if x != nil {
+		return x.BuildDate
+	}
+	return ""
+}
+
+func (x *StatusResponse) GetChatsQueued() int32 {
+	if x != nil {
+		return x.ChatsQueued
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetEmbeddingModel() *ModelStatus {
+	if x != nil {
+		return x.EmbeddingModel
+	}
+	return nil
+}
+
+func (x *StatusResponse) GetToolCallsInFlight() int32 {
+	if x != nil {
+		return x.ToolCallsInFlight
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetToolCallsWaiting() int32 {
+	if x != nil {
+		return x.ToolCallsWaiting
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetToolCallCapacity() int32 {
+	if x != nil {
+		return x.ToolCallCapacity
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetToolStats() []*ToolStats {
+	if x != nil {
+		return x.ToolStats
+	}
+	return nil
+}
+
+type ToolStats struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Name            string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Calls           int64                  `protobuf:"varint,2,opt,name=calls,proto3" json:"calls,omitempty"`
+	Errors          int64                  `protobuf:"varint,3,opt,name=errors,proto3" json:"errors,omitempty"`
+	TotalDurationMs int64                  `protobuf:"varint,4,opt,name=total_duration_ms,json=totalDurationMs,proto3" json:"total_duration_ms,omitempty"`
+	MaxDurationMs   int64                  `protobuf:"varint,5,opt,name=max_duration_ms,json=maxDurationMs,proto3" json:"max_duration_ms,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ToolStats) Reset() {
+	*x = ToolStats{}
+	mi := &file_internal_api_messages_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ToolStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ToolStats) ProtoMessage() {}
+
+func (x *ToolStats) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ToolStats.ProtoReflect.Descriptor instead.
+func (*ToolStats) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ToolStats) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ToolStats) GetCalls() int64 {
+	if x != nil {
+		return x.Calls
+	}
+	return 0
+}
+
+func (x *ToolStats) GetErrors() int64 {
+	if x != nil {
+		return x.Errors
+	}
+	return 0
+}
+
+func (x *ToolStats) GetTotalDurationMs() int64 {
+	if x != nil {
+		return x.TotalDurationMs
+	}
+	return 0
+}
+
+func (x *ToolStats) GetMaxDurationMs() int64 {
+	if x != nil {
+		return x.MaxDurationMs
+	}
+	return 0
+}
+
+type ModelStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Available     bool                   `protobuf:"varint,2,opt,name=available,proto3" json:"available,omitempty"` // Installed in Ollama
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ModelStatus) Reset() {
+	*x = ModelStatus{}
+	mi := &file_internal_api_messages_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ModelStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModelStatus) ProtoMessage() {}
+
+func (x *ModelStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModelStatus.ProtoReflect.Descriptor instead.
+func (*ModelStatus) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ModelStatus) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ModelStatus) GetAvailable() bool {
+	if x != nil {
+		return x.Available
+	}
+	return false
+}
+
+type HistoryMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Role          Role                   `protobuf:"varint,1,opt,name=role,proto3,enum=craby.api.v1.Role" json:"role,omitempty"`
+	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HistoryMessage) Reset() {
+	*x = HistoryMessage{}
+	mi := &file_internal_api_messages_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistoryMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryMessage) ProtoMessage() {}
+
+func (x *HistoryMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryMessage.ProtoReflect.Descriptor instead.
+func (*HistoryMessage) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *HistoryMessage) GetRole() Role {
+	if x != nil {
+		return x.Role
+	}
+	return Role_ASSISTANT
+}
+
+func (x *HistoryMessage) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+type HistoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Messages      []*HistoryMessage      `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HistoryResponse) Reset() {
+	*x = HistoryResponse{}
+	mi := &file_internal_api_messages_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryResponse) ProtoMessage() {}
+
+func (x *HistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryResponse.ProtoReflect.Descriptor instead.
+func (*HistoryResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *HistoryResponse) GetMessages() []*HistoryMessage {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+type ContextRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Context       string                 `protobuf:"bytes,1,opt,name=context,proto3" json:"context,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ContextRequest) Reset() {
+	*x = ContextRequest{}
+	mi := &file_internal_api_messages_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContextRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContextRequest) ProtoMessage() {}
+
+func (x *ContextRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContextRequest.ProtoReflect.Descriptor instead.
+func (*ContextRequest) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *ContextRequest) GetContext() string {
+	if x != nil {
+		return x.Context
+	}
+	return ""
+}
+
+type ContextResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Context       string                 `protobuf:"bytes,1,opt,name=context,proto3" json:"context,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ContextResponse) Reset() {
+	*x = ContextResponse{}
+	mi := &file_internal_api_messages_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContextResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContextResponse) ProtoMessage() {}
+
+func (x *ContextResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContextResponse.ProtoReflect.Descriptor instead.
+func (*ContextResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *ContextResponse) GetContext() string {
+	if x != nil {
+		return x.Context
+	}
+	return ""
+}
+
+// Tool execution request/response
+type ToolRunRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Arguments     string                 `protobuf:"bytes,2,opt,name=arguments,proto3" json:"arguments,omitempty"` // JSON string of key-value pairs
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ToolRunRequest) Reset() {
+	*x = ToolRunRequest{}
+	mi := &file_internal_api_messages_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ToolRunRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ToolRunRequest) ProtoMessage() {}
+
+func (x *ToolRunRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ToolRunRequest.ProtoReflect.Descriptor instead.
+func (*ToolRunRequest) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ToolRunRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ToolRunRequest) GetArguments() string {
+	if x != nil {
+		return x.Arguments
+	}
+	return ""
+}
+
+type ToolRunResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Output        string                 `protobuf:"bytes,1,opt,name=output,proto3" json:"output,omitempty"`
+	Success       bool                   `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ToolRunResponse) Reset() {
+	*x = ToolRunResponse{}
+	mi := &file_internal_api_messages_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ToolRunResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ToolRunResponse) ProtoMessage() {}
+
+func (x *ToolRunResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ToolRunResponse.ProtoReflect.Descriptor instead.
+func (*ToolRunResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ToolRunResponse) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *ToolRunResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *ToolRunResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type ToolListResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tools         []*ToolInfo            `protobuf:"bytes,1,rep,name=tools,proto3" json:"tools,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ToolListResponse) Reset() {
+	*x = ToolListResponse{}
+	mi := &file_internal_api_messages_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ToolListResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ToolListResponse) ProtoMessage() {}
+
+func (x *ToolListResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ToolListResponse.ProtoReflect.Descriptor instead.
+func (*ToolListResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *ToolListResponse) GetTools() []*ToolInfo {
+	if x != nil {
+		return x.Tools
+	}
+	return nil
+}
+
+type ToolInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Description   string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ToolInfo) Reset() {
+	*x = ToolInfo{}
+	mi := &file_internal_api_messages_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ToolInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ToolInfo) ProtoMessage() {}
+
+func (x *ToolInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ToolInfo.ProtoReflect.Descriptor instead.
+func (*ToolInfo) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *ToolInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ToolInfo) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+// The external tools section the daemon adds to the system prompt, and the command schemas discovered so far
+type ToolPromptResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Prompt        string                 `protobuf:"bytes,1,opt,name=prompt,proto3" json:"prompt,omitempty"` // Empty when the shell tool is disabled or no external tools are loaded
+	CachedSchemas []*CachedSchemaInfo    `protobuf:"bytes,2,rep,name=cached_schemas,json=cachedSchemas,proto3" json:"cached_schemas,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ToolPromptResponse) Reset() {
+	*x = ToolPromptResponse{}
+	mi := &file_internal_api_messages_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ToolPromptResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ToolPromptResponse) ProtoMessage() {}
+
+func (x *ToolPromptResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ToolPromptResponse.ProtoReflect.Descriptor instead.
+func (*ToolPromptResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ToolPromptResponse) GetPrompt() string {
+	if x != nil {
+		return x.Prompt
+	}
+	return ""
+}
+
+func (x *ToolPromptResponse) GetCachedSchemas() []*CachedSchemaInfo {
+	if x != nil {
+		return x.CachedSchemas
+	}
+	return nil
+}
+
+type CachedSchemaInfo struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Command         string                 `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	GeneratedAtUnix int64                  `protobuf:"varint,2,opt,name=generated_at_unix,json=generatedAtUnix,proto3" json:"generated_at_unix,omitempty"`
+	Expired         bool                   `protobuf:"varint,3,opt,name=expired,proto3" json:"expired,omitempty"` // Discovered again the next time the model asks for it
+	Summary         string                 `protobuf:"bytes,4,opt,name=summary,proto3" json:"summary,omitempty"`  // e.g. "12 flags, 20 subcommands"
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *CachedSchemaInfo) Reset() {
+	*x = CachedSchemaInfo{}
+	mi := &file_internal_api_messages_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CachedSchemaInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CachedSchemaInfo) ProtoMessage() {}
+
+func (x *CachedSchemaInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CachedSchemaInfo.ProtoReflect.Descriptor instead.
+func (*CachedSchemaInfo) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{24}
 }
 
-func (x *ToolResult) GetId() string {
+func (x *CachedSchemaInfo) GetCommand() string {
 	if x != nil {
-		return x.Id
+		return x.Command
 	}
 	return ""
 }
 
-func (x *ToolResult) GetName() string {
+func (x *CachedSchemaInfo) GetGeneratedAtUnix() int64 {
 	if x != nil {
-		return x.Name
+		return x.GeneratedAtUnix
 	}
-	return ""
+	return 0
 }
 
-func (x *ToolResult) GetOutput() string {
+func (x *CachedSchemaInfo) GetExpired() bool {
 	if x != nil {
-		return x.Output
+		return x.Expired
 	}
-	return ""
+	return false
 }
 
-func (x *ToolResult) GetSuccess() bool {
+func (x *CachedSchemaInfo) GetSummary() string {
 	if x != nil {
-		return x.Success
+		return x.Summary
 	}
-	return false
+	return ""
 }
 
-type StatusRequest struct {
+// Models currently loaded in Ollama
+type RunningModelsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
+	Models        []*RunningModel        `protobuf:"bytes,1,rep,name=models,proto3" json:"models,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *StatusRequest) Reset() {
-	*x = StatusRequest{}
-	mi := &file_internal_api_messages_proto_msgTypes[6]
+func (x *RunningModelsResponse) Reset() {
+	*x = RunningModelsResponse{}
+	mi := &file_internal_api_messages_proto_msgTypes[25]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *StatusRequest) String() string {
+func (x *RunningModelsResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*StatusRequest) ProtoMessage() {}
+func (*RunningModelsResponse) ProtoMessage() {}
 
-func (x *StatusRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_api_messages_proto_msgTypes[6]
+func (x *RunningModelsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[25]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -531,35 +2000,43 @@ func (x *StatusRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use StatusRequest.ProtoReflect.Descriptor instead.
-func (*StatusRequest) Descriptor() ([]byte, []int) {
-	return file_internal_api_messages_proto_rawDescGZIP(), []int{6}
+// Deprecated: Use RunningModelsResponse.ProtoReflect.Descriptor instead.
+func (*RunningModelsResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{25}
 }
 
-type StatusResponse struct {
+func (x *RunningModelsResponse) GetModels() []*RunningModel {
+	if x != nil {
+		return x.Models
+	}
+	return nil
+}
+
+type RunningModel struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Healthy       bool                   `protobuf:"varint,1,opt,name=healthy,proto3" json:"healthy,omitempty"`
-	Model         string                 `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`
-	Version       string                 `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	SizeBytes     int64                  `protobuf:"varint,2,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`               // Total memory used by the model
+	VramBytes     int64                  `protobuf:"varint,3,opt,name=vram_bytes,json=vramBytes,proto3" json:"vram_bytes,omitempty"`               // Portion of size held in GPU memory
+	ExpiresAtUnix int64                  `protobuf:"varint,4,opt,name=expires_at_unix,json=expiresAtUnix,proto3" json:"expires_at_unix,omitempty"` // When Ollama will unload the model
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *StatusResponse) Reset() {
-	*x = StatusResponse{}
-	mi := &file_internal_api_messages_proto_msgTypes[7]
+func (x *RunningModel) Reset() {
+	*x = RunningModel{}
+	mi := &file_internal_api_messages_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *StatusResponse) String() string {
+func (x *RunningModel) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*StatusResponse) ProtoMessage() {}
+func (*RunningModel) ProtoMessage() {}
 
-func (x *StatusResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_api_messages_proto_msgTypes[7]
+func (x *RunningModel) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -570,55 +2047,63 @@ func (x *StatusResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use StatusResponse.ProtoReflect.Descriptor instead.
-func (*StatusResponse) Descriptor() ([]byte, []int) {
-	return file_internal_api_messages_proto_rawDescGZIP(), []int{7}
+// Deprecated: Use RunningModel.ProtoReflect.Descriptor instead.
+func (*RunningModel) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{26}
 }
 
-func (x *StatusResponse) GetHealthy() bool {
+func (x *RunningModel) GetName() string {
 	if x != nil {
-		return x.Healthy
+		return x.Name
 	}
-	return false
+	return ""
 }
 
-func (x *StatusResponse) GetModel() string {
+func (x *RunningModel) GetSizeBytes() int64 {
 	if x != nil {
-		return x.Model
+		return x.SizeBytes
 	}
-	return ""
+	return 0
 }
 
-func (x *StatusResponse) GetVersion() string {
+func (x *RunningModel) GetVramBytes() int64 {
 	if x != nil {
-		return x.Version
+		return x.VramBytes
 	}
-	return ""
+	return 0
 }
 
-type HistoryMessage struct {
+func (x *RunningModel) GetExpiresAtUnix() int64 {
+	if x != nil {
+		return x.ExpiresAtUnix
+	}
+	return 0
+}
+
+// Embeddings request/response
+type EmbedRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Role          Role                   `protobuf:"varint,1,opt,name=role,proto3,enum=craby.api.v1.Role" json:"role,omitempty"`
-	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	Input         string                 `protobuf:"bytes,1,opt,name=input,proto3" json:"input,omitempty"`
+	Model         string                 `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"` // Optional override of the daemon's embedding model
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *HistoryMessage) Reset() {
-	*x = HistoryMessage{}
-	mi := &file_internal_api_messages_proto_msgTypes[8]
+func (x *EmbedRequest) Reset() {
+	*x = EmbedRequest{}
+	mi := &file_internal_api_messages_proto_msgTypes[27]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *HistoryMessage) String() string {
+func (x *EmbedRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*HistoryMessage) ProtoMessage() {}
+func (*EmbedRequest) ProtoMessage() {}
 
-func (x *HistoryMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_api_messages_proto_msgTypes[8]
+func (x *EmbedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[27]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -629,47 +2114,48 @@ func (x *HistoryMessage) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use HistoryMessage.ProtoReflect.Descriptor instead.
-func (*HistoryMessage) Descriptor() ([]byte, []int) {
-	return file_internal_api_messages_proto_rawDescGZIP(), []int{8}
+// Deprecated: Use EmbedRequest.ProtoReflect.Descriptor instead.
+func (*EmbedRequest) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{27}
 }
 
-func (x *HistoryMessage) GetRole() Role {
+func (x *EmbedRequest) GetInput() string {
 	if x != nil {
-		return x.Role
+		return x.Input
 	}
-	return Role_ASSISTANT
+	return ""
 }
 
-func (x *HistoryMessage) GetContent() string {
+func (x *EmbedRequest) GetModel() string {
 	if x != nil {
-		return x.Content
+		return x.Model
 	}
 	return ""
 }
 
-type HistoryResponse struct {
+type EmbedResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Messages      []*HistoryMessage      `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	Embedding     []float32              `protobuf:"fixed32,1,rep,packed,name=embedding,proto3" json:"embedding,omitempty"`
+	Model         string                 `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *HistoryResponse) Reset() {
-	*x = HistoryResponse{}
-	mi := &file_internal_api_messages_proto_msgTypes[9]
+func (x *EmbedResponse) Reset() {
+	*x = EmbedResponse{}
+	mi := &file_internal_api_messages_proto_msgTypes[28]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *HistoryResponse) String() string {
+func (x *EmbedResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*HistoryResponse) ProtoMessage() {}
+func (*EmbedResponse) ProtoMessage() {}
 
-func (x *HistoryResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_api_messages_proto_msgTypes[9]
+func (x *EmbedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[28]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -680,40 +2166,51 @@ func (x *HistoryResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use HistoryResponse.ProtoReflect.Descriptor instead.
-func (*HistoryResponse) Descriptor() ([]byte, []int) {
-	return file_internal_api_messages_proto_rawDescGZIP(), []int{9}
+// Deprecated: Use EmbedResponse.ProtoReflect.Descriptor instead.
+func (*EmbedResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{28}
 }
 
-func (x *HistoryResponse) GetMessages() []*HistoryMessage {
+func (x *EmbedResponse) GetEmbedding() []float32 {
 	if x != nil {
-		return x.Messages
+		return x.Embedding
 	}
 	return nil
 }
 
-type ContextRequest struct {
+func (x *EmbedResponse) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+// Build information
+type VersionResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Context       string                 `protobuf:"bytes,1,opt,name=context,proto3" json:"context,omitempty"`
+	Version       string                 `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	Commit        string                 `protobuf:"bytes,2,opt,name=commit,proto3" json:"commit,omitempty"`                        // Empty if unknown
+	BuildDate     string                 `protobuf:"bytes,3,opt,name=build_date,json=buildDate,proto3" json:"build_date,omitempty"` // RFC 3339, empty if unknown
+	Protocol      int32                  `protobuf:"varint,4,opt,name=protocol,proto3" json:"protocol,omitempty"`                   // Revision of the client-daemon protocol (0 = daemon predates protocol revisions)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ContextRequest) Reset() {
-	*x = ContextRequest{}
-	mi := &file_internal_api_messages_proto_msgTypes[10]
+func (x *VersionResponse) Reset() {
+	*x = VersionResponse{}
+	mi := &file_internal_api_messages_proto_msgTypes[29]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ContextRequest) String() string {
+func (x *VersionResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ContextRequest) ProtoMessage() {}
+func (*VersionResponse) ProtoMessage() {}
 
-func (x *ContextRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_api_messages_proto_msgTypes[10]
+func (x *VersionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[29]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -724,86 +2221,62 @@ func (x *ContextRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ContextRequest.ProtoReflect.Descriptor instead.
-func (*ContextRequest) Descriptor() ([]byte, []int) {
-	return file_internal_api_messages_proto_rawDescGZIP(), []int{10}
+// Deprecated: Use VersionResponse.ProtoReflect.Descriptor instead.
+func (*VersionResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{29}
 }
 
-func (x *ContextRequest) GetContext() string {
+func (x *VersionResponse) GetVersion() string {
 	if x != nil {
-		return x.Context
+		return x.Version
 	}
 	return ""
 }
 
-type ContextResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Context       string                 `protobuf:"bytes,1,opt,name=context,proto3" json:"context,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
-}
-
-func (x *ContextResponse) Reset() {
-	*x = ContextResponse{}
-	mi := &file_internal_api_messages_proto_msgTypes[11]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
-}
-
-func (x *ContextResponse) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*ContextResponse) ProtoMessage() {}
-
-func (x *ContextResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_api_messages_proto_msgTypes[11]
+func (x *VersionResponse) GetCommit() string {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.Commit
 	}
-	return mi.MessageOf(x)
+	return ""
 }
 
-// Deprecated: Use ContextResponse.ProtoReflect.Descriptor instead.
-func (*ContextResponse) Descriptor() ([]byte, []int) {
-	return file_internal_api_messages_proto_rawDescGZIP(), []int{11}
+func (x *VersionResponse) GetBuildDate() string {
+	if x != nil {
+		return x.BuildDate
+	}
+	return ""
 }
 
-func (x *ContextResponse) GetContext() string {
+func (x *VersionResponse) GetProtocol() int32 {
 	if x != nil {
-		return x.Context
+		return x.Protocol
 	}
-	return ""
+	return 0
 }
 
-// Tool execution request/response
-type ToolRunRequest struct {
+// Model switch request/response
+type ModelRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Arguments     string                 `protobuf:"bytes,2,opt,name=arguments,proto3" json:"arguments,omitempty"` // JSON string of key-value pairs
+	Model         string                 `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ToolRunRequest) Reset() {
-	*x = ToolRunRequest{}
-	mi := &file_internal_api_messages_proto_msgTypes[12]
+func (x *ModelRequest) Reset() {
+	*x = ModelRequest{}
+	mi := &file_internal_api_messages_proto_msgTypes[30]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ToolRunRequest) String() string {
+func (x *ModelRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ToolRunRequest) ProtoMessage() {}
+func (*ModelRequest) ProtoMessage() {}
 
-func (x *ToolRunRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_api_messages_proto_msgTypes[12]
+func (x *ModelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[30]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -814,49 +2287,42 @@ func (x *ToolRunRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ToolRunRequest.ProtoReflect.Descriptor instead.
-func (*ToolRunRequest) Descriptor() ([]byte, []int) {
-	return file_internal_api_messages_proto_rawDescGZIP(), []int{12}
-}
-
-func (x *ToolRunRequest) GetName() string {
-	if x != nil {
-		return x.Name
-	}
-	return ""
+// Deprecated: Use ModelRequest.ProtoReflect.Descriptor instead.
+func (*ModelRequest) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{30}
 }
 
-func (x *ToolRunRequest) GetArguments() string {
+func (x *ModelRequest) GetModel() string {
 	if x != nil {
-		return x.Arguments
+		return x.Model
 	}
 	return ""
 }
 
-type ToolRunResponse struct {
+type ModelResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Output        string                 `protobuf:"bytes,1,opt,name=output,proto3" json:"output,omitempty"`
-	Success       bool                   `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
-	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	Model         string                 `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`         // Model used for chats after the request
+	Previous      string                 `protobuf:"bytes,2,opt,name=previous,proto3" json:"previous,omitempty"`   // Model before the switch (empty when unchanged)
+	Available     []string               `protobuf:"bytes,3,rep,name=available,proto3" json:"available,omitempty"` // Models pulled into Ollama, set when the requested model isn't one of them
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ToolRunResponse) Reset() {
-	*x = ToolRunResponse{}
-	mi := &file_internal_api_messages_proto_msgTypes[13]
+func (x *ModelResponse) Reset() {
+	*x = ModelResponse{}
+	mi := &file_internal_api_messages_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ToolRunResponse) String() string {
+func (x *ModelResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ToolRunResponse) ProtoMessage() {}
+func (*ModelResponse) ProtoMessage() {}
 
-func (x *ToolRunResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_api_messages_proto_msgTypes[13]
+func (x *ModelResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -867,54 +2333,55 @@ func (x *ToolRunResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ToolRunResponse.ProtoReflect.Descriptor instead.
-func (*ToolRunResponse) Descriptor() ([]byte, []int) {
-	return file_internal_api_messages_proto_rawDescGZIP(), []int{13}
+// Deprecated: Use ModelResponse.ProtoReflect.Descriptor instead.
+func (*ModelResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{31}
 }
 
-func (x *ToolRunResponse) GetOutput() string {
+func (x *ModelResponse) GetModel() string {
 	if x != nil {
-		return x.Output
+		return x.Model
 	}
 	return ""
 }
 
-func (x *ToolRunResponse) GetSuccess() bool {
+func (x *ModelResponse) GetPrevious() string {
 	if x != nil {
-		return x.Success
+		return x.Previous
 	}
-	return false
+	return ""
 }
 
-func (x *ToolRunResponse) GetError() string {
+func (x *ModelResponse) GetAvailable() []string {
 	if x != nil {
-		return x.Error
+		return x.Available
 	}
-	return ""
+	return nil
 }
 
-type ToolListResponse struct {
+// Cancel a session's running generation
+type CancelRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Tools         []*ToolInfo            `protobuf:"bytes,1,rep,name=tools,proto3" json:"tools,omitempty"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ToolListResponse) Reset() {
-	*x = ToolListResponse{}
-	mi := &file_internal_api_messages_proto_msgTypes[14]
+func (x *CancelRequest) Reset() {
+	*x = CancelRequest{}
+	mi := &file_internal_api_messages_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ToolListResponse) String() string {
+func (x *CancelRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ToolListResponse) ProtoMessage() {}
+func (*CancelRequest) ProtoMessage() {}
 
-func (x *ToolListResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_api_messages_proto_msgTypes[14]
+func (x *CancelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -925,41 +2392,40 @@ func (x *ToolListResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ToolListResponse.ProtoReflect.Descriptor instead.
-func (*ToolListResponse) Descriptor() ([]byte, []int) {
-	return file_internal_api_messages_proto_rawDescGZIP(), []int{14}
+// Deprecated: Use CancelRequest.ProtoReflect.Descriptor instead.
+func (*CancelRequest) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{32}
 }
 
-func (x *ToolListResponse) GetTools() []*ToolInfo {
+func (x *CancelRequest) GetSessionId() string {
 	if x != nil {
-		return x.Tools
+		return x.SessionId
 	}
-	return nil
+	return ""
 }
 
-type ToolInfo struct {
+type CancelResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Description   string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	Canceled      bool                   `protobuf:"varint,1,opt,name=canceled,proto3" json:"canceled,omitempty"` // False if the session had nothing generating
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ToolInfo) Reset() {
-	*x = ToolInfo{}
-	mi := &file_internal_api_messages_proto_msgTypes[15]
+func (x *CancelResponse) Reset() {
+	*x = CancelResponse{}
+	mi := &file_internal_api_messages_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ToolInfo) String() string {
+func (x *CancelResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ToolInfo) ProtoMessage() {}
+func (*CancelResponse) ProtoMessage() {}
 
-func (x *ToolInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_api_messages_proto_msgTypes[15]
+func (x *CancelResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_messages_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -970,43 +2436,70 @@ func (x *ToolInfo) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ToolInfo.ProtoReflect.Descriptor instead.
-func (*ToolInfo) Descriptor() ([]byte, []int) {
-	return file_internal_api_messages_proto_rawDescGZIP(), []int{15}
-}
-
-func (x *ToolInfo) GetName() string {
-	if x != nil {
-		return x.Name
-	}
-	return ""
+// Deprecated: Use CancelResponse.ProtoReflect.Descriptor instead.
+func (*CancelResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_messages_proto_rawDescGZIP(), []int{33}
 }
 
-func (x *ToolInfo) GetDescription() string {
+func (x *CancelResponse) GetCanceled() bool {
 	if x != nil {
-		return x.Description
+		return x.Canceled
 	}
-	return ""
+	return false
 }
 
 var File_internal_api_messages_proto protoreflect.FileDescriptor
 
 const file_internal_api_messages_proto_rawDesc = "" +
 	"\n" +
-	"\x1binternal/api/messages.proto\x12\fcraby.api.v1\"F\n" +
+	"\x1binternal/api/messages.proto\x12\fcraby.api.v1\"\xa5\x03\n" +
 	"\vChatRequest\x12\x18\n" +
 	"\amessage\x18\x01 \x01(\tR\amessage\x12\x1d\n" +
 	"\n" +
-	"session_id\x18\x02 \x01(\tR\tsessionId\"\xad\x02\n" +
+	"session_id\x18\x02 \x01(\tR\tsessionId\x12\x16\n" +
+	"\x06format\x18\x03 \x01(\tR\x06format\x12\x18\n" +
+	"\apersona\x18\x04 \x01(\tR\apersona\x12#\n" +
+	"\rsystem_prompt\x18\x05 \x01(\tR\fsystemPrompt\x12\x19\n" +
+	"\bno_tools\x18\x06 \x01(\bR\anoTools\x12%\n" +
+	"\x0edisabled_tools\x18\a \x03(\tR\rdisabledTools\x12#\n" +
+	"\rprompt_prefix\x18\b \x01(\tR\fpromptPrefix\x12#\n" +
+	"\rprompt_suffix\x18\t \x01(\tR\fpromptSuffix\x12\x14\n" +
+	"\x05trace\x18\n" +
+	" \x01(\bR\x05trace\x12#\n" +
+	"\rapprove_plans\x18\v \x01(\bR\fapprovePlans\x12?\n" +
+	"\rplan_decision\x18\f \x01(\v2\x1a.craby.api.v1.PlanDecisionR\fplanDecision\"*\n" +
+	"\fPlanDecision\x12\x1a\n" +
+	"\bapproved\x18\x01 \x01(\bR\bapproved\"\xe0\x06\n" +
 	"\fChatResponse\x12-\n" +
 	"\x04text\x18\x01 \x01(\v2\x17.craby.api.v1.TextChunkH\x00R\x04text\x125\n" +
 	"\ttool_call\x18\x02 \x01(\v2\x16.craby.api.v1.ToolCallH\x00R\btoolCall\x12;\n" +
 	"\vtool_result\x18\x03 \x01(\v2\x18.craby.api.v1.ToolResultH\x00R\n" +
 	"toolResult\x12\x14\n" +
-	"\x04done\x18\x04 \x01(\bH\x00R\x04done\x12\x16\n" +
-	"\x05error\x18\x05 \x01(\tH\x00R\x05error\x12A\n" +
-	"\rshell_command\x18\x06 \x01(\v2\x1a.craby.api.v1.ShellCommandH\x00R\fshellCommandB\t\n" +
-	"\apayload\"K\n" +
+	"\x04done\x18\x04 \x01(\bH\x00R\x04done\x12A\n" +
+	"\rshell_command\x18\x06 \x01(\v2\x1a.craby.api.v1.ShellCommandH\x00R\fshellCommand\x12\x1c\n" +
+	"\bthinking\x18\b \x01(\tH\x00R\bthinking\x12#\n" +
+	"\fshell_output\x18\v \x01(\tH\x00R\vshellOutput\x12'\n" +
+	"\x0equeue_position\x18\x0f \x01(\x05H\x00R\rqueuePosition\x12/\n" +
+	"\x05error\x18\x10 \x01(\v2\x17.craby.api.v1.ChatErrorH\x00R\x05error\x12D\n" +
+	"\x0ediscovery_step\x18\x11 \x01(\v2\x1b.craby.api.v1.DiscoveryStepH\x00R\rdiscoveryStep\x12(\n" +
+	"\x04plan\x18\x12 \x01(\v2\x12.craby.api.v1.PlanH\x00R\x04plan\x129\n" +
+	"\rplan_approval\x18\x13 \x01(\v2\x12.craby.api.v1.PlanH\x00R\fplanApproval\x120\n" +
+	"\x14rate_limit_remaining\x18\a \x01(\x05R\x12rateLimitRemaining\x12\x14\n" +
+	"\x05model\x18\t \x01(\tR\x05model\x12\x1a\n" +
+	"\bfallback\x18\n" +
+	" \x01(\bR\bfallback\x12#\n" +
+	"\rprompt_tokens\x18\f \x01(\x05R\fpromptTokens\x12+\n" +
+	"\x11completion_tokens\x18\r \x01(\x05R\x10completionTokens\x12(\n" +
+	"\x10eval_duration_ms\x18\x0e \x01(\x03R\x0eevalDurationMs\x12!\n" +
+	"\flegacy_error\x18\x05 \x01(\tR\vlegacyErrorB\t\n" +
+	"\apayload\"j\n" +
+	"\tChatError\x12+\n" +
+	"\x04code\x18\x01 \x01(\x0e2\x17.craby.api.v1.ErrorCodeR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x16\n" +
+	"\x06detail\x18\x03 \x01(\tR\x06detail\"C\n" +
+	"\rDiscoveryStep\x12\x18\n" +
+	"\acommand\x18\x01 \x01(\tR\acommand\x12\x18\n" +
+	"\asummary\x18\x02 \x01(\tR\asummary\"K\n" +
 	"\fShellCommand\x12\x18\n" +
 	"\acommand\x18\x01 \x01(\tR\acommand\x12!\n" +
 	"\fis_discovery\x18\x02 \x01(\bR\visDiscovery\"M\n" +
@@ -1016,18 +2509,61 @@ const file_internal_api_messages_proto_rawDesc = "" +
 	"\bToolCall\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
 	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1c\n" +
-	"\targuments\x18\x03 \x01(\tR\targuments\"b\n" +
+	"\targuments\x18\x03 \x01(\tR\targuments\"\x94\x01\n" +
+	"\x04Plan\x12\x16\n" +
+	"\x06intent\x18\x01 \x01(\tR\x06intent\x12\x1e\n" +
+	"\n" +
+	"complexity\x18\x02 \x01(\tR\n" +
+	"complexity\x12&\n" +
+	"\x0fready_to_answer\x18\x03 \x01(\bR\rreadyToAnswer\x12,\n" +
+	"\x05steps\x18\x04 \x03(\v2\x16.craby.api.v1.PlanStepR\x05steps\"f\n" +
+	"\bPlanStep\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04tool\x18\x02 \x01(\tR\x04tool\x12\x18\n" +
+	"\apurpose\x18\x03 \x01(\tR\apurpose\x12\x1c\n" +
+	"\targuments\x18\x04 \x01(\tR\targuments\"\x83\x01\n" +
 	"\n" +
 	"ToolResult\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
 	"\x04name\x18\x02 \x01(\tR\x04name\x12\x16\n" +
 	"\x06output\x18\x03 \x01(\tR\x06output\x12\x18\n" +
-	"\asuccess\x18\x04 \x01(\bR\asuccess\"\x0f\n" +
-	"\rStatusRequest\"Z\n" +
+	"\asuccess\x18\x04 \x01(\bR\asuccess\x12\x1f\n" +
+	"\vduration_ms\x18\x05 \x01(\x03R\n" +
+	"durationMs\"\x0f\n" +
+	"\rStatusRequest\"\x86\x06\n" +
 	"\x0eStatusResponse\x12\x18\n" +
 	"\ahealthy\x18\x01 \x01(\bR\ahealthy\x12\x14\n" +
 	"\x05model\x18\x02 \x01(\tR\x05model\x12\x18\n" +
-	"\aversion\x18\x03 \x01(\tR\aversion\"R\n" +
+	"\aversion\x18\x03 \x01(\tR\aversion\x12%\n" +
+	"\x0euptime_seconds\x18\x04 \x01(\x03R\ruptimeSeconds\x12-\n" +
+	"\x12active_connections\x18\x05 \x01(\x05R\x11activeConnections\x12!\n" +
+	"\fchats_served\x18\x06 \x01(\x03R\vchatsServed\x12\x1d\n" +
+	"\n" +
+	"ollama_url\x18\a \x01(\tR\tollamaUrl\x121\n" +
+	"\x06models\x18\b \x03(\v2\x19.craby.api.v1.ModelStatusR\x06models\x12)\n" +
+	"\x10ollama_reachable\x18\t \x01(\bR\x0follamaReachable\x12#\n" +
+	"\rmodel_present\x18\n" +
+	" \x01(\bR\fmodelPresent\x12,\n" +
+	"\x12commands_in_flight\x18\v \x01(\x05R\x10commandsInFlight\x12\x16\n" +
+	"\x06commit\x18\f \x01(\tR\x06commit\x12\x1d\n" +
+	"\n" +
+	"build_date\x18\r \x01(\tR\tbuildDate\x12!\n" +
+	"\fchats_queued\x18\x0e \x01(\x05R\vchatsQueued\x12B\n" +
+	"\x0fembedding_model\x18\x0f \x01(\v2\x19.craby.api.v1.ModelStatusR\x0eembeddingModel\x12/\n" +
+	"\x14tool_calls_in_flight\x18\x10 \x01(\x05R\x11toolCallsInFlight\x12,\n" +
+	"\x12tool_calls_waiting\x18\x11 \x01(\x05R\x10toolCallsWaiting\x12,\n" +
+	"\x12tool_call_capacity\x18\x12 \x01(\x05R\x10toolCallCapacity\x126\n" +
+	"\n" +
+	"tool_stats\x18\x13 \x03(\v2\x17.craby.api.v1.ToolStatsR\ttoolStats\"\xa1\x01\n" +
+	"\tToolStats\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05calls\x18\x02 \x01(\x03R\x05calls\x12\x16\n" +
+	"\x06errors\x18\x03 \x01(\x03R\x06errors\x12*\n" +
+	"\x11total_duration_ms\x18\x04 \x01(\x03R\x0ftotalDurationMs\x12&\n" +
+	"\x0fmax_duration_ms\x18\x05 \x01(\x03R\rmaxDurationMs\"?\n" +
+	"\vModelStatus\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1c\n" +
+	"\tavailable\x18\x02 \x01(\bR\tavailable\"R\n" +
 	"\x0eHistoryMessage\x12&\n" +
 	"\x04role\x18\x01 \x01(\x0e2\x12.craby.api.v1.RoleR\x04role\x12\x18\n" +
 	"\acontent\x18\x02 \x01(\tR\acontent\"K\n" +
@@ -1048,7 +2584,57 @@ const file_internal_api_messages_proto_rawDesc = "" +
 	"\x05tools\x18\x01 \x03(\v2\x16.craby.api.v1.ToolInfoR\x05tools\"@\n" +
 	"\bToolInfo\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12 \n" +
-	"\vdescription\x18\x02 \x01(\tR\vdescription*+\n" +
+	"\vdescription\x18\x02 \x01(\tR\vdescription\"s\n" +
+	"\x12ToolPromptResponse\x12\x16\n" +
+	"\x06prompt\x18\x01 \x01(\tR\x06prompt\x12E\n" +
+	"\x0ecached_schemas\x18\x02 \x03(\v2\x1e.craby.api.v1.CachedSchemaInfoR\rcachedSchemas\"\x8c\x01\n" +
+	"\x10CachedSchemaInfo\x12\x18\n" +
+	"\acommand\x18\x01 \x01(\tR\acommand\x12*\n" +
+	"\x11generated_at_unix\x18\x02 \x01(\x03R\x0fgeneratedAtUnix\x12\x18\n" +
+	"\aexpired\x18\x03 \x01(\bR\aexpired\x12\x18\n" +
+	"\asummary\x18\x04 \x01(\tR\asummary\"K\n" +
+	"\x15RunningModelsResponse\x122\n" +
+	"\x06models\x18\x01 \x03(\v2\x1a.craby.api.v1.RunningModelR\x06models\"\x88\x01\n" +
+	"\fRunningModel\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1d\n" +
+	"\n" +
+	"size_bytes\x18\x02 \x01(\x03R\tsizeBytes\x12\x1d\n" +
+	"\n" +
+	"vram_bytes\x18\x03 \x01(\x03R\tvramBytes\x12&\n" +
+	"\x0fexpires_at_unix\x18\x04 \x01(\x03R\rexpiresAtUnix\":\n" +
+	"\fEmbedRequest\x12\x14\n" +
+	"\x05input\x18\x01 \x01(\tR\x05input\x12\x14\n" +
+	"\x05model\x18\x02 \x01(\tR\x05model\"C\n" +
+	"\rEmbedResponse\x12\x1c\n" +
+	"\tembedding\x18\x01 \x03(\x02R\tembedding\x12\x14\n" +
+	"\x05model\x18\x02 \x01(\tR\x05model\"~\n" +
+	"\x0fVersionResponse\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\tR\aversion\x12\x16\n" +
+	"\x06commit\x18\x02 \x01(\tR\x06commit\x12\x1d\n" +
+	"\n" +
+	"build_date\x18\x03 \x01(\tR\tbuildDate\x12\x1a\n" +
+	"\bprotocol\x18\x04 \x01(\x05R\bprotocol\"$\n" +
+	"\fModelRequest\x12\x14\n" +
+	"\x05model\x18\x01 \x01(\tR\x05model\"_\n" +
+	"\rModelResponse\x12\x14\n" +
+	"\x05model\x18\x01 \x01(\tR\x05model\x12\x1a\n" +
+	"\bprevious\x18\x02 \x01(\tR\bprevious\x12\x1c\n" +
+	"\tavailable\x18\x03 \x03(\tR\tavailable\".\n" +
+	"\rCancelRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\",\n" +
+	"\x0eCancelResponse\x12\x1a\n" +
+	"\bcanceled\x18\x01 \x01(\bR\bcanceled*\xb1\x01\n" +
+	"\tErrorCode\x12\x11\n" +
+	"\rERROR_UNKNOWN\x10\x00\x12\x19\n" +
+	"\x15ERROR_INVALID_REQUEST\x10\x01\x12\x16\n" +
+	"\x12ERROR_RATE_LIMITED\x10\x02\x12\x15\n" +
+	"\x11ERROR_SERVER_BUSY\x10\x03\x12\x0f\n" +
+	"\vERROR_MODEL\x10\x04\x12\x0e\n" +
+	"\n" +
+	"ERROR_TOOL\x10\x05\x12\x12\n" +
+	"\x0eERROR_INTERNAL\x10\x06\x12\x12\n" +
+	"\x0eERROR_CANCELED\x10\a*+\n" +
 	"\x04Role\x12\r\n" +
 	"\tASSISTANT\x10\x00\x12\n" +
 	"\n" +
@@ -1067,41 +2653,72 @@ func file_internal_api_messages_proto_rawDescGZIP() []byte {
 	return file_internal_api_messages_proto_rawDescData
 }
 
-var file_internal_api_messages_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_internal_api_messages_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_internal_api_messages_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_internal_api_messages_proto_msgTypes = make([]protoimpl.MessageInfo, 34)
 var file_internal_api_messages_proto_goTypes = []any{
-	(Role)(0),                // 0: craby.api.v1.Role
-	(*ChatRequest)(nil),      // 1: craby.api.v1.ChatRequest
-	(*ChatResponse)(nil),     // 2: craby.api.v1.ChatResponse
-	(*ShellCommand)(nil),     // 3: craby.api.v1.ShellCommand
-	(*TextChunk)(nil),        // 4: craby.api.v1.TextChunk
-	(*ToolCall)(nil),         // 5: craby.api.v1.ToolCall
-	(*ToolResult)(nil),       // 6: craby.api.v1.ToolResult
-	(*StatusRequest)(nil),    // 7: craby.api.v1.StatusRequest
-	(*StatusResponse)(nil),   // 8: craby.api.v1.StatusResponse
-	(*HistoryMessage)(nil),   // 9: craby.api.v1.HistoryMessage
-	(*HistoryResponse)(nil),  // 10: craby.api.v1.HistoryResponse
-	(*ContextRequest)(nil),   // 11: craby.api.v1.ContextRequest
-	(*ContextResponse)(nil),  // 12: craby.api.v1.ContextResponse
-	(*ToolRunRequest)(nil),   // 13: craby.api.v1.ToolRunRequest
-	(*ToolRunResponse)(nil),  // 14: craby.api.v1.ToolRunResponse
-	(*ToolListResponse)(nil), // 15: craby.api.v1.ToolListResponse
-	(*ToolInfo)(nil),         // 16: craby.api.v1.ToolInfo
+	(ErrorCode)(0),                // 0: craby.api.v1.ErrorCode
+	(Role)(0),                     // 1: craby.api.v1.Role
+	(*ChatRequest)(nil),           // 2: craby.api.v1.ChatRequest
+	(*PlanDecision)(nil),          // 3: craby.api.v1.PlanDecision
+	(*ChatResponse)(nil),          // 4: craby.api.v1.ChatResponse
+	(*ChatError)(nil),             // 5: craby.api.v1.ChatError
+	(*DiscoveryStep)(nil),         // 6: craby.api.v1.DiscoveryStep
+	(*ShellCommand)(nil),          // 7: craby.api.v1.ShellCommand
+	(*TextChunk)(nil),             // 8: craby.api.v1.TextChunk
+	(*ToolCall)(nil),              // 9: craby.api.v1.ToolCall
+	(*Plan)(nil),                  // 10: craby.api.v1.Plan
+	(*PlanStep)(nil),              // 11: craby.api.v1.PlanStep
+	(*ToolResult)(nil),            // 12: craby.api.v1.ToolResult
+	(*StatusRequest)(nil),         // 13: craby.api.v1.StatusRequest
+	(*StatusResponse)(nil),        // 14: craby.api.v1.StatusResponse
+	(*ToolStats)(nil),             // 15: craby.api.v1.ToolStats
+	(*ModelStatus)(nil),           // 16: craby.api.v1.ModelStatus
+	(*HistoryMessage)(nil),        // 17: craby.api.v1.HistoryMessage
+	(*HistoryResponse)(nil),       // 18: craby.api.v1.HistoryResponse
+	(*ContextRequest)(nil),        // 19: craby.api.v1.ContextRequest
+	(*ContextResponse)(nil),       // 20: craby.api.v1.ContextResponse
+	(*ToolRunRequest)(nil),        // 21: craby.api.v1.ToolRunRequest
+	(*ToolRunResponse)(nil),       // 22: craby.api.v1.ToolRunResponse
+	(*ToolListResponse)(nil),      // 23: craby.api.v1.ToolListResponse
+	(*ToolInfo)(nil),              // 24: craby.api.v1.ToolInfo
+	(*ToolPromptResponse)(nil),    // 25: craby.api.v1.ToolPromptResponse
+	(*CachedSchemaInfo)(nil),      // 26: craby.api.v1.CachedSchemaInfo
+	(*RunningModelsResponse)(nil), // 27: craby.api.v1.RunningModelsResponse
+	(*RunningModel)(nil),          // 28: craby.api.v1.RunningModel
+	(*EmbedRequest)(nil),          // 29: craby.api.v1.EmbedRequest
+	(*EmbedResponse)(nil),         // 30: craby.api.v1.EmbedResponse
+	(*VersionResponse)(nil),       // 31: craby.api.v1.VersionResponse
+	(*ModelRequest)(nil),          // 32: craby.api.v1.ModelRequest
+	(*ModelResponse)(nil),         // 33: craby.api.v1.ModelResponse
+	(*CancelRequest)(nil),         // 34: craby.api.v1.CancelRequest
+	(*CancelResponse)(nil),        // 35: craby.api.v1.CancelResponse
 }
 var file_internal_api_messages_proto_depIdxs = []int32{
-	4,  // 0: craby.api.v1.ChatResponse.text:type_name -> craby.api.v1.TextChunk
-	5,  // 1: craby.api.v1.ChatResponse.tool_call:type_name -> craby.api.v1.ToolCall
-	6,  // 2: craby.api.v1.ChatResponse.tool_result:type_name -> craby.api.v1.ToolResult
-	3,  // 3: craby.api.v1.ChatResponse.shell_command:type_name -> craby.api.v1.ShellCommand
-	0,  // 4: craby.api.v1.TextChunk.role:type_name -> craby.api.v1.Role
-	0,  // 5: craby.api.v1.HistoryMessage.role:type_name -> craby.api.v1.Role
-	9,  // 6: craby.api.v1.HistoryResponse.messages:type_name -> craby.api.v1.HistoryMessage
-	16, // 7: craby.api.v1.ToolListResponse.tools:type_name -> craby.api.v1.ToolInfo
-	8,  // [8:8] is the sub-list for method output_type
-	8,  // [8:8] is the sub-list for method input_type
-	8,  // [8:8] is the sub-list for extension type_name
-	8,  // [8:8] is the sub-list for extension extendee
-	0,  // [0:8] is the sub-list for field type_name
+	3,  // 0: craby.api.v1.ChatRequest.plan_decision:type_name -> craby.api.v1.PlanDecision
+	8,  // 1: craby.api.v1.ChatResponse.text:type_name -> craby.api.v1.TextChunk
+	9,  // 2: craby.api.v1.ChatResponse.tool_call:type_name -> craby.api.v1.ToolCall
+	12, // 3: craby.api.v1.ChatResponse.tool_result:type_name -> craby.api.v1.ToolResult
+	7,  // 4: craby.api.v1.ChatResponse.shell_command:type_name -> craby.api.v1.ShellCommand
+	5,  // 5: craby.api.v1.ChatResponse.error:type_name -> craby.api.v1.ChatError
+	6,  // 6: craby.api.v1.ChatResponse.discovery_step:type_name -> craby.api.v1.DiscoveryStep
+	10, // 7: craby.api.v1.ChatResponse.plan:type_name -> craby.api.v1.Plan
+	10, // 8: craby.api.v1.ChatResponse.plan_approval:type_name -> craby.api.v1.Plan
+	0,  // 9: craby.api.v1.ChatError.code:type_name -> craby.api.v1.ErrorCode
+	1,  // 10: craby.api.v1.TextChunk.role:type_name -> craby.api.v1.Role
+	11, // 11: craby.api.v1.Plan.steps:type_name -> craby.api.v1.PlanStep
+	16, // 12: craby.api.v1.StatusResponse.models:type_name -> craby.api.v1.ModelStatus
+	16, // 13: craby.api.v1.StatusResponse.embedding_model:type_name -> craby.api.v1.ModelStatus
+	15, // 14: craby.api.v1.StatusResponse.tool_stats:type_name -> craby.api.v1.ToolStats
+	1,  // 15: craby.api.v1.HistoryMessage.role:type_name -> craby.api.v1.Role
+	17, // 16: craby.api.v1.HistoryResponse.messages:type_name -> craby.api.v1.HistoryMessage
+	24, // 17: craby.api.v1.ToolListResponse.tools:type_name -> craby.api.v1.ToolInfo
+	26, // 18: craby.api.v1.ToolPromptResponse.cached_schemas:type_name -> craby.api.v1.CachedSchemaInfo
+	28, // 19: craby.api.v1.RunningModelsResponse.models:type_name -> craby.api.v1.RunningModel
+	20, // [20:20] is the sub-list for method output_type
+	20, // [20:20] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
 }
 
 func init() { file_internal_api_messages_proto_init() }
@@ -1109,21 +2726,27 @@ func file_internal_api_messages_proto_init() {
 	if File_internal_api_messages_proto != nil {
 		return
 	}
-	file_internal_api_messages_proto_msgTypes[1].OneofWrappers = []any{
+	file_internal_api_messages_proto_msgTypes[2].OneofWrappers = []any{
 		(*ChatResponse_Text)(nil),
 		(*ChatResponse_ToolCall)(nil),
 		(*ChatResponse_ToolResult)(nil),
 		(*ChatResponse_Done)(nil),
-		(*ChatResponse_Error)(nil),
 		(*ChatResponse_ShellCommand)(nil),
+		(*ChatResponse_Thinking)(nil),
+		(*ChatResponse_ShellOutput)(nil),
+		(*ChatResponse_QueuePosition)(nil),
+		(*ChatResponse_Error)(nil),
+		(*ChatResponse_DiscoveryStep)(nil),
+		(*ChatResponse_Plan)(nil),
+		(*ChatResponse_PlanApproval)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_api_messages_proto_rawDesc), len(file_internal_api_messages_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   16,
+			NumEnums:      2,
+			NumMessages:   34,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -2,10 +2,16 @@ package client
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"os"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -13,6 +19,8 @@ import (
 	"github.com/charmbracelet/glamour"
 	"github.com/gorilla/websocket"
 	"github.com/marciniwanicki/craby/internal/api"
+	"github.com/marciniwanicki/craby/internal/version"
+	"golang.org/x/term"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -35,23 +43,216 @@ const (
 	VerbosityVerbose                  // Show everything including tool details
 )
 
+// reconnectBackoff is how long to wait before reconnecting after a dropped connection
+const reconnectBackoff = 500 * time.Millisecond
+
+// DefaultHealthTimeout bounds the daemon health probe so a hung daemon isn't mistaken for a slow one
+const DefaultHealthTimeout = 2 * time.Second
+
+// DefaultStatusTimeout bounds the status request, which includes an Ollama health check
+const DefaultStatusTimeout = 10 * time.Second
+
 // Client handles communication with the daemon
 type Client struct {
-	baseURL string
-	wsURL   string
+	baseURL    string
+	wsURL      string
+	sessionID  string
+	httpClient *http.Client
+	wsDialer   *websocket.Dialer
+
+	healthTimeout time.Duration // Bounds IsRunning and Probe
+	statusTimeout time.Duration // Bounds Status and the other quick requests
 }
 
-// NewClient creates a new client
-func NewClient(port int) *Client {
+// NewClient creates a new client for a daemon listening on addr (host:port or unix:/path/to/socket).
+// A daemon bound to all interfaces is reached over loopback.
+func NewClient(addr string) *Client {
+	dialer := &net.Dialer{Timeout: 2 * time.Second}
+	dial := dialer.DialContext
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		// The host in the URLs is a placeholder, every connection goes to the socket
+		addr = "unix"
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", path)
+		}
+	} else {
+		addr = dialAddr(addr)
+	}
 	return &Client{
-		baseURL: fmt.Sprintf("http://localhost:%d", port),
-		wsURL:   fmt.Sprintf("ws://localhost:%d", port),
+		baseURL:   "http://" + addr,
+		wsURL:     "ws://" + addr,
+		sessionID: newSessionID(),
+
+		healthTimeout: DefaultHealthTimeout,
+		statusTimeout: DefaultStatusTimeout,
+		// No overall timeout: tool runs and embeddings can take a while; callers bound them via context
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				DialContext:         dial,
+				MaxIdleConnsPerHost: 4,
+				IdleConnTimeout:     30 * time.Second,
+			},
+		},
+		wsDialer: &websocket.Dialer{
+			NetDialContext:   dial,
+			HandshakeTimeout: websocket.DefaultDialer.HandshakeTimeout,
+		},
+	}
+}
+
+// SetTimeouts changes how long the health probe and status requests wait for the daemon.
+// Zero keeps the current value.
+func (c *Client) SetTimeouts(health, status time.Duration) {
+	if health > 0 {
+		c.healthTimeout = health
+	}
+	if status > 0 {
+		c.statusTimeout = status
+	}
+}
+
+// dialAddr turns a listen address into one the client can connect to
+func dialAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
 	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// SessionID returns the session ID sent with every chat request
+func (c *Client) SessionID() string {
+	return c.sessionID
+}
+
+// newSessionID generates a random session identifier
+func newSessionID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// connectionError indicates the websocket connection failed or dropped mid-conversation
+type connectionError struct {
+	err      error
+	received bool // True if any response was received before the failure
+}
+
+func (e *connectionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *connectionError) Unwrap() error {
+	return e.err
+}
+
+func (e *connectionError) Is(target error) bool {
+	return target == ErrConnection
+}
+
+// Errors a chat can fail with, matched with errors.Is
+var (
+	ErrConnection     = errors.New("connection to daemon failed")
+	ErrNotRunning     = errors.New("daemon is not running")
+	ErrUnresponsive   = errors.New("daemon is not responding")
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrRateLimited    = errors.New("rate limited")
+	ErrServerBusy     = errors.New("server busy")
+	ErrModel          = errors.New("model failed")
+	ErrTool           = errors.New("tool failed")
+	ErrCanceled       = errors.New("generation canceled")
+)
+
+// chatErrorKinds maps daemon error codes to the errors ChatError matches
+var chatErrorKinds = map[api.ErrorCode]error{
+	api.ErrorCode_ERROR_INVALID_REQUEST: ErrInvalidRequest,
+	api.ErrorCode_ERROR_RATE_LIMITED:    ErrRateLimited,
+	api.ErrorCode_ERROR_SERVER_BUSY:     ErrServerBusy,
+	api.ErrorCode_ERROR_MODEL:           ErrModel,
+	api.ErrorCode_ERROR_TOOL:            ErrTool,
+	api.ErrorCode_ERROR_CANCELED:        ErrCanceled,
+}
+
+// ChatError is a failure the daemon reported while answering a chat.
+// Use errors.Is with ErrRateLimited, ErrModel, etc. to react to the cause.
+type ChatError struct {
+	Code    api.ErrorCode
+	Message string // Human-readable, for display
+	Detail  string // Optional extra context
+}
+
+func newChatError(e *api.ChatError) *ChatError {
+	return &ChatError{Code: e.GetCode(), Message: e.GetMessage(), Detail: e.GetDetail()}
+}
+
+func (e *ChatError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("server error: %s (%s)", e.Message, e.Detail)
+	}
+	return "server error: " + e.Message
+}
+
+func (e *ChatError) Is(target error) bool {
+	kind, ok := chatErrorKinds[e.Code]
+	return ok && kind == target
 }
 
 // ChatOptions configures chat behavior
 type ChatOptions struct {
-	Verbosity Verbosity
+	Verbosity  Verbosity
+	Raw        bool // Print answers as plain text instead of rendering markdown
+	JSON       bool // Ask for a JSON answer, validate it and print it without formatting
+	Output     OutputFormat
+	Persona    string      // Persona template the daemon answers as (empty = default identity)
+	Transcript *Transcript // Records completed exchanges (nil = not recorded)
+	NoTools    bool        // Answer without running any tools
+
+	// Replace the daemon's configured instructions around each message (empty = keep them)
+	PromptPrefix string
+	PromptSuffix string
+
+	DisabledTools []string  // Tools the daemon must not use for these chats
+	Tee           io.Writer // Also receives the output, as plain text, e.g. a log file (nil = none)
+
+	// Trace receives every step of the agent loop: plans, tool calls, raw tool output
+	// and model turns (nil = not traced). The answer is still written to the output.
+	Trace io.Writer
+
+	// ApprovePlan is asked to approve each plan that runs shell commands before any of its
+	// steps run. Rejecting one cancels the chat (nil = plans run without approval).
+	ApprovePlan func(plan *api.Plan) bool
+}
+
+// OutputFormat selects how answers are printed
+type OutputFormat string
+
+const (
+	OutputText OutputFormat = "text" // Stream the answer for people to read
+	OutputJSON OutputFormat = "json" // Print one ChatResult JSON object per answer, for scripts
+)
+
+// ChatResult is an answer printed in the JSON output format
+type ChatResult struct {
+	Content      string           `json:"content"`
+	Model        string           `json:"model"`
+	Fallback     bool             `json:"fallback,omitempty"`
+	Tokens       int32            `json:"tokens"`        // Generated tokens across all LLM calls
+	PromptTokens int32            `json:"prompt_tokens"` // Prompt tokens across all LLM calls
+	ToolCalls    []ToolCallResult `json:"tool_calls"`
+}
+
+// ToolCallResult is a tool call made while answering, in the JSON output format
+type ToolCallResult struct {
+	Name       string          `json:"name"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	Success    bool            `json:"success"`
+	DurationMs int64           `json:"duration_ms"`
+	Output     string          `json:"output,omitempty"`
 }
 
 // ANSI cursor control
@@ -71,10 +272,14 @@ type spinner struct {
 	resume   chan struct{}
 	mu       sync.Mutex
 	running  bool
+	label    string // Shown next to the spinner, guarded by mu
 	isPaused bool
 	pausedMu sync.Mutex
 }
 
+// spinnerLabel is shown while waiting for the model
+const spinnerLabel = "thinking…"
+
 func newSpinner(output io.Writer) *spinner {
 	return &spinner{
 		frames:   []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"},
@@ -84,9 +289,23 @@ func newSpinner(output io.Writer) *spinner {
 		done:     make(chan struct{}),
 		pause:    make(chan chan struct{}),
 		resume:   make(chan struct{}, 1),
+		label:    spinnerLabel,
 	}
 }
 
+// SetLabel changes the text shown next to the spinner
+func (s *spinner) SetLabel(label string) {
+	s.mu.Lock()
+	s.label = label
+	s.mu.Unlock()
+}
+
+func (s *spinner) currentLabel() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.label
+}
+
 func (s *spinner) Start() {
 	s.mu.Lock()
 	if s.running {
@@ -125,7 +344,7 @@ func (s *spinner) Start() {
 				s.pausedMu.Unlock()
 			default:
 				if !paused {
-					fmt.Fprintf(s.output, "\r%s%s %s(thinking…)%s", colorLightYellow, s.frames[i%len(s.frames)], colorGray, colorReset)
+					fmt.Fprintf(s.output, "\r\033[K%s%s %s(%s)%s", colorLightYellow, s.frames[i%len(s.frames)], colorGray, s.currentLabel(), colorReset)
 					i++
 				}
 				time.Sleep(s.interval)
@@ -167,17 +386,52 @@ func (s *spinner) Stop() {
 	<-s.done
 }
 
-// Chat sends a message and streams the response to the provided writer
+// Chat sends a message and streams the response to the provided writer.
+// If the connection drops before any response arrives, it reconnects once and replays the request.
 func (c *Client) Chat(ctx context.Context, message string, output io.Writer, opts ChatOptions) error {
-	conn, _, err := websocket.DefaultDialer.DialContext(ctx, c.wsURL+"/ws/chat", nil)
+	err := c.chat(ctx, message, output, opts)
+
+	var connErr *connectionError
+	if !errors.As(err, &connErr) || connErr.received {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return err
+	case <-time.After(reconnectBackoff):
+	}
+
+	retryErr := c.chat(ctx, message, output, opts)
+	if errors.As(retryErr, &connErr) {
+		// Reconnection failed, report the original failure
+		return err
+	}
+	return retryErr
+}
+
+// chat performs a single chat exchange over a new websocket connection
+func (c *Client) chat(ctx context.Context, message string, output io.Writer, opts ChatOptions) error {
+	conn, _, err := c.wsDialer.DialContext(ctx, c.wsURL+"/ws/chat", nil)
 	if err != nil {
-		return fmt.Errorf("failed to connect to daemon: %w", err)
+		return &connectionError{err: fmt.Errorf("failed to connect to daemon: %w", err)}
 	}
 	defer conn.Close()
 
 	// Send request
 	req := &api.ChatRequest{
-		Message: message,
+		Message:       message,
+		SessionId:     c.sessionID,
+		Persona:       opts.Persona,
+		NoTools:       opts.NoTools,
+		DisabledTools: opts.DisabledTools,
+		PromptPrefix:  opts.PromptPrefix,
+		PromptSuffix:  opts.PromptSuffix,
+		Trace:         opts.Trace != nil,
+		ApprovePlans:  opts.ApprovePlan != nil,
+	}
+	if opts.JSON {
+		req.Format = "json"
 	}
 	data, err := proto.Marshal(req)
 	if err != nil {
@@ -185,7 +439,28 @@ func (c *Client) Chat(ctx context.Context, message string, output io.Writer, opt
 	}
 
 	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return &connectionError{err: fmt.Errorf("failed to send request: %w", err)}
+	}
+	start := time.Now()
+
+	// Completed exchanges are added to the transcript, if one is kept
+	collect := newResultCollector()
+	collect.trace = newTracer(opts.Trace, start)
+	defer func() {
+		if collect.done {
+			opts.Transcript.Add(message, collect.result)
+		}
+	}()
+
+	// Scripted output prints nothing but the final result, so tool activity and the spinner are hidden
+	if opts.Tee != nil && (opts.Output == OutputJSON || opts.JSON) {
+		output = io.MultiWriter(output, opts.Tee)
+	}
+	if opts.Output == OutputJSON {
+		return readChatResult(conn, output, opts.JSON, collect, opts.ApprovePlan)
+	}
+	if opts.JSON {
+		return readJSONResponse(conn, output, collect, opts.ApprovePlan)
 	}
 
 	// Start spinner while waiting for response
@@ -200,10 +475,21 @@ func (c *Client) Chat(ctx context.Context, message string, output io.Writer, opt
 	}
 	defer stopSpinner()
 
-	// Markdown streamer for buffered rendering
-	mdStream := newMarkdownStreamer(output)
+	// Markdown is rendered only for terminals; piped, quiet and raw output stays plain text
+	render := !opts.Raw && opts.Verbosity != VerbosityQuiet && os.Getenv("NO_COLOR") == "" && isTerminal(output)
+	mdStream := newMarkdownStreamer(output, render)
+
+	// The tee gets the same output as plain text: never rendered, without colors or the spinner
+	tee := io.Discard
+	if opts.Tee != nil {
+		tee = &plainWriter{w: opts.Tee}
+	}
+	out := io.MultiWriter(output, tee)
+	teeStream := newMarkdownStreamer(tee, false)
 
 	// Read streaming response
+	received := false
+	streamedOutput := false // Shell output for the current tool was streamed live
 	for {
 		select {
 		case <-ctx.Done():
@@ -216,46 +502,82 @@ func (c *Client) Chat(ctx context.Context, message string, output io.Writer, opt
 			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
 				return nil
 			}
-			return fmt.Errorf("failed to read response: %w", err)
+			// The daemon closed the connection, e.g. while shutting down; Chat reconnects if nothing was received yet
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) && closeErr.Code == websocket.CloseGoingAway {
+				return &connectionError{err: fmt.Errorf("daemon closed the connection: %s", closeErr.Text), received: received}
+			}
+			// The request was over the daemon's daemon.connection.max_message_bytes
+			if errors.As(err, &closeErr) && closeErr.Code == websocket.CloseMessageTooBig {
+				return &ChatError{
+					Code:    api.ErrorCode_ERROR_INVALID_REQUEST,
+					Message: "message is too large",
+					Detail:  "shorten it or raise daemon.connection.max_message_bytes",
+				}
+			}
+			return &connectionError{err: fmt.Errorf("failed to read response: %w", err), received: received}
 		}
+		received = true
 
 		var resp api.ChatResponse
 		if err := proto.Unmarshal(respData, &resp); err != nil {
 			return fmt.Errorf("failed to unmarshal response: %w", err)
 		}
+		collect.observe(&resp)
 
 		switch payload := resp.Payload.(type) {
 		case *api.ChatResponse_Text:
 			spin.Pause()
 			// Always show assistant text
-			if payload.Text.Role == api.Role_ASSISTANT {
-				mdStream.Write(payload.Text.Content)
-			} else if opts.Verbosity == VerbosityVerbose {
-				// Show system messages only in verbose mode
+			if payload.Text.Role == api.Role_ASSISTANT || opts.Verbosity == VerbosityVerbose {
+				// System messages are shown only in verbose mode
 				mdStream.Write(payload.Text.Content)
+				teeStream.Write(payload.Text.Content)
+			}
+
+		case *api.ChatResponse_QueuePosition:
+			// Other chats are using the model; position 0 means this one has started
+			if payload.QueuePosition > 0 {
+				spin.SetLabel(fmt.Sprintf("waiting for the model, %s in queue", ordinal(payload.QueuePosition)))
+			} else {
+				spin.SetLabel(spinnerLabel)
+			}
+
+		case *api.ChatResponse_Thinking:
+			// Reasoning is hidden unless verbose
+			if opts.Verbosity == VerbosityVerbose {
+				spin.Pause()
+				mdStream.Flush()
+				fmt.Fprintf(out, "%s%s%s", colorGray, payload.Thinking, colorReset)
 			}
 
 		case *api.ChatResponse_ToolCall:
 			spin.Pause()
 			mdStream.Flush() // Flush before tool output
 			if opts.Verbosity != VerbosityQuiet {
-				fmt.Fprint(output, formatToolCall(payload.ToolCall.Name, payload.ToolCall.Arguments))
+				fmt.Fprint(out, formatToolCall(payload.ToolCall.Name, payload.ToolCall.Arguments))
 			}
 			spin.Resume()
 
 		case *api.ChatResponse_ToolResult:
 			spin.Pause()
 			if opts.Verbosity == VerbosityVerbose {
-				status := "✓"
-				if !payload.ToolResult.Success {
-					status = "✗"
-				}
-				// Truncate long output
-				out := payload.ToolResult.Output
-				if len(out) > 200 {
-					out = out[:200] + "..."
+				result := payload.ToolResult
+				if streamedOutput {
+					// The output was already shown line by line while the command ran
+					result = &api.ToolResult{Name: result.Name, Success: result.Success, DurationMs: result.DurationMs}
+					streamedOutput = false
 				}
-				fmt.Fprintf(output, "%s %s\n", status, out)
+				fmt.Fprint(out, formatToolResult(result))
+			}
+			spin.Resume()
+
+		case *api.ChatResponse_PlanApproval:
+			// Nothing runs until the plan is approved, so the spinner stays paused while asking
+			spin.Pause()
+			mdStream.Flush()
+			if err := sendPlanDecision(conn, payload.PlanApproval, opts.ApprovePlan); err != nil {
+				return err
 			}
 			spin.Resume()
 
@@ -263,30 +585,212 @@ func (c *Client) Chat(ctx context.Context, message string, output io.Writer, opt
 			// Shell command output is now handled by ToolCall event
 			// No need to print separately
 
+		case *api.ChatResponse_DiscoveryStep:
+			// Discovery runs before a command's first use and can take a while
+			if opts.Verbosity == VerbosityVerbose {
+				spin.Pause()
+				mdStream.Flush()
+				fmt.Fprint(out, formatDiscoveryStep(payload.DiscoveryStep))
+				spin.Resume()
+			}
+
+		case *api.ChatResponse_ShellOutput:
+			if opts.Verbosity == VerbosityVerbose {
+				spin.Pause()
+				mdStream.Flush()
+				fmt.Fprintf(out, "  %s%s%s\n", colorGray, payload.ShellOutput, colorReset)
+				streamedOutput = true
+				spin.Resume()
+			}
+
 		case *api.ChatResponse_Done:
 			stopSpinner()
 			mdStream.Flush() // Flush remaining content
-			fmt.Fprintln(output)
+			if collect.result.Content == "" {
+				// Otherwise an empty answer is just a blank line, as if something silently failed
+				fmt.Fprintf(out, "%s(no response)%s\n", colorGray, colorReset)
+			} else {
+				fmt.Fprintln(out)
+			}
+			if resp.Fallback && opts.Verbosity != VerbosityQuiet {
+				fmt.Fprintf(out, "%s(answered by fallback model %s)%s\n", colorGray, resp.Model, colorReset)
+			}
+			if opts.Verbosity == VerbosityVerbose && resp.CompletionTokens > 0 {
+				fmt.Fprintf(out, "%s%s%s\n", colorGray, formatTurnStats(resp.CompletionTokens, time.Since(start), resp.EvalDurationMs), colorReset)
+			}
 			return nil
 
 		case *api.ChatResponse_Error:
 			stopSpinner()
 			mdStream.Flush()
-			return fmt.Errorf("server error: %s", payload.Error)
+			return newChatError(payload.Error)
 		}
 	}
 }
 
+// sendPlanDecision asks approve whether the plan the daemon waits on may run and sends the answer.
+// Without an approver the plan is rejected, as nothing was asked to approve it.
+func sendPlanDecision(conn *websocket.Conn, plan *api.Plan, approve func(*api.Plan) bool) error {
+	req := &api.ChatRequest{PlanDecision: &api.PlanDecision{Approved: approve != nil && approve(plan)}}
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan decision: %w", err)
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		return &connectionError{err: fmt.Errorf("failed to send plan decision: %w", err), received: true}
+	}
+	return nil
+}
+
+// readJSONResponse buffers the full answer, checks that it is valid JSON and writes it to output
+func readJSONResponse(conn *websocket.Conn, output io.Writer, collect *resultCollector, approve func(*api.Plan) bool) error {
+	received := false
+	for {
+		_, respData, err := conn.ReadMessage()
+		if err != nil {
+			return &connectionError{err: fmt.Errorf("failed to read response: %w", err), received: received}
+		}
+		received = true
+
+		var resp api.ChatResponse
+		if err := proto.Unmarshal(respData, &resp); err != nil {
+			return fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		collect.observe(&resp)
+
+		switch payload := resp.Payload.(type) {
+		case *api.ChatResponse_PlanApproval:
+			if err := sendPlanDecision(conn, payload.PlanApproval, approve); err != nil {
+				return err
+			}
+
+		case *api.ChatResponse_Done:
+			result := collect.result.Content
+			if result == "" {
+				return errors.New("model returned an empty response")
+			}
+			if !json.Valid([]byte(result)) {
+				return fmt.Errorf("response is not valid JSON: %s", result)
+			}
+			_, err := fmt.Fprintln(output, result)
+			return err
+
+		case *api.ChatResponse_Error:
+			return newChatError(payload.Error)
+		}
+	}
+}
+
+// readChatResult buffers the full answer with its tool calls and writes it to output as one JSON line.
+// With validateJSON, the answer itself must be valid JSON.
+func readChatResult(conn *websocket.Conn, output io.Writer, validateJSON bool, collect *resultCollector, approve func(*api.Plan) bool) error {
+	received := false
+	for {
+		_, respData, err := conn.ReadMessage()
+		if err != nil {
+			return &connectionError{err: fmt.Errorf("failed to read response: %w", err), received: received}
+		}
+		received = true
+
+		var resp api.ChatResponse
+		if err := proto.Unmarshal(respData, &resp); err != nil {
+			return fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		collect.observe(&resp)
+
+		switch payload := resp.Payload.(type) {
+		case *api.ChatResponse_PlanApproval:
+			if err := sendPlanDecision(conn, payload.PlanApproval, approve); err != nil {
+				return err
+			}
+
+		case *api.ChatResponse_Done:
+			result := collect.result
+			if validateJSON && !json.Valid([]byte(result.Content)) {
+				return fmt.Errorf("response is not valid JSON: %s", result.Content)
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return fmt.Errorf("failed to marshal result: %w", err)
+			}
+			_, err = fmt.Fprintln(output, string(data))
+			return err
+
+		case *api.ChatResponse_Error:
+			return newChatError(payload.Error)
+		}
+	}
+}
+
+// resultCollector assembles a ChatResult from streamed responses
+type resultCollector struct {
+	answer  strings.Builder
+	result  ChatResult
+	pending map[string][]int // Tool name -> indexes of calls still waiting for their result
+	done    bool
+	trace   *tracer // Sees every response, nil when the chat isn't traced
+}
+
+func newResultCollector() *resultCollector {
+	return &resultCollector{
+		result:  ChatResult{ToolCalls: []ToolCallResult{}},
+		pending: make(map[string][]int),
+	}
+}
+
+// observe adds a response to the result
+func (rc *resultCollector) observe(resp *api.ChatResponse) {
+	rc.trace.observe(resp)
+
+	switch payload := resp.Payload.(type) {
+	case *api.ChatResponse_Text:
+		if payload.Text.Role == api.Role_ASSISTANT {
+			rc.answer.WriteString(payload.Text.Content)
+		}
+
+	case *api.ChatResponse_ToolCall:
+		call := ToolCallResult{Name: payload.ToolCall.Name}
+		if json.Valid([]byte(payload.ToolCall.Arguments)) {
+			call.Arguments = json.RawMessage(payload.ToolCall.Arguments)
+		}
+		rc.pending[call.Name] = append(rc.pending[call.Name], len(rc.result.ToolCalls))
+		rc.result.ToolCalls = append(rc.result.ToolCalls, call)
+
+	case *api.ChatResponse_ToolResult:
+		name := payload.ToolResult.Name
+		if len(rc.pending[name]) == 0 {
+			return
+		}
+		call := &rc.result.ToolCalls[rc.pending[name][0]]
+		rc.pending[name] = rc.pending[name][1:]
+		call.Success = payload.ToolResult.Success
+		call.DurationMs = payload.ToolResult.DurationMs
+		call.Output = payload.ToolResult.Output
+
+	case *api.ChatResponse_Done:
+		rc.result.Content = strings.TrimSpace(rc.answer.String())
+		rc.result.Model = resp.Model
+		rc.result.Fallback = resp.Fallback
+		rc.result.Tokens = resp.CompletionTokens
+		rc.result.PromptTokens = resp.PromptTokens
+		rc.done = true
+	}
+}
+
 // Status checks the daemon status
 func (c *Client) Status(ctx context.Context) (*api.StatusResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.statusTimeout)
+	defer cancel()
+
 	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/status", nil)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
+		return nil, probeError(ctx, err, c.statusTimeout)
 	}
 	defer resp.Body.Close()
 
@@ -296,7 +800,7 @@ func (c *Client) Status(ctx context.Context) (*api.StatusResponse, error) {
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, probeError(ctx, err, c.statusTimeout)
 	}
 
 	var status api.StatusResponse
@@ -307,20 +811,98 @@ func (c *Client) Status(ctx context.Context) (*api.StatusResponse, error) {
 	return &status, nil
 }
 
-// IsRunning checks if the daemon is running
+// Version returns the daemon's build information
+func (c *Client) Version(ctx context.Context) (*api.VersionResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.statusTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/version", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var versionResp api.VersionResponse
+	if err := proto.Unmarshal(data, &versionResp); err != nil {
+		return nil, err
+	}
+
+	return &versionResp, nil
+}
+
+// ErrIncompatibleDaemon means the daemon speaks a different protocol revision than this client
+var ErrIncompatibleDaemon = errors.New("daemon is incompatible with this client")
+
+// CheckVersion compares the daemon's build with this client's. A different protocol revision
+// returns an error wrapping ErrIncompatibleDaemon; a different version or commit only returns a warning.
+func CheckVersion(daemon *api.VersionResponse) (warning string, err error) {
+	// Daemons from before protocol revisions report 0, so only the versions can be compared
+	if daemon.Protocol != 0 && daemon.Protocol != version.Protocol {
+		return "", fmt.Errorf("%w: the daemon runs %s with protocol %d, this client %s with protocol %d",
+			ErrIncompatibleDaemon, version.Format(daemon.Version, daemon.Commit, ""), daemon.Protocol,
+			version.Format(version.Version, version.Commit, ""), version.Protocol)
+	}
+	if daemon.Version != version.Version || daemon.Commit != version.Commit {
+		return fmt.Sprintf("the daemon runs %s, this client %s",
+			version.Format(daemon.Version, daemon.Commit, ""), version.Format(version.Version, version.Commit, "")), nil
+	}
+	return "", nil
+}
+
+// IsRunning checks if the daemon is running and answering. A daemon that doesn't answer
+// within the health timeout counts as not running.
 func (c *Client) IsRunning(ctx context.Context) bool {
+	return c.Probe(ctx) == nil
+}
+
+// Probe checks the daemon's health, returning ErrNotRunning if nothing listens on its address,
+// or ErrUnresponsive if it accepts connections but doesn't answer healthy within the health timeout
+func (c *Client) Probe(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.healthTimeout)
+	defer cancel()
+
 	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/health", nil)
 	if err != nil {
-		return false
+		return err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return false
+		return probeError(ctx, err, c.healthTimeout)
 	}
 	defer resp.Body.Close()
 
-	return resp.StatusCode == http.StatusOK
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: health check returned status %d", ErrUnresponsive, resp.StatusCode)
+	}
+	return nil
+}
+
+// probeError classifies a failed request: refused connections mean the daemon isn't running,
+// timeouts mean it's wedged
+func probeError(ctx context.Context, err error, timeout time.Duration) error {
+	var opErr *net.OpError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%w: no answer within %v", ErrUnresponsive, timeout)
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return fmt.Errorf("%w: %w", ErrNotRunning, err)
+	}
+	return fmt.Errorf("failed to connect to daemon: %w", err)
 }
 
 // Shutdown requests the daemon to stop
@@ -330,7 +912,7 @@ func (c *Client) Shutdown(ctx context.Context) error {
 		return err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to connect to daemon: %w", err)
 	}
@@ -350,7 +932,7 @@ func (c *Client) GetContext(ctx context.Context) (string, error) {
 		return "", err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to connect to daemon: %w", err)
 	}
@@ -387,7 +969,7 @@ func (c *Client) SetContext(ctx context.Context, context string) error {
 	}
 	req.Header.Set("Content-Type", "application/x-protobuf")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to connect to daemon: %w", err)
 	}
@@ -407,7 +989,7 @@ func (c *Client) History(ctx context.Context) (*api.HistoryResponse, error) {
 		return nil, err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
 	}
@@ -476,7 +1058,7 @@ func (c *Client) ExecuteTool(ctx context.Context, name string, args map[string]a
 	}
 	req.Header.Set("Content-Type", "application/x-protobuf")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
 	}
@@ -506,7 +1088,7 @@ func (c *Client) ListTools(ctx context.Context) (*api.ToolListResponse, error) {
 		return nil, err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
 	}
@@ -529,6 +1111,221 @@ func (c *Client) ListTools(ctx context.Context) (*api.ToolListResponse, error) {
 	return &toolList, nil
 }
 
+// ToolPrompt returns the external tools prompt the daemon injects and the command schemas it has cached
+func (c *Client) ToolPrompt(ctx context.Context) (*api.ToolPromptResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.statusTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/tool/prompt", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var prompt api.ToolPromptResponse
+	if err := proto.Unmarshal(data, &prompt); err != nil {
+		return nil, err
+	}
+
+	return &prompt, nil
+}
+
+// RunningModels lists the models Ollama currently has loaded
+func (c *Client) RunningModels(ctx context.Context) (*api.RunningModelsResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.statusTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/models/running", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var running api.RunningModelsResponse
+	if err := proto.Unmarshal(data, &running); err != nil {
+		return nil, err
+	}
+
+	return &running, nil
+}
+
+// UnknownModelError is returned by SetModel when the model isn't pulled into Ollama
+type UnknownModelError struct {
+	Model     string
+	Available []string // Models that are pulled
+}
+
+func (e *UnknownModelError) Error() string {
+	return fmt.Sprintf("model %q is not pulled into Ollama", e.Model)
+}
+
+// Model returns the model the daemon uses for chats
+func (c *Client) Model(ctx context.Context) (string, error) {
+	resp, err := c.model(ctx, "GET", nil)
+	if err != nil {
+		return "", err
+	}
+	return resp.Model, nil
+}
+
+// SetModel switches the daemon's chat model for subsequent chats.
+// A model that isn't pulled is rejected with an *UnknownModelError.
+func (c *Client) SetModel(ctx context.Context, model string) (*api.ModelResponse, error) {
+	data, err := proto.Marshal(&api.ModelRequest{Model: model})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.model(ctx, "POST", data)
+	var unknown *UnknownModelError
+	if errors.As(err, &unknown) {
+		unknown.Model = model
+	}
+	return resp, err
+}
+
+// model sends a request to the daemon's /model endpoint
+func (c *Client) model(ctx context.Context, method string, body []byte) (*api.ModelResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.statusTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+"/model", strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var modelResp api.ModelResponse
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		if err := proto.Unmarshal(data, &modelResp); err != nil {
+			return nil, fmt.Errorf("daemon returned status %d", resp.StatusCode)
+		}
+		return nil, &UnknownModelError{Available: modelResp.Available}
+	default:
+		return nil, fmt.Errorf("daemon returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	if err := proto.Unmarshal(data, &modelResp); err != nil {
+		return nil, err
+	}
+	return &modelResp, nil
+}
+
+// Embed computes an embedding vector for the input via the daemon.
+// An empty model uses the daemon's configured embedding model.
+func (c *Client) Embed(ctx context.Context, input, model string) (*api.EmbedResponse, error) {
+	reqBody := &api.EmbedRequest{
+		Input: input,
+		Model: model,
+	}
+	data, err := proto.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/embed", strings.NewReader(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(respData)))
+	}
+
+	var embedResp api.EmbedResponse
+	if err := proto.Unmarshal(respData, &embedResp); err != nil {
+		return nil, err
+	}
+
+	return &embedResp, nil
+}
+
+// ordinal formats a queue position, e.g. "1st" or "12th"
+func ordinal(n int32) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+// formatTurnStats describes a completed turn, e.g. "(142 tokens, 3.2s, 44 tok/s)".
+// The rate uses Ollama's generation time, so it isn't skewed by tool calls or prompt evaluation.
+func formatTurnStats(tokens int32, elapsed time.Duration, evalDurationMs int64) string {
+	stats := fmt.Sprintf("(%d tokens, %.1fs", tokens, elapsed.Seconds())
+	if evalDurationMs > 0 {
+		stats += fmt.Sprintf(", %.0f tok/s", float64(tokens)/(float64(evalDurationMs)/1000))
+	}
+	return stats + ")"
+}
+
+// formatDiscoveryStep formats a discovery step for display, e.g. "  discovering: git --help (120 lines of help)"
+func formatDiscoveryStep(step *api.DiscoveryStep) string {
+	if step.Command == "" {
+		return fmt.Sprintf("  %sdiscovering: %s%s\n", colorGray, step.Summary, colorReset)
+	}
+	return fmt.Sprintf("  %sdiscovering: %s (%s)%s\n", colorGray, step.Command, step.Summary, colorReset)
+}
+
 // formatToolCall formats a tool call for display
 func formatToolCall(name, arguments string) string {
 	// Format tool name: replace underscores with spaces and capitalize each word
@@ -566,6 +1363,26 @@ func formatToolCall(name, arguments string) string {
 		colorWhite, arguments, colorReset)
 }
 
+// formatToolResult formats a tool result with its status, duration and full output for verbose display
+func formatToolResult(result *api.ToolResult) string {
+	status := "✓"
+	if !result.Success {
+		status = "✗"
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s %s%s (%dms)%s\n", status, colorGray, formatToolName(result.Name), result.DurationMs, colorReset))
+
+	out := strings.TrimRight(result.Output, "\n")
+	if out != "" {
+		for _, line := range strings.Split(out, "\n") {
+			sb.WriteString(fmt.Sprintf("  %s%s%s\n", colorGray, line, colorReset))
+		}
+	}
+
+	return sb.String()
+}
+
 // formatToolName converts a tool name like "get_command_schema" to "Get Command Schema"
 func formatToolName(name string) string {
 	// Replace underscores with spaces
@@ -596,33 +1413,103 @@ func init() {
 	}
 }
 
-// markdownStreamer buffers text for markdown rendering
+// markdownStreamer renders streamed text block by block, or passes it through as plain text
 type markdownStreamer struct {
-	output io.Writer
-	buffer strings.Builder
+	output  io.Writer
+	render  bool
+	buffer  strings.Builder
+	started bool // Something was written since the last Flush
 }
 
-func newMarkdownStreamer(output io.Writer) *markdownStreamer {
-	return &markdownStreamer{output: output}
+func newMarkdownStreamer(output io.Writer, render bool) *markdownStreamer {
+	return &markdownStreamer{output: output, render: render}
 }
 
-// Write adds text to the buffer
+// Write adds text, printing every markdown block that is complete
 func (m *markdownStreamer) Write(text string) {
+	if !m.render {
+		m.print(text)
+		return
+	}
+
 	m.buffer.WriteString(text)
+	buffered := m.buffer.String()
+	if end := completeBlocksEnd(buffered); end > 0 {
+		m.buffer.Reset()
+		m.buffer.WriteString(buffered[end:])
+		m.printRendered(buffered[:end])
+	}
 }
 
-// Flush renders and outputs the buffered markdown
+// Flush renders and outputs the rest of the buffered markdown
 func (m *markdownStreamer) Flush() {
-	if m.buffer.Len() == 0 {
+	text := m.buffer.String()
+	m.buffer.Reset()
+	m.printRendered(text)
+	m.started = false
+}
+
+// printRendered renders one or more complete blocks
+func (m *markdownStreamer) printRendered(text string) {
+	if strings.TrimSpace(text) == "" {
 		return
 	}
+	if m.started {
+		fmt.Fprint(m.output, "\n\n") // Blank line between rendered blocks
+	}
+	m.print(renderMarkdown(text))
+}
 
-	text := m.buffer.String()
-	m.buffer.Reset()
+func (m *markdownStreamer) print(text string) {
+	if !m.started {
+		// Add newline before answer to separate from question
+		fmt.Fprint(m.output, "\n")
+		m.started = true
+	}
+	fmt.Fprint(m.output, text)
+}
+
+// ansiEscape matches terminal control sequences such as colors and cursor movement
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// plainWriter strips terminal control sequences before writing, for output that isn't a terminal
+type plainWriter struct {
+	w io.Writer
+}
+
+func (p *plainWriter) Write(b []byte) (int, error) {
+	if _, err := p.w.Write(ansiEscape.ReplaceAll(b, nil)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// completeBlocksEnd returns the length of the leading part of text made of complete markdown blocks,
+// i.e. the text up to the last blank line outside a code fence. Returns 0 if no block is complete yet.
+func completeBlocksEnd(text string) int {
+	end, pos := 0, 0
+	inFence := false
+	for {
+		i := strings.IndexByte(text[pos:], '\n')
+		if i < 0 {
+			return end
+		}
+		line := strings.TrimSpace(text[pos : pos+i])
+		pos += i + 1
+
+		switch {
+		case strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~"):
+			inFence = !inFence
+		case line == "" && !inFence:
+			end = pos
+		}
+	}
+}
 
-	rendered := renderMarkdown(text)
-	// Add newline before answer to separate from question
-	fmt.Fprint(m.output, "\n"+rendered)
+// isTerminal reports whether w writes to a terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd())) //nolint:gosec // G115: file descriptors fit in int
 }
 
 // renderMarkdown converts markdown to styled terminal output using glamour
@@ -639,3 +1526,41 @@ func renderMarkdown(text string) string {
 	// Glamour adds extra newlines, trim them
 	return strings.TrimSpace(rendered)
 }
+
+// Cancel stops this session's running generation, keeping the connection and history.
+// Returns false if nothing was generating.
+func (c *Client) Cancel(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.statusTimeout)
+	defer cancel()
+
+	body, err := proto.Marshal(&api.CancelRequest{SessionId: c.sessionID})
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/cancel", strings.NewReader(string(body)))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("daemon returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var cancelResp api.CancelResponse
+	if err := proto.Unmarshal(data, &cancelResp); err != nil {
+		return false, err
+	}
+	return cancelResp.Canceled, nil
+}
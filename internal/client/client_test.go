@@ -1,12 +1,27 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
-	"strconv"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/marciniwanicki/craby/internal/api"
+	"github.com/marciniwanicki/craby/internal/version"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestFormatToolCall_ShellTool(t *testing.T) {
@@ -151,29 +166,62 @@ func TestFormatToolCall_MissingCommand(t *testing.T) {
 }
 
 func TestNewClient(t *testing.T) {
-	client := NewClient(8787)
+	client := NewClient("127.0.0.1:8787")
 
 	if client == nil {
 		t.Fatal("expected client to be created")
 	}
 
-	if client.baseURL != "http://localhost:8787" {
-		t.Errorf("expected baseURL 'http://localhost:8787', got %q", client.baseURL)
+	if client.baseURL != "http://127.0.0.1:8787" {
+		t.Errorf("expected baseURL 'http://127.0.0.1:8787', got %q", client.baseURL)
 	}
 
-	if client.wsURL != "ws://localhost:8787" {
-		t.Errorf("expected wsURL 'ws://localhost:8787', got %q", client.wsURL)
+	if client.wsURL != "ws://127.0.0.1:8787" {
+		t.Errorf("expected wsURL 'ws://127.0.0.1:8787', got %q", client.wsURL)
 	}
 }
 
-func TestNewClient_DifferentPort(t *testing.T) {
-	client := NewClient(9000)
+func TestNewClient_UnixSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "craby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "d.sock")
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	}))
+	server.Listener = ln
+	server.Start()
+	defer server.Close()
+
+	if err := NewClient("unix:" + path).Probe(context.Background()); err != nil {
+		t.Errorf("expected the daemon reached over the socket, got %v", err)
+	}
+}
+
+func TestNewClient_DifferentHost(t *testing.T) {
+	client := NewClient("localhost:9000")
 
 	if client.baseURL != "http://localhost:9000" {
 		t.Errorf("expected baseURL 'http://localhost:9000', got %q", client.baseURL)
 	}
 }
 
+func TestNewClient_AllInterfaces(t *testing.T) {
+	for _, addr := range []string{":8787", "0.0.0.0:8787", "[::]:8787"} {
+		client := NewClient(addr)
+		if client.baseURL != "http://127.0.0.1:8787" {
+			t.Errorf("expected %s to be reached over loopback, got %q", addr, client.baseURL)
+		}
+	}
+}
+
 func TestVerbosityConstants(t *testing.T) {
 	// Verify verbosity levels are distinct
 	if VerbosityNormal == VerbosityQuiet {
@@ -197,8 +245,7 @@ func TestIsRunning_DaemonRunning(t *testing.T) {
 	}))
 	defer server.Close()
 
-	port := extractPort(t, server.URL)
-	client := NewClient(port)
+	client := NewClient(extractAddr(t, server.URL))
 
 	if !client.IsRunning(context.Background()) {
 		t.Error("expected IsRunning to return true when daemon responds with 200")
@@ -207,7 +254,7 @@ func TestIsRunning_DaemonRunning(t *testing.T) {
 
 func TestIsRunning_DaemonNotRunning(t *testing.T) {
 	// Use a port that's definitely not listening
-	client := NewClient(59999)
+	client := NewClient("127.0.0.1:59999")
 
 	if client.IsRunning(context.Background()) {
 		t.Error("expected IsRunning to return false when daemon is not running")
@@ -220,14 +267,48 @@ func TestIsRunning_DaemonReturnsError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	port := extractPort(t, server.URL)
-	client := NewClient(port)
+	client := NewClient(extractAddr(t, server.URL))
 
 	if client.IsRunning(context.Background()) {
 		t.Error("expected IsRunning to return false when daemon returns 500")
 	}
 }
 
+func TestProbe_NotRunning(t *testing.T) {
+	client := NewClient("127.0.0.1:59999")
+
+	if err := client.Probe(context.Background()); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("expected ErrNotRunning, got %v", err)
+	}
+}
+
+func TestProbe_WedgedDaemon(t *testing.T) {
+	// Accepts connections but never answers
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient(extractAddr(t, server.URL))
+	client.SetTimeouts(100*time.Millisecond, 100*time.Millisecond)
+
+	start := time.Now()
+	if err := client.Probe(context.Background()); !errors.Is(err, ErrUnresponsive) {
+		t.Errorf("expected ErrUnresponsive, got %v", err)
+	}
+	if client.IsRunning(context.Background()) {
+		t.Error("expected a wedged daemon not to count as running")
+	}
+	if _, err := client.Status(context.Background()); !errors.Is(err, ErrUnresponsive) {
+		t.Errorf("expected status to time out as ErrUnresponsive, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the probes to give up quickly, took %v", elapsed)
+	}
+}
+
 func TestShutdown_Success(t *testing.T) {
 	shutdownCalled := false
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -240,8 +321,7 @@ func TestShutdown_Success(t *testing.T) {
 	}))
 	defer server.Close()
 
-	port := extractPort(t, server.URL)
-	client := NewClient(port)
+	client := NewClient(extractAddr(t, server.URL))
 
 	err := client.Shutdown(context.Background())
 	if err != nil {
@@ -255,7 +335,7 @@ func TestShutdown_Success(t *testing.T) {
 
 func TestShutdown_DaemonNotRunning(t *testing.T) {
 	// Use a port that's definitely not listening
-	client := NewClient(59999)
+	client := NewClient("127.0.0.1:59999")
 
 	err := client.Shutdown(context.Background())
 	if err == nil {
@@ -273,8 +353,7 @@ func TestShutdown_DaemonReturnsError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	port := extractPort(t, server.URL)
-	client := NewClient(port)
+	client := NewClient(extractAddr(t, server.URL))
 
 	err := client.Shutdown(context.Background())
 	if err == nil {
@@ -293,8 +372,7 @@ func TestShutdown_ContextCanceled(t *testing.T) {
 	}))
 	defer server.Close()
 
-	port := extractPort(t, server.URL)
-	client := NewClient(port)
+	client := NewClient(extractAddr(t, server.URL))
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel() // Cancel immediately
@@ -359,7 +437,7 @@ func TestRenderMarkdown_Link(t *testing.T) {
 
 func TestMarkdownStreamer_Buffering(t *testing.T) {
 	var buf strings.Builder
-	ms := newMarkdownStreamer(&buf)
+	ms := newMarkdownStreamer(&buf, true)
 
 	// Write chunks
 	ms.Write("Hello ")
@@ -377,7 +455,7 @@ func TestMarkdownStreamer_Buffering(t *testing.T) {
 
 func TestMarkdownStreamer_EmptyFlush(t *testing.T) {
 	var buf strings.Builder
-	ms := newMarkdownStreamer(&buf)
+	ms := newMarkdownStreamer(&buf, true)
 
 	// Flush without writing anything
 	ms.Flush()
@@ -387,17 +465,495 @@ func TestMarkdownStreamer_EmptyFlush(t *testing.T) {
 	}
 }
 
-// extractPort extracts the port number from an httptest server URL
-func extractPort(t *testing.T, url string) int {
+// extractAddr extracts the host:port from an httptest server URL
+func extractAddr(t *testing.T, url string) string {
 	t.Helper()
 	// URL format: http://127.0.0.1:PORT
-	parts := strings.Split(url, ":")
-	if len(parts) < 3 {
+	addr, ok := strings.CutPrefix(url, "http://")
+	if !ok {
 		t.Fatalf("unexpected URL format: %s", url)
 	}
-	port, err := strconv.Atoi(parts[2])
-	if err != nil {
-		t.Fatalf("failed to parse port from URL %s: %v", url, err)
+	return addr
+}
+
+func TestChat_ReconnectsAfterDroppedConnection(t *testing.T) {
+	var connections atomic.Int32
+	var sessionIDs []string
+	var mu sync.Mutex
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req api.ChatRequest
+		_ = proto.Unmarshal(data, &req)
+		mu.Lock()
+		sessionIDs = append(sessionIDs, req.SessionId)
+		mu.Unlock()
+
+		// Drop the first connection before sending anything
+		if connections.Add(1) == 1 {
+			return
+		}
+
+		done, _ := proto.Marshal(&api.ChatResponse{Payload: &api.ChatResponse_Done{Done: true}})
+		_ = conn.WriteMessage(websocket.BinaryMessage, done)
+	}))
+	defer server.Close()
+
+	client := NewClient(extractAddr(t, server.URL))
+
+	var out bytes.Buffer
+	if err := client.Chat(context.Background(), "hello", &out, ChatOptions{}); err != nil {
+		t.Fatalf("expected chat to succeed after reconnect, got: %v", err)
+	}
+
+	if got := connections.Load(); got != 2 {
+		t.Errorf("expected 2 connections, got %d", got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, id := range sessionIDs {
+		if id != client.SessionID() {
+			t.Errorf("expected session ID %q to be replayed, got %q", client.SessionID(), id)
+		}
+	}
+}
+
+func TestChat_NoReconnectAfterResponseReceived(t *testing.T) {
+	var connections atomic.Int32
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		connections.Add(1)
+
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+
+		// Send a token, then drop the connection
+		text, _ := proto.Marshal(&api.ChatResponse{
+			Payload: &api.ChatResponse_Text{Text: &api.TextChunk{Content: "partial"}},
+		})
+		_ = conn.WriteMessage(websocket.BinaryMessage, text)
+	}))
+	defer server.Close()
+
+	client := NewClient(extractAddr(t, server.URL))
+
+	var out bytes.Buffer
+	if err := client.Chat(context.Background(), "hello", &out, ChatOptions{}); err == nil {
+		t.Fatal("expected error when connection drops mid-response")
+	}
+
+	if got := connections.Load(); got != 1 {
+		t.Errorf("expected no reconnect after tokens were received, got %d connections", got)
+	}
+}
+
+func TestChat_MessageTooLarge(t *testing.T) {
+	var connections atomic.Int32
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		connections.Add(1)
+
+		// Like the daemon, refuse requests over the limit by closing the connection
+		conn.SetReadLimit(64)
+		_, _, _ = conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	client := NewClient(extractAddr(t, server.URL))
+
+	var out bytes.Buffer
+	err := client.Chat(context.Background(), strings.Repeat("x", 1024), &out, ChatOptions{})
+	if !errors.Is(err, ErrInvalidRequest) || !strings.Contains(err.Error(), "too large") {
+		t.Fatalf("expected a message too large error, got %v", err)
+	}
+	if got := connections.Load(); got != 1 {
+		t.Errorf("expected no reconnect for an oversized message, got %d connections", got)
+	}
+}
+
+func TestFormatToolResult(t *testing.T) {
+	result := formatToolResult(&api.ToolResult{
+		Name:       "shell",
+		Output:     "line one\nline two\n",
+		Success:    true,
+		DurationMs: 42,
+	})
+
+	if !strings.HasPrefix(result, "✓ ") {
+		t.Errorf("expected success marker, got %q", result)
+	}
+	if !strings.Contains(result, "Shell (42ms)") {
+		t.Errorf("expected tool name and duration, got %q", result)
+	}
+	// Full output is shown, one indented line per output line
+	if !strings.Contains(result, "  "+colorGray+"line one") || !strings.Contains(result, "  "+colorGray+"line two") {
+		t.Errorf("expected indented output lines, got %q", result)
+	}
+}
+
+func TestFormatToolResult_Failure(t *testing.T) {
+	result := formatToolResult(&api.ToolResult{Name: "shell", Output: "Error: boom"})
+	if !strings.HasPrefix(result, "✗ ") {
+		t.Errorf("expected failure marker, got %q", result)
+	}
+}
+
+func TestFormatTurnStats(t *testing.T) {
+	if got := formatTurnStats(142, 3200*time.Millisecond, 3227); got != "(142 tokens, 3.2s, 44 tok/s)" {
+		t.Errorf("unexpected stats %q", got)
+	}
+	// Without Ollama's generation time the rate is left out
+	if got := formatTurnStats(10, time.Second, 0); got != "(10 tokens, 1.0s)" {
+		t.Errorf("unexpected stats %q", got)
+	}
+}
+
+// startReplyServer serves a chat endpoint that answers with the given text chunks and records the request
+func startReplyServer(t *testing.T, chunks []string, gotReq *api.ChatRequest) *httptest.Server {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = proto.Unmarshal(data, gotReq)
+
+		// Tool activity should not leak into JSON output
+		toolCall, _ := proto.Marshal(&api.ChatResponse{
+			Payload: &api.ChatResponse_ToolCall{ToolCall: &api.ToolCall{Name: "shell", Arguments: `{"command":"date"}`}},
+		})
+		_ = conn.WriteMessage(websocket.BinaryMessage, toolCall)
+		toolResult, _ := proto.Marshal(&api.ChatResponse{
+			Payload: &api.ChatResponse_ToolResult{ToolResult: &api.ToolResult{Name: "shell", Output: "Mon", Success: true, DurationMs: 5}},
+		})
+		_ = conn.WriteMessage(websocket.BinaryMessage, toolResult)
+
+		for _, chunk := range chunks {
+			text, _ := proto.Marshal(&api.ChatResponse{
+				Payload: &api.ChatResponse_Text{Text: &api.TextChunk{Content: chunk}},
+			})
+			_ = conn.WriteMessage(websocket.BinaryMessage, text)
+		}
+		done, _ := proto.Marshal(&api.ChatResponse{
+			Payload:          &api.ChatResponse_Done{Done: true},
+			Model:            "qwen2.5:14b",
+			PromptTokens:     120,
+			CompletionTokens: 7,
+		})
+		_ = conn.WriteMessage(websocket.BinaryMessage, done)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestChat_OutputJSON(t *testing.T) {
+	var req api.ChatRequest
+	server := startReplyServer(t, []string{"It is ", "Monday."}, &req)
+	client := NewClient(extractAddr(t, server.URL))
+
+	var out bytes.Buffer
+	if err := client.Chat(context.Background(), "hello", &out, ChatOptions{Output: OutputJSON}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strings.Count(out.String(), "\n") != 1 {
+		t.Errorf("expected a single JSON line, got %q", out.String())
+	}
+	var result ChatResult
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("expected JSON output, got %q: %v", out.String(), err)
+	}
+	if result.Content != "It is Monday." || result.Model != "qwen2.5:14b" || result.Tokens != 7 || result.PromptTokens != 120 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if len(result.ToolCalls) != 1 {
+		t.Fatalf("expected 1 tool call, got %+v", result.ToolCalls)
+	}
+	call := result.ToolCalls[0]
+	if call.Name != "shell" || string(call.Arguments) != `{"command":"date"}` || !call.Success || call.Output != "Mon" || call.DurationMs != 5 {
+		t.Errorf("unexpected tool call: %+v", call)
+	}
+}
+
+func TestChat_EmptyResponse(t *testing.T) {
+	var req api.ChatRequest
+	server := startReplyServer(t, []string{" ", "\n"}, &req)
+	client := NewClient(extractAddr(t, server.URL))
+
+	var out bytes.Buffer
+	if err := client.Chat(context.Background(), "hello", &out, ChatOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "(no response)") {
+		t.Errorf("expected an empty answer to be pointed out, got %q", out.String())
+	}
+
+	err := client.Chat(context.Background(), "hello", &out, ChatOptions{JSON: true})
+	if err == nil || !strings.Contains(err.Error(), "empty response") {
+		t.Errorf("expected an empty JSON answer to fail, got %v", err)
+	}
+}
+
+func TestChat_Tee(t *testing.T) {
+	var req api.ChatRequest
+	server := startReplyServer(t, []string{"It is ", "Monday."}, &req)
+	client := NewClient(extractAddr(t, server.URL))
+
+	var out, tee bytes.Buffer
+	if err := client.Chat(context.Background(), "hello", &out, ChatOptions{Tee: &tee}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "It is Monday.") || !strings.Contains(tee.String(), "It is Monday.") {
+		t.Errorf("expected the answer in both writers, got %q and %q", out.String(), tee.String())
+	}
+	if !strings.Contains(out.String(), "\x1b[") {
+		t.Errorf("expected colors in the output, got %q", out.String())
+	}
+	if strings.Contains(tee.String(), "\x1b[") {
+		t.Errorf("expected the tee to be plain text, got %q", tee.String())
+	}
+	if !strings.Contains(tee.String(), "date") {
+		t.Errorf("expected the tool call in the tee, got %q", tee.String())
+	}
+
+	// Scripted output is copied as is
+	tee.Reset()
+	out.Reset()
+	if err := client.Chat(context.Background(), "hello", &out, ChatOptions{Output: OutputJSON, Tee: &tee}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tee.String() != out.String() {
+		t.Errorf("expected the same JSON in the tee, got %q and %q", out.String(), tee.String())
+	}
+}
+
+func TestChat_ApprovePlan(t *testing.T) {
+	var gotReq, gotDecision api.ChatRequest
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_, data, _ := conn.ReadMessage()
+		_ = proto.Unmarshal(data, &gotReq)
+		approval, _ := proto.Marshal(&api.ChatResponse{
+			Payload: &api.ChatResponse_PlanApproval{PlanApproval: &api.Plan{Steps: []*api.PlanStep{{Id: "step_1", Tool: "shell"}}}},
+		})
+		_ = conn.WriteMessage(websocket.BinaryMessage, approval)
+
+		_, data, _ = conn.ReadMessage()
+		_ = proto.Unmarshal(data, &gotDecision)
+		done, _ := proto.Marshal(&api.ChatResponse{Payload: &api.ChatResponse_Done{Done: true}})
+		_ = conn.WriteMessage(websocket.BinaryMessage, done)
+	}))
+	t.Cleanup(server.Close)
+	client := NewClient(extractAddr(t, server.URL))
+
+	var asked *api.Plan
+	opts := ChatOptions{ApprovePlan: func(plan *api.Plan) bool {
+		asked = plan
+		return true
+	}}
+	if err := client.Chat(context.Background(), "clean up", io.Discard, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !gotReq.ApprovePlans {
+		t.Error("expected plan approval to be requested")
+	}
+	if asked == nil || len(asked.Steps) != 1 {
+		t.Errorf("expected to be asked about the plan, got %v", asked)
+	}
+	if gotDecision.PlanDecision == nil || !gotDecision.PlanDecision.Approved {
+		t.Errorf("expected an approval to be sent, got %v", &gotDecision)
+	}
+}
+
+func TestChat_JSON(t *testing.T) {
+	var req api.ChatRequest
+	server := startReplyServer(t, []string{`{"answer":`, ` 42}`}, &req)
+	client := NewClient(extractAddr(t, server.URL))
+
+	var out bytes.Buffer
+	if err := client.Chat(context.Background(), "hello", &out, ChatOptions{JSON: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if req.Format != "json" {
+		t.Errorf("expected json format to be requested, got %q", req.Format)
+	}
+	if out.String() != "{\"answer\": 42}\n" {
+		t.Errorf("expected only the JSON answer, got %q", out.String())
+	}
+}
+
+func TestChat_JSON_Invalid(t *testing.T) {
+	var req api.ChatRequest
+	server := startReplyServer(t, []string{"not json"}, &req)
+	client := NewClient(extractAddr(t, server.URL))
+
+	var out bytes.Buffer
+	err := client.Chat(context.Background(), "hello", &out, ChatOptions{JSON: true})
+	if err == nil || !strings.Contains(err.Error(), "not valid JSON") {
+		t.Errorf("expected invalid JSON error, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no output for invalid JSON, got %q", out.String())
+	}
+}
+
+func TestCompleteBlocksEnd(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"no blank line", "# Title\nsome text", 0},
+		{"one complete block", "# Title\n\nmore", len("# Title\n\n")},
+		{"last blank line wins", "a\n\nb\n\nc", len("a\n\nb\n\n")},
+		{"blank line inside fence", "```go\nx := 1\n\ny := 2\n", 0},
+		{"closed fence", "```\ncode\n\n```\n\nafter", len("```\ncode\n\n```\n\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := completeBlocksEnd(tt.text); got != tt.want {
+				t.Errorf("completeBlocksEnd(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownStreamer_Plain(t *testing.T) {
+	var out bytes.Buffer
+	md := newMarkdownStreamer(&out, false)
+
+	md.Write("# Title")
+	if out.String() != "\n# Title" {
+		t.Errorf("expected plain text to be written through, got %q", out.String())
+	}
+	md.Write(" and **bold**")
+	md.Flush()
+	if out.String() != "\n# Title and **bold**" {
+		t.Errorf("expected raw markdown, got %q", out.String())
+	}
+}
+
+func TestMarkdownStreamer_RendersCompleteBlocks(t *testing.T) {
+	var out bytes.Buffer
+	md := newMarkdownStreamer(&out, true)
+
+	md.Write("First\n\nSec")
+	if !strings.Contains(out.String(), "First") {
+		t.Errorf("expected completed block to be rendered, got %q", out.String())
+	}
+	if strings.Contains(out.String(), "Sec") {
+		t.Errorf("expected incomplete block to stay buffered, got %q", out.String())
+	}
+
+	md.Write("ond")
+	md.Flush()
+	if !strings.Contains(out.String(), "Second") {
+		t.Errorf("expected remaining block to be rendered on flush, got %q", out.String())
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	same := &api.VersionResponse{Version: version.Version, Commit: version.Commit, Protocol: version.Protocol}
+	if warning, err := CheckVersion(same); warning != "" || err != nil {
+		t.Errorf("expected no warning for the same build, got %q, %v", warning, err)
+	}
+
+	newer := &api.VersionResponse{Version: "99.0.0", Protocol: version.Protocol}
+	warning, err := CheckVersion(newer)
+	if err != nil || !strings.Contains(warning, "99.0.0") {
+		t.Errorf("expected a warning naming the daemon version, got %q, %v", warning, err)
+	}
+
+	// Daemons that predate protocol revisions can only be warned about
+	old := &api.VersionResponse{Version: "0.0.1"}
+	if warning, err := CheckVersion(old); warning == "" || err != nil {
+		t.Errorf("expected only a warning for a daemon without a protocol, got %q, %v", warning, err)
+	}
+
+	incompatible := &api.VersionResponse{Version: "99.0.0", Protocol: version.Protocol + 1}
+	if _, err := CheckVersion(incompatible); !errors.Is(err, ErrIncompatibleDaemon) {
+		t.Errorf("expected ErrIncompatibleDaemon, got %v", err)
+	}
+}
+
+func TestChatError_Is(t *testing.T) {
+	err := fmt.Errorf("chat failed: %w", newChatError(&api.ChatError{
+		Code:    api.ErrorCode_ERROR_RATE_LIMITED,
+		Message: "rate limit exceeded, please slow down",
+	}))
+
+	if !errors.Is(err, ErrRateLimited) {
+		t.Error("expected a rate limited error to match ErrRateLimited")
+	}
+	if errors.Is(err, ErrModel) {
+		t.Error("expected a rate limited error not to match ErrModel")
+	}
+
+	var chatErr *ChatError
+	if !errors.As(err, &chatErr) || chatErr.Code != api.ErrorCode_ERROR_RATE_LIMITED {
+		t.Fatalf("expected a *ChatError with the rate limited code, got %v", err)
+	}
+	if got := chatErr.Error(); got != "server error: rate limit exceeded, please slow down" {
+		t.Errorf("unexpected message %q", got)
+	}
+
+	withDetail := &ChatError{Code: api.ErrorCode_ERROR_MODEL, Message: "model unavailable", Detail: "model: llama3"}
+	if got := withDetail.Error(); got != "server error: model unavailable (model: llama3)" {
+		t.Errorf("unexpected message %q", got)
+	}
+
+	if !errors.Is(&connectionError{err: errors.New("dial tcp: refused")}, ErrConnection) {
+		t.Error("expected connection failures to match ErrConnection")
+	}
+}
+
+func TestFormatDiscoveryStep(t *testing.T) {
+	got := formatDiscoveryStep(&api.DiscoveryStep{Command: "git --help", Summary: "120 lines of help"})
+	if !strings.Contains(got, "discovering: git --help (120 lines of help)") {
+		t.Errorf("expected command and summary, got %q", got)
+	}
+
+	got = formatDiscoveryStep(&api.DiscoveryStep{Summary: "schema for git: 12 flags"})
+	if !strings.Contains(got, "discovering: schema for git: 12 flags") {
+		t.Errorf("expected summary only, got %q", got)
 	}
-	return port
 }
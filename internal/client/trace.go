@@ -0,0 +1,148 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/marciniwanicki/craby/internal/api"
+)
+
+// tracer writes every step of the agent loop to a trace, separate from the answer:
+// plans, tool calls with their arguments, raw tool output and the model's text
+type tracer struct {
+	w     io.Writer
+	start time.Time
+
+	// Text and thinking arrive in chunks and are written as one block once the turn moves on
+	pending     strings.Builder
+	pendingKind string
+}
+
+// newTracer returns a tracer writing to w, or nil when w is nil
+func newTracer(w io.Writer, start time.Time) *tracer {
+	if w == nil {
+		return nil
+	}
+	return &tracer{w: w, start: start}
+}
+
+// observe writes a response to the trace
+func (t *tracer) observe(resp *api.ChatResponse) {
+	if t == nil {
+		return
+	}
+
+	switch payload := resp.Payload.(type) {
+	case *api.ChatResponse_Text:
+		kind := "model text"
+		if payload.Text.Role == api.Role_SYSTEM {
+			kind = "system text"
+		}
+		t.buffer(kind, payload.Text.Content)
+		return
+
+	case *api.ChatResponse_Thinking:
+		t.buffer("model thinking", payload.Thinking)
+		return
+	}
+
+	t.flush()
+	switch payload := resp.Payload.(type) {
+	case *api.ChatResponse_QueuePosition:
+		if payload.QueuePosition > 0 {
+			t.line("queued, %s in line", ordinal(payload.QueuePosition))
+		} else {
+			t.line("model started")
+		}
+
+	case *api.ChatResponse_Plan:
+		plan := payload.Plan
+		t.line("plan: intent=%q complexity=%s ready_to_answer=%t", plan.Intent, plan.Complexity, plan.ReadyToAnswer)
+		for _, step := range plan.Steps {
+			t.block(fmt.Sprintf("  %s %s: %s", step.Id, step.Tool, step.Purpose), indentJSON(step.Arguments))
+		}
+
+	case *api.ChatResponse_PlanApproval:
+		t.line("plan waiting for approval: %d steps", len(payload.PlanApproval.Steps))
+
+	case *api.ChatResponse_ToolCall:
+		call := payload.ToolCall
+		t.block(fmt.Sprintf("tool call %s (id %s)", call.Name, call.Id), indentJSON(call.Arguments))
+
+	case *api.ChatResponse_ToolResult:
+		result := payload.ToolResult
+		status := "ok"
+		if !result.Success {
+			status = "failed"
+		}
+		t.block(fmt.Sprintf("tool result %s (id %s): %s in %dms, %d bytes", result.Name, result.Id, status, result.DurationMs, len(result.Output)), result.Output)
+
+	case *api.ChatResponse_ShellCommand:
+		t.line("shell command: %s", payload.ShellCommand.Command)
+
+	case *api.ChatResponse_DiscoveryStep:
+		step := payload.DiscoveryStep
+		if step.Command == "" {
+			t.line("discovery: %s", step.Summary)
+		} else {
+			t.line("discovery: %s (%s)", step.Command, step.Summary)
+		}
+
+	case *api.ChatResponse_Done:
+		t.line("done: model=%s fallback=%t prompt_tokens=%d completion_tokens=%d eval=%dms",
+			resp.Model, resp.Fallback, resp.PromptTokens, resp.CompletionTokens, resp.EvalDurationMs)
+
+	case *api.ChatResponse_Error:
+		t.line("error: %s: %s", payload.Error.Code, payload.Error.Message)
+	}
+	// Shell output lines are left out, the tool result carries the full output
+}
+
+// buffer collects a chunk of streamed text, flushing text of another kind first
+func (t *tracer) buffer(kind, text string) {
+	if t.pendingKind != kind {
+		t.flush()
+		t.pendingKind = kind
+	}
+	t.pending.WriteString(text)
+}
+
+// flush writes buffered text as one block
+func (t *tracer) flush() {
+	if t.pendingKind == "" {
+		return
+	}
+	t.block(t.pendingKind, t.pending.String())
+	t.pending.Reset()
+	t.pendingKind = ""
+}
+
+// line writes a single trace line, prefixed with the time since the request was sent
+func (t *tracer) line(format string, args ...any) {
+	fmt.Fprintf(t.w, "[trace %6.2fs] %s\n", time.Since(t.start).Seconds(), fmt.Sprintf(format, args...))
+}
+
+// block writes a trace line followed by its body, indented and unabridged
+func (t *tracer) block(header, body string) {
+	t.line("%s", header)
+	body = strings.TrimRight(body, "\n")
+	if body == "" {
+		return
+	}
+	for _, l := range strings.Split(body, "\n") {
+		fmt.Fprintf(t.w, "    %s\n", l)
+	}
+}
+
+// indentJSON pretty-prints JSON arguments, returning anything else unchanged
+func indentJSON(s string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(s), "", "  "); err != nil {
+		return s
+	}
+	return buf.String()
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marciniwanicki/craby/internal/api"
+)
+
+func TestTracer_Observe(t *testing.T) {
+	var buf bytes.Buffer
+	tr := newTracer(&buf, time.Now())
+
+	responses := []*api.ChatResponse{
+		{Payload: &api.ChatResponse_Plan{Plan: &api.Plan{
+			Intent:     "list files",
+			Complexity: "simple",
+			Steps:      []*api.PlanStep{{Id: "step_1", Tool: "shell", Purpose: "list", Arguments: `{"command":"ls"}`}},
+		}}},
+		{Payload: &api.ChatResponse_Thinking{Thinking: "I should "}},
+		{Payload: &api.ChatResponse_Thinking{Thinking: "run ls"}},
+		{Payload: &api.ChatResponse_ToolCall{ToolCall: &api.ToolCall{Id: "call_1", Name: "shell", Arguments: `{"command":"ls"}`}}},
+		{Payload: &api.ChatResponse_ShellOutput{ShellOutput: "a.txt"}},
+		{Payload: &api.ChatResponse_ToolResult{ToolResult: &api.ToolResult{Id: "call_1", Name: "shell", Output: "a.txt\nb.txt\n", Success: true, DurationMs: 12}}},
+		{Payload: &api.ChatResponse_Text{Text: &api.TextChunk{Content: "Two ", Role: api.Role_ASSISTANT}}},
+		{Payload: &api.ChatResponse_Text{Text: &api.TextChunk{Content: "files.", Role: api.Role_ASSISTANT}}},
+		{Payload: &api.ChatResponse_Done{Done: true}, Model: "qwen2.5:14b", CompletionTokens: 5},
+	}
+	for _, resp := range responses {
+		tr.observe(resp)
+	}
+
+	got := buf.String()
+	want := []string{
+		`plan: intent="list files" complexity=simple ready_to_answer=false`,
+		"  step_1 shell: list\n",
+		"] model thinking\n    I should run ls\n",
+		"] tool call shell (id call_1)\n    {\n      \"command\": \"ls\"\n    }\n",
+		"] tool result shell (id call_1): ok in 12ms, 12 bytes\n    a.txt\n    b.txt\n",
+		"] model text\n    Two files.\n",
+		"done: model=qwen2.5:14b fallback=false prompt_tokens=0 completion_tokens=5",
+	}
+	last := -1
+	for _, w := range want {
+		i := strings.Index(got, w)
+		if i < 0 {
+			t.Fatalf("expected trace to contain %q, got:\n%s", w, got)
+		}
+		if i < last {
+			t.Errorf("expected %q after the previous step, got:\n%s", w, got)
+		}
+		last = i
+	}
+	if strings.Contains(got, "\x1b[") {
+		t.Errorf("expected no color codes in the trace, got:\n%s", got)
+	}
+	if strings.Count(got, "a.txt") != 1 {
+		t.Errorf("expected streamed shell output left out, got:\n%s", got)
+	}
+}
+
+func TestTracer_Nil(t *testing.T) {
+	if tr := newTracer(nil, time.Now()); tr != nil {
+		t.Fatal("expected no tracer without a writer")
+	}
+	var tr *tracer
+	tr.observe(&api.ChatResponse{Payload: &api.ChatResponse_Done{Done: true}})
+}
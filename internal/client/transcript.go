@@ -0,0 +1,121 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TranscriptTurn is one exchange of a chat session
+type TranscriptTurn struct {
+	Time      time.Time        `json:"time"`
+	User      string           `json:"user"`
+	Assistant string           `json:"assistant"`
+	Model     string           `json:"model,omitempty"`
+	ToolCalls []ToolCallResult `json:"tool_calls,omitempty"`
+}
+
+// Transcript records the exchanges of a chat session so they can be saved to a file
+type Transcript struct {
+	SessionID string           `json:"session_id"`
+	SavedAt   time.Time        `json:"saved_at"`
+	Turns     []TranscriptTurn `json:"turns"`
+
+	mu sync.Mutex
+}
+
+// NewTranscript creates an empty transcript for a session
+func NewTranscript(sessionID string) *Transcript {
+	return &Transcript{SessionID: sessionID}
+}
+
+// Add records a completed exchange. Does nothing on a nil transcript.
+func (t *Transcript) Add(message string, result ChatResult) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Turns = append(t.Turns, TranscriptTurn{
+		Time:      time.Now(),
+		User:      message,
+		Assistant: result.Content,
+		Model:     result.Model,
+		ToolCalls: result.ToolCalls,
+	})
+}
+
+// Len returns the number of recorded exchanges
+func (t *Transcript) Len() int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.Turns)
+}
+
+// Save writes the transcript to path, as JSON lines for .json/.jsonl files and markdown otherwise.
+// An existing file is appended to, after a separator, rather than overwritten.
+func (t *Transcript) Save(path string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.SavedAt = time.Now()
+
+	var content string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".jsonl":
+		data, err := json.Marshal(t)
+		if err != nil {
+			return fmt.Errorf("failed to marshal transcript: %w", err)
+		}
+		content = string(data) + "\n" // One session per line
+	default:
+		content = t.markdown()
+		if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+			content = "\n---\n\n" + content
+		}
+	}
+
+	//nolint:gosec // G304: path is chosen by the user
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open transcript file: %w", err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+	return f.Close()
+}
+
+// markdown renders the transcript as a markdown document
+func (t *Transcript) markdown() string {
+	var sb strings.Builder
+	sb.WriteString("# Craby transcript\n\n")
+	sb.WriteString(fmt.Sprintf("Session `%s`, saved %s\n", t.SessionID, t.SavedAt.Format(time.RFC3339)))
+
+	for _, turn := range t.Turns {
+		sb.WriteString(fmt.Sprintf("\n## User\n\n%s\n", turn.User))
+		for _, call := range turn.ToolCalls {
+			status := "failed"
+			if call.Success {
+				status = "succeeded"
+			}
+			sb.WriteString(fmt.Sprintf("\n### Tool call: %s (%s, %dms)\n", call.Name, status, call.DurationMs))
+			if len(call.Arguments) > 0 {
+				sb.WriteString(fmt.Sprintf("\n```json\n%s\n```\n", call.Arguments))
+			}
+			if call.Output != "" {
+				sb.WriteString(fmt.Sprintf("\n```\n%s\n```\n", strings.TrimRight(call.Output, "\n")))
+			}
+		}
+		sb.WriteString(fmt.Sprintf("\n## Assistant\n\n%s\n", turn.Assistant))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marciniwanicki/craby/internal/api"
+)
+
+func testTranscript() *Transcript {
+	tr := NewTranscript("abc123")
+	tr.Add("what day is it?", ChatResult{
+		Content: "It is Monday.",
+		Model:   "qwen2.5:14b",
+		ToolCalls: []ToolCallResult{
+			{Name: "shell", Arguments: json.RawMessage(`{"command":"date"}`), Success: true, DurationMs: 5, Output: "Mon"},
+		},
+	})
+	return tr
+}
+
+func TestTranscript_SaveMarkdown_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat.md")
+	tr := testTranscript()
+
+	if err := tr.Save(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tr.Save(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read transcript: %v", err)
+	}
+	content := string(data)
+	for _, want := range []string{"## User\n\nwhat day is it?", "### Tool call: shell (succeeded, 5ms)", `{"command":"date"}`, "## Assistant\n\nIt is Monday."} {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected transcript to contain %q, got:\n%s", want, content)
+		}
+	}
+	if strings.Count(content, "# Craby transcript") != 2 || strings.Count(content, "\n---\n") != 1 {
+		t.Errorf("expected two sessions separated once, got:\n%s", content)
+	}
+}
+
+func TestTranscript_SaveJSON_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat.json")
+	tr := testTranscript()
+
+	for range 2 {
+		if err := tr.Save(path); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read transcript: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one JSON line per save, got %d", len(lines))
+	}
+	var saved Transcript
+	if err := json.Unmarshal([]byte(lines[1]), &saved); err != nil {
+		t.Fatalf("invalid JSON line: %v", err)
+	}
+	if saved.SessionID != "abc123" || len(saved.Turns) != 1 || saved.Turns[0].ToolCalls[0].Name != "shell" {
+		t.Errorf("unexpected transcript: %+v", saved.Turns)
+	}
+}
+
+func TestChat_RecordsTranscript(t *testing.T) {
+	var req api.ChatRequest
+	server := startReplyServer(t, []string{"It is ", "Monday."}, &req)
+	client := NewClient(extractAddr(t, server.URL))
+
+	tr := NewTranscript(client.SessionID())
+	var out bytes.Buffer
+	if err := client.Chat(context.Background(), "what day is it?", &out, ChatOptions{Transcript: tr}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tr.Len() != 1 {
+		t.Fatalf("expected 1 recorded turn, got %d", tr.Len())
+	}
+	turn := tr.Turns[0]
+	if turn.User != "what day is it?" || turn.Assistant != "It is Monday." || len(turn.ToolCalls) != 1 {
+		t.Errorf("unexpected turn: %+v", turn)
+	}
+}
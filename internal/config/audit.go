@@ -0,0 +1,99 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// CommandAuditEntry is one line of the command audit log
+type CommandAuditEntry struct {
+	Time        time.Time `json:"time"`
+	SessionID   string    `json:"session_id,omitempty"`
+	Command     string    `json:"command"`
+	IsDiscovery bool      `json:"is_discovery"`
+	ExitCode    int       `json:"exit_code"` // -1 if the command didn't exit normally, e.g. it timed out
+	DurationMs  int64     `json:"duration_ms"`
+	OutputBytes int       `json:"output_bytes"`
+	OutputHash  string    `json:"output_sha256"` // Truncated SHA-256 of the output the model saw
+}
+
+// CommandAuditLog appends every executed command to ~/.craby/logs/commands.jsonl.
+// Unlike the main log it is never cleared on startup, only rotated.
+type CommandAuditLog struct {
+	mu     sync.Mutex
+	writer io.WriteCloser
+}
+
+// CommandAuditPath returns the path to the command audit log
+func CommandAuditPath() (string, error) {
+	dir, err := LogsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "commands.jsonl"), nil
+}
+
+// NewCommandAuditLog opens the command audit log, rotating it like the main log
+func NewCommandAuditLog(cfg LogConfig) (*CommandAuditLog, error) {
+	path, err := CommandAuditPath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit log path: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create logs directory: %w", err)
+	}
+
+	return &CommandAuditLog{
+		writer: &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    cfg.MaxSize,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAge,
+			Compress:   cfg.Compress,
+		},
+	}, nil
+}
+
+// Record appends an entry as a single JSON line. Safe to call on a nil log.
+func (l *CommandAuditLog) Record(entry CommandAuditEntry) error {
+	if l == nil {
+		return nil
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.writer.Write(data)
+	return err
+}
+
+// Close closes the underlying file
+func (l *CommandAuditLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.writer.Close()
+}
+
+// OutputHash returns the first 16 hex characters of the SHA-256 of output
+func OutputHash(output string) string {
+	sum := sha256.Sum256([]byte(output))
+	return hex.EncodeToString(sum[:])[:16]
+}
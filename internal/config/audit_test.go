@@ -0,0 +1,64 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCommandAuditLog_Record(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	log, err := NewCommandAuditLog(DefaultLogConfig())
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+
+	entries := []CommandAuditEntry{
+		{SessionID: "s1", Command: "ls -la", ExitCode: 0, OutputHash: OutputHash("a")},
+		{SessionID: "s1", Command: "git --help", IsDiscovery: true, ExitCode: 129},
+	}
+	for _, entry := range entries {
+		if err := log.Record(entry); err != nil {
+			t.Fatalf("failed to record entry: %v", err)
+		}
+	}
+	if err := log.Close(); err != nil {
+		t.Fatalf("failed to close audit log: %v", err)
+	}
+
+	path, _ := CommandAuditPath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %s", len(lines), data)
+	}
+	var got CommandAuditEntry
+	if err := json.Unmarshal([]byte(lines[1]), &got); err != nil {
+		t.Fatalf("invalid JSON line: %v", err)
+	}
+	if got.Command != "git --help" || !got.IsDiscovery || got.ExitCode != 129 || got.Time.IsZero() {
+		t.Errorf("unexpected entry %+v", got)
+	}
+}
+
+func TestCommandAuditLog_NilSafe(t *testing.T) {
+	var log *CommandAuditLog
+	if err := log.Record(CommandAuditEntry{Command: "ls"}); err != nil {
+		t.Errorf("expected nil log to ignore records, got %v", err)
+	}
+	if err := log.Close(); err != nil {
+		t.Errorf("expected nil log to close cleanly, got %v", err)
+	}
+}
+
+func TestOutputHash(t *testing.T) {
+	if got := OutputHash("hello"); got != "2cf24dba5fb0a30e" {
+		t.Errorf("unexpected hash %s", got)
+	}
+}
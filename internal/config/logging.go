@@ -1,11 +1,14 @@
 package config
 
 import (
+	"bufio"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -45,6 +48,146 @@ func LogsDir() (string, error) {
 	return filepath.Join(dir, "logs"), nil
 }
 
+// logFileName is the daemon log in the logs directory. Rotated backups are named
+// craby-<timestamp>.log, and craby-<timestamp>.log.gz once compressed.
+const logFileName = "craby.log"
+
+// maxLogLineSize bounds a single line read back from the log
+const maxLogLineSize = 1024 * 1024
+
+// LogPath returns the path to the daemon log, ~/.craby/logs/craby.log
+func LogPath() (string, error) {
+	dir, err := LogsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, logFileName), nil
+}
+
+// LogFiles returns the daemon log followed by its rotated backups, newest first.
+// Files that don't exist are left out.
+func LogFiles() ([]string, error) {
+	logPath, err := LogPath()
+	if err != nil {
+		return nil, err
+	}
+	return logFiles(logPath)
+}
+
+func logFiles(logPath string) ([]string, error) {
+	var files []string
+	if _, err := os.Stat(logPath); err == nil {
+		files = append(files, logPath)
+	}
+
+	ext := filepath.Ext(logPath)
+	prefix := strings.TrimSuffix(filepath.Base(logPath), ext) + "-"
+	entries, err := os.ReadDir(filepath.Dir(logPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return files, nil
+		}
+		return nil, err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if strings.HasSuffix(name, ext) || strings.HasSuffix(name, ext+".gz") {
+			backups = append(backups, filepath.Join(filepath.Dir(logPath), name))
+		}
+	}
+	// Backup names embed a sortable timestamp
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+
+	return append(files, backups...), nil
+}
+
+// TailLog returns the last n lines accepted by match across files, which are
+// ordered newest first as returned by LogFiles. Older files are only read when
+// the newer ones don't have enough lines. A nil match accepts every line.
+func TailLog(files []string, n int, match func(line []byte) bool) ([]string, error) {
+	var tail []string
+	for _, file := range files {
+		if len(tail) >= n {
+			break
+		}
+		lines, err := readLogLines(file, match)
+		if err != nil {
+			return nil, err
+		}
+		// Prepend this older file's last lines
+		if missing := n - len(tail); len(lines) > missing {
+			lines = lines[len(lines)-missing:]
+		}
+		tail = append(lines, tail...)
+	}
+	return tail, nil
+}
+
+// readLogLines reads the lines of a log file accepted by match, decompressing .gz backups
+func readLogLines(path string, match func(line []byte) bool) ([]string, error) {
+	//nolint:gosec // Log files in user's config directory
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // Rotated away since it was listed
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+	for scanner.Scan() {
+		if match == nil || match(scanner.Bytes()) {
+			lines = append(lines, scanner.Text())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return lines, nil
+}
+
+// LogLineLevel returns the level of a JSON log line, or false if the line has none
+func LogLineLevel(line []byte) (zerolog.Level, bool) {
+	var entry struct {
+		Level string `json:"level"`
+	}
+	if err := json.Unmarshal(line, &entry); err != nil || entry.Level == "" {
+		return zerolog.NoLevel, false
+	}
+	level, err := zerolog.ParseLevel(entry.Level)
+	if err != nil {
+		return zerolog.NoLevel, false
+	}
+	return level, true
+}
+
+// DaemonOutputPath returns the path to the file capturing a background daemon's stdout/stderr
+func DaemonOutputPath() (string, error) {
+	dir, err := LogsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "daemon.out"), nil
+}
+
 // SetupLogger creates a zerolog logger that writes to both stdout and a rolling log file
 func SetupLogger(cfg LogConfig) (zerolog.Logger, io.Closer, error) {
 	logsDir, err := LogsDir()
@@ -57,7 +200,7 @@ func SetupLogger(cfg LogConfig) (zerolog.Logger, io.Closer, error) {
 		return zerolog.Logger{}, nil, fmt.Errorf("failed to create logs directory: %w", err)
 	}
 
-	logPath := filepath.Join(logsDir, "craby.log")
+	logPath := filepath.Join(logsDir, logFileName)
 
 	// Delete existing log file to start fresh each daemon session
 	_ = os.Remove(logPath)
@@ -96,7 +239,7 @@ func SetupFileOnlyLogger(cfg LogConfig) (zerolog.Logger, io.Closer, error) {
 		return zerolog.Logger{}, nil, fmt.Errorf("failed to create logs directory: %w", err)
 	}
 
-	logPath := filepath.Join(logsDir, "craby.log")
+	logPath := filepath.Join(logsDir, logFileName)
 
 	// Delete existing log file to start fresh each daemon session
 	_ = os.Remove(logPath)
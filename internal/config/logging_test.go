@@ -0,0 +1,98 @@
+package config
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func writeGzip(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogFiles_NewestFirst(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "craby.log")
+	_ = os.WriteFile(logPath, []byte("current\n"), 0600)
+	_ = os.WriteFile(filepath.Join(dir, "craby-2026-01-02T10-00-00.000.log"), []byte("newer\n"), 0600)
+	writeGzip(t, filepath.Join(dir, "craby-2026-01-01T10-00-00.000.log.gz"), "older\n")
+	_ = os.WriteFile(filepath.Join(dir, "commands.jsonl"), []byte("{}\n"), 0600)
+
+	files, err := logFiles(logPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		logPath,
+		filepath.Join(dir, "craby-2026-01-02T10-00-00.000.log"),
+		filepath.Join(dir, "craby-2026-01-01T10-00-00.000.log.gz"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("expected %v, got %v", want, files)
+	}
+}
+
+func TestTailLog_ReadsBackupsWhenNeeded(t *testing.T) {
+	dir := t.TempDir()
+	current := filepath.Join(dir, "craby.log")
+	backup := filepath.Join(dir, "craby-2026-01-01T10-00-00.000.log.gz")
+	_ = os.WriteFile(current, []byte("c1\nc2\n"), 0600)
+	writeGzip(t, backup, "b1\nb2\nb3\n")
+
+	lines, err := TailLog([]string{current, backup}, 1, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(lines, []string{"c2"}) {
+		t.Errorf("expected only the current log's last line, got %v", lines)
+	}
+
+	lines, err = TailLog([]string{current, backup}, 4, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(lines, []string{"b2", "b3", "c1", "c2"}) {
+		t.Errorf("expected lines from the backup in order, got %v", lines)
+	}
+}
+
+func TestTailLog_Match(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "craby.log")
+	_ = os.WriteFile(path, []byte(`{"level":"debug","message":"a"}
+{"level":"warn","message":"b"}
+not json
+{"level":"error","message":"c"}
+`), 0600)
+
+	atLeastWarn := func(line []byte) bool {
+		level, ok := LogLineLevel(line)
+		return ok && level >= zerolog.WarnLevel
+	}
+
+	lines, err := TailLog([]string{path}, 10, atLeastWarn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{`{"level":"warn","message":"b"}`, `{"level":"error","message":"c"}`}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("expected %v, got %v", want, lines)
+	}
+}
@@ -1,13 +1,18 @@
 package config
 
 import (
+	"cmp"
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 )
 
+// SchemaCacheTTL is how long a cached schema is used before the command is discovered again
+const SchemaCacheTTL = 7 * 24 * time.Hour
+
 // CachedSchema represents a cached tool schema
 type CachedSchema struct {
 	Command     string         `json:"command"`
@@ -17,6 +22,11 @@ type CachedSchema struct {
 	Version     string         `json:"version,omitempty"` // Optional: command version
 }
 
+// Expired reports whether the schema is too old to be used
+func (s *CachedSchema) Expired() bool {
+	return time.Since(s.GeneratedAt) > SchemaCacheTTL
+}
+
 // SchemaCache manages cached tool schemas
 type SchemaCache struct {
 	cacheDir string
@@ -64,8 +74,7 @@ func (c *SchemaCache) Get(command string) (*CachedSchema, bool) {
 		return nil, false
 	}
 
-	// Check if cache is expired (default: 7 days)
-	if time.Since(schema.GeneratedAt) > 7*24*time.Hour {
+	if schema.Expired() {
 		return nil, false
 	}
 
@@ -125,6 +134,41 @@ func (c *SchemaCache) List() ([]string, error) {
 	return commands, nil
 }
 
+// Entries returns every cached schema sorted by command, expired ones included.
+// Files that can't be read are skipped.
+func (c *SchemaCache) Entries() ([]*CachedSchema, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries, err := os.ReadDir(c.cacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var schemas []*CachedSchema
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(c.cacheDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var schema CachedSchema
+		if err := json.Unmarshal(data, &schema); err != nil {
+			continue
+		}
+		schemas = append(schemas, &schema)
+	}
+	slices.SortFunc(schemas, func(a, b *CachedSchema) int {
+		return cmp.Compare(a.Command, b.Command)
+	})
+	return schemas, nil
+}
+
 // Clear removes all cached schemas
 func (c *SchemaCache) Clear() error {
 	c.mu.Lock()
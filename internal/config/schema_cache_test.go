@@ -106,6 +106,32 @@ func TestSchemaCache_List(t *testing.T) {
 	}
 }
 
+func TestSchemaCache_Entries(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "schema_cache_test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(tmpDir) })
+
+	cache := &SchemaCache{cacheDir: tmpDir}
+	_ = cache.Set(&CachedSchema{Command: "tfl status", Schema: map[string]any{}})
+	//nolint:gosec // G306: test file, permissions are fine
+	_ = os.WriteFile(filepath.Join(tmpDir, "git.json"), []byte(`{"command":"git","schema":{},"generated_at":"2020-01-01T00:00:00Z"}`), 0600)
+	//nolint:gosec // G306: test file, permissions are fine
+	_ = os.WriteFile(filepath.Join(tmpDir, "broken.json"), []byte(`{`), 0600)
+
+	entries, err := cache.Entries()
+	if err != nil {
+		t.Fatalf("failed to list entries: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Command != "git" || entries[1].Command != "tfl status" {
+		t.Fatalf("expected git and tfl status sorted, got %+v", entries)
+	}
+	if !entries[0].Expired() || entries[1].Expired() {
+		t.Errorf("expected only git expired")
+	}
+}
+
 func TestSchemaCache_Clear(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "schema_cache_test")
 	if err != nil {
@@ -2,11 +2,15 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/marciniwanicki/craby/templates"
 )
@@ -14,9 +18,122 @@ import (
 // Settings represents the application settings
 type Settings struct {
 	Tools     ToolsSettings     `json:"tools"`
+	Daemon    DaemonSettings    `json:"daemon"`
+	Ollama    OllamaSettings    `json:"ollama"`
+	Redaction RedactionSettings `json:"redaction"`
 	Variables TemplateVariables `json:"variables"`
 }
 
+// RedactionSettings controls masking of secrets in tool output and logs
+type RedactionSettings struct {
+	Enabled bool `json:"enabled"`
+	// SensitiveKeys are case-insensitive substrings of env var names whose values are masked
+	SensitiveKeys []string `json:"sensitive_keys"`
+}
+
+// OllamaSettings contains settings for requests sent to Ollama
+type OllamaSettings struct {
+	// KeepAlive controls how long Ollama keeps the model loaded after a request,
+	// e.g. "30m", or "-1" to keep it loaded indefinitely. "0" unloads it immediately after each request.
+	// Empty uses Ollama's default (5 minutes).
+	KeepAlive string `json:"keep_alive"`
+	// Headers are sent with every Ollama request, e.g. {"Authorization": "Bearer ${OLLAMA_TOKEN}"}.
+	// Values may reference environment variables.
+	Headers map[string]string `json:"headers"`
+	// FallbackModels are tried in order when the primary model is missing or fails to load.
+	// Empty keeps using the primary model only.
+	FallbackModels []string `json:"fallback_models"`
+	// EmbeddingModel computes embeddings, separate from the chat model so a small dedicated model can be used.
+	// Empty uses the chat model.
+	EmbeddingModel string `json:"embedding_model"`
+	// GenerationTimeoutMinutes cuts off a single model request, including streaming its answer,
+	// so a runaway generation can't run forever (0 = unbounded).
+	// Connecting to Ollama fails fast regardless.
+	GenerationTimeoutMinutes int `json:"generation_timeout_minutes"`
+}
+
+// DefaultEmbeddingModel is a small model made for embeddings
+const DefaultEmbeddingModel = "nomic-embed-text"
+
+// OllamaAPIKeyEnv names the environment variable that, when set, is sent as a bearer token to Ollama
+const OllamaAPIKeyEnv = "CRABY_OLLAMA_API_KEY"
+
+// ResolvedHeaders returns the configured headers with environment variables expanded.
+// If CRABY_OLLAMA_API_KEY is set and no Authorization header is configured, it is sent as a bearer token.
+func (o OllamaSettings) ResolvedHeaders() map[string]string {
+	headers := make(map[string]string, len(o.Headers)+1)
+	for name, value := range o.Headers {
+		headers[name] = os.ExpandEnv(value)
+	}
+
+	if key := os.Getenv(OllamaAPIKeyEnv); key != "" {
+		hasAuth := false
+		for name := range headers {
+			if strings.EqualFold(name, "Authorization") {
+				hasAuth = true
+				break
+			}
+		}
+		if !hasAuth {
+			headers["Authorization"] = "Bearer " + key
+		}
+	}
+
+	return headers
+}
+
+// DaemonSettings contains daemon server settings
+type DaemonSettings struct {
+	RateLimit  RateLimitSettings  `json:"rate_limit"`
+	History    HistorySettings    `json:"history"`
+	Connection ConnectionSettings `json:"connection"`
+	Queue      QueueSettings      `json:"queue"`
+	Prompt     PromptSettings     `json:"prompt"`
+}
+
+// PromptSettings wraps every user message in standard instructions before it reaches the model
+type PromptSettings struct {
+	Prefix string `json:"prefix"` // Added before the message, separated by a blank line (empty = none)
+	Suffix string `json:"suffix"` // Added after the message, separated by a blank line (empty = none)
+}
+
+// QueueSettings limits how many chats generate at once, so they don't compete for the GPU
+type QueueSettings struct {
+	MaxConcurrent int `json:"max_concurrent"` // Chats generating at once (0 = unlimited)
+	MaxQueued     int `json:"max_queued"`     // Chats waiting for a turn before new ones are rejected as busy
+
+	// How long a chat keeps its turn waiting for the client to decide on a plan (0 = unlimited)
+	ApprovalTimeoutSeconds int `json:"approval_timeout_seconds"`
+}
+
+// ConnectionSettings controls chat websocket keepalive and idle handling
+type ConnectionSettings struct {
+	PingIntervalSeconds int `json:"ping_interval_seconds"` // Interval between pings; connections not answering within two intervals are closed (0 = disabled)
+	IdleTimeoutMinutes  int `json:"idle_timeout_minutes"`  // Close connections with no chat requests for this long (0 = never)
+	MaxMessageBytes     int `json:"max_message_bytes"`     // Largest chat request accepted; bigger ones close the connection (0 = unlimited)
+}
+
+// History trimming strategies
+const (
+	HistoryStrategyDrop      = "drop"      // Discard the oldest turns
+	HistoryStrategySummarize = "summarize" // Replace the oldest turns with an LLM-written summary
+)
+
+// HistorySettings controls how conversation history is kept within the model's context window
+type HistorySettings struct {
+	MaxTokens int    `json:"max_tokens"` // Approximate token budget for system prompt + history (0 = unlimited)
+	Strategy  string `json:"strategy"`   // "drop" or "summarize"
+	// ContextTurns is how many earlier turns are sent with each message
+	// (-1 = as many as fit max_tokens, 0 = none, so every message stands alone)
+	ContextTurns int `json:"context_turns"`
+}
+
+// RateLimitSettings contains chat request rate limiting settings
+type RateLimitSettings struct {
+	RequestsPerMinute int `json:"requests_per_minute"` // Chat requests allowed per minute (0 = unlimited)
+	Burst             int `json:"burst"`               // Maximum requests allowed at once (0 = same as requests_per_minute)
+}
+
 // TemplateVariables contains variables that are substituted in templates
 type TemplateVariables struct {
 	Username      string `json:"username"`
@@ -26,8 +143,190 @@ type TemplateVariables struct {
 
 // ToolsSettings contains tool-related settings
 type ToolsSettings struct {
-	Shell ShellSettings `json:"shell"`
-	Write WriteSettings `json:"write"`
+	// MaxConcurrent limits tool calls running at once across sessions, whatever the tool (0 = unlimited).
+	// Shell commands are additionally bounded by shell.max_concurrent.
+	MaxConcurrent int `json:"max_concurrent"`
+	// QueueTimeoutSeconds is how long a tool call waits for a free slot before failing
+	QueueTimeoutSeconds int `json:"queue_timeout_seconds"`
+	// Builtin names the built-in tools to register, see BuiltinToolNames. Unset registers all of them,
+	// an empty list none. A tool's own enabled setting still applies.
+	Builtin []string `json:"builtin"`
+
+	Shell  ShellSettings  `json:"shell"`
+	Write  WriteSettings  `json:"write"`
+	Read   ReadSettings   `json:"read"`
+	Search SearchSettings `json:"search"`
+	Fetch  FetchSettings  `json:"fetch"`
+	// Network is the egress policy shared by http_fetch and network commands run through the shell
+	Network NetworkSettings `json:"network"`
+	// Discovery customizes which well-known commands get_command_schema may inspect
+	Discovery DiscoverySettings `json:"discovery"`
+	// External configures tools loaded from ~/.craby/tools/
+	External ExternalToolsSettings `json:"external"`
+}
+
+// BuiltinToolNames lists the tools compiled into the daemon
+var BuiltinToolNames = []string{
+	"list_available_commands",
+	"get_command_schema",
+	"calculator",
+	"current_time",
+	"shell",
+	"write",
+	"read_file",
+	"search_files",
+	"http_fetch",
+}
+
+// BuiltinEnabled reports whether the built-in tool name is selected by tools.builtin
+func (t ToolsSettings) BuiltinEnabled(name string) bool {
+	return t.Builtin == nil || slices.Contains(t.Builtin, name)
+}
+
+// UnknownBuiltins returns the names in tools.builtin that aren't built-in tools
+func (t ToolsSettings) UnknownBuiltins() []string {
+	var unknown []string
+	for _, name := range t.Builtin {
+		if !slices.Contains(BuiltinToolNames, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+// ExternalToolsSettings contains settings for external tools
+type ExternalToolsSettings struct {
+	// StatusCacheMinutes is how long availability check results are reused across starts (0 = always re-check)
+	StatusCacheMinutes int `json:"status_cache_minutes"`
+}
+
+// ToolStatusCache returns the availability cache configured by these settings, or nil if caching is off
+func (e ExternalToolsSettings) ToolStatusCache() *ToolStatusCache {
+	cache, err := NewToolStatusCache(time.Duration(e.StatusCacheMinutes) * time.Minute)
+	if err != nil {
+		return nil
+	}
+	return cache
+}
+
+// DiscoverySettings adjusts the built-in set of well-known commands that can be discovered
+// without being in the shell allowlist, and how much of a multi-step discovery the model keeps in view
+type DiscoverySettings struct {
+	AddCommands    []string `json:"add_commands"`    // Extra commands treated as well-known, e.g. house tools
+	RemoveCommands []string `json:"remove_commands"` // Built-in commands to drop, e.g. ones not installed
+	// RetainedSteps is how many earlier step results the model sees when planning its next step, such
+	// as the next command to discover: the first schema discovered and then the most recent (0 = all)
+	RetainedSteps int `json:"retained_steps"`
+}
+
+// DefaultWellKnownCommands are common developer tools whose --help is always safe to inspect
+var DefaultWellKnownCommands = []string{
+	"git", "docker", "kubectl", "npm",
+	"yarn", "pnpm", "cargo", "go",
+	"python", "pip", "node", "ruby",
+	"make", "cmake", "gradle", "mvn",
+}
+
+// WellKnownCommands returns the built-in well-known commands merged with the discovery settings.
+// Removals win over additions.
+func (s *Settings) WellKnownCommands() map[string]bool {
+	commands := make(map[string]bool, len(DefaultWellKnownCommands)+len(s.Tools.Discovery.AddCommands))
+	for _, cmd := range DefaultWellKnownCommands {
+		commands[cmd] = true
+	}
+	for _, cmd := range s.Tools.Discovery.AddCommands {
+		commands[cmd] = true
+	}
+	for _, cmd := range s.Tools.Discovery.RemoveCommands {
+		delete(commands, cmd)
+	}
+	return commands
+}
+
+// FetchSettings contains http_fetch tool settings
+type FetchSettings struct {
+	Enabled        bool     `json:"enabled"`
+	AllowedHosts   []string `json:"allowed_hosts"`   // Hosts that may be fetched, including subdomains (empty = any host)
+	BlockPrivate   bool     `json:"block_private"`   // Refuse loopback, private and link-local addresses
+	MaxBytes       int      `json:"max_bytes"`       // Maximum response body returned to the model (0 = unlimited)
+	MaxRedirects   int      `json:"max_redirects"`   // Maximum redirects followed per request
+	TimeoutSeconds int      `json:"timeout_seconds"` // Request timeout
+}
+
+// NetworkSettings restricts the hosts tools may reach: http_fetch, and the targets of network
+// commands run through the shell. Denied hosts beat allowed ones.
+type NetworkSettings struct {
+	AllowedHosts []string `json:"allowed_hosts"` // Hosts that may be reached, including subdomains (empty = any host not denied)
+	DeniedHosts  []string `json:"denied_hosts"`  // Hosts that may never be reached, including subdomains
+	Commands     []string `json:"commands"`      // Shell commands whose targets are checked (nil = DefaultNetworkCommands)
+}
+
+// DefaultNetworkCommands are shell commands that connect to the host named in their arguments
+var DefaultNetworkCommands = []string{
+	"curl", "wget", "http", "https",
+	"ping", "ping6", "traceroute", "mtr",
+	"ssh", "scp", "sftp", "rsync", "ftp",
+	"nc", "ncat", "netcat", "telnet",
+	"dig", "nslookup", "host", "whois",
+}
+
+// NetworkCommandList returns the configured network commands, falling back to DefaultNetworkCommands when unset
+func (n NetworkSettings) NetworkCommandList() []string {
+	if n.Commands == nil {
+		return DefaultNetworkCommands
+	}
+	return n.Commands
+}
+
+// Restricted reports whether the policy limits any host
+func (n NetworkSettings) Restricted() bool {
+	return len(n.AllowedHosts) > 0 || len(n.DeniedHosts) > 0
+}
+
+// CheckHost returns an error explaining why host may not be reached, or nil if it may
+func (n NetworkSettings) CheckHost(host string) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		if len(n.AllowedHosts) > 0 {
+			return fmt.Errorf("missing host, only tools.network.allowed_hosts may be reached")
+		}
+		return nil
+	}
+	if MatchesHost(n.DeniedHosts, host) {
+		return fmt.Errorf("host %s is in tools.network.denied_hosts", host)
+	}
+	if len(n.AllowedHosts) > 0 && !MatchesHost(n.AllowedHosts, host) {
+		return fmt.Errorf("host %s is not in tools.network.allowed_hosts", host)
+	}
+	return nil
+}
+
+// MatchesHost reports whether host is one of hosts or a subdomain of one, ignoring case
+func MatchesHost(hosts []string, host string) bool {
+	host = strings.ToLower(host)
+	for _, h := range hosts {
+		h = strings.ToLower(h)
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadSettings contains read_file tool settings
+type ReadSettings struct {
+	Enabled      bool     `json:"enabled"`
+	Root         string   `json:"root"`          // Directory reads are confined to (supports ~)
+	BlockedPaths []string `json:"blocked_paths"` // Paths that are never read, in addition to tools.write.blocked_paths
+	MaxBytes     int      `json:"max_bytes"`     // Maximum file content returned to the model (0 = unlimited)
+}
+
+// SearchSettings contains search_files tool settings. Searches are confined to the read root,
+// and the tool is only registered while read_file is enabled.
+type SearchSettings struct {
+	Enabled    bool `json:"enabled"`
+	MaxResults int  `json:"max_results"` // Matching files or lines returned per search (0 = unlimited)
+	MaxBytes   int  `json:"max_bytes"`   // Maximum output returned to the model (0 = unlimited)
 }
 
 // WriteSettings contains write tool settings
@@ -36,18 +335,134 @@ type WriteSettings struct {
 	AllowedPaths []string `json:"allowed_paths"` // Paths where writing is allowed (supports ~)
 	BlockedPaths []string `json:"blocked_paths"` // Paths that are always blocked
 	MaxFileSize  int64    `json:"max_file_size"` // Maximum file size in bytes (0 = unlimited)
+
+	// Ask the chat's client to approve every write. Chats that can't be asked, e.g. without
+	// --plan or through the OpenAI API, can't write.
+	RequireApproval bool `json:"require_approval"`
+}
+
+// DefaultReadBlockedPaths are credential stores read_file and search_files never read
+var DefaultReadBlockedPaths = []string{
+	"~/.ssh", "~/.gnupg", "~/.aws", "~/.azure", "~/.config/gcloud", "~/.kube", "~/.docker",
+	"~/.netrc", "~/.pgpass", "~/.git-credentials", "~/.craby/settings.json",
 }
 
-// ShellSettings contains shell tool settings
+// ShellSettings contains shell tool settings.
+// Precedence: a denied pattern or denylisted command is always refused, even if the command is allowlisted.
 type ShellSettings struct {
-	Enabled   bool     `json:"enabled"`
-	Allowlist []string `json:"allowlist"`
+	Enabled        bool     `json:"enabled"`
+	Allowlist      []string `json:"allowlist"`
+	Denylist       []string `json:"denylist"`         // Base commands that are never allowed, overriding the allowlist
+	DeniedPatterns []string `json:"denied_patterns"`  // Substrings that reject a command (nil = DefaultDeniedPatterns)
+	MaxOutputBytes int      `json:"max_output_bytes"` // Maximum command output returned to the model (0 = unlimited)
+	// ArgumentRules restrict the arguments of allowlisted commands, keyed by base command
+	ArgumentRules map[string]ArgumentRule `json:"argument_rules"`
+	// InteractiveCommands need a TTY and are rejected up front (nil = DefaultInteractiveCommands)
+	InteractiveCommands []string `json:"interactive_commands"`
+	// MaxConcurrent limits commands running at once across sessions, including discovery (0 = unlimited)
+	MaxConcurrent int `json:"max_concurrent"`
+	// QueueTimeoutSeconds is how long a command waits for a free slot before being refused
+	QueueTimeoutSeconds int `json:"queue_timeout_seconds"`
+	// Binary is the shell commands run through with -c, a name on PATH or a full path (empty = DefaultShell)
+	Binary string `json:"binary,omitempty"`
+	// OutputFormat is how command results reach the model: ShellOutputText or ShellOutputJSON (empty = text)
+	OutputFormat string `json:"output_format"`
+}
+
+const (
+	// ShellOutputText returns stdout and stderr together, with a note on failures
+	ShellOutputText = "text"
+	// ShellOutputJSON returns a JSON object with stdout, stderr, exit_code and duration_ms
+	ShellOutputJSON = "json"
+)
+
+// DefaultShell is the shell commands run through when none is configured
+const DefaultShell = "sh"
+
+// ShellBinary returns the configured shell, falling back to DefaultShell when unset
+func (s ShellSettings) ShellBinary() string {
+	if s.Binary == "" {
+		return DefaultShell
+	}
+	return s.Binary
+}
+
+// ResolveShell returns the path of the configured shell, or an error explaining how to fix it if it isn't installed
+func (s ShellSettings) ResolveShell() (string, error) {
+	binary := s.ShellBinary()
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return "", fmt.Errorf("shell %q is not available (%w): set tools.shell.binary in settings.json to an installed shell, e.g. bash or a full path", binary, err)
+	}
+	return path, nil
+}
+
+// DefaultInteractiveCommands are editors, pagers and full-screen programs that hang without a terminal
+var DefaultInteractiveCommands = []string{
+	"vi", "vim", "nvim", "nano", "emacs", "pico",
+	"less", "more", "most", "man",
+	"top", "htop", "btop", "atop", "watch",
+	"ssh", "telnet", "ftp", "sftp",
+	"tmux", "screen",
+}
+
+// InteractiveCommandList returns the configured interactive commands, falling back to DefaultInteractiveCommands when unset
+func (s ShellSettings) InteractiveCommandList() []string {
+	if s.InteractiveCommands == nil {
+		return DefaultInteractiveCommands
+	}
+	return s.InteractiveCommands
+}
+
+// ArgumentRule restricts how an allowlisted command may be invoked
+type ArgumentRule struct {
+	// ForbiddenSubstrings reject the command if its arguments contain any of them, e.g. "-rf /"
+	ForbiddenSubstrings []string `json:"forbidden_substrings"`
+	// AllowedFlags, when set, is the complete list of flags (arguments starting with "-") that may be passed
+	AllowedFlags []string `json:"allowed_flags"`
+}
+
+// Check returns a reason the arguments violate the rule, or "" if they are allowed
+func (r ArgumentRule) Check(args []string) string {
+	joined := strings.Join(args, " ")
+	for _, forbidden := range r.ForbiddenSubstrings {
+		if forbidden != "" && strings.Contains(joined, forbidden) {
+			return fmt.Sprintf("arguments contain forbidden %q", forbidden)
+		}
+	}
+
+	if len(r.AllowedFlags) > 0 {
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "-") && !slices.Contains(r.AllowedFlags, arg) {
+				return fmt.Sprintf("flag %s is not allowed (allowed: %s)", arg, strings.Join(r.AllowedFlags, ", "))
+			}
+		}
+	}
+
+	return ""
+}
+
+// DefaultDeniedPatterns are shell operators that could chain commands, substitute output or redirect files
+var DefaultDeniedPatterns = []string{"&&", "||", ";", "|", "`", "$(", "${", ">", "<"}
+
+// DeniedPatternList returns the configured denied patterns, falling back to DefaultDeniedPatterns when unset
+func (s ShellSettings) DeniedPatternList() []string {
+	if s.DeniedPatterns == nil {
+		return DefaultDeniedPatterns
+	}
+	return s.DeniedPatterns
 }
 
 // DefaultSettings returns the default settings
 func DefaultSettings() *Settings {
 	return &Settings{
+		Ollama: OllamaSettings{
+			EmbeddingModel:           DefaultEmbeddingModel,
+			GenerationTimeoutMinutes: 30,
+		},
 		Tools: ToolsSettings{
+			MaxConcurrent:       8,
+			QueueTimeoutSeconds: 10,
 			Shell: ShellSettings{
 				Enabled: true,
 				Allowlist: []string{
@@ -64,6 +479,13 @@ func DefaultSettings() *Settings {
 					"hostname",
 					"uptime",
 				},
+				Denylist:            []string{"rm", "sudo", "su", "dd", "mkfs", "shutdown", "reboot"},
+				DeniedPatterns:      append([]string(nil), DefaultDeniedPatterns...),
+				InteractiveCommands: append([]string(nil), DefaultInteractiveCommands...),
+				MaxConcurrent:       4,
+				QueueTimeoutSeconds: 30,
+				MaxOutputBytes:      8 * 1024, // 8KB default
+				OutputFormat:        ShellOutputText,
 			},
 			Write: WriteSettings{
 				Enabled:      true,
@@ -71,6 +493,71 @@ func DefaultSettings() *Settings {
 				BlockedPaths: []string{"~/.ssh", "~/.gnupg", "~/.aws", "~/.craby/settings.json"},
 				MaxFileSize:  10 * 1024 * 1024, // 10MB default
 			},
+			Read: ReadSettings{
+				// Off until enabled, the root is the whole home directory
+				Enabled:      false,
+				Root:         "~",
+				BlockedPaths: append([]string(nil), DefaultReadBlockedPaths...),
+				MaxBytes:     32 * 1024, // 32KB default
+			},
+			Search: SearchSettings{
+				Enabled:    true,
+				MaxResults: 50,
+				MaxBytes:   16 * 1024, // 16KB default
+			},
+			Fetch: FetchSettings{
+				Enabled:        true,
+				AllowedHosts:   []string{},
+				BlockPrivate:   true,
+				MaxBytes:       64 * 1024, // 64KB default
+				MaxRedirects:   5,
+				TimeoutSeconds: 15,
+			},
+			Network: NetworkSettings{
+				AllowedHosts: []string{},
+				DeniedHosts:  []string{},
+			},
+			Discovery: DiscoverySettings{
+				RetainedSteps: 8,
+			},
+			External: ExternalToolsSettings{
+				StatusCacheMinutes: 24 * 60,
+			},
+		},
+		Daemon: DaemonSettings{
+			RateLimit: RateLimitSettings{
+				RequestsPerMinute: 20,
+				Burst:             5,
+			},
+			History: HistorySettings{
+				MaxTokens:    8000,
+				Strategy:     HistoryStrategySummarize,
+				ContextTurns: -1,
+			},
+			Connection: ConnectionSettings{
+				PingIntervalSeconds: 30,
+				IdleTimeoutMinutes:  30,
+				MaxMessageBytes:     1 << 20,
+			},
+			Queue: QueueSettings{
+				MaxConcurrent:          1,
+				MaxQueued:              8,
+				ApprovalTimeoutSeconds: 300,
+			},
+		},
+		Redaction: RedactionSettings{
+			Enabled: true,
+			SensitiveKeys: []string{
+				"TOKEN",
+				"SECRET",
+				"PASSWORD",
+				"PASSWD",
+				"API_KEY",
+				"APIKEY",
+				"ACCESS_KEY",
+				"PRIVATE_KEY",
+				"CREDENTIAL",
+			},
 		},
 		Variables: DefaultTemplateVariables(),
 	}
@@ -106,6 +593,15 @@ func ConfigDir() (string, error) {
 	return filepath.Join(home, ".craby"), nil
 }
 
+// REPLHistoryPath returns the path to ~/.craby/repl_history, the interactive chat's input history
+func REPLHistoryPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "repl_history"), nil
+}
+
 // SettingsPath returns the path to settings.json
 func SettingsPath() (string, error) {
 	dir, err := ConfigDir()
@@ -130,6 +626,9 @@ func Load() (*Settings, error) {
 		if err := settings.Save(); err != nil {
 			return nil, err
 		}
+		if err := settings.expandPaths(); err != nil {
+			return nil, err
+		}
 		return settings, nil
 	}
 
@@ -159,9 +658,52 @@ func Load() (*Settings, error) {
 		settings.Variables.OSName = defaults.OSName
 	}
 
+	if err := settings.expandPaths(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
 	return settings, nil
 }
 
+// expandPaths expands ~ and environment variables in the path settings when they are loaded,
+// so a settings file can be shared across machines
+func (s *Settings) expandPaths() error {
+	var err error
+	if s.Tools.Read.Root, err = expandSetting("tools.read.root", s.Tools.Read.Root); err != nil {
+		return err
+	}
+	for i, path := range s.Tools.Read.BlockedPaths {
+		if s.Tools.Read.BlockedPaths[i], err = expandSetting("tools.read.blocked_paths", path); err != nil {
+			return err
+		}
+	}
+	for i, path := range s.Tools.Write.AllowedPaths {
+		if s.Tools.Write.AllowedPaths[i], err = expandSetting("tools.write.allowed_paths", path); err != nil {
+			return err
+		}
+	}
+	for i, path := range s.Tools.Write.BlockedPaths {
+		if s.Tools.Write.BlockedPaths[i], err = expandSetting("tools.write.blocked_paths", path); err != nil {
+			return err
+		}
+	}
+	// A bare shell name is looked up on PATH, only paths are expanded
+	if strings.ContainsAny(s.Tools.Shell.Binary, "/~$") {
+		if s.Tools.Shell.Binary, err = expandSetting("tools.shell.binary", s.Tools.Shell.Binary); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func expandSetting(name, path string) (string, error) {
+	expanded, err := ExpandConfigPath(path)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return expanded, nil
+}
+
 // Save saves settings to ~/.craby/settings.json
 func (s *Settings) Save() error {
 	dir, err := ConfigDir()
@@ -187,9 +729,9 @@ func (s *Settings) Save() error {
 	return os.WriteFile(path, data, 0600)
 }
 
-// IsCommandAllowed checks if a command is in the shell allowlist
+// IsCommandAllowed checks if a command is in the shell allowlist and not denylisted
 func (s *Settings) IsCommandAllowed(cmd string) bool {
-	if !s.Tools.Shell.Enabled {
+	if !s.Tools.Shell.Enabled || s.IsCommandDenied(cmd) {
 		return false
 	}
 
@@ -201,6 +743,24 @@ func (s *Settings) IsCommandAllowed(cmd string) bool {
 	return false
 }
 
+// AreCommandArgumentsAllowed checks a command's arguments against its argument rule, if any.
+// Returns false and a reason when the arguments are not allowed.
+func (s *Settings) AreCommandArgumentsAllowed(cmd string, args []string) (bool, string) {
+	rule, ok := s.Tools.Shell.ArgumentRules[cmd]
+	if !ok {
+		return true, ""
+	}
+	if reason := rule.Check(args); reason != "" {
+		return false, reason
+	}
+	return true, ""
+}
+
+// IsCommandDenied checks if a command is in the shell denylist
+func (s *Settings) IsCommandDenied(cmd string) bool {
+	return slices.Contains(s.Tools.Shell.Denylist, cmd)
+}
+
 // ExpandPath expands ~ to the user's home directory
 func ExpandPath(path string) string {
 	if strings.HasPrefix(path, "~/") {
@@ -217,6 +777,35 @@ func ExpandPath(path string) string {
 	return path
 }
 
+// ExpandConfigPath expands a leading ~ and $VAR or ${VAR} references in a path from the settings.
+// Unlike ExpandPath it fails on references it can't expand, such as unset variables or ~user,
+// rather than leaving them in the path to create literal ~ or $VAR directories.
+func ExpandConfigPath(path string) (string, error) {
+	if rest, ok := strings.CutPrefix(path, "~"); ok {
+		if rest != "" && !strings.HasPrefix(rest, "/") {
+			return "", fmt.Errorf("can't expand %q, only ~ and ~/ are supported", path)
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("can't expand ~ in %q: %w", path, err)
+		}
+		path = home + rest
+	}
+
+	var unset []string
+	expanded := os.Expand(path, func(name string) string {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			unset = append(unset, "$"+name)
+		}
+		return value
+	})
+	if len(unset) > 0 {
+		return "", fmt.Errorf("can't expand %q, %s not set", path, strings.Join(unset, ", "))
+	}
+	return expanded, nil
+}
+
 // IsWritePathAllowed checks if a path is allowed for writing
 func (s *Settings) IsWritePathAllowed(targetPath string) (bool, string) {
 	if !s.Tools.Write.Enabled {
@@ -232,26 +821,14 @@ func (s *Settings) IsWritePathAllowed(targetPath string) (bool, string) {
 
 	// Check blocked paths first (takes precedence)
 	for _, blocked := range s.Tools.Write.BlockedPaths {
-		expandedBlocked := ExpandPath(blocked)
-		absBlocked, err := filepath.Abs(expandedBlocked)
-		if err != nil {
-			continue
-		}
-		// Check if target is the blocked path or inside it
-		if absTarget == absBlocked || strings.HasPrefix(absTarget, absBlocked+string(filepath.Separator)) {
+		if isUnderPath(absTarget, blocked) {
 			return false, "path is blocked: " + blocked
 		}
 	}
 
 	// Check if path is within allowed paths
 	for _, allowed := range s.Tools.Write.AllowedPaths {
-		expandedAllowed := ExpandPath(allowed)
-		absAllowed, err := filepath.Abs(expandedAllowed)
-		if err != nil {
-			continue
-		}
-		// Check if target is the allowed path or inside it
-		if absTarget == absAllowed || strings.HasPrefix(absTarget, absAllowed+string(filepath.Separator)) {
+		if isUnderPath(absTarget, allowed) {
 			return true, ""
 		}
 	}
@@ -259,6 +836,42 @@ func (s *Settings) IsWritePathAllowed(targetPath string) (bool, string) {
 	return false, "path not in allowed paths"
 }
 
+// isUnderPath reports whether absTarget is the configured path or inside it. The configured path
+// is compared both as written and with symlinks resolved, so resolved targets match it too.
+func isUnderPath(absTarget, configured string) bool {
+	absPath, err := filepath.Abs(ExpandPath(configured))
+	if err != nil {
+		return false
+	}
+	candidates := []string{absPath}
+	if resolved, err := filepath.EvalSymlinks(absPath); err == nil && resolved != absPath {
+		candidates = append(candidates, resolved)
+	}
+	for _, candidate := range candidates {
+		if absTarget == candidate || strings.HasPrefix(absTarget, candidate+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsReadPathBlocked checks if a path is under tools.read.blocked_paths or tools.write.blocked_paths.
+// Blocked paths are compared with symlinks resolved, so targetPath should be resolved too.
+func (s *Settings) IsReadPathBlocked(targetPath string) (bool, string) {
+	absTarget, err := filepath.Abs(ExpandPath(targetPath))
+	if err != nil {
+		return true, "invalid path"
+	}
+
+	blockedPaths := append(append([]string(nil), s.Tools.Read.BlockedPaths...), s.Tools.Write.BlockedPaths...)
+	for _, blocked := range blockedPaths {
+		if isUnderPath(absTarget, blocked) {
+			return true, "path is blocked: " + blocked
+		}
+	}
+	return false, ""
+}
+
 // Templates holds the loaded template content
 type Templates struct {
 	Identity string
@@ -416,3 +1029,74 @@ func LoadTemplatesWithSettings(settings *Settings) (*Templates, error) {
 
 	return result, nil
 }
+
+// PersonasDir returns the path to ~/.craby/templates, where persona identity templates live
+func PersonasDir() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "templates"), nil
+}
+
+// ListPersonas returns the names of the persona templates in ~/.craby/templates, sorted
+func ListPersonas() ([]string, error) {
+	dir, err := PersonasDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".md" {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(entry.Name(), ".md"))
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// LoadPersona loads the identity template for a named persona using default settings
+func LoadPersona(name string) (string, error) {
+	settings, err := Load()
+	if err != nil {
+		settings = DefaultSettings()
+	}
+	return LoadPersonaWithSettings(settings, name)
+}
+
+// LoadPersonaWithSettings loads ~/.craby/templates/<name>.md with placeholders replaced.
+// Returns an error naming the available personas if the template doesn't exist.
+func LoadPersonaWithSettings(settings *Settings, name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid persona name %q", name)
+	}
+
+	dir, err := PersonasDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name+".md")
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		msg := fmt.Sprintf("unknown persona %q: %s does not exist", name, path)
+		if names, _ := ListPersonas(); len(names) > 0 {
+			msg += fmt.Sprintf(" (available: %s)", strings.Join(names, ", "))
+		}
+		return "", errors.New(msg)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read persona %q: %w", name, err)
+	}
+
+	return processTemplate(string(data), settings.Variables), nil
+}
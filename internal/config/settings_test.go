@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -25,6 +26,13 @@ func TestDefaultSettings(t *testing.T) {
 			t.Errorf("expected %q to be in default allowlist", cmd)
 		}
 	}
+
+	if settings.Ollama.EmbeddingModel != DefaultEmbeddingModel {
+		t.Errorf("expected embedding model %q by default, got %q", DefaultEmbeddingModel, settings.Ollama.EmbeddingModel)
+	}
+	if settings.Ollama.GenerationTimeoutMinutes != 30 {
+		t.Errorf("expected a 30 minute generation timeout by default, got %d", settings.Ollama.GenerationTimeoutMinutes)
+	}
 }
 
 func TestIsCommandAllowed(t *testing.T) {
@@ -73,6 +81,118 @@ func TestIsCommandAllowed_ShellDisabled(t *testing.T) {
 	}
 }
 
+func TestIsCommandAllowed_DenylistWins(t *testing.T) {
+	settings := &Settings{
+		Tools: ToolsSettings{
+			Shell: ShellSettings{
+				Enabled:   true,
+				Allowlist: []string{"rm", "ls"},
+				Denylist:  []string{"rm"},
+			},
+		},
+	}
+
+	if settings.IsCommandAllowed("rm") {
+		t.Error("expected denylisted command to be refused even though it is allowlisted")
+	}
+	if !settings.IsCommandAllowed("ls") {
+		t.Error("expected ls to stay allowed")
+	}
+}
+
+func TestAreCommandArgumentsAllowed(t *testing.T) {
+	settings := &Settings{
+		Tools: ToolsSettings{
+			Shell: ShellSettings{
+				Enabled:   true,
+				Allowlist: []string{"rm", "ls"},
+				ArgumentRules: map[string]ArgumentRule{
+					"rm": {
+						ForbiddenSubstrings: []string{"/"},
+						AllowedFlags:        []string{"-i", "-v"},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		cmd     string
+		args    []string
+		allowed bool
+	}{
+		{"no rule", "ls", []string{"-la", "/"}, true},
+		{"allowed flag", "rm", []string{"-i", "notes.txt"}, true},
+		{"disallowed flag", "rm", []string{"-rf", "notes.txt"}, false},
+		{"forbidden substring", "rm", []string{"-i", "/etc/hosts"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, reason := settings.AreCommandArgumentsAllowed(tt.cmd, tt.args)
+			if allowed != tt.allowed {
+				t.Errorf("AreCommandArgumentsAllowed(%q, %v) = %v (%s), want %v", tt.cmd, tt.args, allowed, reason, tt.allowed)
+			}
+			if !allowed && reason == "" {
+				t.Error("expected a reason when arguments are rejected")
+			}
+		})
+	}
+}
+
+func TestShellSettings_DeniedPatternList(t *testing.T) {
+	if got := (ShellSettings{}).DeniedPatternList(); len(got) != len(DefaultDeniedPatterns) {
+		t.Errorf("expected defaults when unset, got %v", got)
+	}
+	if got := (ShellSettings{DeniedPatterns: []string{}}).DeniedPatternList(); len(got) != 0 {
+		t.Errorf("expected an explicit empty list to disable patterns, got %v", got)
+	}
+}
+
+func TestNetworkSettings_CheckHost(t *testing.T) {
+	if err := (NetworkSettings{}).CheckHost("anything.test"); err != nil {
+		t.Errorf("expected any host without a policy, got %v", err)
+	}
+
+	policy := NetworkSettings{AllowedHosts: []string{"example.com"}, DeniedHosts: []string{"secret.example.com"}}
+	for host, ok := range map[string]bool{
+		"example.com":           true,
+		"API.Example.com.":      true,
+		"notexample.com":        false,
+		"secret.example.com":    false,
+		"db.secret.example.com": false,
+		"":                      false,
+	} {
+		if err := policy.CheckHost(host); (err == nil) != ok {
+			t.Errorf("CheckHost(%q) = %v, want allowed %v", host, err, ok)
+		}
+	}
+	if !policy.Restricted() || (NetworkSettings{}).Restricted() {
+		t.Error("expected only a policy with hosts to be restricted")
+	}
+	if got := (NetworkSettings{}).NetworkCommandList(); !slices.Contains(got, "curl") {
+		t.Errorf("expected the default network commands, got %v", got)
+	}
+}
+
+func TestShellSettings_ResolveShell(t *testing.T) {
+	path, err := (ShellSettings{}).ResolveShell()
+	if err != nil || !strings.HasSuffix(path, "/sh") {
+		t.Fatalf("expected sh by default, got %q, %v", path, err)
+	}
+
+	// A full path is used as is
+	if got, err := (ShellSettings{Binary: path}).ResolveShell(); err != nil || got != path {
+		t.Errorf("expected %q, got %q, %v", path, got, err)
+	}
+
+	_, err = (ShellSettings{Binary: "no-such-shell"}).ResolveShell()
+	if err == nil || !strings.Contains(err.Error(), "no-such-shell") || !strings.Contains(err.Error(), "tools.shell.binary") {
+		t.Errorf("expected an error naming the shell and the setting, got %v", err)
+	}
+}
+
 func TestSaveAndLoad(t *testing.T) {
 	// Create a temporary directory for testing
 	tmpDir := t.TempDir()
@@ -146,6 +266,71 @@ func TestLoad_CreatesDefaultIfNotExists(t *testing.T) {
 	}
 }
 
+func TestExpandConfigPath(t *testing.T) {
+	t.Setenv("HOME", "/home/crab")
+	t.Setenv("PROJECTS", "/srv/projects")
+
+	for path, want := range map[string]string{
+		"~":                   "/home/crab",
+		"~/notes":             "/home/crab/notes",
+		"$HOME/notes":         "/home/crab/notes",
+		"${PROJECTS}/craby":   "/srv/projects/craby",
+		"/tmp":                "/tmp",
+		"":                    "",
+		"relative/~/not-home": "relative/~/not-home",
+	} {
+		got, err := ExpandConfigPath(path)
+		if err != nil || got != want {
+			t.Errorf("ExpandConfigPath(%q) = %q, %v; want %q", path, got, err, want)
+		}
+	}
+
+	for _, path := range []string{"~crab/notes", "$CRABY_UNSET_VARIABLE/notes", "${CRABY_UNSET_VARIABLE}"} {
+		if got, err := ExpandConfigPath(path); err == nil {
+			t.Errorf("expected %q to fail, got %q", path, got)
+		}
+	}
+}
+
+func TestLoad_ExpandsPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("WORK", "/srv/work")
+
+	write := func(content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(tmpDir, ".craby"), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, ".craby", "settings.json"), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"tools": {"read": {"root": "$WORK"}, "write": {"allowed_paths": ["~/notes", "${WORK}/out"]}, "shell": {"binary": "~/bin/zsh"}}}`)
+	settings, err := Load()
+	if err != nil {
+		t.Fatalf("failed to load settings: %v", err)
+	}
+	if settings.Tools.Read.Root != "/srv/work" {
+		t.Errorf("expected the read root expanded, got %q", settings.Tools.Read.Root)
+	}
+	if want := []string{filepath.Join(tmpDir, "notes"), "/srv/work/out"}; !slices.Equal(settings.Tools.Write.AllowedPaths, want) {
+		t.Errorf("expected allowed paths %v, got %v", want, settings.Tools.Write.AllowedPaths)
+	}
+	if settings.Tools.Write.BlockedPaths[0] != filepath.Join(tmpDir, ".ssh") {
+		t.Errorf("expected the default blocked paths expanded, got %v", settings.Tools.Write.BlockedPaths)
+	}
+	if settings.Tools.Shell.Binary != filepath.Join(tmpDir, "bin", "zsh") {
+		t.Errorf("expected the shell path expanded, got %q", settings.Tools.Shell.Binary)
+	}
+
+	write(`{"tools": {"read": {"root": "$CRABY_UNSET_VARIABLE/docs"}}}`)
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "tools.read.root") {
+		t.Errorf("expected an error naming the setting, got %v", err)
+	}
+}
+
 func TestConfigDir(t *testing.T) {
 	dir, err := ConfigDir()
 	if err != nil {
@@ -175,3 +360,65 @@ func TestSettingsPath(t *testing.T) {
 		t.Errorf("SettingsPath() = %q, should end with settings.json", path)
 	}
 }
+
+func TestOllamaSettings_ResolvedHeaders(t *testing.T) {
+	t.Setenv("CRABY_TEST_PROXY_TOKEN", "abc123")
+	t.Setenv(OllamaAPIKeyEnv, "")
+
+	settings := OllamaSettings{Headers: map[string]string{"X-Proxy-Token": "${CRABY_TEST_PROXY_TOKEN}"}}
+	headers := settings.ResolvedHeaders()
+	if headers["X-Proxy-Token"] != "abc123" {
+		t.Errorf("expected env var to be expanded, got %q", headers["X-Proxy-Token"])
+	}
+	if _, ok := headers["Authorization"]; ok {
+		t.Error("expected no Authorization header without an API key")
+	}
+
+	t.Setenv(OllamaAPIKeyEnv, "secret")
+	if got := settings.ResolvedHeaders()["Authorization"]; got != "Bearer secret" {
+		t.Errorf("expected bearer token from env, got %q", got)
+	}
+
+	// An explicit Authorization header wins over the env var
+	settings.Headers["authorization"] = "Basic xyz"
+	headers = settings.ResolvedHeaders()
+	if _, ok := headers["Authorization"]; ok {
+		t.Error("expected configured authorization header to take precedence")
+	}
+}
+
+func TestLoadPersonaWithSettings(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	dir := filepath.Join(tmpDir, ".craby", "templates")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "writer.md"), []byte("You edit prose for {{USERNAME}}."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "coder.md"), []byte("You write Go."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := DefaultSettings()
+	settings.Variables.Username = "marcin"
+
+	identity, err := LoadPersonaWithSettings(settings, "writer")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if identity != "You edit prose for marcin." {
+		t.Errorf("expected placeholders to be replaced, got %q", identity)
+	}
+
+	_, err = LoadPersonaWithSettings(settings, "poet")
+	if err == nil || !strings.Contains(err.Error(), `unknown persona "poet"`) || !strings.Contains(err.Error(), "available: coder, writer") {
+		t.Errorf("expected unknown persona error listing the available ones, got %v", err)
+	}
+
+	if _, err := LoadPersonaWithSettings(settings, "../settings"); err == nil {
+		t.Error("expected a path outside the templates directory to be rejected")
+	}
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cachedToolStatus is a tool's last check result, stored in the tool status cache
+type cachedToolStatus struct {
+	Status    ToolStatus `json:"status"`
+	Check     string     `json:"check"` // Check command that produced the status; a changed check invalidates it
+	CheckedAt time.Time  `json:"checked_at"`
+}
+
+// ToolStatusCache remembers external tool availability between daemon starts,
+// so slow check commands don't run every time
+type ToolStatusCache struct {
+	path string
+	ttl  time.Duration
+	mu   sync.Mutex
+}
+
+// ToolStatusCachePath returns the path to ~/.craby/cache/tool_status.json
+func ToolStatusCachePath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "cache", "tool_status.json"), nil
+}
+
+// NewToolStatusCache creates a cache whose entries expire after ttl.
+// Returns nil (no caching) when ttl is 0 or less.
+func NewToolStatusCache(ttl time.Duration) (*ToolStatusCache, error) {
+	if ttl <= 0 {
+		return nil, nil
+	}
+
+	path, err := ToolStatusCachePath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+
+	return &ToolStatusCache{path: path, ttl: ttl}, nil
+}
+
+// ClearToolStatusCache removes all cached tool statuses, forcing the next load to re-run every check
+func ClearToolStatusCache() error {
+	path, err := ToolStatusCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Get returns the cached status for a tool if it is fresh and was produced by the tool's current check
+func (c *ToolStatusCache) Get(tool *ExternalTool) (ToolStatus, bool) {
+	if c == nil {
+		return ToolStatus{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.load()[tool.Name]
+	if !ok || entry.Check != tool.Check.Command || time.Since(entry.CheckedAt) > c.ttl {
+		return ToolStatus{}, false
+	}
+
+	entry.Status.Cached = true
+	return entry.Status, true
+}
+
+// Set stores fresh check results for the given tools, keeping other entries.
+// Checks that timed out or were skipped at the deadline ran too short to tell, so they aren't stored.
+func (c *ToolStatusCache) Set(tools []*ExternalTool, statuses map[string]ToolStatus) error {
+	if c == nil || len(tools) == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := c.load()
+	now := time.Now()
+	for _, tool := range tools {
+		status, ok := statuses[tool.Name]
+		if !ok || status.TimedOut() {
+			continue
+		}
+		entries[tool.Name] = cachedToolStatus{Status: status, Check: tool.Check.Command, CheckedAt: now}
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	//nolint:gosec // G306: cache files in user's config dir
+	return os.WriteFile(c.path, data, 0640)
+}
+
+// load reads all entries, treating a missing or corrupt file as empty
+func (c *ToolStatusCache) load() map[string]cachedToolStatus {
+	entries := make(map[string]cachedToolStatus)
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return entries
+	}
+	_ = json.Unmarshal(data, &entries)
+	return entries
+}
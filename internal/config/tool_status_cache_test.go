@@ -0,0 +1,86 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestToolStatusCache_SetAndGet(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	cache, err := NewToolStatusCache(time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+
+	tool := &ExternalTool{Name: "mytool", Check: ToolCheck{Command: "mytool --version"}}
+	failed := ToolStatus{Available: false, Message: "check failed: exit status 2", ExitCode: 2, Stderr: "bad config"}
+	if err := cache.Set([]*ExternalTool{tool}, map[string]ToolStatus{"mytool": failed}); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+
+	got, ok := cache.Get(tool)
+	if !ok {
+		t.Fatal("expected cache hit")
+	}
+	if !got.Cached || got.ExitCode != 2 || got.Stderr != "bad config" || got.Available {
+		t.Errorf("expected cached failure details to be kept, got %+v", got)
+	}
+
+	// A changed check command invalidates the entry
+	changed := &ExternalTool{Name: "mytool", Check: ToolCheck{Command: "mytool version"}}
+	if _, ok := cache.Get(changed); ok {
+		t.Error("expected miss after check command changed")
+	}
+
+	if err := ClearToolStatusCache(); err != nil {
+		t.Fatalf("failed to clear: %v", err)
+	}
+	if _, ok := cache.Get(tool); ok {
+		t.Error("expected miss after clearing")
+	}
+}
+
+func TestToolStatusCache_Expiration(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	cache, _ := NewToolStatusCache(time.Millisecond)
+	tool := &ExternalTool{Name: "mytool"}
+	_ = cache.Set([]*ExternalTool{tool}, map[string]ToolStatus{"mytool": {Available: true}})
+
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.Get(tool); ok {
+		t.Error("expected expired entry to miss")
+	}
+}
+
+func TestToolStatusCache_SkipsTimeouts(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	cache, _ := NewToolStatusCache(time.Hour)
+	slow := &ExternalTool{Name: "slow"}
+	skipped := &ExternalTool{Name: "skipped"}
+	_ = cache.Set([]*ExternalTool{slow, skipped}, map[string]ToolStatus{
+		"slow":    {Message: "check timed out after 5s", ExitCode: -1, Failure: CheckFailedTimeout},
+		"skipped": {Message: "check skipped: context deadline exceeded", ExitCode: -1, Failure: CheckFailedTimeout},
+	})
+
+	for _, tool := range []*ExternalTool{slow, skipped} {
+		if _, ok := cache.Get(tool); ok {
+			t.Errorf("expected %s checked again next time", tool.Name)
+		}
+	}
+}
+
+func TestToolStatusCache_Disabled(t *testing.T) {
+	cache, err := NewToolStatusCache(0)
+	if err != nil || cache != nil {
+		t.Fatalf("expected nil cache when ttl is 0, got %v, %v", cache, err)
+	}
+	if _, ok := cache.Get(&ExternalTool{Name: "x"}); ok {
+		t.Error("expected nil cache to miss")
+	}
+	if err := cache.Set([]*ExternalTool{{Name: "x"}}, map[string]ToolStatus{"x": {}}); err != nil {
+		t.Errorf("expected nil cache to ignore sets, got %v", err)
+	}
+}
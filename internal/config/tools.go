@@ -2,8 +2,14 @@ package config
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -19,13 +25,75 @@ type ExternalTool struct {
 	Subcommands []ToolSubcommand  `yaml:"subcommands,omitempty"`
 	Examples    []string          `yaml:"examples,omitempty"`
 	Metadata    map[string]string `yaml:"metadata,omitempty"`
+	Discovery   ToolDiscovery     `yaml:"discovery,omitempty"`
+
+	// Unverified is why the check couldn't confirm the tool works, set when the tool
+	// is kept anyway because its check allows inconclusive results
+	Unverified string `yaml:"-"`
+}
+
+// Discovery limits used when a tool doesn't set its own
+const (
+	DefaultDiscoveryMaxHelpChars         = 8000
+	DefaultDiscoveryHelpTimeoutSeconds   = 10
+	DefaultDiscoverySchemaTimeoutSeconds = 30
+
+	maxDiscoveryHelpChars      = 100000
+	maxDiscoveryTimeoutSeconds = 600
+)
+
+// ToolDiscovery tunes how the tool's usage is learned from its --help output.
+// Unset or out of range limits use the defaults.
+type ToolDiscovery struct {
+	MaxHelpChars         int `yaml:"max_help_chars,omitempty"`         // Help output beyond this is truncated before schema generation
+	HelpTimeoutSeconds   int `yaml:"help_timeout_seconds,omitempty"`   // How long --help may run
+	SchemaTimeoutSeconds int `yaml:"schema_timeout_seconds,omitempty"` // How long the model may take to write the schema
+}
+
+// HelpLimit returns how many characters of help output are kept
+func (d ToolDiscovery) HelpLimit() int {
+	if d.MaxHelpChars <= 0 || d.MaxHelpChars > maxDiscoveryHelpChars {
+		return DefaultDiscoveryMaxHelpChars
+	}
+	return d.MaxHelpChars
+}
+
+// HelpTimeout returns how long --help may run
+func (d ToolDiscovery) HelpTimeout() time.Duration {
+	if d.HelpTimeoutSeconds <= 0 || d.HelpTimeoutSeconds > maxDiscoveryTimeoutSeconds {
+		return DefaultDiscoveryHelpTimeoutSeconds * time.Second
+	}
+	return time.Duration(d.HelpTimeoutSeconds) * time.Second
+}
+
+// SchemaTimeout returns how long schema generation may take
+func (d ToolDiscovery) SchemaTimeout() time.Duration {
+	if d.SchemaTimeoutSeconds <= 0 || d.SchemaTimeoutSeconds > maxDiscoveryTimeoutSeconds {
+		return DefaultDiscoverySchemaTimeoutSeconds * time.Second
+	}
+	return time.Duration(d.SchemaTimeoutSeconds) * time.Second
+}
+
+// Validate checks that the limits are within range
+func (d ToolDiscovery) Validate() error {
+	if d.MaxHelpChars < 0 || d.MaxHelpChars > maxDiscoveryHelpChars {
+		return fmt.Errorf("discovery.max_help_chars must be between 0 and %d", maxDiscoveryHelpChars)
+	}
+	if d.HelpTimeoutSeconds < 0 || d.HelpTimeoutSeconds > maxDiscoveryTimeoutSeconds {
+		return fmt.Errorf("discovery.help_timeout_seconds must be between 0 and %d", maxDiscoveryTimeoutSeconds)
+	}
+	if d.SchemaTimeoutSeconds < 0 || d.SchemaTimeoutSeconds > maxDiscoveryTimeoutSeconds {
+		return fmt.Errorf("discovery.schema_timeout_seconds must be between 0 and %d", maxDiscoveryTimeoutSeconds)
+	}
+	return nil
 }
 
 // ToolEnv defines environment variables for a tool
 type ToolEnv struct {
 	// Propagate lists env var names to inherit from parent shell
 	Propagate []string `yaml:"propagate,omitempty"`
-	// Set defines env vars to inject (key: value)
+	// Set defines env vars to inject (key: value). Values may reference the daemon's
+	// environment as ${NAME}, so secrets don't have to be written into the file.
 	Set map[string]string `yaml:"set,omitempty"`
 }
 
@@ -35,12 +103,38 @@ type ToolAccess struct {
 	Command string `yaml:"command"`           // base command for shell type
 	WorkDir string `yaml:"workdir,omitempty"` // working directory for shell commands
 	Details string `yaml:"details,omitempty"` // additional instructions for the LLM about how to use this tool
+	// Template is an optional command line with {{.arg}} placeholders filled by the agent,
+	// e.g. "kubectl get pods -n {{.namespace}}". It must start with Command.
+	Template string `yaml:"template,omitempty"`
 }
 
 // ToolCheck defines how to verify the tool is available
 type ToolCheck struct {
-	Command  string `yaml:"command"`            // command to run
-	Expected string `yaml:"expected,omitempty"` // expected substring in output
+	Command        string `yaml:"command"`                   // command to run
+	Expected       string `yaml:"expected,omitempty"`        // expected substring in output
+	TimeoutSeconds int    `yaml:"timeout_seconds,omitempty"` // how long the command may run (0 = DefaultCheckTimeoutSeconds)
+	// AllowInconclusive keeps the tool when the check times out, exits non-zero or prints
+	// unexpected output, since those don't prove the tool is missing
+	AllowInconclusive bool `yaml:"allow_inconclusive,omitempty"`
+}
+
+// DefaultCheckTimeoutSeconds bounds a check command when its tool doesn't set a timeout
+const DefaultCheckTimeoutSeconds = 10
+
+// Timeout returns how long the check command may run
+func (c ToolCheck) Timeout() time.Duration {
+	if c.TimeoutSeconds <= 0 || c.TimeoutSeconds > maxDiscoveryTimeoutSeconds {
+		return DefaultCheckTimeoutSeconds * time.Second
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// Validate checks that the timeout is within range
+func (c ToolCheck) Validate() error {
+	if c.TimeoutSeconds < 0 || c.TimeoutSeconds > maxDiscoveryTimeoutSeconds {
+		return fmt.Errorf("check.timeout_seconds must be between 0 and %d", maxDiscoveryTimeoutSeconds)
+	}
+	return nil
 }
 
 // ToolSubcommand describes a subcommand of the tool
@@ -146,16 +240,92 @@ func (t *ExternalTool) Validate() error {
 	if t.Access.Type == "shell" && t.Access.Command == "" {
 		return fmt.Errorf("access command is required for shell tools")
 	}
-	return nil
+	if t.Access.Template != "" {
+		if fields := strings.Fields(t.Access.Template); len(fields) == 0 || fields[0] != t.Access.Command {
+			return fmt.Errorf("access template must start with the access command %q", t.Access.Command)
+		}
+		if _, err := template.New(t.Name).Parse(t.Access.Template); err != nil {
+			return fmt.Errorf("invalid access template: %w", err)
+		}
+	}
+	if err := t.Check.Validate(); err != nil {
+		return err
+	}
+	return t.Discovery.Validate()
+}
+
+// templateParam matches {{.name}} placeholders in an access template
+var templateParam = regexp.MustCompile(`{{\s*\.(\w+)\s*}}`)
+
+// safeTemplateValue restricts substituted values to a single plain word, so they can't add
+// shell operators, extra arguments or flags
+var safeTemplateValue = regexp.MustCompile(`^[A-Za-z0-9_./:@%+=,][A-Za-z0-9_./:@%+=,-]*$`)
+
+// TemplateParams returns the placeholder names used by the access template, in order of appearance
+func (t *ExternalTool) TemplateParams() []string {
+	var params []string
+	for _, m := range templateParam.FindAllStringSubmatch(t.Access.Template, -1) {
+		if !slices.Contains(params, m[1]) {
+			params = append(params, m[1])
+		}
+	}
+	return params
+}
+
+// RenderCommand fills the access template with args. Every placeholder must be provided,
+// and every value must be a single plain word.
+func (t *ExternalTool) RenderCommand(args map[string]any) (string, error) {
+	if t.Access.Template == "" {
+		return "", fmt.Errorf("tool %s has no command template", t.Name)
+	}
+
+	values := make(map[string]string, len(args))
+	for name, raw := range args {
+		value := fmt.Sprint(raw)
+		if !safeTemplateValue.MatchString(value) {
+			return "", fmt.Errorf("invalid value for %s: %q (must be a single word without shell characters or a leading -)", name, value)
+		}
+		values[name] = value
+	}
+
+	tmpl, err := template.New(t.Name).Option("missingkey=error").Parse(t.Access.Template)
+	if err != nil {
+		return "", fmt.Errorf("invalid access template: %w", err)
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, values); err != nil {
+		return "", fmt.Errorf("missing template argument (expected: %s): %w", strings.Join(t.TemplateParams(), ", "), err)
+	}
+	return sb.String(), nil
+}
+
+// envReference matches ${NAME} references in env.set values
+var envReference = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// expandEnvValue replaces ${NAME} references with values from the environment.
+// Returns the names of referenced variables that aren't set.
+func expandEnvValue(value string) (string, []string) {
+	var missing []string
+	expanded := envReference.ReplaceAllStringFunc(value, func(ref string) string {
+		name := envReference.FindStringSubmatch(ref)[1]
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return val
+	})
+	return expanded, missing
 }
 
 // BuildEnv builds the environment variables for tool execution.
 // Returns a slice of "KEY=VALUE" strings suitable for exec.Cmd.Env.
 // If no env config, returns nil (inherit all from parent).
-func (t *ExternalTool) BuildEnv() []string {
+// Fails if an env.set value references an unset variable, rather than passing an empty value.
+func (t *ExternalTool) BuildEnv() ([]string, error) {
 	// If no env configuration, return nil to inherit all
 	if len(t.Env.Propagate) == 0 && len(t.Env.Set) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	env := make([]string, 0)
@@ -167,12 +337,16 @@ func (t *ExternalTool) BuildEnv() []string {
 		}
 	}
 
-	// Add/override with explicitly set env vars
-	for name, val := range t.Env.Set {
+	// Add/override with explicitly set env vars, in name order so errors are stable
+	for _, name := range slices.Sorted(maps.Keys(t.Env.Set)) {
+		val, missing := expandEnvValue(t.Env.Set[name])
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("tool %s: env.set.%s references unset environment variable %s", t.Name, name, strings.Join(missing, ", "))
+		}
 		env = append(env, name+"="+val)
 	}
 
-	return env
+	return env, nil
 }
 
 // GenerateSystemPrompt generates a description of the tool for the LLM
@@ -188,6 +362,15 @@ func (t *ExternalTool) GenerateSystemPrompt() string {
 		prompt += fmt.Sprintf("**Important:** %s\n\n", t.Access.Details)
 	}
 
+	if t.Unverified != "" {
+		prompt += fmt.Sprintf("**Note:** this tool could not be verified (%s). If its commands fail, tell the user.\n\n", t.Unverified)
+	}
+
+	if t.Access.Template != "" {
+		prompt += fmt.Sprintf("**Usage:** call the shell tool with command `%s` and args %s\n\n",
+			t.Name, strings.Join(t.TemplateParams(), ", "))
+	}
+
 	if len(t.Subcommands) > 0 {
 		prompt += "**Available subcommands:**\n"
 		for _, sub := range t.Subcommands {
@@ -4,23 +4,73 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/marciniwanicki/craby/internal/procgroup"
 )
 
 // ToolStatus represents the availability status of a tool
 type ToolStatus struct {
-	Available bool
-	Message   string
+	Available bool   `json:"available"`
+	Message   string `json:"message"`
 	// Detailed error information (only set when Available is false)
-	ExitCode int
-	Stdout   string
-	Stderr   string
+	ExitCode int    `json:"exit_code"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	// Failure says why the tool is unavailable, one of the CheckFailed constants (empty when available)
+	Failure string `json:"failure,omitempty"`
+	// Cached is true when the status came from the tool status cache instead of a fresh check
+	Cached bool `json:"-"`
+	// Kept is true when the tool is used despite an inconclusive check (check.allow_inconclusive)
+	Kept bool `json:"kept,omitempty"`
 }
 
+// Reasons a tool check fails
+const (
+	CheckFailedTimeout  = "timeout"   // The check command didn't finish in time
+	CheckFailedExit     = "exit"      // The check command exited non-zero
+	CheckFailedNotFound = "not_found" // The command isn't installed
+	CheckFailedOutput   = "output"    // The check succeeded but its output lacked the expected text
+	CheckFailedEnv      = "env"       // The tool's env references a variable that isn't set
+)
+
+// Inconclusive reports whether the check failed without showing the tool is broken.
+// A missing command or unset env variable is conclusive; a timeout, non-zero exit or
+// unexpected output may just be a flaky or overly strict check.
+func (s ToolStatus) Inconclusive() bool {
+	switch s.Failure {
+	case CheckFailedTimeout, CheckFailedExit, CheckFailedOutput:
+		return !s.Available
+	}
+	return false
+}
+
+// TimedOut reports whether the check failed because it ran out of time
+func (s ToolStatus) TimedOut() bool {
+	return s.Failure == CheckFailedTimeout
+}
+
+// exitCommandNotFound is the shell's exit code when the command doesn't exist
+const exitCommandNotFound = 127
+
+const (
+	// toolCheckDeadline bounds checking all tools together, unless a tool's own timeout is longer
+	toolCheckDeadline = 30 * time.Second
+	// toolCheckWorkers is how many checks run at once
+	toolCheckWorkers = 8
+)
+
 // CheckAvailability runs the tool's check command to verify it's available
 func (t *ExternalTool) CheckAvailability() ToolStatus {
+	return t.CheckAvailabilityContext(context.Background())
+}
+
+// CheckAvailabilityContext is like CheckAvailability but gives up when ctx is done
+func (t *ExternalTool) CheckAvailabilityContext(ctx context.Context) ToolStatus {
 	if t.Check.Command == "" {
 		// No check defined, assume available if access command exists
 		if t.Access.Type == "shell" && t.Access.Command != "" {
@@ -29,13 +79,22 @@ func (t *ExternalTool) CheckAvailability() ToolStatus {
 		return ToolStatus{Available: true, Message: "no check defined"}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	env, err := t.BuildEnv()
+	if err != nil {
+		return ToolStatus{Available: false, Message: err.Error(), ExitCode: -1, Failure: CheckFailedEnv}
+	}
+
+	timeout := t.Check.Timeout()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "sh", "-c", t.Check.Command)
+	procgroup.KillOnCancel(cmd)
+	// Don't wait on grandchildren holding the output pipes open after the shell is killed
+	cmd.WaitDelay = 500 * time.Millisecond
 
 	// Set environment variables from tool config
-	if env := t.BuildEnv(); env != nil {
+	if env != nil {
 		cmd.Env = env
 	}
 
@@ -43,25 +102,33 @@ func (t *ExternalTool) CheckAvailability() ToolStatus {
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	err := cmd.Run()
+	err = cmd.Run()
 	stdoutStr := strings.TrimSpace(stdout.String())
 	stderrStr := strings.TrimSpace(stderr.String())
 
 	if err != nil {
-		// Extract exit code if available
-		exitCode := -1
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			exitCode = exitErr.ExitCode()
-		}
-
-		return ToolStatus{
+		status := ToolStatus{
 			Available: false,
 			Message:   "check failed: " + err.Error(),
-			ExitCode:  exitCode,
+			ExitCode:  -1,
 			Stdout:    stdoutStr,
 			Stderr:    stderrStr,
+			Failure:   CheckFailedExit,
+		}
+
+		var exitErr *exec.ExitError
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			status.Message = fmt.Sprintf("check timed out after %v", timeout)
+			status.Failure = CheckFailedTimeout
+		case errors.As(err, &exitErr):
+			status.ExitCode = exitErr.ExitCode()
+			if status.ExitCode == exitCommandNotFound {
+				status.Message = "check failed: command not found"
+				status.Failure = CheckFailedNotFound
+			}
 		}
+		return status
 	}
 
 	// If expected string is set, verify it's in the output
@@ -74,6 +141,7 @@ func (t *ExternalTool) CheckAvailability() ToolStatus {
 				ExitCode:  0,
 				Stdout:    stdoutStr,
 				Stderr:    stderrStr,
+				Failure:   CheckFailedOutput,
 			}
 		}
 	}
@@ -84,6 +152,53 @@ func (t *ExternalTool) CheckAvailability() ToolStatus {
 	}
 }
 
+// CheckTools checks the availability of tools concurrently, keyed by tool name.
+// Tools whose check hasn't finished when ctx is done are reported unavailable.
+func CheckTools(ctx context.Context, tools []*ExternalTool) map[string]ToolStatus {
+	return checkTools(ctx, tools, toolCheckWorkers)
+}
+
+func checkTools(ctx context.Context, tools []*ExternalTool, workers int) map[string]ToolStatus {
+	results := make([]ToolStatus, len(tools))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(workers, len(tools)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = tools[i].CheckAvailabilityContext(ctx)
+			}
+		}()
+	}
+
+	for i := range tools {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			results[i] = ToolStatus{Available: false, Message: "check skipped: " + ctx.Err().Error(), ExitCode: -1, Failure: CheckFailedTimeout}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	statuses := make(map[string]ToolStatus, len(tools))
+	for i, tool := range tools {
+		statuses[tool.Name] = results[i]
+	}
+	return statuses
+}
+
+// checkDeadline bounds checking the tools together, leaving room for the slowest tool's own timeout
+func checkDeadline(tools []*ExternalTool) time.Duration {
+	deadline := toolCheckDeadline
+	for _, tool := range tools {
+		deadline = max(deadline, tool.Check.Timeout()+time.Second)
+	}
+	return deadline
+}
+
 // checkCommandExists checks if a command exists in PATH
 func (t *ExternalTool) checkCommandExists(command string) ToolStatus {
 	// Extract base command (first word)
@@ -97,6 +212,7 @@ func (t *ExternalTool) checkCommandExists(command string) ToolStatus {
 		return ToolStatus{
 			Available: false,
 			Message:   "command not found in PATH",
+			Failure:   CheckFailedNotFound,
 		}
 	}
 
@@ -106,20 +222,44 @@ func (t *ExternalTool) checkCommandExists(command string) ToolStatus {
 	}
 }
 
-// LoadAndCheckTools loads external tools and checks their availability
-func LoadAndCheckTools() ([]*ExternalTool, map[string]ToolStatus, error) {
+// LoadAndCheckTools loads external tools and checks their availability.
+// Fresh results from cache are reused; a nil cache checks every tool.
+func LoadAndCheckTools(cache *ToolStatusCache) ([]*ExternalTool, map[string]ToolStatus, error) {
 	tools, err := LoadExternalTools()
 	if err != nil {
 		return nil, nil, err
 	}
 
-	statuses := make(map[string]ToolStatus)
-	var availableTools []*ExternalTool
+	statuses := make(map[string]ToolStatus, len(tools))
+	var unchecked []*ExternalTool
+	for _, tool := range tools {
+		if status, ok := cache.Get(tool); ok {
+			statuses[tool.Name] = status
+		} else {
+			unchecked = append(unchecked, tool)
+		}
+	}
 
+	if len(unchecked) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), checkDeadline(unchecked))
+		defer cancel()
+
+		checked := CheckTools(ctx, unchecked)
+		for name, status := range checked {
+			statuses[name] = status
+		}
+		_ = cache.Set(unchecked, checked)
+	}
+
+	var availableTools []*ExternalTool
 	for _, tool := range tools {
-		status := tool.CheckAvailability()
-		statuses[tool.Name] = status
-		if status.Available {
+		status := statuses[tool.Name]
+		if !status.Available && tool.Check.AllowInconclusive && status.Inconclusive() {
+			status.Kept = true
+			statuses[tool.Name] = status
+			tool.Unverified = status.Message
+		}
+		if status.Available || status.Kept {
 			availableTools = append(availableTools, tool)
 		}
 	}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func slowTool(name string, seconds string) *ExternalTool {
+	return &ExternalTool{
+		Name:   name,
+		Access: ToolAccess{Type: "shell", Command: name},
+		Check:  ToolCheck{Command: "sleep " + seconds},
+	}
+}
+
+func TestCheckTools_RunsConcurrently(t *testing.T) {
+	var tools []*ExternalTool
+	for i := range 6 {
+		tools = append(tools, slowTool(fmt.Sprintf("tool%d", i), "0.3"))
+	}
+
+	start := time.Now()
+	statuses := CheckTools(context.Background(), tools)
+	elapsed := time.Since(start)
+
+	if len(statuses) != len(tools) {
+		t.Fatalf("expected %d statuses, got %d", len(tools), len(statuses))
+	}
+	for name, status := range statuses {
+		if !status.Available {
+			t.Errorf("expected %s to be available, got %+v", name, status)
+		}
+	}
+	// Sequential checks would take 1.8s; concurrent ones are bounded by the slowest
+	if elapsed > time.Second {
+		t.Errorf("expected checks to run concurrently, took %v", elapsed)
+	}
+}
+
+func TestCheckTools_RespectsDeadline(t *testing.T) {
+	tools := []*ExternalTool{
+		slowTool("fast", "0"),
+		slowTool("slow", "5"),
+		slowTool("queued", "5"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	statuses := checkTools(ctx, tools, 2)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected deadline to bound the checks, took %v", elapsed)
+	}
+
+	if !statuses["fast"].Available {
+		t.Errorf("expected fast tool to be available, got %+v", statuses["fast"])
+	}
+	for _, name := range []string{"slow", "queued"} {
+		if statuses[name].Available {
+			t.Errorf("expected %s to be unavailable after the deadline", name)
+		}
+	}
+}
+
+func TestCheckAvailability_PerToolTimeout(t *testing.T) {
+	tool := slowTool("slow", "5")
+	tool.Check.TimeoutSeconds = 1
+
+	start := time.Now()
+	status := tool.CheckAvailability()
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected the tool's timeout to bound the check, took %v", elapsed)
+	}
+	if status.Available || !status.TimedOut() {
+		t.Errorf("expected a timeout, got %+v", status)
+	}
+}
+
+func TestCheckAvailability_FailureReasons(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   ToolCheck
+		failure string
+	}{
+		{"non-zero exit", ToolCheck{Command: "exit 3"}, CheckFailedExit},
+		{"missing binary", ToolCheck{Command: "no-such-command-craby"}, CheckFailedNotFound},
+		{"unexpected output", ToolCheck{Command: "echo v1", Expected: "v2"}, CheckFailedOutput},
+		{"passes", ToolCheck{Command: "echo v2", Expected: "v2"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool := &ExternalTool{Name: "tool", Check: tt.check}
+			if status := tool.CheckAvailability(); status.Failure != tt.failure {
+				t.Errorf("expected failure %q, got %+v", tt.failure, status)
+			}
+		})
+	}
+}
+
+func TestToolCheck_Timeout(t *testing.T) {
+	if got := (ToolCheck{}).Timeout(); got != DefaultCheckTimeoutSeconds*time.Second {
+		t.Errorf("expected the default timeout, got %v", got)
+	}
+	if got := (ToolCheck{TimeoutSeconds: 2}).Timeout(); got != 2*time.Second {
+		t.Errorf("expected 2s, got %v", got)
+	}
+	if err := (ToolCheck{TimeoutSeconds: -1}).Validate(); err == nil {
+		t.Error("expected a negative timeout to be invalid")
+	}
+	if got := checkDeadline([]*ExternalTool{{Check: ToolCheck{TimeoutSeconds: 120}}}); got <= 120*time.Second {
+		t.Errorf("expected the deadline to fit the slowest tool, got %v", got)
+	}
+}
+
+func TestToolStatus_Inconclusive(t *testing.T) {
+	tests := map[string]bool{
+		CheckFailedTimeout:  true,
+		CheckFailedExit:     true,
+		CheckFailedOutput:   true,
+		CheckFailedNotFound: false,
+		CheckFailedEnv:      false,
+	}
+	for failure, want := range tests {
+		if got := (ToolStatus{Failure: failure}).Inconclusive(); got != want {
+			t.Errorf("Inconclusive() for %q = %v, want %v", failure, got, want)
+		}
+	}
+}
+
+func TestLoadAndCheckTools_AllowInconclusive(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeTool := func(name, check string, allow bool) {
+		dir := filepath.Join(home, ".craby", "tools", name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		yaml := fmt.Sprintf("name: %s\ndescription: test\naccess:\n  type: shell\n  command: %s\ncheck:\n  command: %q\n  allow_inconclusive: %v\n", name, name, check, allow)
+		if err := os.WriteFile(filepath.Join(dir, name+".yaml"), []byte(yaml), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTool("flaky", "exit 1", true)
+	writeTool("strict", "exit 1", false)
+	writeTool("missing", "no-such-command-craby", true)
+
+	tools, statuses, err := LoadAndCheckTools(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(tools) != 1 || tools[0].Name != "flaky" {
+		t.Fatalf("expected only the flaky tool to be kept, got %d tools", len(tools))
+	}
+	if tools[0].Unverified == "" || !statuses["flaky"].Kept {
+		t.Errorf("expected the kept tool to be marked unverified, got %+v", statuses["flaky"])
+	}
+	if statuses["strict"].Kept || statuses["missing"].Kept {
+		t.Error("expected strict and missing tools not to be kept")
+	}
+}
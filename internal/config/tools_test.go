@@ -0,0 +1,152 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func templatedTool() *ExternalTool {
+	return &ExternalTool{
+		Name:        "pods",
+		Description: "List pods",
+		Access: ToolAccess{
+			Type:     "shell",
+			Command:  "kubectl",
+			Template: "kubectl get pods -n {{.namespace}} -l app={{.app}}",
+		},
+	}
+}
+
+func TestExternalTool_RenderCommand(t *testing.T) {
+	cmd, err := templatedTool().RenderCommand(map[string]any{"namespace": "default", "app": "web-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cmd != "kubectl get pods -n default -l app=web-1" {
+		t.Errorf("unexpected command: %q", cmd)
+	}
+}
+
+func TestExternalTool_RenderCommand_RejectsUnsafeValues(t *testing.T) {
+	for _, value := range []string{"default; rm -rf /", "$(whoami)", "a b", "--all-namespaces", "x|y", ""} {
+		_, err := templatedTool().RenderCommand(map[string]any{"namespace": value, "app": "web"})
+		if err == nil || !strings.Contains(err.Error(), "invalid value for namespace") {
+			t.Errorf("expected %q to be rejected, got: %v", value, err)
+		}
+	}
+}
+
+func TestExternalTool_RenderCommand_MissingArgument(t *testing.T) {
+	_, err := templatedTool().RenderCommand(map[string]any{"namespace": "default"})
+	if err == nil || !strings.Contains(err.Error(), "expected: namespace, app") {
+		t.Errorf("expected missing argument error, got: %v", err)
+	}
+}
+
+func TestExternalTool_Validate_Template(t *testing.T) {
+	tool := templatedTool()
+	if err := tool.Validate(); err != nil {
+		t.Fatalf("expected valid tool, got: %v", err)
+	}
+
+	tool.Access.Template = "helm list -n {{.namespace}}"
+	if err := tool.Validate(); err == nil {
+		t.Error("expected error for template not starting with the access command")
+	}
+
+	tool.Access.Template = "kubectl get pods -n {{.namespace"
+	if err := tool.Validate(); err == nil {
+		t.Error("expected error for unparsable template")
+	}
+}
+
+func TestToolDiscovery_Limits(t *testing.T) {
+	var defaults ToolDiscovery
+	if got := defaults.HelpLimit(); got != DefaultDiscoveryMaxHelpChars {
+		t.Errorf("expected default help limit, got %d", got)
+	}
+	if got := defaults.HelpTimeout(); got != DefaultDiscoveryHelpTimeoutSeconds*time.Second {
+		t.Errorf("expected default help timeout, got %v", got)
+	}
+	if got := defaults.SchemaTimeout(); got != DefaultDiscoverySchemaTimeoutSeconds*time.Second {
+		t.Errorf("expected default schema timeout, got %v", got)
+	}
+
+	custom := ToolDiscovery{MaxHelpChars: 20000, HelpTimeoutSeconds: 3, SchemaTimeoutSeconds: 90}
+	if err := custom.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if custom.HelpLimit() != 20000 || custom.HelpTimeout() != 3*time.Second || custom.SchemaTimeout() != 90*time.Second {
+		t.Errorf("expected custom limits, got %d, %v, %v", custom.HelpLimit(), custom.HelpTimeout(), custom.SchemaTimeout())
+	}
+
+	invalid := ToolDiscovery{MaxHelpChars: -1, HelpTimeoutSeconds: 100000}
+	if err := invalid.Validate(); err == nil || !strings.Contains(err.Error(), "max_help_chars") {
+		t.Errorf("expected max_help_chars error, got %v", err)
+	}
+	if invalid.HelpLimit() != DefaultDiscoveryMaxHelpChars || invalid.HelpTimeout() != DefaultDiscoveryHelpTimeoutSeconds*time.Second {
+		t.Error("expected invalid limits to fall back to the defaults")
+	}
+}
+
+func TestLoadToolFromYAML_Discovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tool.yaml")
+	data := `name: kubectl
+description: Kubernetes CLI
+access:
+  type: shell
+  command: kubectl
+discovery:
+  max_help_chars: 20000
+  schema_timeout_seconds: 60
+`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tool, err := loadToolFromYAML(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tool.Discovery.HelpLimit() != 20000 || tool.Discovery.SchemaTimeout() != time.Minute {
+		t.Errorf("expected limits from YAML, got %+v", tool.Discovery)
+	}
+	if tool.Discovery.HelpTimeout() != DefaultDiscoveryHelpTimeoutSeconds*time.Second {
+		t.Errorf("expected unset help timeout to use the default, got %v", tool.Discovery.HelpTimeout())
+	}
+}
+
+func TestExternalTool_BuildEnv_ExpandsReferences(t *testing.T) {
+	t.Setenv("CRABY_TEST_TOKEN", "s3cret")
+	tool := &ExternalTool{Name: "gh", Env: ToolEnv{Set: map[string]string{
+		"GITHUB_TOKEN": "${CRABY_TEST_TOKEN}",
+		"PRICE":        "$5",
+	}}}
+
+	env, err := tool.BuildEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"GITHUB_TOKEN=s3cret", "PRICE=$5"}
+	if strings.Join(env, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %q, got %q", want, env)
+	}
+}
+
+func TestExternalTool_BuildEnv_UnsetReference(t *testing.T) {
+	tool := &ExternalTool{
+		Name:  "gh",
+		Check: ToolCheck{Command: "true"},
+		Env:   ToolEnv{Set: map[string]string{"GITHUB_TOKEN": "${CRABY_TEST_UNSET_TOKEN}"}},
+	}
+
+	if _, err := tool.BuildEnv(); err == nil || !strings.Contains(err.Error(), "CRABY_TEST_UNSET_TOKEN") {
+		t.Errorf("expected an error naming the unset variable, got %v", err)
+	}
+	if status := tool.CheckAvailability(); status.Available || status.Failure != CheckFailedEnv {
+		t.Errorf("expected the check to fail on the unset variable, got %+v", status)
+	}
+}
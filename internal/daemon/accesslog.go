@@ -0,0 +1,101 @@
+package daemon
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// statusRecorder captures the response status and notices websocket upgrades
+type statusRecorder struct {
+	http.ResponseWriter
+	status   int
+	hijacked bool
+	onHijack func()
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes through, so streamed responses aren't buffered until the end
+func (r *statusRecorder) Flush() {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Hijack passes the connection through to websocket upgrades
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	r.hijacked = true
+	r.status = http.StatusSwitchingProtocols
+	if r.onHijack != nil {
+		r.onHijack()
+	}
+	return conn, rw, nil
+}
+
+// accessLog logs each request's method, path, status, duration and remote address.
+// A websocket is logged when it is upgraded and again when it closes.
+func accessLog(logger zerolog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		rec.onHijack = func() {
+			logger.Info().
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Str("remote", r.RemoteAddr).
+				Msg("websocket upgraded")
+		}
+
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		event, msg := logger.Info(), "http request"
+		if rec.hijacked {
+			msg = "websocket closed"
+		} else if rec.status >= http.StatusInternalServerError {
+			event = logger.Warn()
+		}
+		event.
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Int("status", rec.status).
+			Dur("duration", time.Since(start)).
+			Str("remote", r.RemoteAddr).
+			Msg(msg)
+	})
+}
@@ -0,0 +1,110 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+)
+
+// logEntries decodes one JSON log line per entry
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAccessLog_Request(t *testing.T) {
+	var buf bytes.Buffer
+	handler := accessLog(zerolog.New(&buf), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logEntries(t, &buf)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry["method"] != "GET" || entry["path"] != "/missing" || entry["status"] != float64(404) {
+		t.Errorf("unexpected fields %v", entry)
+	}
+	if entry["remote"] != req.RemoteAddr || entry["duration"] == nil {
+		t.Errorf("expected remote address and duration, got %v", entry)
+	}
+	if entry["level"] != "info" {
+		t.Errorf("expected info level for a client error, got %v", entry["level"])
+	}
+}
+
+func TestAccessLog_Websocket(t *testing.T) {
+	var buf bytes.Buffer
+	upgrader := websocket.Upgrader{}
+	logged := accessLog(zerolog.New(&buf), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _, _ = conn.ReadMessage()
+	}))
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		logged.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/chat", nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	_ = conn.Close()
+	<-done
+
+	entries := logEntries(t, &buf)
+	if len(entries) != 2 {
+		t.Fatalf("expected upgrade and close entries, got %d", len(entries))
+	}
+	if entries[0]["message"] != "websocket upgraded" || entries[0]["path"] != "/ws/chat" {
+		t.Errorf("unexpected upgrade entry %v", entries[0])
+	}
+	if entries[1]["message"] != "websocket closed" || entries[1]["status"] != float64(101) || entries[1]["duration"] == nil {
+		t.Errorf("unexpected close entry %v", entries[1])
+	}
+}
+
+func TestAccessLog_Flush(t *testing.T) {
+	handler := accessLog(zerolog.Nop(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("expected the wrapped writer to flush")
+		}
+		_, _ = w.Write([]byte("data: 1\n\n"))
+		flusher.Flush()
+		// And through a ResponseController, which unwraps the recorder
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/chat/completions", nil))
+	if !rec.Flushed {
+		t.Error("expected the flush passed through")
+	}
+}
@@ -0,0 +1,55 @@
+package daemon
+
+import (
+	"errors"
+
+	"github.com/marciniwanicki/craby/internal/api"
+)
+
+// codedError attaches the error code sent to the client to a chat failure
+type codedError struct {
+	code api.ErrorCode
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// withCode marks err with the code the client receives if the chat fails with it
+func withCode(code api.ErrorCode, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+// toChatError converts a chat failure into the structured error sent to the client
+func toChatError(err error) *api.ChatError {
+	chatErr := &api.ChatError{Code: api.ErrorCode_ERROR_INTERNAL, Message: err.Error()}
+
+	var coded *codedError
+	if errors.As(err, &coded) {
+		chatErr.Code = coded.code
+	}
+	if errors.Is(err, errServerBusy) {
+		chatErr.Code = api.ErrorCode_ERROR_SERVER_BUSY
+	}
+
+	var unavailable *ModelUnavailableError
+	if errors.As(err, &unavailable) {
+		chatErr.Code = api.ErrorCode_ERROR_MODEL
+		chatErr.Detail = "model: " + unavailable.Model
+	}
+
+	return chatErr
+}
+
+// errorResponse wraps a chat error, repeating its message in the legacy field older clients read
+func errorResponse(chatErr *api.ChatError) *api.ChatResponse {
+	return &api.ChatResponse{
+		Payload:     &api.ChatResponse_Error{Error: chatErr},
+		LegacyError: chatErr.GetMessage(),
+	}
+}
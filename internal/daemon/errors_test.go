@@ -0,0 +1,69 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/marciniwanicki/craby/internal/agent"
+	"github.com/marciniwanicki/craby/internal/api"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestToChatError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   api.ErrorCode
+		wantDetail string
+	}{
+		{"uncoded", errors.New("boom"), api.ErrorCode_ERROR_INTERNAL, ""},
+		{"invalid request", withCode(api.ErrorCode_ERROR_INVALID_REQUEST, errors.New(`unknown persona "poet"`)), api.ErrorCode_ERROR_INVALID_REQUEST, ""},
+		{"server busy", errServerBusy, api.ErrorCode_ERROR_SERVER_BUSY, ""},
+		{"tool", withCode(api.ErrorCode_ERROR_TOOL, fmt.Errorf("%w: circular dependency", agent.ErrToolExecution)), api.ErrorCode_ERROR_TOOL, ""},
+		{
+			"model unavailable",
+			withCode(api.ErrorCode_ERROR_MODEL, fmt.Errorf("planning failed: %w", &ModelUnavailableError{Model: "llama3", Status: 404, Reason: "not found"})),
+			api.ErrorCode_ERROR_MODEL,
+			"model: llama3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toChatError(tt.err)
+			if got.Code != tt.wantCode {
+				t.Errorf("expected code %v, got %v", tt.wantCode, got.Code)
+			}
+			if got.Message != tt.err.Error() {
+				t.Errorf("expected message %q, got %q", tt.err.Error(), got.Message)
+			}
+			if got.Detail != tt.wantDetail {
+				t.Errorf("expected detail %q, got %q", tt.wantDetail, got.Detail)
+			}
+		})
+	}
+}
+
+func TestErrorResponse_LegacyField(t *testing.T) {
+	data, err := proto.Marshal(errorResponse(&api.ChatError{Code: api.ErrorCode_ERROR_MODEL, Message: "model offline"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clients predating ChatError read the message as a string in field 5
+	var legacy string
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		data = data[n:]
+		if num == 5 && typ == protowire.BytesType {
+			value, _ := protowire.ConsumeString(data)
+			legacy = value
+		}
+		data = data[protowire.ConsumeFieldValue(num, typ, data):]
+	}
+	if legacy != "model offline" {
+		t.Errorf("expected the message in the legacy error field, got %q", legacy)
+	}
+}
@@ -2,13 +2,20 @@ package daemon
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/marciniwanicki/craby/internal/agent"
 	"github.com/marciniwanicki/craby/internal/api"
+	"github.com/marciniwanicki/craby/internal/config"
 	"github.com/marciniwanicki/craby/internal/tools"
 	"github.com/rs/zerolog"
 	"google.golang.org/protobuf/proto"
@@ -21,32 +28,258 @@ type Runner interface {
 
 // Handler manages WebSocket connections and message handling
 type Handler struct {
+	toolsMu      sync.RWMutex // Guards runner and systemPrompt, replaced on reload
 	runner       Runner
 	systemPrompt string
-	shellTool    *tools.ShellTool
 	logger       zerolog.Logger
 	history      []agent.Message
 	context      string
+
+	// Rate limiting (0 requests per minute = unlimited)
+	rateLimitPerMinute int
+	rateLimitBurst     int
+	limitersMu         sync.Mutex
+	sessionLimiters    map[string]*rateLimiter
+
+	// Limits chats generating at once (nil = unlimited), and how long one may wait for a plan decision
+	queueMu         sync.Mutex
+	queue           *chatQueue
+	approvalTimeout time.Duration // 0 = unlimited
+
+	// In-flight chats by session ID, so a client can cancel its generation
+	generationsMu sync.Mutex
+	generations   map[string]context.CancelCauseFunc
+
+	// Instructions wrapped around every user message, replaced on reload
+	promptMu     sync.RWMutex
+	promptPrefix string
+	promptSuffix string
+
+	// Keeps history within the model's context window (nil = unlimited)
+	historyTrimmer *historyTrimmer
+	contextTurns   int // Earlier turns kept for the next message (-1 = unlimited)
+
+	// Websocket keepalive (0 = disabled)
+	pingInterval time.Duration
+	idleTimeout  time.Duration
+
+	// Bounds writing a response, so a client that stopped reading can't stall a chat forever
+	writeTimeout time.Duration
+
+	// Largest request read from a client (0 = unlimited)
+	maxMessageBytes int64
+
+	// Records every executed command (nil = disabled)
+	auditLog *config.CommandAuditLog
+
+	// Statistics
+	activeConnections atomic.Int32
+	chatsServed       atomic.Int64
+
+	// Shutdown: draining stops new chats, stop aborts the ones still running when the drain times out
+	chats    sync.WaitGroup
+	drainMu  sync.Mutex
+	draining chan struct{}
+	stopCtx  context.Context
+	stop     context.CancelFunc
 }
 
 // NewHandler creates a new handler with an Agent
-func NewHandler(agnt *agent.Agent, shellTool *tools.ShellTool, logger zerolog.Logger) *Handler {
-	return &Handler{
-		runner:       agnt,
-		systemPrompt: agnt.SystemPrompt(),
-		shellTool:    shellTool,
-		logger:       logger,
-	}
+func NewHandler(agnt *agent.Agent, logger zerolog.Logger) *Handler {
+	return newHandler(agnt, agnt.SystemPrompt(), logger)
 }
 
 // NewPipelineHandler creates a new handler with a Pipeline
-func NewPipelineHandler(pipeline *agent.Pipeline, systemPrompt string, shellTool *tools.ShellTool, logger zerolog.Logger) *Handler {
+func NewPipelineHandler(pipeline *agent.Pipeline, systemPrompt string, logger zerolog.Logger) *Handler {
+	return newHandler(pipeline, systemPrompt, logger)
+}
+
+func newHandler(runner Runner, systemPrompt string, logger zerolog.Logger) *Handler {
+	stopCtx, stop := context.WithCancel(context.Background())
 	return &Handler{
-		runner:       pipeline,
+		runner:       runner,
 		systemPrompt: systemPrompt,
-		shellTool:    shellTool,
 		logger:       logger,
+		generations:  make(map[string]context.CancelCauseFunc),
+		writeTimeout: responseWriteTimeout,
+		contextTurns: -1,
+		draining:     make(chan struct{}),
+		stopCtx:      stopCtx,
+		stop:         stop,
+	}
+}
+
+// Drain stops accepting chats and waits for the ones in progress to finish their current
+// generation. Chats still running when ctx expires are aborted.
+func (h *Handler) Drain(ctx context.Context) error {
+	h.drainMu.Lock()
+	if !h.isDraining() {
+		close(h.draining)
+	}
+	h.drainMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.chats.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		h.stop()
+		return ctx.Err()
+	}
+}
+
+func (h *Handler) isDraining() bool {
+	select {
+	case <-h.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// trackChat registers a chat connection with the drain, unless the handler is already draining
+func (h *Handler) trackChat() bool {
+	h.drainMu.Lock()
+	defer h.drainMu.Unlock()
+	if h.isDraining() {
+		return false
 	}
+	h.chats.Add(1)
+	return true
+}
+
+// closeGoingAway tells the client the daemon is closing the connection
+func (h *Handler) closeGoingAway(conn *websocket.Conn, reason string) {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, reason)
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(pingWriteTimeout))
+}
+
+// discardUnread reads and drops what the client is still sending, for a short while, so closing the
+// connection with unread data doesn't reset it before the client sees the close frame
+func discardUnread(conn *websocket.Conn) {
+	_ = conn.NetConn().SetReadDeadline(time.Now().Add(time.Second))
+	_, _ = io.Copy(io.Discard, conn.NetConn())
+}
+
+// pingWriteTimeout bounds sending a ping or close frame
+const pingWriteTimeout = 5 * time.Second
+
+// responseWriteTimeout bounds sending a chat response to a client that stopped reading
+const responseWriteTimeout = 10 * time.Second
+
+// SetMaxMessageSize limits the size of chat requests. Bigger ones are rejected before being read into
+// memory by closing the connection with a "message too big" status. Zero disables the limit.
+func (h *Handler) SetMaxMessageSize(bytes int) {
+	h.maxMessageBytes = int64(bytes)
+}
+
+// SetKeepalive pings chat connections every pingInterval, closing those that don't answer within
+// two intervals, and closes connections without chat requests for idleTimeout. Zero disables either.
+func (h *Handler) SetKeepalive(pingInterval, idleTimeout time.Duration) {
+	h.pingInterval = pingInterval
+	h.idleTimeout = idleTimeout
+}
+
+// extendReadDeadline gives the client another two ping intervals to show it is alive
+func (h *Handler) extendReadDeadline(conn *websocket.Conn) {
+	if h.pingInterval > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(2 * h.pingInterval))
+	}
+}
+
+// keepAlive pings the client until ctx is done or a ping can't be sent
+func (h *Handler) keepAlive(ctx context.Context, conn *websocket.Conn) {
+	ticker := time.NewTicker(h.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pingWriteTimeout)); err != nil {
+				h.logger.Debug().Err(err).Msg("failed to send ping")
+				return
+			}
+		}
+	}
+}
+
+// SetTools replaces the runner used for new chats. Chats in progress keep the previous one.
+func (h *Handler) SetTools(runner Runner, systemPrompt string) {
+	h.toolsMu.Lock()
+	defer h.toolsMu.Unlock()
+	h.runner = runner
+	h.systemPrompt = systemPrompt
+}
+
+// currentRunner returns the runner for a new chat
+func (h *Handler) currentRunner() Runner {
+	h.toolsMu.RLock()
+	defer h.toolsMu.RUnlock()
+	return h.runner
+}
+
+// SetCommandAudit records every command run by the shell tool, and help commands run by the
+// schema tool during discovery, to the audit log
+func (h *Handler) SetCommandAudit(auditLog *config.CommandAuditLog) {
+	h.toolsMu.Lock()
+	defer h.toolsMu.Unlock()
+	h.auditLog = auditLog
+}
+
+// commandRecorder returns a recorder that writes commands run for sessionID to the audit log
+func (h *Handler) commandRecorder(sessionID string) tools.CommandRecorder {
+	return func(record tools.CommandRecord) {
+		err := h.auditLog.Record(config.CommandAuditEntry{
+			SessionID:   sessionID,
+			Command:     record.Command,
+			IsDiscovery: record.IsDiscovery,
+			ExitCode:    record.ExitCode,
+			DurationMs:  record.Duration.Milliseconds(),
+			OutputBytes: len(record.Output),
+			OutputHash:  config.OutputHash(record.Output),
+		})
+		if err != nil {
+			h.logger.Warn().Err(err).Msg("failed to write command audit log")
+		}
+	}
+}
+
+// toolHooks returns the hooks for the tool calls of a chat in sessionID. They report the commands
+// tools run, their output and discovery progress as chat events.
+func (h *Handler) toolHooks(sessionID string, eventChan chan<- agent.Event) tools.Hooks {
+	hooks := tools.Hooks{
+		Command: func(command string) {
+			eventChan <- agent.Event{
+				Type:         agent.EventShellCommand,
+				ShellCommand: command,
+			}
+		},
+		Output: func(line string) {
+			eventChan <- agent.Event{
+				Type:        agent.EventShellOutput,
+				ShellOutput: line,
+			}
+		},
+		// Discovery can take a while on a command's first use
+		Discovery: func(step tools.DiscoveryStep) {
+			eventChan <- agent.Event{
+				Type:             agent.EventDiscoveryStep,
+				DiscoveryCommand: step.Command,
+				DiscoverySummary: step.Summary,
+			}
+		},
+	}
+	if h.auditLog != nil {
+		hooks.Recorder = h.commandRecorder(sessionID)
+	}
+	return hooks
 }
 
 // History returns the current conversation history
@@ -61,10 +294,14 @@ func (h *Handler) Context() string {
 
 // FullContext returns the complete context (system prompt + user context)
 func (h *Handler) FullContext() string {
+	h.toolsMu.RLock()
+	systemPrompt := h.systemPrompt
+	h.toolsMu.RUnlock()
+
 	if h.context == "" {
-		return h.systemPrompt
+		return systemPrompt
 	}
-	return h.systemPrompt + "\n\n<context>\n" + h.context + "\n</context>"
+	return systemPrompt + "\n\n<context>\n" + h.context + "\n</context>"
 }
 
 // SetContext sets the context string
@@ -72,72 +309,503 @@ func (h *Handler) SetContext(ctx string) {
 	h.context = ctx
 }
 
+// ActiveConnections returns the number of open chat connections
+func (h *Handler) ActiveConnections() int32 {
+	return h.activeConnections.Load()
+}
+
+// ChatsServed returns the number of chat requests completed successfully
+func (h *Handler) ChatsServed() int64 {
+	return h.chatsServed.Load()
+}
+
+// SetRateLimit caps chat requests per connection, and per session across connections when a
+// session ID is sent. A perMinute of 0 disables rate limiting.
+func (h *Handler) SetRateLimit(perMinute, burst int) {
+	h.limitersMu.Lock()
+	defer h.limitersMu.Unlock()
+	// Keep the buckets when the limits are unchanged, so reloading doesn't refill them
+	if h.sessionLimiters != nil && h.rateLimitPerMinute == perMinute && h.rateLimitBurst == burst {
+		return
+	}
+	h.rateLimitPerMinute = perMinute
+	h.rateLimitBurst = burst
+	h.sessionLimiters = make(map[string]*rateLimiter)
+}
+
+// SetChatQueue lets maxConcurrent chats generate at once, queueing up to maxQueued more in arrival
+// order and rejecting the rest as busy. A maxConcurrent of 0 disables the limit. Chats already
+// running or queued keep their place.
+func (h *Handler) SetChatQueue(maxConcurrent, maxQueued int) {
+	h.queueMu.Lock()
+	defer h.queueMu.Unlock()
+	// Keep the queue when the limits are unchanged, so reloading doesn't forget the running chats
+	if q := h.queue; q != nil && q.maxConcurrent == maxConcurrent && q.maxQueued == max(maxQueued, 0) {
+		return
+	}
+	h.queue = newChatQueue(maxConcurrent, maxQueued)
+}
+
+// SetApprovalTimeout bounds how long a chat waits for the client to decide on a plan, holding its
+// turn in the queue meanwhile. A timeout of 0 waits for as long as the client stays connected.
+func (h *Handler) SetApprovalTimeout(timeout time.Duration) {
+	h.queueMu.Lock()
+	defer h.queueMu.Unlock()
+	h.approvalTimeout = timeout
+}
+
+// currentApprovalTimeout returns how long a new plan decision may take
+func (h *Handler) currentApprovalTimeout() time.Duration {
+	h.queueMu.Lock()
+	defer h.queueMu.Unlock()
+	return h.approvalTimeout
+}
+
+// currentQueue returns the queue new chats wait in
+func (h *Handler) currentQueue() *chatQueue {
+	h.queueMu.Lock()
+	defer h.queueMu.Unlock()
+	return h.queue
+}
+
+// ChatsQueued returns the number of chats waiting for the model
+func (h *Handler) ChatsQueued() int32 {
+	return int32(h.currentQueue().Queued()) //nolint:gosec // G115: bounded by max_queued
+}
+
+// SetHistoryLimit bounds the conversation history to roughly maxTokens, including the system prompt.
+// Older turns are dropped or, with the summarize strategy, condensed into a system note by summarizer.
+// A maxTokens of 0 disables trimming.
+func (h *Handler) SetHistoryLimit(maxTokens int, strategy string, summarizer Summarizer) {
+	h.historyTrimmer = &historyTrimmer{
+		maxTokens:  maxTokens,
+		strategy:   strategy,
+		summarizer: summarizer,
+		logger:     h.logger,
+	}
+}
+
+// SetPromptWrap sets instructions added before and after every user message.
+// Chats can replace either with their own.
+func (h *Handler) SetPromptWrap(prefix, suffix string) {
+	h.promptMu.Lock()
+	defer h.promptMu.Unlock()
+	h.promptPrefix = prefix
+	h.promptSuffix = suffix
+}
+
+// wrapMessage adds the chat's or the configured prefix and suffix to the user's message
+func (h *Handler) wrapMessage(req *api.ChatRequest) string {
+	h.promptMu.RLock()
+	prefix, suffix := h.promptPrefix, h.promptSuffix
+	h.promptMu.RUnlock()
+
+	if req.PromptPrefix != "" {
+		prefix = req.PromptPrefix
+	}
+	if req.PromptSuffix != "" {
+		suffix = req.PromptSuffix
+	}
+	if prefix == "" && suffix == "" {
+		return req.Message
+	}
+
+	h.logger.Info().Str("prefix", prefix).Str("suffix", suffix).Msg("wrapping user message")
+	parts := make([]string, 0, 3)
+	for _, part := range []string{prefix, req.Message, suffix} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// SetContextTurns bounds how many earlier turns are kept and sent with the next message.
+// A negative count keeps every turn that fits the history limit; 0 makes each message stand alone.
+func (h *Handler) SetContextTurns(turns int) {
+	h.contextTurns = turns
+}
+
+// limiterFor returns the rate limiters a request is charged to: the connection's, and the session's
+// so a session can't get a fresh budget by reconnecting. The connection's is charged too, so
+// neither can a connection by sending new session IDs.
+func (h *Handler) limiterFor(sessionID string, connLimiter *rateLimiter) rateLimiters {
+	if sessionID == "" {
+		return rateLimiters{connLimiter}
+	}
+
+	h.limitersMu.Lock()
+	defer h.limitersMu.Unlock()
+
+	if h.rateLimitPerMinute <= 0 {
+		return rateLimiters{connLimiter}
+	}
+	limiter, ok := h.sessionLimiters[sessionID]
+	if !ok {
+		// Sessions whose bucket refilled lose nothing by starting over, so they don't pile up
+		for id, idle := range h.sessionLimiters {
+			if idle.full() {
+				delete(h.sessionLimiters, id)
+			}
+		}
+		limiter = newRateLimiter(h.rateLimitPerMinute, h.rateLimitBurst)
+		h.sessionLimiters[sessionID] = limiter
+	}
+	return rateLimiters{connLimiter, limiter}
+}
+
 // HandleChat processes a chat WebSocket connection
 func (h *Handler) HandleChat(conn *websocket.Conn) {
 	defer conn.Close()
 
+	if !h.trackChat() {
+		h.closeGoingAway(conn, "daemon shutting down")
+		return
+	}
+	defer h.chats.Done()
+
+	h.activeConnections.Add(1)
+	defer h.activeConnections.Add(-1)
+
+	h.limitersMu.Lock()
+	connLimiter := newRateLimiter(h.rateLimitPerMinute, h.rateLimitBurst)
+	h.limitersMu.Unlock()
+
+	// Canceled when the client disconnects or shutdown can't wait any longer, aborting any in-flight chat
+	ctx, cancel := context.WithCancel(h.stopCtx)
+	defer cancel()
+
+	if h.maxMessageBytes > 0 {
+		conn.SetReadLimit(h.maxMessageBytes)
+	}
+
+	// Detect dead connections: pongs and messages push the read deadline forward
+	if h.pingInterval > 0 {
+		h.extendReadDeadline(conn)
+		conn.SetPongHandler(func(string) error {
+			h.extendReadDeadline(conn)
+			return nil
+		})
+		go h.keepAlive(ctx, conn)
+	}
+
+	// Read in the background so a disconnect is noticed while a chat is being processed
+	messages := make(chan []byte)
+	go func() {
+		defer cancel()
+		defer close(messages)
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				var netErr net.Error
+				switch {
+				// Treat EOF, unexpected EOF, and normal close as clean disconnects
+				case websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) ||
+					errors.Is(err, io.EOF) || strings.Contains(err.Error(), "EOF"):
+					h.logger.Debug().Msg("client disconnected")
+				case errors.As(err, &netErr) && netErr.Timeout():
+					h.logger.Info().Msg("client stopped answering pings, closing connection")
+				case errors.Is(err, websocket.ErrReadLimit):
+					h.logger.Warn().Int64("max_bytes", h.maxMessageBytes).Msg("chat request too large, closing connection")
+					discardUnread(conn)
+				default:
+					h.logger.Error().Err(err).Msg("failed to read message")
+				}
+				return
+			}
+			h.extendReadDeadline(conn)
+
+			if messageType != websocket.BinaryMessage {
+				h.logger.Warn().Int("type", messageType).Msg("received non-binary message")
+				continue
+			}
+
+			select {
+			case messages <- data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Idle time is counted from the end of the last chat
+	var idle <-chan time.Time
+	var idleTimer *time.Timer
+	if h.idleTimeout > 0 {
+		idleTimer = time.NewTimer(h.idleTimeout)
+		defer idleTimer.Stop()
+		idle = idleTimer.C
+	}
+
 	for {
-		messageType, data, err := conn.ReadMessage()
-		if err != nil {
-			// Treat EOF, unexpected EOF, and normal close as clean disconnects
-			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) ||
-				errors.Is(err, io.EOF) || strings.Contains(err.Error(), "EOF") {
-				h.logger.Debug().Msg("client disconnected")
-			} else {
-				h.logger.Error().Err(err).Msg("failed to read message")
+		if idleTimer != nil {
+			idleTimer.Reset(h.idleTimeout)
+		}
+
+		var data []byte
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return
 			}
+			data = msg
+		case <-h.draining:
+		case <-idle:
+			h.logger.Info().Dur("idle_timeout", h.idleTimeout).Msg("closing idle chat connection")
+			h.closeGoingAway(conn, "idle timeout")
 			return
 		}
-
-		if messageType != websocket.BinaryMessage {
-			h.logger.Warn().Int("type", messageType).Msg("received non-binary message")
-			continue
+		// Finish the current generation, but don't start new ones once shutdown began
+		if h.isDraining() {
+			h.logger.Debug().Msg("closing chat connection for shutdown")
+			h.closeGoingAway(conn, "daemon shutting down")
+			return
 		}
 
 		var req api.ChatRequest
 		if err := proto.Unmarshal(data, &req); err != nil {
 			h.logger.Error().Err(err).Msg("failed to unmarshal request")
-			h.sendError(conn, "invalid request format")
+			h.sendError(conn, &api.ChatError{Code: api.ErrorCode_ERROR_INVALID_REQUEST, Message: "invalid request format"})
+			continue
+		}
+		if req.PlanDecision != nil {
+			h.logger.Warn().Msg("ignoring plan decision, no plan is waiting for approval")
+			continue
+		}
+
+		limiter := h.limiterFor(req.SessionId, connLimiter)
+		if allowed, _ := limiter.Allow(); !allowed {
+			h.logger.Warn().Str("session_id", req.SessionId).Msg("chat request rate limited")
+			h.sendRateLimited(conn)
 			continue
 		}
 
 		h.logger.Info().Str("message", req.Message).Msg("received chat request")
 
-		if err := h.processChat(conn, req.Message); err != nil {
+		// Canceled by the client through CancelGeneration, without closing the connection
+		chatCtx, cancelChat := context.WithCancelCause(ctx)
+		untrack := h.trackGeneration(req.SessionId, cancelChat)
+
+		queued := false
+		release, err := h.currentQueue().Acquire(chatCtx, func(position int) {
+			h.logger.Debug().Int("position", position).Msg("chat queued")
+			queued = true
+			h.sendQueuePosition(conn, position)
+		})
+		if err == nil {
+			if queued {
+				h.sendQueuePosition(conn, 0)
+			}
+			err = h.processChat(chatCtx, conn, &req, limiter, messages)
+			release()
+		}
+		untrack()
+		canceled := errors.Is(context.Cause(chatCtx), errGenerationCanceled)
+		cancelChat(nil)
+
+		switch {
+		case err == nil:
+			h.chatsServed.Add(1)
+		case ctx.Err() != nil:
+			h.logger.Info().Msg("chat canceled, client disconnected")
+			return
+		case errors.Is(err, errClientUnreachable):
+			// Writes fail for good after one times out, so the connection is of no further use
+			h.logger.Warn().Err(err).Msg("chat aborted, client stopped reading")
+			return
+		case canceled:
+			h.logger.Info().Str("session_id", req.SessionId).Msg("chat canceled by client")
+			h.sendError(conn, toChatError(withCode(api.ErrorCode_ERROR_CANCELED, errGenerationCanceled)))
+		case errors.Is(err, agent.ErrPlanRejected):
+			h.logger.Info().Str("session_id", req.SessionId).Msg("chat aborted, plan rejected by client")
+			h.sendError(conn, toChatError(err))
+		case errors.Is(err, errApprovalTimeout):
+			h.logger.Info().Str("session_id", req.SessionId).Msg("chat aborted, no plan decision in time")
+			h.sendError(conn, toChatError(err))
+		case errors.Is(err, errServerBusy):
+			h.logger.Warn().Msg("chat rejected, queue is full")
+			h.sendError(conn, toChatError(err))
+		default:
 			h.logger.Error().Err(err).Msg("failed to process chat")
-			h.sendError(conn, err.Error())
+			h.sendError(conn, toChatError(err))
 		}
 	}
 }
 
-func (h *Handler) processChat(conn *websocket.Conn, message string) error {
-	ctx := context.Background()
-	eventChan := make(chan agent.Event, 100)
+// errGenerationCanceled is the cause of a chat canceled through CancelGeneration
+var errGenerationCanceled = errors.New("generation canceled")
 
-	opts := agent.RunOptions{
-		History: h.history,
-		Context: h.context,
+// errApprovalTimeout is returned by a chat's PlanApprover when the client didn't decide in time
+var errApprovalTimeout = errors.New("no plan decision in time")
+
+// errClientUnreachable is returned by processChat when a response couldn't be written to the client
+var errClientUnreachable = errors.New("client unreachable")
+
+// trackGeneration registers a chat so CancelGeneration can stop it, returning a func that unregisters it.
+// Chats without a session ID can't be canceled.
+func (h *Handler) trackGeneration(sessionID string, cancel context.CancelCauseFunc) func() {
+	if sessionID == "" {
+		return func() {}
 	}
+	h.generationsMu.Lock()
+	h.generations[sessionID] = cancel
+	h.generationsMu.Unlock()
 
-	// Set command observer on shell tool
-	if h.shellTool != nil {
-		h.shellTool.SetCommandObserver(func(command string) {
-			eventChan <- agent.Event{
-				Type:         agent.EventShellCommand,
-				ShellCommand: command,
-			}
+	return func() {
+		h.generationsMu.Lock()
+		delete(h.generations, sessionID)
+		h.generationsMu.Unlock()
+	}
+}
+
+// CancelGeneration stops the session's running or queued chat, keeping its connection and history.
+// Returns false if the session had no chat in progress.
+func (h *Handler) CancelGeneration(sessionID string) bool {
+	h.generationsMu.Lock()
+	cancel, ok := h.generations[sessionID]
+	delete(h.generations, sessionID)
+	h.generationsMu.Unlock()
+
+	if ok {
+		cancel(errGenerationCanceled)
+	}
+	return ok
+}
+
+// planToProto converts a plan for streaming to traced chats
+func planToProto(plan *agent.Plan) *api.Plan {
+	steps := make([]*api.PlanStep, 0, len(plan.Steps))
+	for i := range plan.Steps {
+		step := &plan.Steps[i]
+		args, err := json.Marshal(step.ArgsMap())
+		if err != nil {
+			args = []byte("{}")
+		}
+		steps = append(steps, &api.PlanStep{
+			Id:        step.ID,
+			Tool:      step.Tool,
+			Purpose:   step.Purpose,
+			Arguments: string(args),
 		})
 	}
+	return &api.Plan{
+		Intent:        plan.Intent,
+		Complexity:    string(plan.Complexity),
+		ReadyToAnswer: plan.ReadyToAnswer,
+		Steps:         steps,
+	}
+}
+
+// awaitPlanDecision returns an approver that waits for the client's PlanDecision on the chat's connection.
+// Other messages meanwhile are answered with a notice on eventChan and otherwise ignored.
+func (h *Handler) awaitPlanDecision(messages <-chan []byte, eventChan chan<- agent.Event) agent.PlanApprover {
+	return func(ctx context.Context, steps []agent.PlanStep) (bool, error) {
+		h.logger.Debug().Int("steps", len(steps)).Msg("waiting for plan approval")
+		if timeout := h.currentApprovalTimeout(); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w: waited %v", errApprovalTimeout, timeout))
+			defer cancel()
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return false, context.Cause(ctx)
+			case data, ok := <-messages:
+				if !ok {
+					return false, errors.New("client disconnected before deciding on the plan")
+				}
+				var req api.ChatRequest
+				if err := proto.Unmarshal(data, &req); err != nil || req.PlanDecision == nil {
+					h.logger.Warn().Msg("ignoring message, a plan is waiting for approval")
+					eventChan <- agent.Event{
+						Type: agent.EventText,
+						Role: agent.RoleSystem,
+						Text: "Message ignored: a plan is waiting for approval.\n",
+					}
+					continue
+				}
+				return req.PlanDecision.Approved, nil
+			}
+		}
+	}
+}
+
+// confirmWrites returns a confirmer that asks the client to approve each write as a one-step plan
+func confirmWrites(ctx context.Context, approve agent.PlanApprover, eventChan chan<- agent.Event) tools.WriteConfirmer {
+	return func(path, description string) bool {
+		step := agent.PlanStep{
+			ID:      "write",
+			Tool:    "write",
+			Purpose: description,
+			Args:    []agent.PlanArg{{Name: "path", Value: path}},
+		}
+		eventChan <- agent.Event{
+			Type: agent.EventPlanApproval,
+			Plan: &agent.Plan{Intent: "write " + path, NeedsTools: true, Steps: []agent.PlanStep{step}},
+		}
+		approved, err := approve(ctx, []agent.PlanStep{step})
+		return err == nil && approved
+	}
+}
+
+// resolveIdentity returns the identity requested for a chat: an explicit system prompt,
+// a persona template, or empty for the default identity
+func resolveIdentity(req *api.ChatRequest) (string, error) {
+	if req.SystemPrompt != "" {
+		return req.SystemPrompt, nil
+	}
+	if req.Persona == "" {
+		return "", nil
+	}
+	return config.LoadPersona(req.Persona)
+}
+
+// processChat answers a chat request. Messages read from the connection meanwhile carry plan decisions.
+func (h *Handler) processChat(ctx context.Context, conn *websocket.Conn, req *api.ChatRequest, limiter rateLimiters, messages <-chan []byte) error {
+	// Canceled if the client can no longer be written to, so the runner stops early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Records which model answered, in case a fallback stepped in for the primary
+	served := &agent.ServedModel{}
+	ctx = agent.WithServedModel(ctx, served)
+	usage := &agent.Usage{}
+	ctx = agent.WithUsage(ctx, usage)
+
+	identity, err := resolveIdentity(req)
+	if err != nil {
+		return withCode(api.ErrorCode_ERROR_INVALID_REQUEST, err)
+	}
+
+	eventChan := make(chan agent.Event, 100)
+
+	opts := agent.RunOptions{
+		History:       h.history,
+		Context:       h.context,
+		Format:        req.Format,
+		Identity:      identity,
+		NoTools:       req.NoTools,
+		DisabledTools: req.DisabledTools,
+	}
+	runner := h.currentRunner()
+	hooks := h.toolHooks(req.SessionId, eventChan)
+	if req.ApprovePlans {
+		opts.ApprovePlan = h.awaitPlanDecision(messages, eventChan)
+		hooks.ConfirmWrite = confirmWrites(ctx, opts.ApprovePlan, eventChan)
+	}
+	ctx = tools.WithHooks(ctx, hooks)
 
 	h.logger.Debug().
 		Int("history_len", len(h.history)).
 		Bool("has_context", h.context != "").
 		Msg("starting chat processing")
 
+	message := h.wrapMessage(req)
+
 	resultChan := make(chan []agent.Message, 1)
 	errChan := make(chan error, 1)
 	go func() {
-		history, err := h.runner.Run(ctx, message, opts, eventChan)
+		history, err := runner.Run(ctx, message, opts, eventChan)
 		if err != nil {
 			h.logger.Error().Err(err).Msg("runner failed")
 			errChan <- err
@@ -148,6 +816,8 @@ func (h *Handler) processChat(conn *websocket.Conn, message string) error {
 	}()
 
 	// Stream events to client
+	var sendErr error
+	answered := false // Whether the model said anything beyond whitespace
 	for event := range eventChan {
 		var resp *api.ChatResponse
 
@@ -156,6 +826,8 @@ func (h *Handler) processChat(conn *websocket.Conn, message string) error {
 			role := api.Role_ASSISTANT
 			if event.Role == agent.RoleSystem {
 				role = api.Role_SYSTEM
+			} else if strings.TrimSpace(event.Text) != "" {
+				answered = true
 			}
 			h.logger.Debug().
 				Str("type", "text").
@@ -170,6 +842,11 @@ func (h *Handler) processChat(conn *websocket.Conn, message string) error {
 				},
 			}
 
+		case agent.EventThinking:
+			resp = &api.ChatResponse{
+				Payload: &api.ChatResponse_Thinking{Thinking: event.Text},
+			}
+
 		case agent.EventToolCall:
 			h.logger.Debug().
 				Str("type", "tool_call").
@@ -192,14 +869,16 @@ func (h *Handler) processChat(conn *websocket.Conn, message string) error {
 				Str("tool", event.ToolName).
 				Bool("success", event.ToolSuccess).
 				Int("output_len", len(event.ToolOutput)).
+				Dur("duration", event.ToolDuration).
 				Msg("streaming event")
 			resp = &api.ChatResponse{
 				Payload: &api.ChatResponse_ToolResult{
 					ToolResult: &api.ToolResult{
-						Id:      event.ToolID,
-						Name:    event.ToolName,
-						Output:  event.ToolOutput,
-						Success: event.ToolSuccess,
+						Id:         event.ToolID,
+						Name:       event.ToolName,
+						Output:     event.ToolOutput,
+						Success:    event.ToolSuccess,
+						DurationMs: event.ToolDuration.Milliseconds(),
 					},
 				},
 			}
@@ -219,8 +898,27 @@ func (h *Handler) processChat(conn *websocket.Conn, message string) error {
 				},
 			}
 
+		case agent.EventShellOutput:
+			resp = &api.ChatResponse{
+				Payload: &api.ChatResponse_ShellOutput{ShellOutput: event.ShellOutput},
+			}
+
+		case agent.EventDiscoveryStep:
+			h.logger.Debug().
+				Str("type", "discovery_step").
+				Str("command", event.DiscoveryCommand).
+				Str("summary", event.DiscoverySummary).
+				Msg("streaming event")
+			resp = &api.ChatResponse{
+				Payload: &api.ChatResponse_DiscoveryStep{
+					DiscoveryStep: &api.DiscoveryStep{
+						Command: event.DiscoveryCommand,
+						Summary: event.DiscoverySummary,
+					},
+				},
+			}
+
 		case agent.EventPlanGenerated:
-			// Log plan generation (no client notification needed)
 			if event.Plan != nil {
 				h.logger.Debug().
 					Str("type", "plan_generated").
@@ -228,8 +926,22 @@ func (h *Handler) processChat(conn *websocket.Conn, message string) error {
 					Str("complexity", string(event.Plan.Complexity)).
 					Int("steps", len(event.Plan.Steps)).
 					Msg("plan generated")
+				// Plans are internal, only traced chats see them
+				if req.Trace {
+					resp = &api.ChatResponse{
+						Payload: &api.ChatResponse_Plan{Plan: planToProto(event.Plan)},
+					}
+				}
+			}
+
+		case agent.EventPlanApproval:
+			h.logger.Debug().
+				Str("type", "plan_approval").
+				Int("steps", len(event.Plan.Steps)).
+				Msg("streaming event")
+			resp = &api.ChatResponse{
+				Payload: &api.ChatResponse_PlanApproval{PlanApproval: planToProto(event.Plan)},
 			}
-			// Don't send to client - this is internal
 
 		case agent.EventStepStarted:
 			// Log step start (could add client notification in the future)
@@ -240,26 +952,65 @@ func (h *Handler) processChat(conn *websocket.Conn, message string) error {
 			// Don't send to client - tool call event follows
 		}
 
-		if resp != nil {
+		if resp != nil && sendErr == nil {
 			if err := h.sendResponse(conn, resp); err != nil {
-				return err
+				// Stop the runner but keep draining events so it can finish
+				sendErr = err
+				cancel()
 			}
 		}
 	}
 
+	if sendErr != nil {
+		return withCode(api.ErrorCode_ERROR_INTERNAL, fmt.Errorf("%w: %w", errClientUnreachable, sendErr))
+	}
+
 	// Check for errors or get updated history
+	var model string
+	var fallback bool
 	select {
 	case err := <-errChan:
-		return err
+		if errors.Is(err, agent.ErrToolExecution) {
+			return withCode(api.ErrorCode_ERROR_TOOL, err)
+		}
+		if errors.Is(err, agent.ErrPlanRejected) || errors.Is(err, errApprovalTimeout) {
+			return withCode(api.ErrorCode_ERROR_CANCELED, err)
+		}
+		return withCode(api.ErrorCode_ERROR_MODEL, err)
 	case history := <-resultChan:
-		h.history = history
+		model, fallback = served.Get()
+		h.history = h.historyTrimmer.Trim(ctx, h.FullContext(), lastTurns(history, h.contextTurns))
+	}
+
+	if fallback {
+		h.logger.Warn().Str("model", model).Msg("chat served by fallback model")
+	}
+	if !answered {
+		// Tell "the model said nothing" apart from failures, which look the same to the user
+		_, completionTokens := usage.Totals()
+		h.logger.Warn().
+			Str("session_id", req.SessionId).
+			Str("message", req.Message).
+			Str("model", model).
+			Int("completion_tokens", completionTokens).
+			Msg("model returned an empty response")
 	}
 
 	// Send done signal
+	promptTokens, completionTokens := usage.Totals()
 	resp := &api.ChatResponse{
-		Payload: &api.ChatResponse_Done{Done: true},
+		Payload:            &api.ChatResponse_Done{Done: true},
+		RateLimitRemaining: int32(limiter.Remaining()), //nolint:gosec // G115: bounded by burst size
+		Model:              model,
+		Fallback:           fallback,
+		PromptTokens:       int32(promptTokens),     //nolint:gosec // G115: token counts fit in int32
+		CompletionTokens:   int32(completionTokens), //nolint:gosec // G115: token counts fit in int32
+		EvalDurationMs:     usage.EvalDuration().Milliseconds(),
 	}
-	return h.sendResponse(conn, resp)
+	if err := h.sendResponse(conn, resp); err != nil {
+		return fmt.Errorf("%w: %w", errClientUnreachable, err)
+	}
+	return nil
 }
 
 func (h *Handler) sendResponse(conn *websocket.Conn, resp *api.ChatResponse) error {
@@ -267,19 +1018,45 @@ func (h *Handler) sendResponse(conn *websocket.Conn, resp *api.ChatResponse) err
 	if err != nil {
 		return err
 	}
-	return conn.WriteMessage(websocket.BinaryMessage, data)
+	return h.writeMessage(conn, data)
 }
 
-func (h *Handler) sendError(conn *websocket.Conn, errMsg string) {
-	resp := &api.ChatResponse{
-		Payload: &api.ChatResponse_Error{Error: errMsg},
+// writeMessage writes a binary message, failing if the client doesn't take it within the write timeout
+func (h *Handler) writeMessage(conn *websocket.Conn, data []byte) error {
+	if h.writeTimeout > 0 {
+		_ = conn.SetWriteDeadline(time.Now().Add(h.writeTimeout))
 	}
-	data, err := proto.Marshal(resp)
+	return conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+func (h *Handler) sendError(conn *websocket.Conn, chatErr *api.ChatError) {
+	data, err := proto.Marshal(errorResponse(chatErr))
 	if err != nil {
 		h.logger.Error().Err(err).Msg("failed to marshal error response")
 		return
 	}
-	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+	if err := h.writeMessage(conn, data); err != nil {
 		h.logger.Error().Err(err).Msg("failed to send error response")
 	}
 }
+
+// sendQueuePosition tells the client where its chat is in the queue, 0 once it has started
+func (h *Handler) sendQueuePosition(conn *websocket.Conn, position int) {
+	resp := &api.ChatResponse{
+		Payload: &api.ChatResponse_QueuePosition{QueuePosition: int32(position)}, //nolint:gosec // G115: bounded by max_queued
+	}
+	if err := h.sendResponse(conn, resp); err != nil {
+		h.logger.Debug().Err(err).Msg("failed to send queue position")
+	}
+}
+
+// sendRateLimited tells the client its request was rejected because the rate limit was exceeded
+func (h *Handler) sendRateLimited(conn *websocket.Conn) {
+	resp := errorResponse(&api.ChatError{
+		Code:    api.ErrorCode_ERROR_RATE_LIMITED,
+		Message: "rate limit exceeded, please slow down",
+	})
+	if err := h.sendResponse(conn, resp); err != nil {
+		h.logger.Error().Err(err).Msg("failed to send rate limit response")
+	}
+}
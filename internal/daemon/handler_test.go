@@ -1,11 +1,27 @@
 package daemon
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/marciniwanicki/craby/internal/agent"
+	"github.com/marciniwanicki/craby/internal/api"
+	"github.com/marciniwanicki/craby/internal/config"
 	"github.com/marciniwanicki/craby/internal/tools"
 	"github.com/rs/zerolog"
+	"google.golang.org/protobuf/proto"
 )
 
 func testLogger() zerolog.Logger {
@@ -15,7 +31,7 @@ func testLogger() zerolog.Logger {
 func TestHandler_Context(t *testing.T) {
 	registry := tools.NewRegistry()
 	agnt := agent.NewAgent(nil, registry, testLogger(), "system prompt")
-	handler := NewHandler(agnt, nil, testLogger())
+	handler := NewHandler(agnt, testLogger())
 
 	// Initially empty
 	if got := handler.Context(); got != "" {
@@ -38,7 +54,7 @@ func TestHandler_Context(t *testing.T) {
 func TestHandler_FullContext(t *testing.T) {
 	registry := tools.NewRegistry()
 	agnt := agent.NewAgent(nil, registry, testLogger(), "system prompt")
-	handler := NewHandler(agnt, nil, testLogger())
+	handler := NewHandler(agnt, testLogger())
 
 	// Without user context, should return just system prompt
 	if got := handler.FullContext(); got != "system prompt" {
@@ -56,10 +72,854 @@ func TestHandler_FullContext(t *testing.T) {
 func TestHandler_History(t *testing.T) {
 	registry := tools.NewRegistry()
 	agnt := agent.NewAgent(nil, registry, testLogger(), "system prompt")
-	handler := NewHandler(agnt, nil, testLogger())
+	handler := NewHandler(agnt, testLogger())
 
 	// Initially empty
 	if got := handler.History(); len(got) != 0 {
 		t.Errorf("expected empty history, got %d items", len(got))
 	}
 }
+
+// fakeRunner streams a fixed reply and records the messages it was asked to answer
+type fakeRunner struct {
+	reply string
+}
+
+func (r *fakeRunner) Run(_ context.Context, userMessage string, opts agent.RunOptions, eventChan chan<- agent.Event) ([]agent.Message, error) {
+	defer close(eventChan)
+	eventChan <- agent.Event{Type: agent.EventText, Text: r.reply, Role: agent.RoleAssistant}
+	history := append([]agent.Message{}, opts.History...)
+	return append(history,
+		agent.Message{Role: "user", Content: userMessage},
+		agent.Message{Role: "assistant", Content: r.reply},
+	), nil
+}
+
+// startChatServer serves the handler's chat endpoint and returns a connected websocket
+func startChatServer(t *testing.T, handler *Handler) *websocket.Conn {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		handler.HandleChat(conn)
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// sendChat sends a chat request and collects responses until Done or Error
+func sendChat(t *testing.T, conn *websocket.Conn, req *api.ChatRequest) []*api.ChatResponse {
+	t.Helper()
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatalf("failed to marshal request: %v", err)
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+
+	var responses []*api.ChatResponse
+	for {
+		_, respData, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		var resp api.ChatResponse
+		if err := proto.Unmarshal(respData, &resp); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		responses = append(responses, &resp)
+		switch resp.Payload.(type) {
+		case *api.ChatResponse_Done, *api.ChatResponse_Error:
+			return responses
+		}
+	}
+}
+
+func TestHandler_HandleChat_Stats(t *testing.T) {
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = &fakeRunner{reply: "hi"}
+
+	conn := startChatServer(t, handler)
+	responses := sendChat(t, conn, &api.ChatRequest{Message: "hello"})
+
+	if _, ok := responses[len(responses)-1].Payload.(*api.ChatResponse_Done); !ok {
+		t.Fatalf("expected done response, got %v", responses[len(responses)-1])
+	}
+	if got := handler.ChatsServed(); got != 1 {
+		t.Errorf("expected 1 chat served, got %d", got)
+	}
+	if got := handler.ActiveConnections(); got != 1 {
+		t.Errorf("expected 1 active connection, got %d", got)
+	}
+}
+
+// lockedBuffer collects log output written from the handler's goroutines
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestHandler_HandleChat_WarnsOnEmptyResponse(t *testing.T) {
+	var logs lockedBuffer
+	handler := NewPipelineHandler(nil, "system prompt", zerolog.New(&logs).Level(zerolog.WarnLevel))
+	handler.runner = &fakeRunner{reply: " \n"}
+
+	conn := startChatServer(t, handler)
+	responses := sendChat(t, conn, &api.ChatRequest{Message: "say nothing", SessionId: "s1"})
+	if _, ok := responses[len(responses)-1].Payload.(*api.ChatResponse_Done); !ok {
+		t.Fatalf("expected done response, got %v", responses[len(responses)-1])
+	}
+	got := logs.String()
+	if !strings.Contains(got, `"level":"warn"`) || !strings.Contains(got, "model returned an empty response") ||
+		!strings.Contains(got, `"message":"say nothing"`) || !strings.Contains(got, `"session_id":"s1"`) {
+		t.Errorf("expected a warning with the request, got %s", got)
+	}
+
+	handler.runner = &fakeRunner{reply: "hi"}
+	sendChat(t, conn, &api.ChatRequest{Message: "hello"})
+	if strings.Count(logs.String(), "empty response") != 1 {
+		t.Errorf("expected no warning for an answer, got %s", logs.String())
+	}
+}
+
+func TestHandler_HandleChat_RateLimited(t *testing.T) {
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = &fakeRunner{reply: "hi"}
+	handler.SetRateLimit(1, 1)
+
+	conn := startChatServer(t, handler)
+
+	first := sendChat(t, conn, &api.ChatRequest{Message: "one"})
+	done := first[len(first)-1]
+	if _, ok := done.Payload.(*api.ChatResponse_Done); !ok {
+		t.Fatalf("expected first request to succeed, got %v", done)
+	}
+	if done.RateLimitRemaining != 0 {
+		t.Errorf("expected 0 remaining, got %d", done.RateLimitRemaining)
+	}
+
+	second := sendChat(t, conn, &api.ChatRequest{Message: "two"})
+	if code := second[len(second)-1].GetError().GetCode(); code != api.ErrorCode_ERROR_RATE_LIMITED {
+		t.Errorf("expected second request to be rate limited, got %v", second[len(second)-1])
+	}
+}
+
+func TestHandler_HandleChat_RateLimitedPerSession(t *testing.T) {
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = &fakeRunner{reply: "hi"}
+	handler.SetRateLimit(1, 1)
+
+	isLimited := func(responses []*api.ChatResponse) bool {
+		return responses[len(responses)-1].GetError().GetCode() == api.ErrorCode_ERROR_RATE_LIMITED
+	}
+
+	// Reconnecting doesn't give a session a fresh budget
+	first := startChatServer(t, handler)
+	if isLimited(sendChat(t, first, &api.ChatRequest{Message: "one", SessionId: "s1"})) {
+		t.Fatal("expected the first request to succeed")
+	}
+	second := startChatServer(t, handler)
+	if !isLimited(sendChat(t, second, &api.ChatRequest{Message: "two", SessionId: "s1"})) {
+		t.Error("expected the session to stay limited on a new connection")
+	}
+
+	// Nor does a new session ID on the same connection
+	if !isLimited(sendChat(t, first, &api.ChatRequest{Message: "three", SessionId: "s2"})) {
+		t.Error("expected the connection to stay limited with a new session ID")
+	}
+}
+
+func TestHandler_HandleChat_RejectsOversizedRequest(t *testing.T) {
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = &fakeRunner{reply: "hi"}
+	handler.SetMaxMessageSize(1024)
+
+	conn := startChatServer(t, handler)
+	responses := sendChat(t, conn, &api.ChatRequest{Message: "small enough"})
+	if _, ok := responses[len(responses)-1].Payload.(*api.ChatResponse_Done); !ok {
+		t.Fatalf("expected a request under the limit to succeed, got %v", responses[len(responses)-1])
+	}
+
+	data, _ := proto.Marshal(&api.ChatRequest{Message: strings.Repeat("x", 4096)})
+	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Fatalf("expected the connection to be closed as message too big, got %v", err)
+	}
+	if got := handler.ChatsServed(); got != 1 {
+		t.Errorf("expected the oversized request not to be answered, got %d chats served", got)
+	}
+}
+
+// blockingRunner waits until its context is canceled, recording that it was
+type blockingRunner struct {
+	started  chan struct{}
+	canceled chan struct{}
+}
+
+func (r *blockingRunner) Run(ctx context.Context, _ string, _ agent.RunOptions, eventChan chan<- agent.Event) ([]agent.Message, error) {
+	defer close(eventChan)
+	close(r.started)
+	<-ctx.Done()
+	close(r.canceled)
+	return nil, ctx.Err()
+}
+
+func TestHandler_HandleChat_CancelsOnDisconnect(t *testing.T) {
+	runner := &blockingRunner{started: make(chan struct{}), canceled: make(chan struct{})}
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = runner
+
+	conn := startChatServer(t, handler)
+	data, _ := proto.Marshal(&api.ChatRequest{Message: "hello"})
+	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+
+	<-runner.started
+	_ = conn.Close()
+
+	select {
+	case <-runner.canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("runner context was not canceled after client disconnected")
+	}
+}
+
+// floodingRunner streams large chunks until its context is canceled
+type floodingRunner struct {
+	stopped chan struct{}
+}
+
+func (r *floodingRunner) Run(ctx context.Context, _ string, _ agent.RunOptions, eventChan chan<- agent.Event) ([]agent.Message, error) {
+	defer close(r.stopped)
+	defer close(eventChan)
+	chunk := strings.Repeat("x", 64*1024)
+	for ctx.Err() == nil {
+		eventChan <- agent.Event{Type: agent.EventText, Text: chunk, Role: agent.RoleAssistant}
+	}
+	return nil, ctx.Err()
+}
+
+func TestHandler_HandleChat_AbortsForSlowClient(t *testing.T) {
+	runner := &floodingRunner{stopped: make(chan struct{})}
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = runner
+	handler.writeTimeout = 100 * time.Millisecond
+
+	handled := make(chan struct{})
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		handler.HandleChat(conn)
+		close(handled)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	// Never read, so the socket buffers fill up and writes stall
+	data, _ := proto.Marshal(&api.ChatRequest{Message: "hello"})
+	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+
+	select {
+	case <-runner.stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runner kept producing for a client that stopped reading")
+	}
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler did not return after the client stopped reading")
+	}
+	if active := handler.ActiveConnections(); active != 0 {
+		t.Errorf("expected no active connections, got %d", active)
+	}
+}
+
+// gatedRunner replies once release is closed, or gives up when its context is canceled
+type gatedRunner struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *gatedRunner) Run(ctx context.Context, userMessage string, opts agent.RunOptions, eventChan chan<- agent.Event) ([]agent.Message, error) {
+	defer close(eventChan)
+	close(r.started)
+	select {
+	case <-r.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	eventChan <- agent.Event{Type: agent.EventText, Text: "finished", Role: agent.RoleAssistant}
+	return []agent.Message{{Role: "user", Content: userMessage}, {Role: "assistant", Content: "finished"}}, nil
+}
+
+func TestHandler_Drain_FinishesActiveChat(t *testing.T) {
+	runner := &gatedRunner{started: make(chan struct{}), release: make(chan struct{})}
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = runner
+
+	conn := startChatServer(t, handler)
+	responses := make(chan []*api.ChatResponse, 1)
+	go func() { responses <- sendChat(t, conn, &api.ChatRequest{Message: "hello"}) }()
+	<-runner.started
+
+	drained := make(chan error, 1)
+	go func() { drained <- handler.Drain(context.Background()) }()
+
+	select {
+	case <-drained:
+		t.Fatal("drain returned while a chat was still generating")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(runner.release)
+	got := <-responses
+	if _, ok := got[len(got)-1].Payload.(*api.ChatResponse_Done); !ok {
+		t.Fatalf("expected the active chat to complete, got %v", got[len(got)-1])
+	}
+
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Errorf("unexpected drain error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("drain did not return after the chat finished")
+	}
+
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("expected going away close, got %v", err)
+	}
+}
+
+func TestHandler_Drain_AbortsAfterTimeout(t *testing.T) {
+	runner := &blockingRunner{started: make(chan struct{}), canceled: make(chan struct{})}
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = runner
+
+	conn := startChatServer(t, handler)
+	data, _ := proto.Marshal(&api.ChatRequest{Message: "hello"})
+	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	<-runner.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := handler.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+
+	select {
+	case <-runner.canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("active chat was not aborted after the drain timed out")
+	}
+}
+
+func TestHandler_HandleChat_IdleTimeout(t *testing.T) {
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = &fakeRunner{reply: "hi"}
+	handler.SetKeepalive(0, 100*time.Millisecond)
+
+	conn := startChatServer(t, handler)
+	sendChat(t, conn, &api.ChatRequest{Message: "hello"})
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway || closeErr.Text != "idle timeout" {
+		t.Errorf("expected idle timeout close, got %v", err)
+	}
+}
+
+func TestHandler_HandleChat_ClosesUnresponsiveClient(t *testing.T) {
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = &fakeRunner{reply: "hi"}
+	handler.SetKeepalive(50*time.Millisecond, 0)
+
+	// The client never reads, so pings are not answered
+	startChatServer(t, handler)
+	deadline := time.Now().Add(2 * time.Second)
+	for handler.ActiveConnections() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for handler.ActiveConnections() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := handler.ActiveConnections(); got != 0 {
+		t.Errorf("expected unresponsive connection to be closed, %d still active", got)
+	}
+}
+
+func TestHandler_HandleChat_PingsKeepClientAlive(t *testing.T) {
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = &fakeRunner{reply: "hi"}
+	handler.SetKeepalive(20*time.Millisecond, 0)
+
+	conn := startChatServer(t, handler)
+	// Reading answers pings automatically
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	if got := handler.ActiveConnections(); got != 1 {
+		t.Errorf("expected responsive connection to stay open, got %d active", got)
+	}
+}
+
+func TestHandler_SetTools_AppliesToNewChats(t *testing.T) {
+	handler := NewPipelineHandler(nil, "old prompt", testLogger())
+	handler.runner = &fakeRunner{reply: "old"}
+
+	conn := startChatServer(t, handler)
+	sendChat(t, conn, &api.ChatRequest{Message: "one"})
+
+	handler.SetTools(&fakeRunner{reply: "new"}, "new prompt")
+
+	responses := sendChat(t, conn, &api.ChatRequest{Message: "two"})
+	text, ok := responses[0].Payload.(*api.ChatResponse_Text)
+	if !ok || text.Text.Content != "new" {
+		t.Errorf("expected reply from the new runner, got %v", responses[0])
+	}
+	if got := handler.FullContext(); got != "new prompt" {
+		t.Errorf("expected new system prompt, got %q", got)
+	}
+}
+
+func TestHandler_HandleChat_ContextTurns(t *testing.T) {
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = &fakeRunner{reply: "ok"}
+	handler.SetContextTurns(2)
+	conn := startChatServer(t, handler)
+
+	for _, msg := range []string{"first", "second", "third"} {
+		sendChat(t, conn, &api.ChatRequest{Message: msg})
+	}
+	history := handler.History()
+	if len(history) != 4 || history[0].Content != "second" {
+		t.Errorf("expected the last two turns kept, got %v", history)
+	}
+
+	handler.SetContextTurns(0)
+	sendChat(t, conn, &api.ChatRequest{Message: "fourth"})
+	if got := handler.History(); len(got) != 0 {
+		t.Errorf("expected no turns kept with 0 context turns, got %d messages", len(got))
+	}
+}
+
+func TestHandler_HandleChat_PromptWrap(t *testing.T) {
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = &fakeRunner{reply: "ok"}
+	handler.SetContextTurns(1)
+	conn := startChatServer(t, handler)
+
+	sendChat(t, conn, &api.ChatRequest{Message: "hi"})
+	if got := handler.History()[0].Content; got != "hi" {
+		t.Errorf("expected the message unchanged by default, got %q", got)
+	}
+
+	handler.SetPromptWrap("Answer concisely.", "")
+	sendChat(t, conn, &api.ChatRequest{Message: "hi"})
+	if got := handler.History()[0].Content; got != "Answer concisely.\n\nhi" {
+		t.Errorf("expected the configured prefix, got %q", got)
+	}
+
+	sendChat(t, conn, &api.ChatRequest{Message: "hi", PromptPrefix: "Be formal.", PromptSuffix: "Use bullets."})
+	if got := handler.History()[0].Content; got != "Be formal.\n\nhi\n\nUse bullets." {
+		t.Errorf("expected the chat's own prefix and suffix, got %q", got)
+	}
+}
+
+// planRunner plans a tool call before answering
+type planRunner struct{}
+
+func (planRunner) Run(_ context.Context, userMessage string, _ agent.RunOptions, eventChan chan<- agent.Event) ([]agent.Message, error) {
+	defer close(eventChan)
+	eventChan <- agent.Event{Type: agent.EventPlanGenerated, Plan: &agent.Plan{
+		Intent:     "list files",
+		Complexity: agent.ComplexitySimple,
+		NeedsTools: true,
+		Steps: []agent.PlanStep{{
+			ID:      "step_1",
+			Tool:    "shell",
+			Purpose: "list the directory",
+			Args:    []agent.PlanArg{{Name: "command", Value: " ls "}},
+		}},
+	}}
+	eventChan <- agent.Event{Type: agent.EventText, Text: "ok", Role: agent.RoleAssistant}
+	return []agent.Message{{Role: "user", Content: userMessage}, {Role: "assistant", Content: "ok"}}, nil
+}
+
+func TestHandler_HandleChat_TracePlans(t *testing.T) {
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = planRunner{}
+	conn := startChatServer(t, handler)
+
+	plans := func(responses []*api.ChatResponse) []*api.Plan {
+		var plans []*api.Plan
+		for _, resp := range responses {
+			if payload, ok := resp.Payload.(*api.ChatResponse_Plan); ok {
+				plans = append(plans, payload.Plan)
+			}
+		}
+		return plans
+	}
+
+	if got := plans(sendChat(t, conn, &api.ChatRequest{Message: "hi"})); len(got) != 0 {
+		t.Errorf("expected no plans without a trace, got %v", got)
+	}
+
+	got := plans(sendChat(t, conn, &api.ChatRequest{Message: "hi", Trace: true}))
+	if len(got) != 1 {
+		t.Fatalf("expected one plan, got %d", len(got))
+	}
+	if got[0].Intent != "list files" || got[0].Complexity != "simple" || len(got[0].Steps) != 1 {
+		t.Errorf("unexpected plan: %v", got[0])
+	}
+	step := got[0].Steps[0]
+	if step.Id != "step_1" || step.Tool != "shell" || step.Arguments != `{"command":"ls"}` {
+		t.Errorf("unexpected step: %v", step)
+	}
+}
+
+// approvalRunner asks for approval of a shell plan and runs it only when approved
+type approvalRunner struct{}
+
+func (approvalRunner) Run(ctx context.Context, userMessage string, opts agent.RunOptions, eventChan chan<- agent.Event) ([]agent.Message, error) {
+	defer close(eventChan)
+	if opts.ApprovePlan != nil {
+		steps := []agent.PlanStep{{ID: "step_1", Tool: "shell", Args: []agent.PlanArg{{Name: "command", Value: "rm -r build"}}}}
+		eventChan <- agent.Event{Type: agent.EventPlanApproval, Plan: &agent.Plan{Intent: "clean up", Steps: steps}}
+		approved, err := opts.ApprovePlan(ctx, steps)
+		if err != nil {
+			return nil, err
+		}
+		if !approved {
+			return nil, agent.ErrPlanRejected
+		}
+	}
+	eventChan <- agent.Event{Type: agent.EventText, Text: "ran", Role: agent.RoleAssistant}
+	return []agent.Message{{Role: "user", Content: userMessage}, {Role: "assistant", Content: "ran"}}, nil
+}
+
+func TestHandler_HandleChat_ApprovePlans(t *testing.T) {
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = approvalRunner{}
+	conn := startChatServer(t, handler)
+
+	// A stray decision is ignored rather than answered as a chat
+	data, _ := proto.Marshal(&api.ChatRequest{PlanDecision: &api.PlanDecision{Approved: true}})
+	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		t.Fatalf("failed to send decision: %v", err)
+	}
+
+	for _, approved := range []bool{true, false} {
+		data, _ := proto.Marshal(&api.ChatRequest{Message: "clean up", ApprovePlans: true})
+		if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		_, respData, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		var resp api.ChatResponse
+		if err := proto.Unmarshal(respData, &resp); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		approval, ok := resp.Payload.(*api.ChatResponse_PlanApproval)
+		if !ok {
+			t.Fatalf("expected a plan approval first, got %v", &resp)
+		}
+		if steps := approval.PlanApproval.Steps; len(steps) != 1 || steps[0].Arguments != `{"command":"rm -r build"}` {
+			t.Errorf("unexpected plan steps: %v", steps)
+		}
+
+		responses := sendChat(t, conn, &api.ChatRequest{PlanDecision: &api.PlanDecision{Approved: approved}})
+		last := responses[len(responses)-1]
+		if approved {
+			if _, ok := last.Payload.(*api.ChatResponse_Done); !ok {
+				t.Errorf("expected an approved plan to run, got %v", last)
+			}
+			continue
+		}
+		chatErr, ok := last.Payload.(*api.ChatResponse_Error)
+		if !ok || chatErr.Error.Code != api.ErrorCode_ERROR_CANCELED {
+			t.Errorf("expected a rejected plan to cancel the chat, got %v", last)
+		}
+	}
+}
+
+func TestHandler_HandleChat_ApprovalWait(t *testing.T) {
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = approvalRunner{}
+	handler.SetApprovalTimeout(200 * time.Millisecond)
+	conn := startChatServer(t, handler)
+
+	send := func(req *api.ChatRequest) {
+		data, _ := proto.Marshal(req)
+		if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+	}
+	read := func() *api.ChatResponse {
+		_, respData, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		var resp api.ChatResponse
+		if err := proto.Unmarshal(respData, &resp); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		return &resp
+	}
+
+	// Another message meanwhile is answered with a notice
+	send(&api.ChatRequest{Message: "clean up", ApprovePlans: true})
+	if resp := read(); resp.GetPlanApproval() == nil {
+		t.Fatalf("expected a plan approval first, got %v", resp)
+	}
+	send(&api.ChatRequest{Message: "hello?"})
+	if text := read().GetText(); text == nil || text.Role != api.Role_SYSTEM || !strings.Contains(text.Content, "waiting for approval") {
+		t.Errorf("expected a notice about the ignored message, got %v", text)
+	}
+
+	// Without a decision the chat gives up its turn
+	resp := read()
+	if chatErr := resp.GetError(); chatErr == nil || chatErr.Code != api.ErrorCode_ERROR_CANCELED || !strings.Contains(chatErr.Message, "no plan decision") {
+		t.Errorf("expected the chat canceled for want of a decision, got %v", resp)
+	}
+}
+
+// writeRunner writes a file with the write tool and answers with the outcome
+type writeRunner struct {
+	tool *tools.WriteTool
+	path string
+}
+
+func (r writeRunner) Run(ctx context.Context, userMessage string, _ agent.RunOptions, eventChan chan<- agent.Event) ([]agent.Message, error) {
+	defer close(eventChan)
+	reply, err := r.tool.ExecuteContext(ctx, map[string]any{"path": r.path, "content": "hi"})
+	if err != nil {
+		reply = err.Error()
+	}
+	eventChan <- agent.Event{Type: agent.EventText, Text: reply, Role: agent.RoleAssistant}
+	return []agent.Message{{Role: "user", Content: userMessage}, {Role: "assistant", Content: reply}}, nil
+}
+
+func TestHandler_HandleChat_ConfirmWrites(t *testing.T) {
+	dir := t.TempDir()
+	settings := config.DefaultSettings()
+	settings.Tools.Write.AllowedPaths = []string{dir}
+	tool := tools.NewWriteTool(settings)
+	tool.SetConfirmer(func(path, description string) bool { return false })
+	path := filepath.Join(dir, "note.txt")
+
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = writeRunner{tool: tool, path: path}
+	conn := startChatServer(t, handler)
+
+	// A client that doesn't approve plans can't be asked, so the tool's own confirmer refuses
+	responses := sendChat(t, conn, &api.ChatRequest{Message: "write"})
+	if text, ok := responses[0].Payload.(*api.ChatResponse_Text); !ok || !strings.Contains(text.Text.Content, "not approved") {
+		t.Errorf("expected the write refused, got %v", responses[0])
+	}
+
+	// Otherwise the client approves the write as a one-step plan
+	data, _ := proto.Marshal(&api.ChatRequest{Message: "write", ApprovePlans: true})
+	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	_, respData, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	var resp api.ChatResponse
+	if err := proto.Unmarshal(respData, &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	approval, ok := resp.Payload.(*api.ChatResponse_PlanApproval)
+	if !ok {
+		t.Fatalf("expected a plan approval first, got %v", &resp)
+	}
+	if steps := approval.PlanApproval.Steps; len(steps) != 1 || steps[0].Tool != "write" || !strings.Contains(steps[0].Arguments, "note.txt") {
+		t.Errorf("unexpected plan steps: %v", steps)
+	}
+	sendChat(t, conn, &api.ChatRequest{PlanDecision: &api.PlanDecision{Approved: true}})
+	if data, err := os.ReadFile(path); err != nil || string(data) != "hi" {
+		t.Errorf("expected the approved write to happen, got %q, %v", data, err)
+	}
+}
+
+// identityRunner records the identity and options each run was asked to use
+type identityRunner struct {
+	identities []string
+	options    []agent.RunOptions
+}
+
+func (r *identityRunner) Run(_ context.Context, userMessage string, opts agent.RunOptions, eventChan chan<- agent.Event) ([]agent.Message, error) {
+	defer close(eventChan)
+	r.identities = append(r.identities, opts.Identity)
+	r.options = append(r.options, opts)
+	eventChan <- agent.Event{Type: agent.EventText, Text: "ok", Role: agent.RoleAssistant}
+	return []agent.Message{{Role: "user", Content: userMessage}, {Role: "assistant", Content: "ok"}}, nil
+}
+
+func TestHandler_HandleChat_Persona(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".craby", "templates")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "writer.md"), []byte("You are an editor."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &identityRunner{}
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = runner
+	conn := startChatServer(t, handler)
+
+	sendChat(t, conn, &api.ChatRequest{Message: "hi"})
+	sendChat(t, conn, &api.ChatRequest{Message: "hi", Persona: "writer"})
+	sendChat(t, conn, &api.ChatRequest{Message: "hi", Persona: "writer", SystemPrompt: "You are terse."})
+
+	want := []string{"", "You are an editor.", "You are terse."}
+	if !slices.Equal(runner.identities, want) {
+		t.Errorf("expected identities %q, got %q", want, runner.identities)
+	}
+
+	responses := sendChat(t, conn, &api.ChatRequest{Message: "hi", Persona: "poet"})
+	last := responses[len(responses)-1].GetError()
+	if last.GetCode() != api.ErrorCode_ERROR_INVALID_REQUEST || !strings.Contains(last.GetMessage(), `unknown persona "poet"`) {
+		t.Errorf("expected invalid request error for the unknown persona, got %v", last)
+	}
+	if len(runner.identities) != 3 {
+		t.Error("expected the chat with an unknown persona not to run")
+	}
+}
+
+func TestHandler_HandleChat_ToolSelection(t *testing.T) {
+	runner := &identityRunner{}
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = runner
+	conn := startChatServer(t, handler)
+
+	sendChat(t, conn, &api.ChatRequest{Message: "hi", NoTools: true})
+	sendChat(t, conn, &api.ChatRequest{Message: "hi", DisabledTools: []string{"shell"}})
+	sendChat(t, conn, &api.ChatRequest{Message: "hi"})
+
+	if !runner.options[0].NoTools {
+		t.Error("expected the first chat to run without tools")
+	}
+	if runner.options[1].NoTools || !slices.Equal(runner.options[1].DisabledTools, []string{"shell"}) {
+		t.Errorf("expected only shell disabled for the second chat, got %+v", runner.options[1])
+	}
+	if runner.options[2].NoTools || len(runner.options[2].DisabledTools) != 0 {
+		t.Error("expected the tool selection not to carry over to the next chat")
+	}
+}
+
+// cancelableRunner blocks its first run until canceled, then answers normally
+type cancelableRunner struct {
+	started chan struct{}
+	runs    atomic.Int32
+}
+
+func (r *cancelableRunner) Run(ctx context.Context, userMessage string, opts agent.RunOptions, eventChan chan<- agent.Event) ([]agent.Message, error) {
+	defer close(eventChan)
+	if r.runs.Add(1) == 1 {
+		close(r.started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	eventChan <- agent.Event{Type: agent.EventText, Text: "ok", Role: agent.RoleAssistant}
+	return append(append([]agent.Message{}, opts.History...),
+		agent.Message{Role: "user", Content: userMessage},
+		agent.Message{Role: "assistant", Content: "ok"},
+	), nil
+}
+
+func TestHandler_CancelGeneration(t *testing.T) {
+	runner := &cancelableRunner{started: make(chan struct{})}
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = runner
+	conn := startChatServer(t, handler)
+
+	if handler.CancelGeneration("session-1") {
+		t.Error("expected nothing to cancel before a chat started")
+	}
+
+	result := make(chan []*api.ChatResponse, 1)
+	go func() {
+		result <- sendChat(t, conn, &api.ChatRequest{Message: "long task", SessionId: "session-1"})
+	}()
+
+	select {
+	case <-runner.started:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for chat to start")
+	}
+	if !handler.CancelGeneration("session-1") {
+		t.Fatal("expected the running chat to be canceled")
+	}
+
+	responses := <-result
+	if code := responses[len(responses)-1].GetError().GetCode(); code != api.ErrorCode_ERROR_CANCELED {
+		t.Fatalf("expected a canceled error, got %v", responses[len(responses)-1])
+	}
+
+	// The connection stays usable and the canceled exchange isn't kept in history
+	responses = sendChat(t, conn, &api.ChatRequest{Message: "hello", SessionId: "session-1"})
+	if _, ok := responses[len(responses)-1].Payload.(*api.ChatResponse_Done); !ok {
+		t.Fatalf("expected the next chat to succeed, got %v", responses[len(responses)-1])
+	}
+	if history := handler.History(); len(history) != 2 || history[0].Content != "hello" {
+		t.Errorf("expected only the second exchange in history, got %v", history)
+	}
+	if handler.CancelGeneration("session-1") {
+		t.Error("expected nothing to cancel after the chat finished")
+	}
+}
@@ -0,0 +1,153 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/marciniwanicki/craby/internal/agent"
+	"github.com/marciniwanicki/craby/internal/config"
+	"github.com/rs/zerolog"
+)
+
+// charsPerToken is the rough ratio used to estimate token counts from text length
+const charsPerToken = 4
+
+// summaryPrefix marks the system note that replaces summarized turns
+const summaryPrefix = "Summary of the earlier conversation:\n"
+
+const summarizePrompt = `You condense chat transcripts. Summarize the conversation below in a few short paragraphs.
+Keep facts, names, decisions, file paths and commands that later messages may rely on. Do not add commentary.`
+
+// Summarizer produces a completion for a single prompt without tools
+type Summarizer interface {
+	SimpleChat(ctx context.Context, systemPrompt, userMessage string) (string, error)
+}
+
+// historyTrimmer keeps the conversation history within an approximate token budget
+type historyTrimmer struct {
+	maxTokens  int
+	strategy   string
+	summarizer Summarizer
+	logger     zerolog.Logger
+}
+
+// estimateTokens approximates the token count of text
+func estimateTokens(text string) int {
+	return (len(text) + charsPerToken - 1) / charsPerToken
+}
+
+// estimateHistoryTokens approximates the token count of a message list
+func estimateHistoryTokens(messages []agent.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += estimateTokens(msg.Content)
+		for _, tc := range msg.ToolCalls {
+			total += estimateTokens(tc.Function.Name) + estimateTokens(fmt.Sprint(tc.Function.Arguments))
+		}
+	}
+	return total
+}
+
+// Trim returns history reduced to fit the budget alongside the system prompt.
+// The system prompt is never part of the history, so it is always retained.
+func (t *historyTrimmer) Trim(ctx context.Context, systemPrompt string, history []agent.Message) []agent.Message {
+	if t == nil || t.maxTokens <= 0 {
+		return history
+	}
+
+	budget := t.maxTokens - estimateTokens(systemPrompt)
+	if estimateHistoryTokens(history) <= budget {
+		return history
+	}
+
+	// Trim down to three quarters of the budget so we don't trim again on the very next turn
+	target := budget * 3 / 4
+	cut := trimPoint(history, target)
+	if cut == 0 {
+		return history
+	}
+
+	if t.strategy == config.HistoryStrategySummarize && t.summarizer != nil {
+		summary, err := t.summarize(ctx, history[:cut])
+		if err == nil {
+			t.logger.Info().Int("summarized", cut).Int("kept", len(history)-cut).Msg("summarized conversation history")
+			trimmed := make([]agent.Message, 0, len(history)-cut+1)
+			trimmed = append(trimmed, agent.Message{Role: "system", Content: summaryPrefix + summary})
+			return append(trimmed, history[cut:]...)
+		}
+		t.logger.Warn().Err(err).Msg("failed to summarize history, dropping oldest turns instead")
+	}
+
+	t.logger.Info().Int("dropped", cut).Int("kept", len(history)-cut).Msg("dropped oldest conversation turns")
+	return history[cut:]
+}
+
+// lastTurns returns the most recent turns of history, each starting at a user message.
+// A negative count keeps everything and 0 keeps nothing.
+func lastTurns(history []agent.Message, turns int) []agent.Message {
+	if turns < 0 {
+		return history
+	}
+	if turns == 0 {
+		return nil
+	}
+
+	seen := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role != "user" {
+			continue
+		}
+		seen++
+		if seen == turns {
+			return history[i:]
+		}
+	}
+	return history
+}
+
+// trimPoint returns the index of the first message to keep so that the kept messages fit target.
+// Cuts only happen at user messages so a turn is never split from its tool calls and answer.
+// The most recent turn is always kept.
+func trimPoint(history []agent.Message, target int) int {
+	lastUser := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "user" {
+			lastUser = i
+			break
+		}
+	}
+
+	for i := 1; i <= lastUser; i++ {
+		if history[i].Role != "user" {
+			continue
+		}
+		if estimateHistoryTokens(history[i:]) <= target {
+			return i
+		}
+	}
+	return lastUser
+}
+
+// summarize asks the LLM to condense messages into a short note
+func (t *historyTrimmer) summarize(ctx context.Context, messages []agent.Message) (string, error) {
+	var sb strings.Builder
+	for _, msg := range messages {
+		content := strings.TrimPrefix(msg.Content, summaryPrefix)
+		if content == "" {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n\n", msg.Role, content))
+	}
+
+	summary, err := t.summarizer.SimpleChat(ctx, summarizePrompt, sb.String())
+	if err != nil {
+		return "", err
+	}
+
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return summary, nil
+}
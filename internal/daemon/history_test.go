@@ -0,0 +1,143 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/marciniwanicki/craby/internal/agent"
+	"github.com/marciniwanicki/craby/internal/config"
+)
+
+// fakeSummarizer returns a fixed summary and records what it was asked to summarize
+type fakeSummarizer struct {
+	summary string
+	err     error
+	input   string
+}
+
+func (s *fakeSummarizer) SimpleChat(_ context.Context, _, userMessage string) (string, error) {
+	s.input = userMessage
+	return s.summary, s.err
+}
+
+// testTurns builds n user/assistant turns, each roughly 50 tokens
+func testTurns(n int) []agent.Message {
+	var history []agent.Message
+	for i := 0; i < n; i++ {
+		history = append(history,
+			agent.Message{Role: "user", Content: "question " + strings.Repeat("q", 96)},
+			agent.Message{Role: "assistant", Content: "answer " + strings.Repeat("a", 96)},
+		)
+	}
+	return history
+}
+
+func TestEstimateTokens(t *testing.T) {
+	if got := estimateTokens(""); got != 0 {
+		t.Errorf("expected 0, got %d", got)
+	}
+	if got := estimateTokens("abcdefgh"); got != 2 {
+		t.Errorf("expected 2, got %d", got)
+	}
+	if got := estimateTokens("abcde"); got != 2 {
+		t.Errorf("expected 2 (rounded up), got %d", got)
+	}
+}
+
+func TestHistoryTrimmer_UnderBudget(t *testing.T) {
+	trimmer := &historyTrimmer{maxTokens: 10000, strategy: config.HistoryStrategyDrop, logger: testLogger()}
+	history := testTurns(3)
+
+	got := trimmer.Trim(context.Background(), "system", history)
+	if len(got) != len(history) {
+		t.Errorf("expected history unchanged, got %d messages", len(got))
+	}
+}
+
+func TestHistoryTrimmer_Drop(t *testing.T) {
+	trimmer := &historyTrimmer{maxTokens: 300, strategy: config.HistoryStrategyDrop, logger: testLogger()}
+	history := testTurns(10)
+
+	got := trimmer.Trim(context.Background(), "system", history)
+	if len(got) >= len(history) {
+		t.Fatalf("expected history to be trimmed, got %d messages", len(got))
+	}
+	if got[0].Role != "user" {
+		t.Errorf("expected trimmed history to start at a user turn, got %q", got[0].Role)
+	}
+	if estimateHistoryTokens(got) > 300 {
+		t.Errorf("expected trimmed history within budget, got %d tokens", estimateHistoryTokens(got))
+	}
+	if got[len(got)-1].Content != history[len(history)-1].Content {
+		t.Error("expected most recent message to be kept")
+	}
+}
+
+func TestHistoryTrimmer_KeepsLatestTurn(t *testing.T) {
+	trimmer := &historyTrimmer{maxTokens: 10, strategy: config.HistoryStrategyDrop, logger: testLogger()}
+	history := testTurns(3)
+
+	got := trimmer.Trim(context.Background(), "system", history)
+	if len(got) != 2 || got[0].Content != history[4].Content {
+		t.Errorf("expected only the latest turn to remain, got %v", got)
+	}
+}
+
+func TestHistoryTrimmer_Summarize(t *testing.T) {
+	summarizer := &fakeSummarizer{summary: "they talked about q and a"}
+	trimmer := &historyTrimmer{maxTokens: 300, strategy: config.HistoryStrategySummarize, summarizer: summarizer, logger: testLogger()}
+	history := testTurns(10)
+
+	got := trimmer.Trim(context.Background(), "system", history)
+	if got[0].Role != "system" || got[0].Content != summaryPrefix+"they talked about q and a" {
+		t.Fatalf("expected summary note first, got %+v", got[0])
+	}
+	if got[1].Role != "user" {
+		t.Errorf("expected summary to be followed by a user turn, got %q", got[1].Role)
+	}
+	if !strings.Contains(summarizer.input, "user: question") {
+		t.Errorf("expected summarizer to receive the transcript, got %q", summarizer.input)
+	}
+}
+
+func TestHistoryTrimmer_SummarizeFallsBackToDrop(t *testing.T) {
+	summarizer := &fakeSummarizer{err: errors.New("ollama down")}
+	trimmer := &historyTrimmer{maxTokens: 300, strategy: config.HistoryStrategySummarize, summarizer: summarizer, logger: testLogger()}
+	history := testTurns(10)
+
+	got := trimmer.Trim(context.Background(), "system", history)
+	if len(got) >= len(history) {
+		t.Fatalf("expected history to be trimmed, got %d messages", len(got))
+	}
+	if got[0].Role != "user" {
+		t.Errorf("expected dropped history to start at a user turn, got %q", got[0].Role)
+	}
+}
+
+func TestHistoryTrimmer_Nil(t *testing.T) {
+	var trimmer *historyTrimmer
+	history := testTurns(2)
+	if got := trimmer.Trim(context.Background(), "system", history); len(got) != len(history) {
+		t.Errorf("expected nil trimmer to keep history, got %d messages", len(got))
+	}
+}
+
+func TestLastTurns(t *testing.T) {
+	history := testTurns(3)
+
+	if got := lastTurns(history, -1); len(got) != 6 {
+		t.Errorf("expected every turn kept, got %d messages", len(got))
+	}
+	if got := lastTurns(history, 0); len(got) != 0 {
+		t.Errorf("expected no turns kept, got %d messages", len(got))
+	}
+	got := lastTurns(history, 2)
+	if len(got) != 4 || got[0].Role != "user" || got[0].Content != history[2].Content {
+		t.Errorf("expected the last two turns, got %v", got)
+	}
+	if got := lastTurns(history, 5); len(got) != 6 {
+		t.Errorf("expected every turn when asking for more than exist, got %d messages", len(got))
+	}
+}
@@ -5,8 +5,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/marciniwanicki/craby/internal/agent"
@@ -16,26 +23,107 @@ import (
 // OllamaClient handles communication with the Ollama API
 type OllamaClient struct {
 	baseURL       string
+	modelMu       sync.RWMutex // Guards model and fallbacks, which can be switched at runtime
 	model         string
-	httpClient    *http.Client
+	fallbacks     []string // Tried in order when the primary model can't be loaded
+	embedModel    string
+	keepAlive     any               // Sent as keep_alive on every request (nil = Ollama default)
+	httpClient    *http.Client      // Shared transport, no overall timeout so streams can run as long as needed
+	genTimeout    time.Duration     // Caps a chat request including its whole stream (0 = unbounded)
+	healthClient  *http.Client      // Same transport with a short total timeout
+	headers       map[string]string // Extra headers sent with every request, e.g. Authorization
 	llmCallLogger *config.StepLogger
 }
 
+const (
+	// ollamaDialTimeout bounds establishing a connection to Ollama
+	ollamaDialTimeout = 10 * time.Second
+	// ollamaResponseHeaderTimeout bounds waiting for Ollama to start responding.
+	// Generous because Ollama loads the model before sending headers.
+	ollamaResponseHeaderTimeout = 5 * time.Minute
+	// ollamaHealthTimeout bounds a health check end to end
+	ollamaHealthTimeout = 5 * time.Second
+)
+
+// newOllamaTransport creates a pooled transport shared by all Ollama requests
+func newOllamaTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   ollamaDialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          32,
+		MaxIdleConnsPerHost:   16,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: ollamaResponseHeaderTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
 // OllamaRequest represents a chat request to Ollama
 type OllamaRequest struct {
-	Model    string          `json:"model"`
-	Messages []OllamaMessage `json:"messages"`
-	Tools    []any           `json:"tools,omitempty"`
-	Stream   bool            `json:"stream"`
+	Model     string          `json:"model"`
+	Messages  []OllamaMessage `json:"messages"`
+	Tools     []any           `json:"tools,omitempty"`
+	Stream    bool            `json:"stream"`
+	KeepAlive any             `json:"keep_alive,omitempty"` // Duration string or seconds (negative = forever, 0 = unload)
+	Format    json.RawMessage `json:"format,omitempty"`     // "json" or a JSON schema
+}
+
+// OllamaGenerateRequest represents a generate request to Ollama, used to load the model
+type OllamaGenerateRequest struct {
+	Model     string `json:"model"`
+	Stream    bool   `json:"stream"`
+	KeepAlive any    `json:"keep_alive,omitempty"`
 }
 
 // OllamaMessage represents a message in the Ollama chat format
 type OllamaMessage struct {
 	Role      string           `json:"role"`
 	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"` // Reasoning from models that report it separately
 	ToolCalls []OllamaToolCall `json:"tool_calls,omitempty"`
 }
 
+// thinkingTagger re-wraps Ollama's separate thinking field in <think> tags so downstream
+// consumers handle it the same way as models that emit the tags inline
+type thinkingTagger struct {
+	open bool
+}
+
+// token returns the text to stream for a message chunk
+func (t *thinkingTagger) token(msg OllamaMessage) string {
+	var sb strings.Builder
+	if msg.Thinking != "" {
+		if !t.open {
+			sb.WriteString("<think>")
+			t.open = true
+		}
+		sb.WriteString(msg.Thinking)
+	}
+	if msg.Content != "" {
+		if t.open {
+			sb.WriteString("</think>")
+			t.open = false
+		}
+		sb.WriteString(msg.Content)
+	}
+	return sb.String()
+}
+
+// close returns the closing tag if a thinking block is still open
+func (t *thinkingTagger) close() string {
+	if !t.open {
+		return ""
+	}
+	t.open = false
+	return "</think>"
+}
+
 // OllamaToolCall represents a tool call from the model
 type OllamaToolCall struct {
 	ID       string             `json:"id,omitempty"`
@@ -56,54 +144,77 @@ type OllamaResponse struct {
 	Done      bool          `json:"done"`
 	Error     string        `json:"error,omitempty"`
 	CreatedAt string        `json:"created_at"`
+
+	// Set on the final response
+	PromptEvalCount int   `json:"prompt_eval_count,omitempty"`
+	EvalCount       int   `json:"eval_count,omitempty"`
+	EvalDuration    int64 `json:"eval_duration,omitempty"` // Nanoseconds spent generating the EvalCount tokens
+}
+
+// OllamaRunningModel represents a model loaded in memory, as reported by /api/ps
+type OllamaRunningModel struct {
+	Name      string    `json:"name"`
+	Model     string    `json:"model"`
+	Size      int64     `json:"size"`
+	SizeVRAM  int64     `json:"size_vram"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// OllamaEmbeddingsRequest represents an embeddings request to Ollama
+type OllamaEmbeddingsRequest struct {
+	Model     string `json:"model"`
+	Prompt    string `json:"prompt"`
+	KeepAlive any    `json:"keep_alive,omitempty"`
+}
+
+// OllamaEmbeddingsResponse represents an embeddings response from Ollama
+type OllamaEmbeddingsResponse struct {
+	Embedding []float32 `json:"embedding"`
+	Error     string    `json:"error,omitempty"`
 }
 
 // NewOllamaClient creates a new Ollama client
 func NewOllamaClient(baseURL, model string, llmCallLogger *config.StepLogger) *OllamaClient {
+	transport := newOllamaTransport()
 	return &OllamaClient{
-		baseURL:       baseURL,
+		baseURL:       NormalizeOllamaURL(baseURL),
 		model:         model,
-		httpClient:    &http.Client{},
+		httpClient:    &http.Client{Transport: transport},
+		healthClient:  &http.Client{Transport: transport, Timeout: ollamaHealthTimeout},
 		llmCallLogger: llmCallLogger,
 	}
 }
 
 // Chat sends a message to Ollama and streams the response
-func (c *OllamaClient) Chat(ctx context.Context, message string, tokenChan chan<- string) error {
+func (c *OllamaClient) Chat(ctx context.Context, message string, tokenChan chan<- string) (err error) {
 	startTime := time.Now()
 	defer close(tokenChan)
+	ctx, cancel := c.withGenerationTimeout(ctx)
+	defer cancel()
+	defer func() { err = generationError(ctx, err) }()
 
 	req := OllamaRequest{
-		Model: c.model,
+		Model: c.Model(),
 		Messages: []OllamaMessage{
 			{Role: "user", Content: message},
 		},
-		Stream: true,
-	}
-
-	body, err := json.Marshal(req)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
+		Stream:    true,
+		KeepAlive: c.keepAlive,
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/chat", bytes.NewReader(body))
+	resp, err := c.openChat(ctx, req)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(httpReq)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("ollama returned status %d", resp.StatusCode)
-	}
-
 	var contentBuilder bytes.Buffer
-	scanner := bufio.NewScanner(resp.Body)
+	// Closing the body on cancellation unblocks a scanner stuck waiting for the next token
+	// and drops the connection, which makes Ollama stop generating
+	stopClose := context.AfterFunc(ctx, func() { _ = resp.Body.Close() })
+	defer stopClose()
+
+	scanner := newLineScanner(resp.Body)
 	for scanner.Scan() {
 		select {
 		case <-ctx.Done():
@@ -127,14 +238,20 @@ func (c *OllamaClient) Chat(ctx context.Context, message string, tokenChan chan<
 
 		if ollamaResp.Message.Content != "" {
 			contentBuilder.WriteString(ollamaResp.Message.Content)
-			tokenChan <- ollamaResp.Message.Content
+			if err := sendToken(ctx, tokenChan, ollamaResp.Message.Content); err != nil {
+				return err
+			}
 		}
 
 		if ollamaResp.Done {
+			agent.RecordUsage(ctx, ollamaResp.PromptEvalCount, ollamaResp.EvalCount, time.Duration(ollamaResp.EvalDuration))
 			break
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if err := scanner.Err(); err != nil {
 		return fmt.Errorf("error reading response: %w", err)
 	}
@@ -150,8 +267,11 @@ func (c *OllamaClient) Chat(ctx context.Context, message string, tokenChan chan<
 
 // ChatWithTools sends messages with tools to Ollama and streams the response
 // Implements agent.LLMClient interface
-func (c *OllamaClient) ChatWithTools(ctx context.Context, messages []agent.Message, tools []any, tokenChan chan<- string) (*agent.ChatResult, error) {
+func (c *OllamaClient) ChatWithTools(ctx context.Context, messages []agent.Message, tools []any, tokenChan chan<- string) (_ *agent.ChatResult, err error) {
 	startTime := time.Now()
+	ctx, cancel := c.withGenerationTimeout(ctx)
+	defer cancel()
+	defer func() { err = generationError(ctx, err) }()
 
 	// Close the token channel when done
 	if tokenChan != nil {
@@ -179,37 +299,30 @@ func (c *OllamaClient) ChatWithTools(ctx context.Context, messages []agent.Messa
 	}
 
 	req := OllamaRequest{
-		Model:    c.model,
-		Messages: ollamaMessages,
-		Tools:    tools,
-		Stream:   true,
-	}
-
-	body, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/chat", bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		Model:     c.Model(),
+		Messages:  ollamaMessages,
+		Tools:     tools,
+		Stream:    true,
+		KeepAlive: c.keepAlive,
+		Format:    formatValue(agent.ResponseFormat(ctx)),
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.openChat(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ollama returned status %d", resp.StatusCode)
-	}
-
 	result := &agent.ChatResult{}
 	var contentBuilder bytes.Buffer
+	var tagger thinkingTagger
+
+	// Closing the body on cancellation unblocks a scanner stuck waiting for the next token
+	// and drops the connection, which makes Ollama stop generating
+	stopClose := context.AfterFunc(ctx, func() { _ = resp.Body.Close() })
+	defer stopClose()
 
-	scanner := bufio.NewScanner(resp.Body)
+	scanner := newLineScanner(resp.Body)
 	for scanner.Scan() {
 		select {
 		case <-ctx.Done():
@@ -232,11 +345,9 @@ func (c *OllamaClient) ChatWithTools(ctx context.Context, messages []agent.Messa
 		}
 
 		// Accumulate content
-		if ollamaResp.Message.Content != "" {
-			contentBuilder.WriteString(ollamaResp.Message.Content)
-			if tokenChan != nil {
-				tokenChan <- ollamaResp.Message.Content
-			}
+		contentBuilder.WriteString(ollamaResp.Message.Content)
+		if err := sendToken(ctx, tokenChan, tagger.token(ollamaResp.Message)); err != nil {
+			return nil, err
 		}
 
 		// Collect tool calls and convert to agent format
@@ -253,15 +364,23 @@ func (c *OllamaClient) ChatWithTools(ctx context.Context, messages []agent.Messa
 		}
 
 		if ollamaResp.Done {
+			agent.RecordUsage(ctx, ollamaResp.PromptEvalCount, ollamaResp.EvalCount, time.Duration(ollamaResp.EvalDuration))
 			result.Done = true
 			break
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("error reading response: %w", err)
 	}
 
+	if err := sendToken(ctx, tokenChan, tagger.close()); err != nil {
+		return nil, err
+	}
+
 	result.Content = contentBuilder.String()
 
 	// Log the LLM call
@@ -270,31 +389,500 @@ func (c *OllamaClient) ChatWithTools(ctx context.Context, messages []agent.Messa
 	return result, nil
 }
 
-// Health checks if Ollama is healthy and the model is available
+// ErrModelNotPulled is returned by Health when Ollama is reachable but the configured model isn't installed
+var ErrModelNotPulled = errors.New("model not pulled")
+
+// Health checks if Ollama is healthy and the model is available.
+// A missing model is reported as ErrModelNotPulled; any other error means Ollama couldn't be reached.
 func (c *OllamaClient) Health(ctx context.Context) (bool, error) {
-	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/tags", nil)
+	installed, err := c.installedModels(ctx)
 	if err != nil {
 		return false, err
 	}
+	if model := c.Model(); !installed[normalizeModelName(model)] {
+		return false, fmt.Errorf("%w: %s", ErrModelNotPulled, model)
+	}
+	return true, nil
+}
+
+// Model returns the configured model name
+func (c *OllamaClient) Model() string {
+	c.modelMu.RLock()
+	defer c.modelMu.RUnlock()
+	return c.model
+}
+
+// SetModel switches the primary model for subsequent requests
+func (c *OllamaClient) SetModel(model string) {
+	c.modelMu.Lock()
+	c.model = model
+	c.modelMu.Unlock()
+}
+
+// SetFallbackModels sets models to try, in order, when the primary model is unavailable
+func (c *OllamaClient) SetFallbackModels(models []string) {
+	c.modelMu.Lock()
+	defer c.modelMu.Unlock()
+	c.fallbacks = nil
+	for _, model := range models {
+		model = strings.TrimSpace(model)
+		if model != "" && model != c.model && !slices.Contains(c.fallbacks, model) {
+			c.fallbacks = append(c.fallbacks, model)
+		}
+	}
+}
+
+// Models returns the primary model followed by any fallbacks
+func (c *OllamaClient) Models() []string {
+	c.modelMu.RLock()
+	defer c.modelMu.RUnlock()
+	models := []string{c.model}
+	for _, model := range c.fallbacks {
+		// A fallback may have been switched to as the primary
+		if model != c.model {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// InstalledModel returns the name under which model is pulled into Ollama, or false if it isn't
+func (c *OllamaClient) InstalledModel(ctx context.Context, model string) (string, bool, error) {
+	tags, err := c.tags(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	for _, tag := range tags {
+		if normalizeModelName(tag.Name) == normalizeModelName(model) || normalizeModelName(tag.Model) == normalizeModelName(model) {
+			return tag.Name, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// ModelUnavailableError reports that Ollama couldn't find or load a model
+type ModelUnavailableError struct {
+	Model  string
+	Status int
+	Reason string
+}
+
+func (e *ModelUnavailableError) Error() string {
+	return fmt.Sprintf("model %s unavailable (status %d): %s", e.Model, e.Status, e.Reason)
+}
+
+// isModelUnavailable reports whether an Ollama error response means the model is missing or failed to load
+func isModelUnavailable(status int, reason string) bool {
+	if status == http.StatusNotFound {
+		return true
+	}
+	reason = strings.ToLower(reason)
+	return strings.Contains(reason, "not found") ||
+		strings.Contains(reason, "failed to load") ||
+		strings.Contains(reason, "unable to load") ||
+		strings.Contains(reason, "error loading model")
+}
+
+// openChat posts a chat request, moving on to the next fallback model when the current one is unavailable.
+// The model that accepted the request is reported via agent.RecordServedModel.
+func (c *OllamaClient) openChat(ctx context.Context, req OllamaRequest) (*http.Response, error) {
+	var lastErr error
+	for i, model := range c.Models() {
+		req.Model = model
+		resp, err := c.postChat(ctx, req)
+		if err == nil {
+			agent.RecordServedModel(ctx, model, i > 0)
+			return resp, nil
+		}
+
+		var unavailable *ModelUnavailableError
+		if !errors.As(err, &unavailable) {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// postChat sends a single chat request to /api/chat and checks the response status
+func (c *OllamaClient) postChat(ctx context.Context, req OllamaRequest) (*http.Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := c.newRequest(ctx, "POST", "/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return false, err
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		reason := readErrorReason(resp.Body)
+		if isModelUnavailable(resp.StatusCode, reason) {
+			return nil, &ModelUnavailableError{Model: req.Model, Status: resp.StatusCode, Reason: reason}
+		}
+		return nil, statusError(resp.StatusCode, reason)
+	}
+
+	return resp, nil
+}
+
+// Bounds on error responses, so a huge error page can't exhaust memory or flood the logs
+const (
+	maxErrorBodyBytes = 64 * 1024 // Read from the response
+	maxErrorReasonLen = 512       // Kept in the error message
+)
+
+// readErrorReason returns why Ollama rejected a request: the "error" field of a JSON body,
+// or otherwise the body itself, e.g. an HTML page from a proxy, truncated
+func readErrorReason(body io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(body, maxErrorBodyBytes))
+
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	reason := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &errResp) == nil && errResp.Error != "" {
+		reason = errResp.Error
+	}
+
+	reason = strings.Join(strings.Fields(reason), " ")
+	if len(reason) > maxErrorReasonLen {
+		reason = strings.ToValidUTF8(reason[:maxErrorReasonLen], "") + "…"
+	}
+	return reason
+}
+
+// statusError reports an unexpected Ollama response status, with the reason if there is one
+func statusError(status int, reason string) error {
+	if reason == "" {
+		return fmt.Errorf("ollama returned status %d", status)
+	}
+	return fmt.Errorf("ollama returned status %d: %s", status, reason)
+}
+
+// ModelAvailability reports which of the primary, fallback and embedding models are installed in Ollama
+func (c *OllamaClient) ModelAvailability(ctx context.Context) (map[string]bool, error) {
+	installed, err := c.installedModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	availability := make(map[string]bool)
+	for _, model := range append(c.Models(), c.EmbeddingModel()) {
+		availability[model] = installed[normalizeModelName(model)]
+	}
+	return availability, nil
+}
+
+// RunningModels returns the models Ollama currently has loaded, with their memory usage
+func (c *OllamaClient) RunningModels(ctx context.Context) ([]OllamaRunningModel, error) {
+	httpReq, err := c.newRequest(ctx, "GET", "/api/ps", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.healthClient.Do(httpReq)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	return resp.StatusCode == http.StatusOK, nil
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp.StatusCode, readErrorReason(resp.Body))
+	}
+
+	var ps struct {
+		Models []OllamaRunningModel `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ps); err != nil {
+		return nil, fmt.Errorf("failed to decode running models: %w", err)
+	}
+	return ps.Models, nil
 }
 
-// Model returns the configured model name
-func (c *OllamaClient) Model() string {
-	return c.model
+// ollamaTag is a model pulled into Ollama, as listed by /api/tags
+type ollamaTag struct {
+	Name  string `json:"name"`
+	Model string `json:"model"`
+}
+
+// tags lists the models pulled into Ollama
+func (c *OllamaClient) tags(ctx context.Context) ([]ollamaTag, error) {
+	httpReq, err := c.newRequest(ctx, "GET", "/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.healthClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp.StatusCode, readErrorReason(resp.Body))
+	}
+
+	var tags struct {
+		Models []ollamaTag `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("failed to decode model list: %w", err)
+	}
+	return tags.Models, nil
+}
+
+// installedModels lists the models pulled into Ollama via /api/tags, keyed by normalized name
+func (c *OllamaClient) installedModels(ctx context.Context) (map[string]bool, error) {
+	tags, err := c.tags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	installed := make(map[string]bool)
+	for _, m := range tags {
+		installed[normalizeModelName(m.Name)] = true
+		installed[normalizeModelName(m.Model)] = true
+	}
+	return installed, nil
+}
+
+// ModelNames returns the names of the models pulled into Ollama
+func (c *OllamaClient) ModelNames(ctx context.Context) ([]string, error) {
+	tags, err := c.tags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(tags))
+	for _, m := range tags {
+		names = append(names, m.Name)
+	}
+	return names, nil
+}
+
+// normalizeModelName adds the implicit ":latest" tag so "llama3" and "llama3:latest" compare equal
+func normalizeModelName(name string) string {
+	if name != "" && !strings.Contains(name, ":") {
+		return name + ":latest"
+	}
+	return name
+}
+
+// SetEmbeddingModel sets the model used for embeddings (defaults to the chat model)
+func (c *OllamaClient) SetEmbeddingModel(model string) {
+	c.embedModel = model
+}
+
+// EmbeddingModel returns the model used for embeddings
+func (c *OllamaClient) EmbeddingModel() string {
+	if c.embedModel == "" {
+		return c.Model()
+	}
+	return c.embedModel
+}
+
+// Embeddings computes an embedding vector for the input using the configured embedding model
+func (c *OllamaClient) Embeddings(ctx context.Context, input string) ([]float32, error) {
+	return c.EmbeddingsWithModel(ctx, c.EmbeddingModel(), input)
+}
+
+// EmbeddingsWithModel computes an embedding vector for the input using the given model
+func (c *OllamaClient) EmbeddingsWithModel(ctx context.Context, model, input string) ([]float32, error) {
+	req := OllamaEmbeddingsRequest{
+		Model:     model,
+		Prompt:    input,
+		KeepAlive: c.keepAlive,
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := c.newRequest(ctx, "POST", "/api/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var embResp OllamaEmbeddingsResponse
+	_ = json.Unmarshal(data, &embResp)
+
+	if resp.StatusCode != http.StatusOK || embResp.Error != "" {
+		if strings.Contains(embResp.Error, "does not support") {
+			return nil, fmt.Errorf("model %q does not support embeddings", model)
+		}
+		if embResp.Error != "" {
+			return nil, fmt.Errorf("ollama error: %s", embResp.Error)
+		}
+		return nil, fmt.Errorf("ollama returned status %d", resp.StatusCode)
+	}
+
+	if len(embResp.Embedding) == 0 {
+		return nil, fmt.Errorf("model %q does not support embeddings (empty embedding returned)", model)
+	}
+
+	return embResp.Embedding, nil
+}
+
+// SetGenerationTimeout caps how long a chat request may take, including streaming the whole answer.
+// Connecting and waiting for Ollama to respond stay bounded separately. Zero leaves generations unbounded.
+func (c *OllamaClient) SetGenerationTimeout(timeout time.Duration) {
+	c.genTimeout = timeout
+}
+
+// GenerationTimeoutError reports a chat request cut off by the generation timeout
+type GenerationTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *GenerationTimeoutError) Error() string {
+	return fmt.Sprintf("generation took longer than the %s generation timeout", e.Timeout)
+}
+
+// withGenerationTimeout bounds a chat request by the generation timeout, if one is set
+func (c *OllamaClient) withGenerationTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.genTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, c.genTimeout, &GenerationTimeoutError{Timeout: c.genTimeout})
+}
+
+// generationError reports a request cut off by the generation timeout as such,
+// instead of the transport or context error it surfaced as
+func generationError(ctx context.Context, err error) error {
+	var timeout *GenerationTimeoutError
+	if err != nil && errors.As(context.Cause(ctx), &timeout) {
+		return timeout
+	}
+	return err
+}
+
+// SetKeepAlive sets how long Ollama keeps the model loaded after each request.
+// Accepts a duration such as "30m", or a number of seconds where "-1" keeps the model loaded
+// indefinitely and "0" unloads it immediately. An empty string uses Ollama's default.
+func (c *OllamaClient) SetKeepAlive(keepAlive string) {
+	c.keepAlive = parseKeepAlive(keepAlive)
+}
+
+// parseKeepAlive converts a keep_alive setting into the JSON value Ollama expects
+func parseKeepAlive(keepAlive string) any {
+	keepAlive = strings.TrimSpace(keepAlive)
+	if keepAlive == "" {
+		return nil
+	}
+	// Bare numbers are seconds; Ollama rejects duration strings without a unit
+	if seconds, err := strconv.Atoi(keepAlive); err == nil {
+		return seconds
+	}
+	return keepAlive
+}
+
+// formatValue converts a response format into Ollama's format field.
+// A JSON object is passed through as a structured output schema; anything else is sent as a string.
+func formatValue(format string) json.RawMessage {
+	format = strings.TrimSpace(format)
+	if format == "" {
+		return nil
+	}
+	if strings.HasPrefix(format, "{") && json.Valid([]byte(format)) {
+		return json.RawMessage(format)
+	}
+	quoted, _ := json.Marshal(format)
+	return quoted
+}
+
+// Warm loads the model into memory (or refreshes its keep-alive) without generating anything
+func (c *OllamaClient) Warm(ctx context.Context) error {
+	req := OllamaGenerateRequest{
+		Model:     c.Model(),
+		Stream:    false,
+		KeepAlive: c.keepAlive,
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := c.newRequest(ctx, "POST", "/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp.StatusCode, readErrorReason(resp.Body))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// SetHeaders sets extra headers sent with every request, such as Authorization for a remote Ollama behind a proxy
+func (c *OllamaClient) SetHeaders(headers map[string]string) {
+	c.headers = headers
+}
+
+// newRequest creates a request to an Ollama API path with the configured headers
+func (c *OllamaClient) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range c.headers {
+		httpReq.Header.Set(name, value)
+	}
+	return httpReq, nil
+}
+
+// NormalizeOllamaURL trims trailing slashes and defaults to http when no scheme is given,
+// so both "localhost:11434" and "https://gpu.example.com/" work
+func NormalizeOllamaURL(rawURL string) string {
+	rawURL = strings.TrimRight(strings.TrimSpace(rawURL), "/")
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	return rawURL
+}
+
+// BaseURL returns the Ollama API endpoint
+func (c *OllamaClient) BaseURL() string {
+	return c.baseURL
 }
 
 // ChatMessages sends messages without tools and streams the response.
 // Implements agent.PipelineLLMClient interface.
-func (c *OllamaClient) ChatMessages(ctx context.Context, messages []agent.Message, tokenChan chan<- string) (string, error) {
+func (c *OllamaClient) ChatMessages(ctx context.Context, messages []agent.Message, tokenChan chan<- string) (_ string, err error) {
 	startTime := time.Now()
+	ctx, cancel := c.withGenerationTimeout(ctx)
+	defer cancel()
+	defer func() { err = generationError(ctx, err) }()
 
 	// Close the token channel when done (if provided)
 	if tokenChan != nil {
@@ -311,34 +899,27 @@ func (c *OllamaClient) ChatMessages(ctx context.Context, messages []agent.Messag
 	}
 
 	req := OllamaRequest{
-		Model:    c.model,
-		Messages: ollamaMessages,
-		Stream:   true,
-	}
-
-	body, err := json.Marshal(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		Model:     c.Model(),
+		Messages:  ollamaMessages,
+		Stream:    true,
+		KeepAlive: c.keepAlive,
+		Format:    formatValue(agent.ResponseFormat(ctx)),
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/chat", bytes.NewReader(body))
+	resp, err := c.openChat(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(httpReq)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("ollama returned status %d", resp.StatusCode)
-	}
-
 	var contentBuilder bytes.Buffer
-	scanner := bufio.NewScanner(resp.Body)
+	var tagger thinkingTagger
+	// Closing the body on cancellation unblocks a scanner stuck waiting for the next token
+	// and drops the connection, which makes Ollama stop generating
+	stopClose := context.AfterFunc(ctx, func() { _ = resp.Body.Close() })
+	defer stopClose()
+
+	scanner := newLineScanner(resp.Body)
 	for scanner.Scan() {
 		select {
 		case <-ctx.Done():
@@ -360,32 +941,55 @@ func (c *OllamaClient) ChatMessages(ctx context.Context, messages []agent.Messag
 			return "", fmt.Errorf("ollama error: %s", ollamaResp.Error)
 		}
 
-		if ollamaResp.Message.Content != "" {
-			contentBuilder.WriteString(ollamaResp.Message.Content)
-			if tokenChan != nil {
-				tokenChan <- ollamaResp.Message.Content
-			}
+		contentBuilder.WriteString(ollamaResp.Message.Content)
+		if err := sendToken(ctx, tokenChan, tagger.token(ollamaResp.Message)); err != nil {
+			return "", err
 		}
 
 		if ollamaResp.Done {
+			agent.RecordUsage(ctx, ollamaResp.PromptEvalCount, ollamaResp.EvalCount, time.Duration(ollamaResp.EvalDuration))
 			break
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	if err := scanner.Err(); err != nil {
 		return "", fmt.Errorf("error reading response: %w", err)
 	}
 
+	if err := sendToken(ctx, tokenChan, tagger.close()); err != nil {
+		return "", err
+	}
+
 	// Log the LLM call
 	c.logCall("chat_messages", messages, nil, &agent.ChatResult{Content: contentBuilder.String()}, "", startTime)
 
 	return contentBuilder.String(), nil
 }
 
+// sendToken delivers a token to tokenChan, giving up if the context is canceled first.
+// Empty tokens and a nil channel are ignored.
+func sendToken(ctx context.Context, tokenChan chan<- string, token string) error {
+	if token == "" || tokenChan == nil {
+		return nil
+	}
+	select {
+	case tokenChan <- token:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // SimpleChat makes a simple chat completion call without tools.
 // Implements tools.LLMClient interface for tool discovery.
-func (c *OllamaClient) SimpleChat(ctx context.Context, systemPrompt, userMessage string) (string, error) {
+func (c *OllamaClient) SimpleChat(ctx context.Context, systemPrompt, userMessage string) (_ string, err error) {
 	startTime := time.Now()
+	ctx, cancel := c.withGenerationTimeout(ctx)
+	defer cancel()
+	defer func() { err = generationError(ctx, err) }()
 
 	messages := []OllamaMessage{
 		{Role: "system", Content: systemPrompt},
@@ -393,32 +997,18 @@ func (c *OllamaClient) SimpleChat(ctx context.Context, systemPrompt, userMessage
 	}
 
 	req := OllamaRequest{
-		Model:    c.model,
-		Messages: messages,
-		Stream:   false, // Non-streaming for simplicity
-	}
-
-	body, err := json.Marshal(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/chat", bytes.NewReader(body))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		Model:     c.Model(),
+		Messages:  messages,
+		Stream:    false, // Non-streaming for simplicity
+		KeepAlive: c.keepAlive,
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.openChat(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("ollama returned status %d", resp.StatusCode)
-	}
-
 	var ollamaResp OllamaResponse
 	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
 		return "", fmt.Errorf("failed to decode response: %w", err)
@@ -427,6 +1017,7 @@ func (c *OllamaClient) SimpleChat(ctx context.Context, systemPrompt, userMessage
 	if ollamaResp.Error != "" {
 		return "", fmt.Errorf("ollama error: %s", ollamaResp.Error)
 	}
+	agent.RecordUsage(ctx, ollamaResp.PromptEvalCount, ollamaResp.EvalCount, time.Duration(ollamaResp.EvalDuration))
 
 	// Log the LLM call
 	agentMessages := []agent.Message{
@@ -484,7 +1075,7 @@ func (c *OllamaClient) logCall(phase string, messages []agent.Message, tools []a
 
 	call := config.LLMStepLog{
 		Phase:      phase,
-		Model:      c.model,
+		Model:      c.Model(),
 		Messages:   msgLogs,
 		Tools:      toolNames,
 		Response:   response,
@@ -495,3 +1086,46 @@ func (c *OllamaClient) logCall(phase string, messages []agent.Message, tools []a
 
 	_ = c.llmCallLogger.LogLLM(call)
 }
+
+// lineScanner reads Ollama's newline-delimited stream. Unlike bufio.Scanner, whose lines are capped
+// at 64KB, it handles lines of any length, such as a large tool call sent as a single chunk.
+type lineScanner struct {
+	reader *bufio.Reader
+	line   []byte
+	err    error
+	done   bool
+}
+
+func newLineScanner(r io.Reader) *lineScanner {
+	return &lineScanner{reader: bufio.NewReader(r)}
+}
+
+// Scan advances to the next line, returning false at the end of the stream or on an error
+func (s *lineScanner) Scan() bool {
+	if s.done {
+		return false
+	}
+	line, err := s.reader.ReadBytes('\n')
+	if err != nil {
+		s.done = true
+		if !errors.Is(err, io.EOF) {
+			s.err = err
+			return false
+		}
+		if len(line) == 0 {
+			return false
+		}
+	}
+	s.line = bytes.TrimRight(line, "\r\n")
+	return true
+}
+
+// Bytes returns the current line without its line ending
+func (s *lineScanner) Bytes() []byte {
+	return s.line
+}
+
+// Err returns the first error other than io.EOF
+func (s *lineScanner) Err() error {
+	return s.err
+}
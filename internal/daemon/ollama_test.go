@@ -0,0 +1,594 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marciniwanicki/craby/internal/agent"
+)
+
+func TestOllamaClient_Embeddings(t *testing.T) {
+	var gotReq OllamaEmbeddingsRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embeddings" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&gotReq)
+		_, _ = w.Write([]byte(`{"embedding":[0.1,0.2,0.3]}`))
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL, "chat-model", nil)
+	client.SetEmbeddingModel("embed-model")
+
+	embedding, err := client.Embeddings(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(embedding) != 3 || embedding[1] != 0.2 {
+		t.Errorf("unexpected embedding: %v", embedding)
+	}
+	if gotReq.Model != "embed-model" {
+		t.Errorf("expected embedding model to be used, got %q", gotReq.Model)
+	}
+	if gotReq.Prompt != "hello" {
+		t.Errorf("expected prompt 'hello', got %q", gotReq.Prompt)
+	}
+}
+
+func TestOllamaClient_EmbeddingModelDefaultsToChatModel(t *testing.T) {
+	client := NewOllamaClient("http://localhost:11434", "chat-model", nil)
+	if got := client.EmbeddingModel(); got != "chat-model" {
+		t.Errorf("expected chat model as default, got %q", got)
+	}
+}
+
+func TestOllamaClient_Embeddings_Unsupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error":"\"chat-model\" does not support embeddings"}`))
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL, "chat-model", nil)
+
+	_, err := client.Embeddings(context.Background(), "hello")
+	if err == nil {
+		t.Fatal("expected error for model without embedding support")
+	}
+	if !strings.Contains(err.Error(), "does not support embeddings") {
+		t.Errorf("expected clear unsupported error, got: %v", err)
+	}
+}
+
+func TestParseKeepAlive(t *testing.T) {
+	tests := []struct {
+		input string
+		want  any
+	}{
+		{"", nil},
+		{"30m", "30m"},
+		{"-1", -1},
+		{"0", 0},
+		{" 300 ", 300},
+	}
+
+	for _, tt := range tests {
+		if got := parseKeepAlive(tt.input); got != tt.want {
+			t.Errorf("parseKeepAlive(%q) = %#v, want %#v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestOllamaClient_Warm_SendsKeepAlive(t *testing.T) {
+	var got map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"done":true}`))
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL, "qwen", nil)
+	client.SetKeepAlive("-1")
+	if err := client.Warm(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got["model"] != "qwen" {
+		t.Errorf("expected model qwen, got %v", got["model"])
+	}
+	if got["keep_alive"] != float64(-1) {
+		t.Errorf("expected keep_alive -1, got %v", got["keep_alive"])
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"json", `"json"`},
+		{`{"type":"object"}`, `{"type":"object"}`},
+		{"{not json", `"{not json"`},
+	}
+
+	for _, tt := range tests {
+		if got := string(formatValue(tt.input)); got != tt.want {
+			t.Errorf("formatValue(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestThinkingTagger(t *testing.T) {
+	var tagger thinkingTagger
+	var sb strings.Builder
+	for _, msg := range []OllamaMessage{
+		{Thinking: "let me"},
+		{Thinking: " think"},
+		{Content: "Answer"},
+		{Content: " here"},
+	} {
+		sb.WriteString(tagger.token(msg))
+	}
+	sb.WriteString(tagger.close())
+
+	if got := sb.String(); got != "<think>let me think</think>Answer here" {
+		t.Errorf("unexpected tagged stream: %q", got)
+	}
+
+	// An unterminated thinking block is closed at the end of the stream
+	tagger = thinkingTagger{}
+	if got := tagger.token(OllamaMessage{Thinking: "hmm"}) + tagger.close(); got != "<think>hmm</think>" {
+		t.Errorf("expected thinking block to be closed, got %q", got)
+	}
+}
+
+func TestOllamaClient_ChatMessages_CancelAbortsRequest(t *testing.T) {
+	serverCanceled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"message":{"role":"assistant","content":"first"}}` + "\n"))
+		w.(http.Flusher).Flush()
+
+		// Stall mid-generation until the client goes away
+		select {
+		case <-r.Context().Done():
+			close(serverCanceled)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL, "qwen", nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	tokenChan := make(chan string, 10)
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := client.ChatMessages(ctx, []agent.Message{{Role: "user", Content: "hi"}}, tokenChan)
+		errChan <- err
+	}()
+
+	if token := <-tokenChan; token != "first" {
+		t.Fatalf("expected first token, got %q", token)
+	}
+	cancel()
+
+	select {
+	case err := <-errChan:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ChatMessages did not return after cancellation")
+	}
+
+	select {
+	case <-serverCanceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Ollama request was not aborted after cancellation")
+	}
+}
+
+func TestOllamaClient_GenerationTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"message":{"role":"assistant","content":"first"}}` + "\n"))
+		w.(http.Flusher).Flush()
+
+		// A runaway generation that never finishes
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL, "qwen", nil)
+	client.SetGenerationTimeout(100 * time.Millisecond)
+
+	start := time.Now()
+	_, err := client.ChatMessages(context.Background(), []agent.Message{{Role: "user", Content: "hi"}}, nil)
+	var timeout *GenerationTimeoutError
+	if !errors.As(err, &timeout) {
+		t.Fatalf("expected a generation timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the generation to be cut off after the timeout, took %s", elapsed)
+	}
+}
+
+func TestOllamaClient_Health_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewOllamaClient(server.URL, "qwen", nil)
+	client.healthClient.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	healthy, err := client.Health(context.Background())
+	if healthy || err == nil {
+		t.Errorf("expected unhealthy with error, got healthy=%v err=%v", healthy, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected health check to time out quickly, took %v", elapsed)
+	}
+}
+
+func TestOllamaClient_SharedTransport(t *testing.T) {
+	client := NewOllamaClient("http://localhost:11434", "qwen", nil)
+	if client.httpClient.Timeout != 0 {
+		t.Errorf("expected no overall timeout for streaming requests, got %v", client.httpClient.Timeout)
+	}
+	if client.httpClient.Transport != client.healthClient.Transport {
+		t.Error("expected health checks to share the pooled transport")
+	}
+}
+
+func TestOllamaClient_SendsHeaders(t *testing.T) {
+	var paths []string
+	var auths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		auths = append(auths, r.Header.Get("Authorization"))
+		if r.URL.Path == "/api/tags" {
+			_, _ = w.Write([]byte(`{"models":[{"name":"qwen:latest"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"message":{"content":"ok"},"done":true}`))
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL+"/", "qwen", nil)
+	client.SetHeaders(map[string]string{"Authorization": "Bearer token"})
+
+	if _, err := client.Health(context.Background()); err != nil {
+		t.Fatalf("health failed: %v", err)
+	}
+	if _, err := client.SimpleChat(context.Background(), "system", "hi"); err != nil {
+		t.Fatalf("chat failed: %v", err)
+	}
+
+	for i, auth := range auths {
+		if auth != "Bearer token" {
+			t.Errorf("request to %s missing Authorization header, got %q", paths[i], auth)
+		}
+	}
+	if paths[0] != "/api/tags" || paths[1] != "/api/chat" {
+		t.Errorf("unexpected request paths %v", paths)
+	}
+}
+
+func TestNormalizeOllamaURL(t *testing.T) {
+	tests := map[string]string{
+		"http://localhost:11434":    "http://localhost:11434",
+		"http://localhost:11434/":   "http://localhost:11434",
+		"localhost:11434":           "http://localhost:11434",
+		"https://gpu.example.com/":  "https://gpu.example.com",
+		" https://gpu.example.com ": "https://gpu.example.com",
+	}
+	for input, want := range tests {
+		if got := NormalizeOllamaURL(input); got != want {
+			t.Errorf("NormalizeOllamaURL(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestIsPlaintextRemote(t *testing.T) {
+	tests := map[string]bool{
+		"http://localhost:11434":   false,
+		"http://127.0.0.1:11434":   false,
+		"https://gpu.example.com":  false,
+		"http://gpu.example.com":   true,
+		"http://192.168.1.20:8080": true,
+	}
+	for input, want := range tests {
+		if got := isPlaintextRemote(input); got != want {
+			t.Errorf("isPlaintextRemote(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
+
+func TestOllamaClient_FallbackModel(t *testing.T) {
+	var models []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		models = append(models, req.Model)
+		if req.Model == "primary" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"model \"primary\" not found, try pulling it first"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"message":{"content":"ok"},"done":true}`))
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL, "primary", nil)
+	client.SetFallbackModels([]string{"primary", "backup", " ", "backup"})
+
+	served := &agent.ServedModel{}
+	ctx := agent.WithServedModel(context.Background(), served)
+	content, err := client.ChatMessages(ctx, []agent.Message{{Role: "user", Content: "hi"}}, nil)
+	if err != nil {
+		t.Fatalf("chat failed: %v", err)
+	}
+	if content != "ok" {
+		t.Errorf("expected content ok, got %q", content)
+	}
+	if strings.Join(models, ",") != "primary,backup" {
+		t.Errorf("unexpected models tried: %v", models)
+	}
+	if name, fallback := served.Get(); name != "backup" || !fallback {
+		t.Errorf("expected backup to be recorded as fallback, got %q (fallback=%v)", name, fallback)
+	}
+}
+
+func TestOllamaClient_FallbackModel_OtherErrors(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error":"out of memory"}`))
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL, "primary", nil)
+	client.SetFallbackModels([]string{"backup"})
+
+	_, err := client.SimpleChat(context.Background(), "system", "hi")
+	if err == nil || !strings.Contains(err.Error(), "out of memory") {
+		t.Fatalf("expected out of memory error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected no fallback for unrelated errors, got %d calls", calls)
+	}
+}
+
+func TestOllamaClient_FallbackModel_AllUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error":"llama runner process has terminated: failed to load model"}`))
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL, "primary", nil)
+	client.SetFallbackModels([]string{"backup"})
+
+	_, err := client.SimpleChat(context.Background(), "system", "hi")
+	var unavailable *ModelUnavailableError
+	if !errors.As(err, &unavailable) {
+		t.Fatalf("expected ModelUnavailableError, got %v", err)
+	}
+	if unavailable.Model != "backup" {
+		t.Errorf("expected last tried model backup, got %s", unavailable.Model)
+	}
+}
+
+func TestOllamaClient_ModelAvailability(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"models":[{"name":"qwen2.5:14b","model":"qwen2.5:14b"},{"name":"llama3:latest","model":"llama3:latest"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL, "qwen2.5:14b", nil)
+	client.SetFallbackModels([]string{"llama3", "mistral"})
+	client.SetEmbeddingModel("nomic-embed-text")
+
+	availability, err := client.ModelAvailability(context.Background())
+	if err != nil {
+		t.Fatalf("availability failed: %v", err)
+	}
+	expected := map[string]bool{"qwen2.5:14b": true, "llama3": true, "mistral": false, "nomic-embed-text": false}
+	for model, want := range expected {
+		if availability[model] != want {
+			t.Errorf("%s: expected available=%v, got %v", model, want, availability[model])
+		}
+	}
+}
+
+func TestOllamaClient_Health_ModelNotPulled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"models":[{"name":"llama3:latest","model":"llama3:latest"}]}`))
+	}))
+	defer server.Close()
+
+	healthy, err := NewOllamaClient(server.URL, "qwen2.5:14b", nil).Health(context.Background())
+	if healthy || !errors.Is(err, ErrModelNotPulled) {
+		t.Errorf("expected ErrModelNotPulled, got healthy=%v err=%v", healthy, err)
+	}
+
+	healthy, err = NewOllamaClient(server.URL, "llama3", nil).Health(context.Background())
+	if !healthy || err != nil {
+		t.Errorf("expected healthy for pulled model, got healthy=%v err=%v", healthy, err)
+	}
+}
+
+func TestOllamaClient_RunningModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/ps" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"models":[{"name":"qwen2.5:14b","model":"qwen2.5:14b","size":9000000000,"size_vram":8000000000,"expires_at":"2030-01-02T15:04:05Z"}]}`))
+	}))
+	defer server.Close()
+
+	models, err := NewOllamaClient(server.URL, "qwen2.5:14b", nil).RunningModels(context.Background())
+	if err != nil {
+		t.Fatalf("running models failed: %v", err)
+	}
+	if len(models) != 1 {
+		t.Fatalf("expected 1 model, got %d", len(models))
+	}
+	m := models[0]
+	if m.Name != "qwen2.5:14b" || m.Size != 9000000000 || m.SizeVRAM != 8000000000 {
+		t.Errorf("unexpected model %+v", m)
+	}
+	if m.ExpiresAt.Year() != 2030 {
+		t.Errorf("expected expiry to be parsed, got %v", m.ExpiresAt)
+	}
+}
+
+func TestOllamaClient_RecordsUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"message":{"content":"ok"},"done":false}` + "\n"))
+		_, _ = w.Write([]byte(`{"message":{"content":""},"done":true,"prompt_eval_count":30,"eval_count":4,"eval_duration":250000000}` + "\n"))
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL, "model", nil)
+	usage := &agent.Usage{}
+	ctx := agent.WithUsage(context.Background(), usage)
+
+	for range 2 {
+		if _, err := client.ChatMessages(ctx, []agent.Message{{Role: "user", Content: "hi"}}, nil); err != nil {
+			t.Fatalf("chat failed: %v", err)
+		}
+	}
+
+	if prompt, completion := usage.Totals(); prompt != 60 || completion != 8 {
+		t.Errorf("expected 60 prompt and 8 completion tokens, got %d and %d", prompt, completion)
+	}
+	if got := usage.EvalDuration(); got != 500*time.Millisecond {
+		t.Errorf("expected 500ms of generation, got %v", got)
+	}
+}
+
+func TestOllamaClient_ModelNames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"models":[{"name":"qwen2.5:14b","model":"qwen2.5:14b"},{"name":"llama3.2:latest","model":"llama3.2:latest"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL, "qwen2.5:14b", nil)
+
+	names, err := client.ModelNames(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(names) != 2 || names[0] != "qwen2.5:14b" || names[1] != "llama3.2:latest" {
+		t.Errorf("unexpected model names: %v", names)
+	}
+}
+
+func TestOllamaClient_OversizedStreamLine(t *testing.T) {
+	// A single chunk well past bufio.Scanner's 64KB line limit
+	big := strings.Repeat("x", 1<<20)
+	arguments := `{"content":"` + big + `"}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Tools) > 0 {
+			_, _ = w.Write([]byte(`{"message":{"content":"","tool_calls":[{"function":{"name":"write_file","arguments":` + arguments + `}}]},"done":false}` + "\n"))
+		} else {
+			_, _ = w.Write([]byte(`{"message":{"content":"` + big + `"},"done":false}` + "\n"))
+		}
+		// The last line has no trailing newline
+		_, _ = w.Write([]byte(`{"message":{"content":""},"done":true}`))
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL, "model", nil)
+
+	content, err := client.ChatMessages(context.Background(), []agent.Message{{Role: "user", Content: "hi"}}, nil)
+	if err != nil {
+		t.Fatalf("chat failed: %v", err)
+	}
+	if len(content) != len(big) {
+		t.Errorf("expected %d bytes of content, got %d", len(big), len(content))
+	}
+
+	result, err := client.ChatWithTools(context.Background(), []agent.Message{{Role: "user", Content: "hi"}}, []any{map[string]any{"type": "function"}}, nil)
+	if err != nil {
+		t.Fatalf("chat with tools failed: %v", err)
+	}
+	if len(result.ToolCalls) != 1 || len(result.ToolCalls[0].Function.Arguments["content"].(string)) != len(big) {
+		t.Errorf("expected the oversized tool call arguments intact, got %d tool calls", len(result.ToolCalls))
+	}
+}
+
+func TestOllamaClient_ErrorBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		contains string
+	}{
+		{"json error", http.StatusBadRequest, `{"error":"invalid options"}`, "status 400: invalid options"},
+		{"plain text", http.StatusBadGateway, "upstream connect error\n", "status 502: upstream connect error"},
+		{"empty body", http.StatusInternalServerError, "", "status 500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := NewOllamaClient(server.URL, "qwen2.5:14b", nil)
+			_, chatErr := client.SimpleChat(context.Background(), "system", "hi")
+			_, healthErr := client.Health(context.Background())
+			_, psErr := client.RunningModels(context.Background())
+			warmErr := client.Warm(context.Background())
+			for _, err := range []error{chatErr, healthErr, psErr, warmErr} {
+				if err == nil || !strings.HasSuffix(err.Error(), tt.contains) {
+					t.Errorf("expected error ending with %q, got %v", tt.contains, err)
+				}
+			}
+		})
+	}
+}
+
+func TestReadErrorReason_Truncates(t *testing.T) {
+	page := "<html><body>" + strings.Repeat("proxy error ", 20000) + "</body></html>"
+	reason := readErrorReason(strings.NewReader(page))
+	if !strings.HasPrefix(reason, "<html><body>proxy error") || !strings.HasSuffix(reason, "…") {
+		t.Errorf("expected truncated page, got %q", reason)
+	}
+	if len(reason) > maxErrorReasonLen+len("…") {
+		t.Errorf("expected at most %d bytes, got %d", maxErrorReasonLen, len(reason))
+	}
+}
@@ -0,0 +1,334 @@
+package daemon
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/marciniwanicki/craby/internal/agent"
+	"github.com/marciniwanicki/craby/internal/tools"
+)
+
+// openAIMessage is a chat message in the OpenAI chat completions API
+type openAIMessage struct {
+	Role    string        `json:"role"`
+	Content openAIContent `json:"content"`
+}
+
+// openAIContent is message content, sent either as a string or as an array of parts.
+// Only text parts are kept.
+type openAIContent string
+
+func (c *openAIContent) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = openAIContent(text)
+		return nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return errors.New("content must be a string or an array of content parts")
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	*c = openAIContent(strings.Join(texts, "\n"))
+	return nil
+}
+
+// openAIChatRequest is the body of POST /v1/chat/completions. Fields craby can't honor, such as
+// temperature or tools, are ignored; craby answers with its own tools.
+type openAIChatRequest struct {
+	Model          string          `json:"model"`
+	Messages       []openAIMessage `json:"messages"`
+	Stream         bool            `json:"stream"`
+	ResponseFormat *struct {
+		Type       string `json:"type"` // "text", "json_object" or "json_schema"
+		JSONSchema *struct {
+			Schema json.RawMessage `json:"schema"`
+		} `json:"json_schema"`
+	} `json:"response_format"`
+}
+
+type openAIChatResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+}
+
+type openAIChoice struct {
+	Index        int          `json:"index"`
+	Message      *openAIReply `json:"message,omitempty"` // Set in complete responses
+	Delta        *openAIReply `json:"delta,omitempty"`   // Set in streamed chunks
+	FinishReason *string      `json:"finish_reason"`     // Null until the last chunk
+}
+
+// openAIReply is an assistant message or, when streaming, the part of it added by a chunk
+type openAIReply struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAIErrorResponse struct {
+	Error openAIError `json:"error"`
+}
+
+type openAIError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+// openAISessionID is the session that commands run for chat completions are audited under
+const openAISessionID = "openai"
+
+// openAIFinishStop is the finish reason of every answer, craby always answers in full
+var openAIFinishStop = "stop"
+
+// HandleChatCompletions serves the OpenAI chat completions API, answering through the same
+// runner and chat queue as websocket chats. Requests are stateless: the history comes with each
+// request, and the daemon's own history is left untouched.
+func (h *Handler) HandleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "use POST")
+		return
+	}
+	if h.maxMessageBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxMessageBytes)
+	}
+
+	var req openAIChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	message, opts, err := openAIRunOptions(&req)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	opts.Context = h.context
+
+	if !h.trackChat() {
+		writeOpenAIError(w, http.StatusServiceUnavailable, "server_error", "daemon shutting down")
+		return
+	}
+	defer h.chats.Done()
+
+	ctx := r.Context()
+	release, err := h.currentQueue().Acquire(ctx, nil)
+	if errors.Is(err, errServerBusy) {
+		writeOpenAIError(w, http.StatusTooManyRequests, "rate_limit_error", "server busy, too many chats queued")
+		return
+	}
+	if err != nil {
+		return // The client went away while queued
+	}
+	defer release()
+
+	served := &agent.ServedModel{}
+	ctx = agent.WithServedModel(ctx, served)
+	usage := &agent.Usage{}
+	ctx = agent.WithUsage(ctx, usage)
+
+	h.logger.Info().Bool("stream", req.Stream).Int("messages", len(req.Messages)).Msg("received chat completions request")
+
+	runner := h.currentRunner()
+	eventChan := make(chan agent.Event, 100)
+	// Tools report to the chat running them; their events are drained here with the answer
+	ctx = tools.WithHooks(ctx, h.toolHooks(openAISessionID, eventChan))
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := runner.Run(ctx, message, opts, eventChan)
+		errChan <- err
+	}()
+
+	completion := &openAIChatResponse{
+		ID:      newCompletionID(),
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+	}
+	if req.Stream {
+		h.streamCompletion(w, completion, eventChan, errChan, served)
+		return
+	}
+
+	var answer strings.Builder
+	for event := range eventChan {
+		if event.Type == agent.EventText && event.Role == agent.RoleAssistant {
+			answer.WriteString(event.Text)
+		}
+	}
+	if err := <-errChan; err != nil {
+		h.logger.Error().Err(err).Msg("chat completions request failed")
+		writeOpenAIError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	promptTokens, completionTokens := usage.Totals()
+	completion.Object = "chat.completion"
+	completion.Model = servedModelName(served, req.Model)
+	completion.Choices = []openAIChoice{{
+		Message:      &openAIReply{Role: "assistant", Content: strings.TrimSpace(answer.String())},
+		FinishReason: &openAIFinishStop,
+	}}
+	completion.Usage = &openAIUsage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(completion)
+	h.chatsServed.Add(1)
+}
+
+// streamCompletion sends the answer as server-sent events, one chunk per streamed piece of text
+func (h *Handler) streamCompletion(w http.ResponseWriter, completion *openAIChatResponse, events <-chan agent.Event, errChan <-chan error, served *agent.ServedModel) {
+	// Through a ResponseController, so middleware wrapping w doesn't hide its Flush
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	completion.Object = "chat.completion.chunk"
+
+	writeFailed := false
+	send := func(payload any) {
+		if writeFailed {
+			return
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			// The runner stops once the request context is canceled; keep draining its events
+			writeFailed = true
+			return
+		}
+		_ = controller.Flush()
+	}
+	chunk := func(delta *openAIReply, finish *string) *openAIChatResponse {
+		c := *completion
+		c.Model = servedModelName(served, completion.Model)
+		c.Choices = []openAIChoice{{Delta: delta, FinishReason: finish}}
+		return &c
+	}
+
+	send(chunk(&openAIReply{Role: "assistant"}, nil))
+	for event := range events {
+		if event.Type == agent.EventText && event.Role == agent.RoleAssistant && event.Text != "" {
+			send(chunk(&openAIReply{Content: event.Text}, nil))
+		}
+	}
+	if err := <-errChan; err != nil {
+		h.logger.Error().Err(err).Msg("chat completions stream failed")
+		send(openAIErrorResponse{Error: openAIError{Message: err.Error(), Type: "server_error"}})
+		return
+	}
+	send(chunk(&openAIReply{}, &openAIFinishStop))
+	if !writeFailed {
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+		_ = controller.Flush()
+	}
+	h.chatsServed.Add(1)
+}
+
+// openAIRunOptions maps an OpenAI request onto a runner call: system messages become the identity,
+// earlier messages the history, and the last message, which must come from the user, is answered
+func openAIRunOptions(req *openAIChatRequest) (string, agent.RunOptions, error) {
+	var opts agent.RunOptions
+	if len(req.Messages) == 0 {
+		return "", opts, errors.New("messages must not be empty")
+	}
+	last := req.Messages[len(req.Messages)-1]
+	if last.Role != "user" {
+		return "", opts, errors.New("the last message must have the user role")
+	}
+
+	var identity []string
+	for _, msg := range req.Messages[:len(req.Messages)-1] {
+		switch msg.Role {
+		case "system", "developer":
+			identity = append(identity, string(msg.Content))
+		case "user", "assistant":
+			opts.History = append(opts.History, agent.Message{Role: msg.Role, Content: string(msg.Content)})
+		default:
+			return "", opts, fmt.Errorf("unsupported message role %q", msg.Role)
+		}
+	}
+	opts.Identity = strings.Join(identity, "\n\n")
+
+	if rf := req.ResponseFormat; rf != nil {
+		switch rf.Type {
+		case "", "text":
+		case "json_object":
+			opts.Format = "json"
+		case "json_schema":
+			if rf.JSONSchema == nil || len(rf.JSONSchema.Schema) == 0 {
+				return "", opts, errors.New("response_format json_schema needs a schema")
+			}
+			opts.Format = string(rf.JSONSchema.Schema)
+		default:
+			return "", opts, fmt.Errorf("unsupported response_format type %q", rf.Type)
+		}
+	}
+	return string(last.Content), opts, nil
+}
+
+// servedModelName returns the model that answered, or the requested name if none was recorded
+func servedModelName(served *agent.ServedModel, requested string) string {
+	if name, _ := served.Get(); name != "" {
+		return name
+	}
+	return requested
+}
+
+// newCompletionID returns a random ID in the style of OpenAI's completion IDs
+func newCompletionID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "chatcmpl-" + hex.EncodeToString(b)
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, errType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(openAIErrorResponse{Error: openAIError{Message: message, Type: errType}})
+}
+
+// handleOpenAIModels serves the OpenAI models list: the primary model, then the fallbacks
+func (s *Server) handleOpenAIModels(w http.ResponseWriter, r *http.Request) {
+	type model struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		OwnedBy string `json:"owned_by"`
+	}
+	resp := struct {
+		Object string  `json:"object"`
+		Data   []model `json:"data"`
+	}{Object: "list", Data: []model{}}
+	for _, name := range s.ollama.Models() {
+		resp.Data = append(resp.Data, model{ID: name, Object: "model", OwnedBy: "craby"})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,208 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marciniwanicki/craby/internal/agent"
+)
+
+func TestOpenAIRunOptions(t *testing.T) {
+	var req openAIChatRequest
+	body := `{
+		"model": "gpt-4o",
+		"messages": [
+			{"role": "system", "content": "You are terse."},
+			{"role": "user", "content": "hi"},
+			{"role": "assistant", "content": "hello"},
+			{"role": "user", "content": [{"type": "text", "text": "what day"}, {"type": "image_url"}, {"type": "text", "text": "is it?"}]}
+		],
+		"response_format": {"type": "json_object"}
+	}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+
+	message, opts, err := openAIRunOptions(&req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if message != "what day\nis it?" {
+		t.Errorf("expected the text parts of the last message, got %q", message)
+	}
+	if opts.Identity != "You are terse." {
+		t.Errorf("expected the system message as identity, got %q", opts.Identity)
+	}
+	if len(opts.History) != 2 || opts.History[0].Role != "user" || opts.History[1].Content != "hello" {
+		t.Errorf("unexpected history: %+v", opts.History)
+	}
+	if opts.Format != "json" {
+		t.Errorf("expected json format, got %q", opts.Format)
+	}
+
+	for _, body := range []string{
+		`{"messages": []}`,
+		`{"messages": [{"role": "assistant", "content": "hi"}]}`,
+		`{"messages": [{"role": "tool", "content": "x"}, {"role": "user", "content": "hi"}]}`,
+		`{"messages": [{"role": "user", "content": "hi"}], "response_format": {"type": "json_schema"}}`,
+	} {
+		var req openAIChatRequest
+		if err := json.Unmarshal([]byte(body), &req); err != nil {
+			t.Fatalf("failed to decode %s: %v", body, err)
+		}
+		if _, _, err := openAIRunOptions(&req); err == nil {
+			t.Errorf("expected %s to be rejected", body)
+		}
+	}
+}
+
+func startCompletionsServer(t *testing.T, reply string) (*Handler, *httptest.Server) {
+	t.Helper()
+	handler := NewPipelineHandler(nil, "system prompt", testLogger())
+	handler.runner = &fakeRunner{reply: reply}
+	// Served like the daemon does, through the access log
+	server := httptest.NewServer(accessLog(testLogger(), http.HandlerFunc(handler.HandleChatCompletions)))
+	t.Cleanup(server.Close)
+	return handler, server
+}
+
+func TestHandler_HandleChatCompletions(t *testing.T) {
+	handler, server := startCompletionsServer(t, "It is Monday.")
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{"model": "craby", "messages": [{"role": "user", "content": "what day is it?"}]}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var completion openAIChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if completion.Object != "chat.completion" || !strings.HasPrefix(completion.ID, "chatcmpl-") || completion.Model != "craby" {
+		t.Errorf("unexpected completion: %+v", completion)
+	}
+	if len(completion.Choices) != 1 || completion.Choices[0].Message.Content != "It is Monday." || *completion.Choices[0].FinishReason != "stop" {
+		t.Errorf("unexpected choices: %+v", completion.Choices)
+	}
+	if len(handler.History()) != 0 {
+		t.Errorf("expected the daemon's history untouched, got %+v", handler.History())
+	}
+
+	resp, err = http.Post(server.URL, "application/json", strings.NewReader(`{"messages": []}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var apiErr openAIErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || resp.StatusCode != http.StatusBadRequest || apiErr.Error.Type != "invalid_request_error" {
+		t.Errorf("expected an OpenAI-style 400, got %d %+v %v", resp.StatusCode, apiErr, err)
+	}
+}
+
+func TestHandler_HandleChatCompletions_Stream(t *testing.T) {
+	_, server := startCompletionsServer(t, "It is Monday.")
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{"messages": [{"role": "user", "content": "hi"}], "stream": true}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected an event stream, got %q", ct)
+	}
+
+	var events []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			events = append(events, data)
+		}
+	}
+	if len(events) != 4 || events[3] != "[DONE]" {
+		t.Fatalf("expected role, content and finish chunks then [DONE], got %q", events)
+	}
+
+	var content strings.Builder
+	for i, data := range events[:3] {
+		var chunk openAIChatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("invalid chunk %q: %v", data, err)
+		}
+		if chunk.Object != "chat.completion.chunk" || len(chunk.Choices) != 1 {
+			t.Fatalf("unexpected chunk: %q", data)
+		}
+		choice := chunk.Choices[0]
+		if i == 0 && choice.Delta.Role != "assistant" {
+			t.Errorf("expected the first chunk to carry the role, got %q", data)
+		}
+		if (choice.FinishReason != nil) != (i == 2) {
+			t.Errorf("expected only the last chunk to finish, got %q", data)
+		}
+		content.WriteString(choice.Delta.Content)
+	}
+	if content.String() != "It is Monday." {
+		t.Errorf("expected the streamed answer, got %q", content.String())
+	}
+}
+
+// pausingRunner streams one piece of text, then waits for release before finishing
+type pausingRunner struct {
+	release chan struct{}
+}
+
+func (r *pausingRunner) Run(ctx context.Context, _ string, _ agent.RunOptions, eventChan chan<- agent.Event) ([]agent.Message, error) {
+	defer close(eventChan)
+	eventChan <- agent.Event{Type: agent.EventText, Text: "first", Role: agent.RoleAssistant}
+	select {
+	case <-r.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	eventChan <- agent.Event{Type: agent.EventText, Text: " second", Role: agent.RoleAssistant}
+	return nil, nil
+}
+
+func TestHandler_HandleChatCompletions_StreamFlushes(t *testing.T) {
+	handler, server := startCompletionsServer(t, "")
+	runner := &pausingRunner{release: make(chan struct{})}
+	handler.runner = runner
+	defer close(runner.release)
+
+	// The first piece arrives while the runner is still going, a buffered response wouldn't even send headers
+	got := make(chan error, 1)
+	go func() {
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{"messages": [{"role": "user", "content": "hi"}], "stream": true}`))
+		if err != nil {
+			got <- err
+			return
+		}
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if strings.Contains(scanner.Text(), `"content":"first"`) {
+				got <- nil
+				return
+			}
+		}
+		got <- errors.New("stream ended without the first chunk")
+	}()
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the first chunk flushed before the answer finished")
+	}
+}
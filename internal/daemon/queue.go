@@ -0,0 +1,149 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errServerBusy is returned by chatQueue.Acquire when the queue is full
+var errServerBusy = errors.New("server busy: too many chats waiting for the model, try again later")
+
+// chatQueue limits how many chats generate at once, queueing the rest in arrival order up to a bounded depth
+type chatQueue struct {
+	mu            sync.Mutex
+	maxConcurrent int
+	maxQueued     int
+	running       int
+	waiters       []*queueWaiter
+}
+
+// queueWaiter is a chat waiting for a slot
+type queueWaiter struct {
+	ready chan struct{} // Closed when the waiter is handed a slot
+	moved chan struct{} // Signaled when the waiter moves up the queue
+}
+
+// newChatQueue creates a queue running maxConcurrent chats at once with up to maxQueued waiting.
+// Returns nil (unlimited) when maxConcurrent is 0 or less.
+func newChatQueue(maxConcurrent, maxQueued int) *chatQueue {
+	if maxConcurrent <= 0 {
+		return nil
+	}
+	return &chatQueue{
+		maxConcurrent: maxConcurrent,
+		maxQueued:     max(maxQueued, 0),
+	}
+}
+
+// Acquire waits for a slot and returns a function that releases it. While queued, onPosition is
+// called with the chat's 1-based position whenever it changes. Returns errServerBusy if the queue
+// is full, or ctx's error if ctx is done first.
+func (q *chatQueue) Acquire(ctx context.Context, onPosition func(position int)) (func(), error) {
+	if q == nil {
+		return func() {}, nil
+	}
+
+	q.mu.Lock()
+	if q.running < q.maxConcurrent && len(q.waiters) == 0 {
+		q.running++
+		q.mu.Unlock()
+		return q.release, nil
+	}
+	if len(q.waiters) >= q.maxQueued {
+		q.mu.Unlock()
+		return nil, errServerBusy
+	}
+	w := &queueWaiter{ready: make(chan struct{}), moved: make(chan struct{}, 1)}
+	q.waiters = append(q.waiters, w)
+	position := len(q.waiters)
+	q.mu.Unlock()
+
+	reported := 0
+	for {
+		if position != reported {
+			onPosition(position)
+			reported = position
+		}
+
+		select {
+		case <-w.ready:
+			return q.release, nil
+		case <-w.moved:
+			q.mu.Lock()
+			position = q.positionOf(w)
+			q.mu.Unlock()
+			if position == 0 {
+				// Handed a slot right after moving up
+				<-w.ready
+				return q.release, nil
+			}
+		case <-ctx.Done():
+			q.mu.Lock()
+			queued := q.remove(w)
+			q.mu.Unlock()
+			if !queued {
+				// Handed a slot while giving up, pass it on
+				q.release()
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// release hands the slot to the first waiter, or frees it when nobody is waiting
+func (q *chatQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.waiters) == 0 {
+		q.running--
+		return
+	}
+	next := q.waiters[0]
+	q.waiters = q.waiters[1:]
+	close(next.ready)
+	q.notifyMoved()
+}
+
+// remove takes w out of the queue, reporting whether it was still waiting. The caller holds mu.
+func (q *chatQueue) remove(w *queueWaiter) bool {
+	for i, waiter := range q.waiters {
+		if waiter == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			q.notifyMoved()
+			return true
+		}
+	}
+	return false
+}
+
+// positionOf returns w's 1-based position, or 0 if it isn't queued. The caller holds mu.
+func (q *chatQueue) positionOf(w *queueWaiter) int {
+	for i, waiter := range q.waiters {
+		if waiter == w {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// notifyMoved tells every waiter its position may have changed. The caller holds mu.
+func (q *chatQueue) notifyMoved() {
+	for _, w := range q.waiters {
+		select {
+		case w.moved <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Queued returns the number of chats waiting for a slot
+func (q *chatQueue) Queued() int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiters)
+}
@@ -0,0 +1,113 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// acquireAsync acquires a slot in the background, reporting positions and the result on channels
+func acquireAsync(ctx context.Context, q *chatQueue) (<-chan int, <-chan func()) {
+	positions := make(chan int, 10)
+	acquired := make(chan func(), 1)
+	go func() {
+		release, err := q.Acquire(ctx, func(position int) { positions <- position })
+		if err == nil {
+			acquired <- release
+		}
+		close(acquired)
+	}()
+	return positions, acquired
+}
+
+func expectPosition(t *testing.T, positions <-chan int, want int) {
+	t.Helper()
+	select {
+	case got := <-positions:
+		if got != want {
+			t.Fatalf("expected position %d, got %d", want, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for position %d", want)
+	}
+}
+
+func expectAcquired(t *testing.T, acquired <-chan func()) func() {
+	t.Helper()
+	select {
+	case release, ok := <-acquired:
+		if !ok {
+			t.Fatal("expected a slot, acquire failed")
+		}
+		return release
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a slot")
+		return nil
+	}
+}
+
+func TestChatQueue_QueuesInOrderAndRejectsWhenFull(t *testing.T) {
+	q := newChatQueue(1, 2)
+	ctx := context.Background()
+
+	release, err := q.Acquire(ctx, func(int) { t.Error("first chat should not be queued") })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	secondPositions, second := acquireAsync(ctx, q)
+	expectPosition(t, secondPositions, 1)
+	thirdPositions, third := acquireAsync(ctx, q)
+	expectPosition(t, thirdPositions, 2)
+
+	if _, err := q.Acquire(ctx, func(int) {}); !errors.Is(err, errServerBusy) {
+		t.Fatalf("expected busy error when the queue is full, got %v", err)
+	}
+	if got := q.Queued(); got != 2 {
+		t.Errorf("expected 2 queued chats, got %d", got)
+	}
+
+	release()
+	releaseSecond := expectAcquired(t, second)
+	expectPosition(t, thirdPositions, 1)
+
+	releaseSecond()
+	expectAcquired(t, third)()
+
+	if got := q.Queued(); got != 0 {
+		t.Errorf("expected empty queue, got %d", got)
+	}
+}
+
+func TestChatQueue_CanceledWaiterLeavesQueue(t *testing.T) {
+	q := newChatQueue(1, 5)
+	release, _ := q.Acquire(context.Background(), func(int) {})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstPositions, first := acquireAsync(ctx, q)
+	expectPosition(t, firstPositions, 1)
+	secondPositions, second := acquireAsync(context.Background(), q)
+	expectPosition(t, secondPositions, 2)
+
+	cancel()
+	if _, ok := <-first; ok {
+		t.Fatal("expected canceled chat not to get a slot")
+	}
+	expectPosition(t, secondPositions, 1)
+
+	release()
+	expectAcquired(t, second)()
+}
+
+func TestChatQueue_NilIsUnlimited(t *testing.T) {
+	var q *chatQueue
+	for range 3 {
+		if _, err := q.Acquire(context.Background(), func(int) { t.Error("unexpected queueing") }); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if newChatQueue(0, 5) != nil {
+		t.Error("expected no queue when max_concurrent is 0")
+	}
+}
@@ -0,0 +1,110 @@
+package daemon
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket that refills continuously at a fixed rate
+type rateLimiter struct {
+	mu       sync.Mutex
+	rate     float64 // Tokens added per second
+	capacity float64
+	tokens   float64
+	last     time.Time
+	now      func() time.Time
+}
+
+// newRateLimiter creates a limiter allowing perMinute requests per minute with the given burst size.
+// A burst of 0 defaults to perMinute. Returns nil when perMinute is 0 (unlimited).
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = perMinute
+	}
+	return &rateLimiter{
+		rate:     float64(perMinute) / 60,
+		capacity: float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+		now:      time.Now,
+	}
+}
+
+// Allow consumes a token if one is available.
+// Returns whether the request is allowed and the number of whole tokens remaining.
+// A nil limiter always allows and reports -1 remaining.
+func (l *rateLimiter) Allow() (bool, int) {
+	if l == nil {
+		return true, -1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.capacity {
+		l.tokens = l.capacity
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false, 0
+	}
+	l.tokens--
+	return true, int(l.tokens)
+}
+
+// Remaining returns the number of whole tokens currently available without consuming one
+func (l *rateLimiter) Remaining() int {
+	if l == nil {
+		return -1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tokens := l.tokens + l.now().Sub(l.last).Seconds()*l.rate
+	if tokens > l.capacity {
+		tokens = l.capacity
+	}
+	return int(tokens)
+}
+
+// full reports whether the bucket has refilled completely, making it no different from a new one
+func (l *rateLimiter) full() bool {
+	return l.Remaining() >= int(l.capacity)
+}
+
+// rateLimiters charges each request to several limiters, e.g. a connection's and its session's
+type rateLimiters []*rateLimiter
+
+// Allow consumes a token from every limiter, provided each of them has one.
+// Returns whether the request is allowed and the fewest whole tokens any limiter has left.
+func (ls rateLimiters) Allow() (bool, int) {
+	for _, l := range ls {
+		if l.Remaining() == 0 {
+			return false, 0
+		}
+	}
+	for _, l := range ls {
+		if allowed, _ := l.Allow(); !allowed {
+			return false, 0
+		}
+	}
+	return true, ls.Remaining()
+}
+
+// Remaining returns the fewest whole tokens any limiter has left, or -1 if none of them limits
+func (ls rateLimiters) Remaining() int {
+	remaining := -1
+	for _, l := range ls {
+		if r := l.Remaining(); r >= 0 && (remaining < 0 || r < remaining) {
+			remaining = r
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,150 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marciniwanicki/craby/internal/agent"
+	"github.com/marciniwanicki/craby/internal/tools"
+)
+
+func newTestRateLimiter(perMinute, burst int, now *time.Time) *rateLimiter {
+	l := newRateLimiter(perMinute, burst)
+	l.now = func() time.Time { return *now }
+	l.last = *now
+	return l
+}
+
+func TestRateLimiter_Unlimited(t *testing.T) {
+	l := newRateLimiter(0, 0)
+	if l != nil {
+		t.Fatal("expected nil limiter for unlimited rate")
+	}
+
+	for i := 0; i < 100; i++ {
+		allowed, remaining := l.Allow()
+		if !allowed {
+			t.Fatal("expected unlimited limiter to always allow")
+		}
+		if remaining != -1 {
+			t.Errorf("expected remaining -1, got %d", remaining)
+		}
+	}
+}
+
+func TestRateLimiter_BurstThenReject(t *testing.T) {
+	now := time.Now()
+	l := newTestRateLimiter(60, 3, &now)
+
+	for i := 0; i < 3; i++ {
+		allowed, remaining := l.Allow()
+		if !allowed {
+			t.Fatalf("request %d should be allowed", i)
+		}
+		if remaining != 2-i {
+			t.Errorf("request %d: expected remaining %d, got %d", i, 2-i, remaining)
+		}
+	}
+
+	if allowed, _ := l.Allow(); allowed {
+		t.Error("expected request beyond burst to be rejected")
+	}
+}
+
+func TestRateLimiter_Refill(t *testing.T) {
+	now := time.Now()
+	l := newTestRateLimiter(60, 1, &now)
+
+	if allowed, _ := l.Allow(); !allowed {
+		t.Fatal("first request should be allowed")
+	}
+	if allowed, _ := l.Allow(); allowed {
+		t.Fatal("second request should be rejected")
+	}
+
+	// 60 per minute refills one token per second
+	now = now.Add(time.Second)
+	if allowed, _ := l.Allow(); !allowed {
+		t.Error("expected request to be allowed after refill")
+	}
+
+	// Refill never exceeds capacity
+	now = now.Add(time.Hour)
+	if got := l.Remaining(); got != 1 {
+		t.Errorf("expected remaining capped at 1, got %d", got)
+	}
+}
+
+func TestRateLimiter_BurstDefaultsToRate(t *testing.T) {
+	l := newRateLimiter(10, 0)
+	if got := l.Remaining(); got != 10 {
+		t.Errorf("expected burst to default to 10, got %d", got)
+	}
+}
+
+func TestHandler_LimiterForSession(t *testing.T) {
+	registry := tools.NewRegistry()
+	agnt := agent.NewAgent(nil, registry, testLogger(), "system prompt")
+	handler := NewHandler(agnt, testLogger())
+	handler.SetRateLimit(10, 2)
+
+	connLimiter := newRateLimiter(10, 2)
+
+	// Without a session ID the connection limiter is used
+	if got := handler.limiterFor("", connLimiter); len(got) != 1 || got[0] != connLimiter {
+		t.Error("expected connection limiter when no session ID is set")
+	}
+
+	// The same session shares a limiter across connections, each connection's is charged too
+	first := handler.limiterFor("session-1", connLimiter)
+	second := handler.limiterFor("session-1", newRateLimiter(10, 2))
+	if len(first) != 2 || first[0] != connLimiter || first[1] == nil || first[1] != second[1] {
+		t.Error("expected the same limiter for the same session, next to the connection's")
+	}
+	first.Allow() // Keeps the session from being idle
+
+	if other := handler.limiterFor("session-2", connLimiter); other[1] == first[1] {
+		t.Error("expected different sessions to have different limiters")
+	}
+
+	// Sessions with a full bucket are dropped when another one starts, busy ones stay
+	handler.limiterFor("session-3", connLimiter)
+	if _, ok := handler.sessionLimiters["session-2"]; ok {
+		t.Error("expected the idle session's limiter dropped")
+	}
+	if _, ok := handler.sessionLimiters["session-1"]; !ok {
+		t.Error("expected the busy session's limiter kept")
+	}
+
+	// Reloading the same limits keeps the buckets, new limits start over
+	handler.SetRateLimit(10, 2)
+	if got := handler.limiterFor("session-1", connLimiter); got[1] != first[1] {
+		t.Error("expected the session's limiter kept on reload")
+	}
+	handler.SetRateLimit(20, 2)
+	if got := handler.limiterFor("session-1", connLimiter); got[1] == first[1] {
+		t.Error("expected a new limiter for new limits")
+	}
+}
+
+func TestRateLimiters_Allow(t *testing.T) {
+	now := time.Now()
+	conn := newTestRateLimiter(60, 2, &now)
+	session := newTestRateLimiter(60, 1, &now)
+	limiters := rateLimiters{conn, session}
+
+	if allowed, remaining := limiters.Allow(); !allowed || remaining != 0 {
+		t.Fatalf("expected allowed with 0 remaining, got %v, %d", allowed, remaining)
+	}
+	// The session is out of tokens, so the connection isn't charged either
+	if allowed, _ := limiters.Allow(); allowed {
+		t.Error("expected the request rejected")
+	}
+	if got := conn.Remaining(); got != 1 {
+		t.Errorf("expected the connection charged once, got %d remaining", got)
+	}
+
+	if got := (rateLimiters{nil, nil}).Remaining(); got != -1 {
+		t.Errorf("expected -1 without limits, got %d", got)
+	}
+}
@@ -3,11 +3,20 @@ package daemon
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"maps"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -16,27 +25,43 @@ import (
 	"github.com/marciniwanicki/craby/internal/api"
 	"github.com/marciniwanicki/craby/internal/config"
 	"github.com/marciniwanicki/craby/internal/tools"
+	"github.com/marciniwanicki/craby/internal/version"
 	"github.com/rs/zerolog"
 	"google.golang.org/protobuf/proto"
 )
 
-const Version = "0.1.0"
+// shutdownTimeout bounds how long shutdown waits for active chats to finish their current generation
+const shutdownTimeout = 30 * time.Second
+
+// healthzTimeout bounds probing Ollama for /healthz, so monitors get an answer while it hangs
+const healthzTimeout = 5 * time.Second
 
 // Server represents the daemon server
 type Server struct {
-	port      int
-	ollama    *OllamaClient
-	handler   *Handler
-	registry  *tools.Registry
-	settings  *config.Settings
-	logger    zerolog.Logger
-	logCloser io.Closer
-	upgrader  websocket.Upgrader
-	quit      chan os.Signal
-}
-
-// NewServer creates a new daemon server
-func NewServer(port int, ollamaURL, model string) *Server {
+	addr          string // Listen address (host:port or unix:/path/to/socket)
+	ollama        *OllamaClient
+	handler       *Handler
+	toolsetMu     sync.RWMutex
+	toolset       *toolset // Replaced on SIGHUP
+	logger        zerolog.Logger
+	logCloser     io.Closer
+	llmCallLogger *config.StepLogger
+	schemaCache   *config.SchemaCache
+	auditLog      *config.CommandAuditLog
+	toolStats     *tools.ToolStats // Calls, errors and latency per tool, kept across reloads
+	upgrader      websocket.Upgrader
+	quit          chan os.Signal
+	startTime     time.Time
+	keepWarm      time.Duration // Interval between model warm-up pings (0 = disabled)
+	warmup        bool          // Load the model before accepting connections
+	openAICompat  bool          // Serve the OpenAI-compatible /v1 endpoints
+	readyOut      io.Writer     // Receives a READY line once connections are accepted (nil = disabled)
+	once          bool          // Serve a single chat, then shut down
+	onceClaimed   atomic.Bool   // Set once the single chat of a --once daemon has started
+}
+
+// NewServer creates a new daemon server listening on addr (host:port or unix:/path/to/socket)
+func NewServer(addr string, ollamaURL, model string) *Server {
 	// Set up rolling file logger
 	logCfg := config.DefaultLogConfig()
 	logger, logCloser, err := config.SetupLogger(logCfg)
@@ -82,78 +107,195 @@ func NewServer(port int, ollamaURL, model string) *Server {
 	}
 	logger.Info().Msg("loaded pipeline templates")
 
-	// Build system prompt from templates (for context display)
-	systemPrompt := pipelineTemplates.Identity + "\n\n" + pipelineTemplates.User
-
 	// Create Ollama client
 	ollama := NewOllamaClient(ollamaURL, model, llmCallLogger)
+	ollama.SetKeepAlive(settings.Ollama.KeepAlive)
+	ollama.SetFallbackModels(settings.Ollama.FallbackModels)
+	ollama.SetEmbeddingModel(settings.Ollama.EmbeddingModel)
+	ollama.SetGenerationTimeout(time.Duration(settings.Ollama.GenerationTimeoutMinutes) * time.Minute)
+	if headers := settings.Ollama.ResolvedHeaders(); len(headers) > 0 {
+		ollama.SetHeaders(headers)
+		if isPlaintextRemote(ollama.BaseURL()) {
+			logger.Warn().Str("ollama_url", ollama.BaseURL()).Msg("sending Ollama credentials over plain http to a remote host, consider https")
+		}
+	}
 
 	// Load external tools
-	externalTools, toolStatuses, err := config.LoadAndCheckTools()
+	externalTools, toolStatuses, err := config.LoadAndCheckTools(settings.Tools.External.ToolStatusCache())
 	if err != nil {
 		logger.Warn().Err(err).Msg("failed to load external tools")
 	} else {
-		for name, status := range toolStatuses {
-			if status.Available {
-				logger.Info().Str("tool", name).Msg("external tool available")
-			} else {
-				logEvent := logger.Warn().
-					Str("tool", name).
-					Str("reason", status.Message).
-					Int("exit_code", status.ExitCode)
-				if status.Stdout != "" {
-					logEvent = logEvent.Str("stdout", status.Stdout)
-				}
-				if status.Stderr != "" {
-					logEvent = logEvent.Str("stderr", status.Stderr)
-				}
-				logEvent.Msg("external tool not available")
-			}
-		}
+		logToolStatuses(logger, toolStatuses)
 	}
 
-	// Create tool registry
-	registry := tools.NewRegistry()
-
 	// Create schema cache for dynamic tool discovery
 	schemaCache, err := config.NewSchemaCache()
 	if err != nil {
 		logger.Warn().Err(err).Msg("failed to create schema cache")
 	}
 
-	// Register discovery tools (always available)
-	listCmdTool := tools.NewListCommandsTool(settings, externalTools, schemaCache)
-	registry.Register(listCmdTool)
-	logger.Info().Msg("registered list_available_commands tool")
+	// Audit every executed command to a separate append-only log
+	auditLog, err := config.NewCommandAuditLog(logCfg)
+	if err != nil {
+		logger.Warn().Err(err).Msg("failed to open command audit log")
+	}
+
+	s := &Server{
+		addr:          addr,
+		startTime:     time.Now(),
+		ollama:        ollama,
+		logger:        logger,
+		logCloser:     logCloser,
+		llmCallLogger: llmCallLogger,
+		schemaCache:   schemaCache,
+		auditLog:      auditLog,
+		toolStats:     tools.NewToolStats(),
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				return true // Allow local connections
+			},
+		},
+	}
+
+	ts := s.buildToolset(settings, externalTools, pipelineTemplates)
+	s.toolset = ts
+
+	// Create handler with pipeline
+	handler := NewPipelineHandler(ts.pipeline, ts.systemPrompt, logger)
+	handler.SetRateLimit(settings.Daemon.RateLimit.RequestsPerMinute, settings.Daemon.RateLimit.Burst)
+	handler.SetChatQueue(settings.Daemon.Queue.MaxConcurrent, settings.Daemon.Queue.MaxQueued)
+	handler.SetApprovalTimeout(time.Duration(settings.Daemon.Queue.ApprovalTimeoutSeconds) * time.Second)
+	handler.SetHistoryLimit(settings.Daemon.History.MaxTokens, settings.Daemon.History.Strategy, ollama)
+	handler.SetContextTurns(settings.Daemon.History.ContextTurns)
+	handler.SetPromptWrap(settings.Daemon.Prompt.Prefix, settings.Daemon.Prompt.Suffix)
+	handler.SetKeepalive(
+		time.Duration(settings.Daemon.Connection.PingIntervalSeconds)*time.Second,
+		time.Duration(settings.Daemon.Connection.IdleTimeoutMinutes)*time.Minute,
+	)
+	handler.SetMaxMessageSize(settings.Daemon.Connection.MaxMessageBytes)
+	handler.SetCommandAudit(auditLog)
+	s.handler = handler
+
+	return s
+}
+
+// toolset is everything built from settings and tool definitions. A reload replaces it as a whole.
+type toolset struct {
+	settings      *config.Settings
+	externalTools []*config.ExternalTool
+	registry      *tools.Registry
+	shellTool     *tools.ShellTool
+	schemaTool    *tools.GetCommandSchemaTool
+	execLimiter   *tools.ExecLimiter // Shell and discovery commands
+	toolLimiter   *tools.ExecLimiter // Tool calls of any tool
+	pipeline      *agent.Pipeline
+	systemPrompt  string
+	toolsPrompt   string // External tools section of the system prompt
+}
+
+// buildToolset creates the tool registry and the pipeline using it
+func (s *Server) buildToolset(settings *config.Settings, externalTools []*config.ExternalTool, pipelineTemplates *config.PipelineTemplates) *toolset {
+	logger := s.logger
+
+	// Build system prompt from templates (for context display)
+	systemPrompt := pipelineTemplates.Identity + "\n\n" + pipelineTemplates.User
+
+	execLimiter, toolLimiter := s.limitersFor(settings)
+
+	// Create tool registry
+	registry := tools.NewRegistry()
+	registry.Use(tools.LimitConcurrency(toolLimiter))
+	// Inside the limiter, so latency leaves out the time spent waiting for a slot
+	registry.Use(s.toolStats.Middleware())
+
+	// tools.builtin selects which built-in tools are registered, an unknown name is likely a typo
+	builtin := settings.Tools.BuiltinEnabled
+	if unknown := settings.Tools.UnknownBuiltins(); len(unknown) > 0 {
+		logger.Warn().Strs("unknown", unknown).Strs("known", config.BuiltinToolNames).
+			Msg("ignoring unknown tools in tools.builtin")
+	}
+
+	// Register discovery tools
+	if builtin("list_available_commands") {
+		listCmdTool := tools.NewListCommandsTool(settings, externalTools, s.schemaCache)
+		registry.Register(listCmdTool)
+		logger.Info().Msg("registered list_available_commands tool")
+	}
+
+	var getSchemaTool *tools.GetCommandSchemaTool
+	if builtin("get_command_schema") {
+		getSchemaTool = tools.NewGetCommandSchemaTool(settings, s.schemaCache, s.ollama)
+		getSchemaTool.SetExecLimiter(execLimiter)
+		getSchemaTool.SetExternalTools(externalTools)
+		registry.Register(getSchemaTool)
+		logger.Info().Msg("registered get_command_schema tool")
+	}
 
-	getSchemaTool := tools.NewGetCommandSchemaTool(settings, schemaCache, ollama)
-	registry.Register(getSchemaTool)
-	logger.Info().Msg("registered get_command_schema tool")
+	// Report a missing shell once here, the tools return the same error for every command
+	if _, err := settings.Tools.Shell.ResolveShell(); err != nil {
+		logger.Error().Err(err).Msg("shell is unavailable, shell commands and command discovery will fail")
+	}
+
+	// Register calculator and clock (the model shouldn't do arithmetic or guess the date)
+	if builtin("calculator") {
+		registry.Register(tools.NewCalcTool())
+		logger.Info().Msg("registered calculator tool")
+	}
+	if builtin("current_time") {
+		registry.Register(tools.NewTimeTool())
+		logger.Info().Msg("registered current_time tool")
+	}
 
 	// Register shell tool if enabled
 	var shellTool *tools.ShellTool
-	if settings.Tools.Shell.Enabled {
+	if settings.Tools.Shell.Enabled && builtin("shell") {
 		if len(externalTools) > 0 {
 			shellTool = tools.NewShellToolWithExternalTools(settings, externalTools)
 		} else {
 			shellTool = tools.NewShellTool(settings)
 		}
+		shellTool.SetExecLimiter(execLimiter)
 		registry.Register(shellTool)
 		logger.Info().Msg("registered shell tool")
 	}
 
 	// Register write tool if enabled
-	if settings.Tools.Write.Enabled {
+	if settings.Tools.Write.Enabled && builtin("write") {
 		writeTool := tools.NewWriteTool(settings)
+		if settings.Tools.Write.RequireApproval {
+			// Chats whose client approves plans ask it through their hooks, others can't write
+			writeTool.SetConfirmer(func(path, description string) bool { return false })
+		}
 		registry.Register(writeTool)
 		logger.Info().Msg("registered write tool")
 	}
 
+	// Register read_file tool if enabled
+	if settings.Tools.Read.Enabled && builtin("read_file") {
+		readTool := tools.NewReadFileTool(settings)
+		registry.Register(readTool)
+		logger.Info().Msg("registered read_file tool")
+	}
+
+	// Register search_files tool if enabled, it reads the same files as read_file
+	if settings.Tools.Search.Enabled && settings.Tools.Read.Enabled && builtin("search_files") {
+		registry.Register(tools.NewSearchTool(settings))
+		logger.Info().Msg("registered search_files tool")
+	}
+
+	// Register http_fetch tool if enabled
+	if settings.Tools.Fetch.Enabled && builtin("http_fetch") {
+		fetchTool := tools.NewHTTPFetchTool(settings)
+		registry.Register(fetchTool)
+		logger.Info().Msg("registered http_fetch tool")
+	}
+
 	// Add external tools info to system prompt
+	var toolsPrompt string
 	if shellTool != nil {
-		externalToolsPrompt := shellTool.GetExternalToolsPrompt()
-		if externalToolsPrompt != "" {
-			systemPrompt += "\n" + externalToolsPrompt
+		toolsPrompt = shellTool.GetExternalToolsPrompt()
+		if toolsPrompt != "" {
+			systemPrompt += "\n" + toolsPrompt
 		}
 	}
 
@@ -164,34 +306,356 @@ func NewServer(port int, ollamaURL, model string) *Server {
 	}
 
 	// Create pipeline with templates and external tools
-	pipeline := agent.NewPipelineWithExternalTools(ollama, registry, logger, agent.PipelineTemplates{
+	pipeline := agent.NewPipelineWithExternalTools(s.ollama, registry, logger, agent.PipelineTemplates{
 		Planning:  pipelineTemplates.Planning,
 		Synthesis: pipelineTemplates.Synthesis,
 		Identity:  pipelineTemplates.Identity,
 		User:      pipelineTemplates.User,
 	}, externalToolNames)
 
+	pipeline.SetRetainedResults(settings.Tools.Discovery.RetainedSteps)
+	pipeline.SetRedactor(tools.NewRedactor(settings.Redaction, externalTools))
+
 	// Set step logger for debugging
-	if llmCallLogger != nil {
-		pipeline.SetStepLogger(&stepLoggerAdapter{logger: llmCallLogger})
+	if s.llmCallLogger != nil {
+		pipeline.SetStepLogger(&stepLoggerAdapter{logger: s.llmCallLogger})
 	}
 
-	// Create handler with pipeline
-	handler := NewPipelineHandler(pipeline, systemPrompt, shellTool, logger)
-
-	return &Server{
-		port:      port,
-		ollama:    ollama,
-		handler:   handler,
-		registry:  registry,
-		settings:  settings,
-		logger:    logger,
-		logCloser: logCloser,
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true // Allow local connections
-			},
-		},
+	return &toolset{
+		settings:      settings,
+		externalTools: externalTools,
+		registry:      registry,
+		shellTool:     shellTool,
+		schemaTool:    getSchemaTool,
+		execLimiter:   execLimiter,
+		toolLimiter:   toolLimiter,
+		pipeline:      pipeline,
+		systemPrompt:  systemPrompt,
+		toolsPrompt:   toolsPrompt,
+	}
+}
+
+// limitersFor returns the concurrency limiters for settings. The current toolset's are kept while
+// their limits are unchanged, so calls already running keep counting against them after a reload.
+func (s *Server) limitersFor(settings *config.Settings) (execLimiter, toolLimiter *tools.ExecLimiter) {
+	shell, toolsSettings := settings.Tools.Shell, settings.Tools
+	if current := s.currentToolset(); current != nil {
+		old := current.settings.Tools
+		if old.Shell.MaxConcurrent == shell.MaxConcurrent && old.Shell.QueueTimeoutSeconds == shell.QueueTimeoutSeconds {
+			execLimiter = current.execLimiter
+		}
+		if old.MaxConcurrent == toolsSettings.MaxConcurrent && old.QueueTimeoutSeconds == toolsSettings.QueueTimeoutSeconds {
+			toolLimiter = current.toolLimiter
+		}
+	}
+	if execLimiter == nil {
+		// Commands from the shell tool and discovery share one concurrency budget
+		execLimiter = tools.NewExecLimiter(shell.MaxConcurrent, time.Duration(shell.QueueTimeoutSeconds)*time.Second)
+	}
+	if toolLimiter == nil {
+		// Tool calls of every tool share another, so bursts across sessions can't overwhelm the machine
+		toolLimiter = tools.NewToolCallLimiter(toolsSettings.MaxConcurrent, time.Duration(toolsSettings.QueueTimeoutSeconds)*time.Second)
+	}
+	return execLimiter, toolLimiter
+}
+
+// currentToolset returns the toolset in use
+func (s *Server) currentToolset() *toolset {
+	s.toolsetMu.RLock()
+	defer s.toolsetMu.RUnlock()
+	return s.toolset
+}
+
+// reload re-reads settings, templates and external tools and swaps in a freshly built toolset.
+// Chats already running finish with the old one. On any load error the running configuration is kept.
+func (s *Server) reload() {
+	s.logger.Info().Msg("reloading configuration")
+
+	settings, err := config.Load()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("failed to reload settings, keeping current configuration")
+		return
+	}
+
+	pipelineTemplates, err := config.LoadPipelineTemplatesWithSettings(settings)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("failed to reload pipeline templates, keeping current configuration")
+		return
+	}
+
+	externalTools, toolStatuses, err := config.LoadAndCheckTools(settings.Tools.External.ToolStatusCache())
+	if err != nil {
+		s.logger.Error().Err(err).Msg("failed to reload external tools, keeping current configuration")
+		return
+	}
+	logToolStatuses(s.logger, toolStatuses)
+
+	ts := s.buildToolset(settings, externalTools, pipelineTemplates)
+
+	s.toolsetMu.Lock()
+	old := s.toolset
+	s.toolset = ts
+	s.toolsetMu.Unlock()
+
+	s.handler.SetTools(ts.pipeline, ts.systemPrompt)
+	s.handler.SetRateLimit(settings.Daemon.RateLimit.RequestsPerMinute, settings.Daemon.RateLimit.Burst)
+	s.handler.SetChatQueue(settings.Daemon.Queue.MaxConcurrent, settings.Daemon.Queue.MaxQueued)
+	s.handler.SetApprovalTimeout(time.Duration(settings.Daemon.Queue.ApprovalTimeoutSeconds) * time.Second)
+	s.handler.SetPromptWrap(settings.Daemon.Prompt.Prefix, settings.Daemon.Prompt.Suffix)
+
+	logToolsetChanges(s.logger, old, ts)
+	s.logger.Info().Msg("configuration reloaded")
+}
+
+// logToolsetChanges logs what a reload added or removed
+func logToolsetChanges(logger zerolog.Logger, old, updated *toolset) {
+	logDiff := func(what string, before, after []string) {
+		added, removed := diffNames(before, after)
+		if len(added) > 0 || len(removed) > 0 {
+			logger.Info().Strs("added", added).Strs("removed", removed).Msg(what + " changed")
+		}
+	}
+
+	logDiff("shell allowlist", old.settings.Tools.Shell.Allowlist, updated.settings.Tools.Shell.Allowlist)
+	logDiff("shell denylist", old.settings.Tools.Shell.Denylist, updated.settings.Tools.Shell.Denylist)
+	logDiff("external tools", externalToolNames(old.externalTools), externalToolNames(updated.externalTools))
+	logDiff("registered tools", registryNames(old.registry), registryNames(updated.registry))
+}
+
+// diffNames returns names only in after (added) and names only in before (removed)
+func diffNames(before, after []string) (added, removed []string) {
+	for _, name := range after {
+		if !slices.Contains(before, name) {
+			added = append(added, name)
+		}
+	}
+	for _, name := range before {
+		if !slices.Contains(after, name) {
+			removed = append(removed, name)
+		}
+	}
+	return added, removed
+}
+
+func externalToolNames(externalTools []*config.ExternalTool) []string {
+	names := make([]string, 0, len(externalTools))
+	for _, tool := range externalTools {
+		names = append(names, tool.Name)
+	}
+	return names
+}
+
+func registryNames(registry *tools.Registry) []string {
+	var names []string
+	for _, tool := range registry.List() {
+		names = append(names, tool.Name())
+	}
+	slices.Sort(names)
+	return names
+}
+
+// logToolStatuses logs the availability of each external tool
+func logToolStatuses(logger zerolog.Logger, toolStatuses map[string]config.ToolStatus) {
+	for name, status := range toolStatuses {
+		if status.Available {
+			logger.Info().Str("tool", name).Bool("cached", status.Cached).Msg("external tool available")
+		} else {
+			logEvent := logger.Warn().
+				Str("tool", name).
+				Str("reason", status.Message).
+				Int("exit_code", status.ExitCode).
+				Bool("cached", status.Cached)
+			if status.Stdout != "" {
+				logEvent = logEvent.Str("stdout", status.Stdout)
+			}
+			if status.Stderr != "" {
+				logEvent = logEvent.Str("stderr", status.Stderr)
+			}
+			if status.Kept {
+				logEvent.Msg("external tool check inconclusive, keeping it unverified")
+				continue
+			}
+			logEvent.Msg("external tool not available")
+		}
+	}
+}
+
+// isPlaintextRemote reports whether rawURL uses plain http to a host other than the local machine
+func isPlaintextRemote(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "http" {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || !ip.IsLoopback()
+}
+
+// unixSocketPrefix marks a listen address as a unix socket path, e.g. "unix:/tmp/craby.sock"
+const unixSocketPrefix = "unix:"
+
+// listen binds addr, either host:port or a unix socket path after unixSocketPrefix.
+// A socket file left behind by a daemon that didn't exit cleanly is replaced.
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, unixSocketPrefix)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Whoever can connect can run shell commands, keep the socket to the owner
+	if err := os.Chmod(path, 0600); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+	return ln, nil
+}
+
+// removeStaleSocket removes a socket file nothing listens on anymore
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("another daemon is listening on %s", path)
+	}
+	return os.Remove(path)
+}
+
+// isLoopbackAddr reports whether a listen address only accepts connections from the local machine
+func isLoopbackAddr(addr string) bool {
+	if strings.HasPrefix(addr, unixSocketPrefix) {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// SetKeepAlive overrides how long Ollama keeps the model loaded after each request
+func (s *Server) SetKeepAlive(keepAlive string) {
+	s.ollama.SetKeepAlive(keepAlive)
+}
+
+// SetGenerationTimeout overrides how long a single model request may take (0 = unbounded)
+func (s *Server) SetGenerationTimeout(timeout time.Duration) {
+	s.ollama.SetGenerationTimeout(timeout)
+}
+
+// SetFallbackModels overrides the models tried when the primary model is unavailable
+func (s *Server) SetFallbackModels(models []string) {
+	s.ollama.SetFallbackModels(models)
+}
+
+// SetEmbeddingModel overrides the model used for embeddings
+func (s *Server) SetEmbeddingModel(model string) {
+	s.ollama.SetEmbeddingModel(model)
+}
+
+// SetContextTurns overrides how many earlier turns are sent with each message (-1 = unlimited)
+func (s *Server) SetContextTurns(turns int) {
+	s.handler.SetContextTurns(turns)
+}
+
+// SetKeepWarm enables periodic pings that keep the model loaded in Ollama.
+// An interval of 0 disables them.
+func (s *Server) SetKeepWarm(interval time.Duration) {
+	s.keepWarm = interval
+}
+
+// SetWarmup makes Run load the model into Ollama before it starts accepting connections
+func (s *Server) SetWarmup(warmup bool) {
+	s.warmup = warmup
+}
+
+// SetOpenAICompat makes Run also serve /v1/chat/completions and /v1/models,
+// so tools speaking the OpenAI API can chat through the daemon
+func (s *Server) SetOpenAICompat(enabled bool) {
+	s.openAICompat = enabled
+}
+
+// SetReadyNotify makes Run write "READY <addr>" and a newline to w once the listener is bound and
+// any warm-up is done, so scripts and the autostart can wait for it instead of polling
+func (s *Server) SetReadyNotify(w io.Writer) {
+	s.readyOut = w
+}
+
+// SetOnce makes the daemon serve a single chat, over websocket or the OpenAI API, and shut down
+// once it finishes. Later chats are refused while it shuts down.
+func (s *Server) SetOnce(once bool) {
+	s.once = once
+}
+
+// notifyReady reports that the daemon accepts connections on addr
+func (s *Server) notifyReady(addr string) {
+	if s.readyOut == nil {
+		return
+	}
+	if _, err := fmt.Fprintf(s.readyOut, "READY %s\n", addr); err != nil {
+		s.logger.Warn().Err(err).Msg("failed to send ready notification")
+	}
+}
+
+// warmUp loads the model so the first chat doesn't pay the load time.
+// Failures only warn, since the model can still load on the first real request.
+func (s *Server) warmUp() {
+	ctx, cancel := context.WithTimeout(context.Background(), ollamaResponseHeaderTimeout)
+	defer cancel()
+
+	start := time.Now()
+	s.logger.Info().Str("model", s.ollama.Model()).Msg("warming up model")
+	if err := s.ollama.Warm(ctx); err != nil {
+		s.logger.Warn().Err(err).Dur("duration", time.Since(start)).Msg("model warm-up failed, it will load on first request")
+		return
+	}
+	s.logger.Info().Str("model", s.ollama.Model()).Dur("duration", time.Since(start)).Msg("model warmed up")
+}
+
+// runKeepWarm pings Ollama at the keep-warm interval until done is closed
+func (s *Server) runKeepWarm(done <-chan bool) {
+	ticker := time.NewTicker(s.keepWarm)
+	defer ticker.Stop()
+
+	warm := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		if err := s.ollama.Warm(ctx); err != nil {
+			s.logger.Warn().Err(err).Msg("failed to keep model warm")
+			return
+		}
+		s.logger.Debug().Str("model", s.ollama.Model()).Msg("model kept warm")
+	}
+
+	warm()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			warm()
+		}
 	}
 }
 
@@ -201,19 +665,31 @@ func (s *Server) Run() error {
 
 	// HTTP endpoints
 	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/healthz", s.handleHealthz)
 	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/version", s.handleVersion)
 	mux.HandleFunc("/shutdown", s.handleShutdown)
 	mux.HandleFunc("/history", s.handleHistory)
 	mux.HandleFunc("/context", s.handleContext)
 	mux.HandleFunc("/tool/run", s.handleToolRun)
 	mux.HandleFunc("/tool/list", s.handleToolList)
+	mux.HandleFunc("/tool/prompt", s.handleToolPrompt)
+	mux.HandleFunc("/embed", s.handleEmbed)
+	mux.HandleFunc("/models/running", s.handleRunningModels)
+	mux.HandleFunc("/model", s.handleModel)
+	mux.HandleFunc("/cancel", s.handleCancel)
 
 	// WebSocket endpoints
-	mux.HandleFunc("/ws/chat", s.handleWSChat)
+	mux.HandleFunc("/ws/chat", s.singleChat(s.handleWSChat))
+
+	if s.openAICompat {
+		mux.HandleFunc("/v1/chat/completions", s.singleChat(s.handler.HandleChatCompletions))
+		mux.HandleFunc("/v1/models", s.handleOpenAIModels)
+	}
 
 	server := &http.Server{
-		Addr:              fmt.Sprintf(":%d", s.port),
-		Handler:           mux,
+		Addr:              s.addr,
+		Handler:           accessLog(s.logger, mux),
 		ReadHeaderTimeout: 10 * time.Second,
 	}
 
@@ -226,21 +702,65 @@ func (s *Server) Run() error {
 		<-s.quit
 		s.logger.Info().Msg("shutting down server...")
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 		defer cancel()
 
+		// Stop accepting connections first. Chat websockets are hijacked, so Shutdown doesn't wait for them.
 		if err := server.Shutdown(ctx); err != nil {
 			s.logger.Error().Err(err).Msg("server shutdown error")
 		}
+		if err := s.handler.Drain(ctx); err != nil {
+			s.logger.Warn().Err(err).Msg("active chats did not finish in time, aborting them")
+		}
 		close(done)
 	}()
 
+	// Reload configuration on SIGHUP without dropping connections
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go func() {
+		for {
+			select {
+			case <-hup:
+				s.reload()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if s.warmup {
+		s.warmUp()
+	}
+
+	if s.keepWarm > 0 {
+		go s.runKeepWarm(done)
+	}
+
+	if !isLoopbackAddr(s.addr) {
+		s.logger.Warn().Str("addr", s.addr).Msg("daemon is reachable from other machines; it can run shell commands, bind to 127.0.0.1 unless that is intended")
+	}
+
 	s.logger.Info().
-		Int("port", s.port).
+		Str("addr", s.addr).
+		Str("version", version.String()).
 		Str("model", s.ollama.Model()).
+		Dur("keep_warm", s.keepWarm).
+		Bool("once", s.once).
 		Msg("starting daemon server")
 
-	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+	ln, err := listen(s.addr)
+	if err != nil {
+		return err
+	}
+	readyAddr := ln.Addr().String()
+	if ln.Addr().Network() == "unix" {
+		readyAddr = "unix:" + readyAddr
+	}
+	s.notifyReady(readyAddr)
+
+	if err := server.Serve(ln); err != http.ErrServerClosed {
 		return err
 	}
 
@@ -251,6 +771,7 @@ func (s *Server) Run() error {
 	if s.logCloser != nil {
 		_ = s.logCloser.Close()
 	}
+	_ = s.auditLog.Close()
 
 	return nil
 }
@@ -260,16 +781,112 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte("OK"))
 }
 
+// healthCheck is the state of one subsystem in the /healthz report
+type healthCheck struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// healthReport is the /healthz response. Healthy is false when a chat couldn't be answered.
+type healthReport struct {
+	Healthy bool        `json:"healthy"`
+	HTTP    healthCheck `json:"http"`
+	Ollama  healthCheck `json:"ollama"`
+	Model   healthCheck `json:"model"`
+}
+
+// handleHealthz reports each subsystem as JSON, with 503 when Ollama or every model is unavailable.
+// /health stays a plain liveness check that doesn't depend on Ollama.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthzTimeout)
+	defer cancel()
+
+	report := healthReport{HTTP: healthCheck{OK: true}}
+	availability, err := s.ollama.ModelAvailability(ctx)
+	if err != nil {
+		report.Ollama.Detail = err.Error()
+		report.Model.Detail = "unknown, Ollama is unreachable"
+	} else {
+		report.Ollama = healthCheck{OK: true, Detail: s.ollama.BaseURL()}
+		report.Model = modelHealth(s.ollama.Models(), availability)
+	}
+	report.Healthy = report.HTTP.OK && report.Ollama.OK && report.Model.OK
+
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// modelHealth reports whether the primary model, or failing that a fallback, is pulled
+func modelHealth(models []string, availability map[string]bool) healthCheck {
+	primary := models[0]
+	if availability[primary] {
+		return healthCheck{OK: true, Detail: primary}
+	}
+	for _, fallback := range models[1:] {
+		if availability[fallback] {
+			return healthCheck{OK: true, Detail: fmt.Sprintf("%s is not pulled, fallback %s answers instead", primary, fallback)}
+		}
+	}
+	return healthCheck{Detail: fmt.Sprintf("%s is not pulled", primary)}
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	data, err := proto.Marshal(&api.VersionResponse{
+		Version:   version.Version,
+		Commit:    version.Commit,
+		BuildDate: version.Date,
+		Protocol:  version.Protocol,
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(data)
+}
+
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	healthy, _ := s.ollama.Health(ctx)
+	healthy, err := s.ollama.Health(ctx)
+	if err != nil {
+		s.logger.Debug().Err(err).Msg("ollama health check failed")
+	}
 
+	ts := s.currentToolset()
 	resp := &api.StatusResponse{
-		Healthy: healthy,
-		Model:   s.ollama.Model(),
-		Version: Version,
+		Healthy:           healthy,
+		Model:             s.ollama.Model(),
+		Version:           version.Version,
+		Commit:            version.Commit,
+		BuildDate:         version.Date,
+		UptimeSeconds:     int64(time.Since(s.startTime).Seconds()),
+		ActiveConnections: s.handler.ActiveConnections(),
+		ChatsServed:       s.handler.ChatsServed(),
+		ChatsQueued:       s.handler.ChatsQueued(),
+		OllamaUrl:         s.ollama.BaseURL(),
+		OllamaReachable:   healthy || errors.Is(err, ErrModelNotPulled),
+		ModelPresent:      healthy,
+		CommandsInFlight:  int32(ts.execLimiter.InFlight()), //nolint:gosec // G115: bounded by max_concurrent
+		ToolCallsInFlight: int32(ts.toolLimiter.InFlight()), //nolint:gosec // G115: bounded by tools.max_concurrent
+		ToolCallsWaiting:  int32(ts.toolLimiter.Waiting()),  //nolint:gosec // G115: bounded by the chats running
+		ToolCallCapacity:  int32(ts.toolLimiter.Capacity()), //nolint:gosec // G115: from settings
 	}
 
+	availability, err := s.ollama.ModelAvailability(ctx)
+	if err != nil {
+		s.logger.Debug().Err(err).Msg("failed to list Ollama models")
+	}
+	for _, model := range s.ollama.Models() {
+		resp.Models = append(resp.Models, &api.ModelStatus{Name: model, Available: availability[model]})
+	}
+	embedModel := s.ollama.EmbeddingModel()
+	resp.EmbeddingModel = &api.ModelStatus{Name: embedModel, Available: availability[embedModel]}
+	resp.ToolStats = toolStatsToProto(s.toolStats.Snapshot(), registryNames(ts.registry))
+
 	data, err := proto.Marshal(resp)
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
@@ -280,6 +897,33 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(data)
 }
 
+// toolStatsToProto converts tool statistics, adding registered tools that were never called
+// so unused tools show up too
+func toolStatsToProto(stats []tools.ToolStat, registered []string) []*api.ToolStats {
+	byName := make(map[string]tools.ToolStat, len(stats))
+	for _, stat := range stats {
+		byName[stat.Name] = stat
+	}
+	for _, name := range registered {
+		if _, ok := byName[name]; !ok {
+			byName[name] = tools.ToolStat{Name: name}
+		}
+	}
+
+	result := make([]*api.ToolStats, 0, len(byName))
+	for _, name := range slices.Sorted(maps.Keys(byName)) {
+		stat := byName[name]
+		result = append(result, &api.ToolStats{
+			Name:            stat.Name,
+			Calls:           stat.Calls,
+			Errors:          stat.Errors,
+			TotalDurationMs: stat.TotalDuration.Milliseconds(),
+			MaxDurationMs:   stat.MaxDuration.Milliseconds(),
+		})
+	}
+	return result
+}
+
 func (s *Server) handleWSChat(w http.ResponseWriter, r *http.Request) {
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -291,6 +935,33 @@ func (s *Server) handleWSChat(w http.ResponseWriter, r *http.Request) {
 	s.handler.HandleChat(conn)
 }
 
+// singleChat wraps a chat endpoint of a --once daemon: the first chat is served and then shuts the
+// daemon down, any chat arriving after it is refused
+func (s *Server) singleChat(next http.HandlerFunc) http.HandlerFunc {
+	if !s.once {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.onceClaimed.CompareAndSwap(false, true) {
+			http.Error(w, "daemon serves a single chat and is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		defer func() {
+			s.logger.Info().Msg("single chat served, shutting down")
+			s.requestShutdown()
+		}()
+		next(w, r)
+	}
+}
+
+// requestShutdown starts a graceful shutdown, as SIGTERM does
+func (s *Server) requestShutdown() {
+	// Sent in the background, so the caller can finish its response
+	go func() {
+		s.quit <- syscall.SIGTERM
+	}()
+}
+
 func (s *Server) handleShutdown(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -301,10 +972,7 @@ func (s *Server) handleShutdown(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("shutting down"))
 
-	// Trigger shutdown in background to allow response to be sent
-	go func() {
-		s.quit <- syscall.SIGTERM
-	}()
+	s.requestShutdown()
 }
 
 func (s *Server) handleContext(w http.ResponseWriter, r *http.Request) {
@@ -412,7 +1080,7 @@ func (s *Server) handleToolRun(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Execute the tool
-	output, err := s.registry.Execute(req.Name, args)
+	output, err := s.currentToolset().registry.Execute(req.Name, args)
 
 	resp := &api.ToolRunResponse{
 		Output:  output,
@@ -431,7 +1099,7 @@ func (s *Server) handleToolList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	toolList := s.registry.List()
+	toolList := s.currentToolset().registry.List()
 
 	resp := &api.ToolListResponse{
 		Tools: make([]*api.ToolInfo, 0, len(toolList)),
@@ -454,6 +1122,214 @@ func (s *Server) handleToolList(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(respData)
 }
 
+// handleToolPrompt returns the external tools prompt in use and the command schemas cached so far
+func (s *Server) handleToolPrompt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := &api.ToolPromptResponse{Prompt: s.currentToolset().toolsPrompt}
+	if s.schemaCache != nil {
+		schemas, err := s.schemaCache.Entries()
+		if err != nil {
+			s.logger.Warn().Err(err).Msg("failed to list cached schemas")
+		}
+		resp.CachedSchemas = cachedSchemasToProto(schemas)
+	}
+
+	respData, err := proto.Marshal(resp)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(respData)
+}
+
+// cachedSchemasToProto describes cached schemas without their full contents
+func cachedSchemasToProto(schemas []*config.CachedSchema) []*api.CachedSchemaInfo {
+	result := make([]*api.CachedSchemaInfo, 0, len(schemas))
+	for _, schema := range schemas {
+		result = append(result, &api.CachedSchemaInfo{
+			Command:         schema.Command,
+			GeneratedAtUnix: schema.GeneratedAt.Unix(),
+			Expired:         schema.Expired(),
+			Summary:         tools.SchemaContents(schema.Schema),
+		})
+	}
+	return result
+}
+
+func (s *Server) handleEmbed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	var req api.EmbedRequest
+	if err := proto.Unmarshal(data, &req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	model := req.Model
+	if model == "" {
+		model = s.ollama.EmbeddingModel()
+	}
+
+	s.logger.Info().Str("model", model).Int("input_len", len(req.Input)).Msg("computing embeddings")
+
+	embedding, err := s.ollama.EmbeddingsWithModel(r.Context(), model, req.Input)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("model", model).Msg("embeddings failed")
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	respData, err := proto.Marshal(&api.EmbedResponse{
+		Embedding: embedding,
+		Model:     model,
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(respData)
+}
+
+func (s *Server) handleRunningModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	models, err := s.ollama.RunningModels(r.Context())
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("failed to list running models")
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	resp := &api.RunningModelsResponse{
+		Models: make([]*api.RunningModel, 0, len(models)),
+	}
+	for _, m := range models {
+		resp.Models = append(resp.Models, &api.RunningModel{
+			Name:          m.Name,
+			SizeBytes:     m.Size,
+			VramBytes:     m.SizeVRAM,
+			ExpiresAtUnix: m.ExpiresAt.Unix(),
+		})
+	}
+
+	respData, err := proto.Marshal(resp)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(respData)
+}
+
+// handleModel returns the chat model (GET) or switches it for subsequent chats (POST).
+// A model that isn't pulled into Ollama is rejected with 404 and the list of available models.
+func (s *Server) handleModel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.sendModelResponse(w, http.StatusOK, &api.ModelResponse{Model: s.ollama.Model()})
+
+	case http.MethodPost:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		var req api.ModelRequest
+		if err := proto.Unmarshal(data, &req); err != nil || strings.TrimSpace(req.Model) == "" {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+
+		name, installed, err := s.ollama.InstalledModel(r.Context(), strings.TrimSpace(req.Model))
+		if err != nil {
+			s.logger.Warn().Err(err).Msg("failed to list Ollama models")
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if !installed {
+			available, err := s.ollama.ModelNames(r.Context())
+			if err != nil {
+				s.logger.Debug().Err(err).Msg("failed to list Ollama models")
+			}
+			s.sendModelResponse(w, http.StatusNotFound, &api.ModelResponse{Model: s.ollama.Model(), Available: available})
+			return
+		}
+
+		previous := s.ollama.Model()
+		s.ollama.SetModel(name)
+		s.logger.Info().Str("model", name).Str("previous", previous).Msg("switched model")
+		s.sendModelResponse(w, http.StatusOK, &api.ModelResponse{Model: name, Previous: previous})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleCancel stops the running generation of a session, keeping its connection and history
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	var req api.CancelRequest
+	if err := proto.Unmarshal(data, &req); err != nil || req.SessionId == "" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	canceled := s.handler.CancelGeneration(req.SessionId)
+	s.logger.Debug().Str("session_id", req.SessionId).Bool("canceled", canceled).Msg("cancel requested")
+
+	data, err = proto.Marshal(&api.CancelResponse{Canceled: canceled})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(data)
+}
+
+func (s *Server) sendModelResponse(w http.ResponseWriter, status int, resp *api.ModelResponse) {
+	data, err := proto.Marshal(resp)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
 func (s *Server) sendToolResponse(w http.ResponseWriter, resp *api.ToolRunResponse) {
 	data, err := proto.Marshal(resp)
 	if err != nil {
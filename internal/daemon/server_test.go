@@ -0,0 +1,327 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marciniwanicki/craby/internal/api"
+	"github.com/marciniwanicki/craby/internal/config"
+	"github.com/marciniwanicki/craby/internal/tools"
+	"github.com/rs/zerolog"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestIsLoopbackAddr(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1:8787": true,
+		"localhost:8787": true,
+		"[::1]:8787":     true,
+		":8787":          false,
+		"0.0.0.0:8787":   false,
+		"192.168.1.5:80": false,
+		"8787":           false,
+		"unix:/tmp/x":    true,
+	}
+	for addr, want := range tests {
+		if got := isLoopbackAddr(addr); got != want {
+			t.Errorf("isLoopbackAddr(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestDiffNames(t *testing.T) {
+	added, removed := diffNames([]string{"ls", "cat", "git"}, []string{"ls", "git", "kubectl"})
+	if !slices.Equal(added, []string{"kubectl"}) {
+		t.Errorf("expected kubectl added, got %v", added)
+	}
+	if !slices.Equal(removed, []string{"cat"}) {
+		t.Errorf("expected cat removed, got %v", removed)
+	}
+}
+
+func TestServer_BuildToolset_Builtin(t *testing.T) {
+	var logs bytes.Buffer
+	s := &Server{
+		ollama:    NewOllamaClient("http://127.0.0.1:0", "qwen2.5:14b", nil),
+		logger:    zerolog.New(&logs),
+		toolStats: tools.NewToolStats(),
+	}
+	templates := &config.PipelineTemplates{}
+
+	// Unset registers every enabled built-in tool
+	settings := config.DefaultSettings()
+	settings.Tools.Read.Enabled = true
+	ts := s.buildToolset(settings, nil, templates)
+	want := slices.Sorted(slices.Values(config.BuiltinToolNames))
+	if got := registryNames(ts.registry); !slices.Equal(got, want) {
+		t.Errorf("expected all built-in tools %v, got %v", want, got)
+	}
+
+	// search_files reads what read_file does, so it's off along with it by default
+	settings.Tools.Read.Enabled = false
+	ts = s.buildToolset(settings, nil, templates)
+	if names := registryNames(ts.registry); slices.Contains(names, "read_file") || slices.Contains(names, "search_files") {
+		t.Errorf("expected no file reading tools, got %v", names)
+	}
+
+	settings.Tools.Read.Enabled = true
+	settings.Tools.Builtin = []string{"calculator", "read_file", "shell", "calc"}
+	settings.Tools.Shell.Enabled = false
+	ts = s.buildToolset(settings, nil, templates)
+	if got := registryNames(ts.registry); !slices.Equal(got, []string{"calculator", "read_file"}) {
+		t.Errorf("expected only the listed and enabled tools, got %v", got)
+	}
+	if ts.shellTool != nil || ts.schemaTool != nil {
+		t.Error("expected no shell or schema tool")
+	}
+	if !strings.Contains(logs.String(), `"unknown":["calc"]`) {
+		t.Errorf("expected a warning about the unknown tool, got %s", logs.String())
+	}
+}
+
+func TestServer_BuildToolset_Limiters(t *testing.T) {
+	s := &Server{
+		ollama:    NewOllamaClient("http://127.0.0.1:0", "qwen2.5:14b", nil),
+		logger:    zerolog.Nop(),
+		toolStats: tools.NewToolStats(),
+	}
+	templates := &config.PipelineTemplates{}
+
+	settings := config.DefaultSettings()
+	s.toolset = s.buildToolset(settings, nil, templates)
+	first := s.toolset
+
+	// Unchanged limits keep the limiters, so calls running across a reload still count
+	s.toolset = s.buildToolset(config.DefaultSettings(), nil, templates)
+	if s.toolset.execLimiter != first.execLimiter || s.toolset.toolLimiter != first.toolLimiter {
+		t.Error("expected unchanged limits to keep the limiters")
+	}
+
+	settings = config.DefaultSettings()
+	settings.Tools.MaxConcurrent = 2
+	s.toolset = s.buildToolset(settings, nil, templates)
+	if s.toolset.toolLimiter.Capacity() != 2 {
+		t.Errorf("expected the reloaded tool call limit, got %d", s.toolset.toolLimiter.Capacity())
+	}
+	if s.toolset.execLimiter != first.execLimiter {
+		t.Error("expected the command limiter kept")
+	}
+}
+
+func TestToolStatsToProto(t *testing.T) {
+	stats := []tools.ToolStat{
+		{Name: "shell", Calls: 3, Errors: 1, TotalDuration: 300 * time.Millisecond, MaxDuration: 200 * time.Millisecond},
+		{Name: "removed_tool", Calls: 1},
+	}
+	got := toolStatsToProto(stats, []string{"calculator", "shell"})
+
+	var names []string
+	for _, stat := range got {
+		names = append(names, stat.Name)
+	}
+	if !slices.Equal(names, []string{"calculator", "removed_tool", "shell"}) {
+		t.Fatalf("expected called and registered tools sorted by name, got %v", names)
+	}
+	if got[0].Calls != 0 {
+		t.Errorf("expected a never called tool with no calls, got %+v", got[0])
+	}
+	if shell := got[2]; shell.Calls != 3 || shell.Errors != 1 || shell.TotalDurationMs != 300 || shell.MaxDurationMs != 200 {
+		t.Errorf("unexpected shell stats: %+v", shell)
+	}
+}
+
+func TestServer_HandleToolPrompt(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cache, err := config.NewSchemaCache()
+	if err != nil {
+		t.Fatalf("failed to create schema cache: %v", err)
+	}
+	_ = cache.Set(&config.CachedSchema{Command: "tfl", Schema: map[string]any{"subcommands": []any{"status", "line"}}})
+
+	s := &Server{logger: zerolog.Nop(), schemaCache: cache, toolset: &toolset{toolsPrompt: "## Available External Tools"}}
+	rec := httptest.NewRecorder()
+	s.handleToolPrompt(rec, httptest.NewRequest(http.MethodGet, "/tool/prompt", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var resp api.ToolPromptResponse
+	if err := proto.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if resp.Prompt != "## Available External Tools" {
+		t.Errorf("expected the injected prompt, got %q", resp.Prompt)
+	}
+	if len(resp.CachedSchemas) != 1 || resp.CachedSchemas[0].Command != "tfl" || resp.CachedSchemas[0].Summary != "2 subcommands" || resp.CachedSchemas[0].Expired {
+		t.Errorf("unexpected cached schemas: %v", resp.CachedSchemas)
+	}
+}
+
+func TestServer_HandleModel(t *testing.T) {
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"models":[{"name":"qwen2.5:14b","model":"qwen2.5:14b"},{"name":"llama3.2:latest","model":"llama3.2:latest"}]}`))
+	}))
+	defer ollama.Close()
+
+	s := &Server{ollama: NewOllamaClient(ollama.URL, "qwen2.5:14b", nil), logger: zerolog.Nop()}
+
+	post := func(model string) (*httptest.ResponseRecorder, *api.ModelResponse) {
+		data, _ := proto.Marshal(&api.ModelRequest{Model: model})
+		rec := httptest.NewRecorder()
+		s.handleModel(rec, httptest.NewRequest(http.MethodPost, "/model", bytes.NewReader(data)))
+		var resp api.ModelResponse
+		if err := proto.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		return rec, &resp
+	}
+
+	// A pulled model is matched without its :latest tag
+	rec, resp := post("llama3.2")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if resp.Model != "llama3.2:latest" || resp.Previous != "qwen2.5:14b" {
+		t.Errorf("unexpected switch: %v", resp)
+	}
+	if got := s.ollama.Model(); got != "llama3.2:latest" {
+		t.Errorf("expected client to use the new model, got %q", got)
+	}
+
+	rec, resp = post("mistral")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a model that isn't pulled, got %d", rec.Code)
+	}
+	if len(resp.Available) != 2 {
+		t.Errorf("expected the available models, got %v", resp.Available)
+	}
+	if got := s.ollama.Model(); got != "llama3.2:latest" {
+		t.Errorf("expected model to stay unchanged, got %q", got)
+	}
+}
+
+func TestServer_NotifyReady(t *testing.T) {
+	s := &Server{logger: zerolog.Nop()}
+	s.notifyReady("127.0.0.1:8787") // Disabled, must not panic
+
+	var out bytes.Buffer
+	s.SetReadyNotify(&out)
+	s.notifyReady("127.0.0.1:8787")
+	if got := out.String(); got != "READY 127.0.0.1:8787\n" {
+		t.Errorf("unexpected ready line %q", got)
+	}
+}
+
+func TestServer_SingleChat(t *testing.T) {
+	s := &Server{logger: zerolog.Nop(), quit: make(chan os.Signal, 1)}
+	served := 0
+	chat := func(w http.ResponseWriter, r *http.Request) { served++ }
+
+	s.singleChat(chat)(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ws/chat", nil))
+	if served != 1 {
+		t.Fatal("expected the chat served without --once")
+	}
+
+	s.SetOnce(true)
+	handler := s.singleChat(chat)
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ws/chat", nil))
+	if served != 2 {
+		t.Fatal("expected the first chat served")
+	}
+	select {
+	case <-s.quit:
+	case <-time.After(time.Second):
+		t.Fatal("expected shutdown after the first chat")
+	}
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/ws/chat", nil))
+	if served != 2 || rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a later chat refused with 503, got %d after %d chats", rec.Code, served)
+	}
+}
+
+func TestListen_UnixSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "craby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "d.sock")
+
+	ln, err := listen("unix:" + path)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected an owner-only socket, got %v %v", info, err)
+	}
+	if _, err := listen("unix:" + path); err == nil {
+		t.Error("expected a socket in use to be kept")
+	}
+
+	// A socket left behind without a listener is replaced
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = ln.Close()
+	ln, err = listen("unix:" + path)
+	if err != nil {
+		t.Fatalf("expected a stale socket replaced: %v", err)
+	}
+	_ = ln.Close()
+
+	notSocket := filepath.Join(dir, "file")
+	if err := os.WriteFile(notSocket, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listen("unix:" + notSocket); err == nil {
+		t.Error("expected a regular file left alone")
+	}
+}
+
+func TestServer_HandleHealthz(t *testing.T) {
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"models":[{"name":"llama3.2:latest","model":"llama3.2:latest"}]}`))
+	}))
+	defer ollama.Close()
+
+	client := NewOllamaClient(ollama.URL, "qwen2.5:14b", nil)
+	s := &Server{ollama: client, logger: zerolog.Nop()}
+
+	get := func() (int, healthReport) {
+		rec := httptest.NewRecorder()
+		s.handleHealthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		var report healthReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+		}
+		return rec.Code, report
+	}
+
+	// The primary model isn't pulled and there is no fallback
+	code, report := get()
+	if code != http.StatusServiceUnavailable || report.Healthy || !report.Ollama.OK || report.Model.OK {
+		t.Errorf("expected 503 with only the model down, got %d %+v", code, report)
+	}
+
+	// A pulled fallback can answer instead
+	client.SetFallbackModels([]string{"llama3.2"})
+	code, report = get()
+	if code != http.StatusOK || !report.Healthy || !strings.Contains(report.Model.Detail, "fallback") {
+		t.Errorf("expected healthy via the fallback, got %d %+v", code, report)
+	}
+
+	// Ollama is down
+	ollama.Close()
+	code, report = get()
+	if code != http.StatusServiceUnavailable || report.Ollama.OK || !report.HTTP.OK {
+		t.Errorf("expected 503 with Ollama down, got %d %+v", code, report)
+	}
+}
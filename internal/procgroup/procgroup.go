@@ -0,0 +1,11 @@
+// Package procgroup stops a command together with the processes it started
+package procgroup
+
+import "os/exec"
+
+// KillOnCancel starts cmd in its own process group and, when its context is done, kills
+// the whole group instead of only cmd. A shell running "sh -c" would otherwise leave its
+// children running after a timeout. Must be called before cmd is started.
+func KillOnCancel(cmd *exec.Cmd) {
+	killOnCancel(cmd)
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package procgroup
+
+import "os/exec"
+
+// killOnCancel keeps the default behavior of killing only cmd where process groups aren't available
+func killOnCancel(cmd *exec.Cmd) {}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package procgroup
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func killOnCancel(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		// A negative pid signals every process in the group, which has the same id as its leader
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
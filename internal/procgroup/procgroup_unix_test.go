@@ -0,0 +1,55 @@
+//go:build unix
+
+package procgroup
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKillOnCancel_KillsChildren(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	// The shell prints its sleeping child's pid and waits on it. The child shares the output
+	// pipe, so Run only returns once the child is gone too.
+	cmd := exec.CommandContext(ctx, "sh", "-c", "sleep 30 & echo $!; wait")
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	KillOnCancel(cmd)
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Run() }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected the command to be killed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the child to be killed with the shell, but it kept the output open")
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(stdout.String()))
+	if err != nil {
+		t.Fatalf("expected the child pid, got %q", stdout.String())
+	}
+	// The child may linger as a zombie until it is reaped, but it must not be running
+	if processRunning(pid) {
+		t.Errorf("expected child %d to be killed", pid)
+	}
+}
+
+// processRunning reports whether pid exists and isn't a zombie
+func processRunning(pid int) bool {
+	out, err := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return false // ps exits non-zero when the process is gone
+	}
+	return !strings.HasPrefix(strings.TrimSpace(string(out)), "Z")
+}
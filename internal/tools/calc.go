@@ -0,0 +1,390 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxExpressionLen bounds the expressions the calculator accepts
+const maxExpressionLen = 1000
+
+var (
+	errDivisionByZero = errors.New("division by zero")
+	errOverflow       = errors.New("result is too large (overflow)")
+	errUndefined      = errors.New("result is undefined (not a number)")
+)
+
+// calcConstants are the named values an expression can use
+var calcConstants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+// calcFunction is a function callable from an expression
+type calcFunction struct {
+	args int // Number of arguments, -1 = one or more
+	fn   func(args []float64) float64
+}
+
+// calcFunctions are the functions an expression can call
+var calcFunctions = map[string]calcFunction{
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"cbrt":  {1, func(a []float64) float64 { return math.Cbrt(a[0]) }},
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"ln":    {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log":   {1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"log2":  {1, func(a []float64) float64 { return math.Log2(a[0]) }},
+	"sin":   {1, func(a []float64) float64 { return math.Sin(a[0]) }},
+	"cos":   {1, func(a []float64) float64 { return math.Cos(a[0]) }},
+	"tan":   {1, func(a []float64) float64 { return math.Tan(a[0]) }},
+	"asin":  {1, func(a []float64) float64 { return math.Asin(a[0]) }},
+	"acos":  {1, func(a []float64) float64 { return math.Acos(a[0]) }},
+	"atan":  {1, func(a []float64) float64 { return math.Atan(a[0]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"round": {1, func(a []float64) float64 { return math.Round(a[0]) }},
+	"pow":   {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"min":   {-1, func(a []float64) float64 { return minOf(a) }},
+	"max":   {-1, func(a []float64) float64 { return maxOf(a) }},
+}
+
+// CalcTool evaluates arithmetic expressions so the model doesn't have to do math itself
+type CalcTool struct{}
+
+// NewCalcTool creates a new calculator tool
+func NewCalcTool() *CalcTool {
+	return &CalcTool{}
+}
+
+func (t *CalcTool) Name() string {
+	return "calculator"
+}
+
+func (t *CalcTool) Description() string {
+	return "Evaluate an arithmetic expression and return the exact result. Use this for any calculation " +
+		"instead of doing math yourself. Supports + - * / % ^, parentheses, the constants pi and e, and " +
+		"the functions sqrt, cbrt, abs, exp, ln, log (base 10), log2, sin, cos, tan, asin, acos, atan, " +
+		"floor, ceil, round, pow, min and max. Angles are in radians."
+}
+
+func (t *CalcTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"expression": map[string]any{
+				"type":        "string",
+				"description": "The expression to evaluate, e.g. (17 * 23) / 4 or sqrt(2) ^ 3",
+			},
+		},
+		"required": []string{"expression"},
+	}
+}
+
+func (t *CalcTool) Execute(args map[string]any) (string, error) {
+	exprRaw, ok := args["expression"]
+	if !ok {
+		return "", fmt.Errorf("missing required parameter: expression")
+	}
+	expr, ok := exprRaw.(string)
+	if !ok {
+		return "", fmt.Errorf("expression must be a string")
+	}
+
+	result, err := Evaluate(expr)
+	if err != nil {
+		return "", fmt.Errorf("cannot evaluate %q: %w", expr, err)
+	}
+	return formatNumber(result), nil
+}
+
+// Evaluate parses and evaluates an arithmetic expression
+func Evaluate(expr string) (float64, error) {
+	if strings.TrimSpace(expr) == "" {
+		return 0, errors.New("empty expression")
+	}
+	if len(expr) > maxExpressionLen {
+		return 0, fmt.Errorf("expression is longer than %d characters", maxExpressionLen)
+	}
+
+	p := &calcParser{input: expr}
+	value, err := p.parseExpression()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos+1)
+	}
+	return value, nil
+}
+
+// formatNumber prints a result without float noise, e.g. 0.1+0.2 as 0.3
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'g', 15, 64)
+}
+
+// calcParser is a recursive descent parser that evaluates as it parses:
+//
+//	expression = term { ("+" | "-") term }
+//	term       = unary { ("*" | "/" | "%") unary }
+//	unary      = ("+" | "-") unary | power
+//	power      = primary [ "^" unary ]
+//	primary    = number | constant | function "(" expression { "," expression } ")" | "(" expression ")"
+type calcParser struct {
+	input string
+	pos   int
+}
+
+func (p *calcParser) parseExpression() (float64, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return 0, err
+		}
+		if op == '+' {
+			left, err = checkResult(left + right)
+		} else {
+			left, err = checkResult(left - right)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (p *calcParser) parseTerm() (float64, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' && op != '%' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case '*':
+			left, err = checkResult(left * right)
+		case '/':
+			if right == 0 {
+				return 0, errDivisionByZero
+			}
+			left, err = checkResult(left / right)
+		case '%':
+			if right == 0 {
+				return 0, errDivisionByZero
+			}
+			left, err = checkResult(math.Mod(left, right))
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (p *calcParser) parseUnary() (float64, error) {
+	switch p.peek() {
+	case '+':
+		p.pos++
+		return p.parseUnary()
+	case '-':
+		p.pos++
+		value, err := p.parseUnary()
+		return -value, err
+	}
+	return p.parsePower()
+}
+
+func (p *calcParser) parsePower() (float64, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() != '^' {
+		return base, nil
+	}
+	p.pos++
+	// Right-associative: 2^3^2 = 2^(3^2)
+	exponent, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	if base == 0 && exponent < 0 {
+		return 0, errDivisionByZero
+	}
+	return checkResult(math.Pow(base, exponent))
+}
+
+func (p *calcParser) parsePrimary() (float64, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return 0, errors.New("unexpected end of expression")
+	case c == '(':
+		p.pos++
+		value, err := p.parseExpression()
+		if err != nil {
+			return 0, err
+		}
+		if err := p.expect(')'); err != nil {
+			return 0, err
+		}
+		return value, nil
+	case c == '.' || isDigit(c):
+		return p.parseNumber()
+	case unicode.IsLetter(rune(c)):
+		return p.parseIdentifier()
+	}
+	return 0, fmt.Errorf("unexpected %q at position %d", c, p.pos+1)
+}
+
+func (p *calcParser) parseNumber() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && (isDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+		p.pos++
+	}
+	// Exponent, e.g. 1.5e-3
+	if p.pos < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+		end := p.pos + 1
+		if end < len(p.input) && (p.input[end] == '+' || p.input[end] == '-') {
+			end++
+		}
+		if end < len(p.input) && isDigit(p.input[end]) {
+			for end < len(p.input) && isDigit(p.input[end]) {
+				end++
+			}
+			p.pos = end
+		}
+	}
+
+	text := p.input[start:p.pos]
+	value, err := strconv.ParseFloat(text, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, errOverflow
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q at position %d", text, start+1)
+	}
+	return value, nil
+}
+
+func (p *calcParser) parseIdentifier() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && (unicode.IsLetter(rune(p.input[p.pos])) || isDigit(p.input[p.pos])) {
+		p.pos++
+	}
+	name := strings.ToLower(p.input[start:p.pos])
+
+	if p.peek() != '(' {
+		if value, ok := calcConstants[name]; ok {
+			return value, nil
+		}
+		if _, ok := calcFunctions[name]; ok {
+			return 0, fmt.Errorf("function %s needs arguments in parentheses", name)
+		}
+		return 0, fmt.Errorf("unknown name %q at position %d", name, start+1)
+	}
+
+	fn, ok := calcFunctions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown function %q at position %d", name, start+1)
+	}
+	p.pos++
+
+	var args []float64
+	for {
+		arg, err := p.parseExpression()
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, arg)
+		if p.peek() != ',' {
+			break
+		}
+		p.pos++
+	}
+	if err := p.expect(')'); err != nil {
+		return 0, err
+	}
+
+	if fn.args >= 0 && len(args) != fn.args {
+		return 0, fmt.Errorf("%s takes %d argument(s), got %d", name, fn.args, len(args))
+	}
+	return checkResult(fn.fn(args))
+}
+
+// peek skips whitespace and returns the next character, or 0 at the end of the input
+func (p *calcParser) peek() byte {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *calcParser) expect(c byte) error {
+	if p.peek() != c {
+		if p.pos >= len(p.input) {
+			return fmt.Errorf("missing %q at end of expression", c)
+		}
+		return fmt.Errorf("expected %q at position %d, got %q", c, p.pos+1, p.input[p.pos])
+	}
+	p.pos++
+	return nil
+}
+
+func (p *calcParser) skipSpaces() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t' || p.input[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+// checkResult turns infinities and NaN into errors
+func checkResult(v float64) (float64, error) {
+	switch {
+	case math.IsInf(v, 0):
+		return 0, errOverflow
+	case math.IsNaN(v):
+		return 0, errUndefined
+	}
+	return v, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func minOf(values []float64) float64 {
+	result := values[0]
+	for _, v := range values[1:] {
+		result = math.Min(result, v)
+	}
+	return result
+}
+
+func maxOf(values []float64) float64 {
+	result := values[0]
+	for _, v := range values[1:] {
+		result = math.Max(result, v)
+	}
+	return result
+}
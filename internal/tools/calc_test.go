@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCalcTool_Name(t *testing.T) {
+	tool := NewCalcTool()
+	if tool.Name() != "calculator" {
+		t.Errorf("expected name 'calculator', got %q", tool.Name())
+	}
+}
+
+func TestCalcTool_Execute(t *testing.T) {
+	tool := NewCalcTool()
+
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"(1 + 2) * 3", "9"},
+		{"10 / 4", "2.5"},
+		{"17 % 5", "2"},
+		{"2 ^ 10", "1024"},
+		{"2 ^ 3 ^ 2", "512"},
+		{"-2 ^ 2", "-4"},
+		{"--3", "3"},
+		{"0.1 + 0.2", "0.3"},
+		{"1.5e3 + 1", "1501"},
+		{"sqrt(16) + abs(-3)", "7"},
+		{"max(1, 7, 3) - min(4, 2)", "5"},
+		{"pow(2, 0.5) ^ 2", "2"},
+		{"round(pi * 100) / 100", "3.14"},
+		{"ln(e)", "1"},
+		{"log(1000)", "3"},
+		{"  12345679 * 9 ", "111111111"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, err := tool.Execute(map[string]any{"expression": tt.expression})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCalcTool_Execute_Errors(t *testing.T) {
+	tool := NewCalcTool()
+
+	tests := []struct {
+		expression string
+		wantErr    string
+	}{
+		{"1 / 0", "division by zero"},
+		{"5 % (2 - 2)", "division by zero"},
+		{"0 ^ -1", "division by zero"},
+		{"10 ^ 400", "overflow"},
+		{"1e999", "overflow"},
+		{"exp(1000)", "overflow"},
+		{"sqrt(-1)", "undefined"},
+		{"(1 + 2", `missing ')'`},
+		{"1 + ", "unexpected end"},
+		{"2 * x", `unknown name "x"`},
+		{"foo(1)", `unknown function "foo"`},
+		{"pow(2)", "pow takes 2 argument(s), got 1"},
+		{"1 2", "unexpected '2' at position 3"},
+		{"os.exit(1)", `unknown name "os"`},
+		{"", "empty expression"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := tool.Execute(map[string]any{"expression": tt.expression})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if _, err := tool.Execute(map[string]any{}); err == nil {
+		t.Error("expected error for missing expression")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -12,8 +13,6 @@ import (
 	"github.com/marciniwanicki/craby/internal/config"
 )
 
-const discoverySchemaTimeout = 30 * time.Second
-
 // SchemaGeneratorLLM is the interface for generating schemas from help text
 type SchemaGeneratorLLM interface {
 	SimpleChat(ctx context.Context, systemPrompt, userMessage string) (string, error)
@@ -112,11 +111,26 @@ func (t *ListCommandsTool) Execute(args map[string]any) (string, error) {
 	return result.String(), nil
 }
 
+// DiscoveryStep describes a finished step of command discovery
+type DiscoveryStep struct {
+	Command string // Command that was run, empty for steps that don't run one
+	Summary string // One-line outcome, e.g. "42 lines of help"
+}
+
+// DiscoveryObserver is called after each discovery step finishes
+type DiscoveryObserver func(step DiscoveryStep)
+
 // GetCommandSchemaTool discovers and returns the schema for a CLI command
 type GetCommandSchemaTool struct {
-	settings    *config.Settings
-	schemaCache *config.SchemaCache
-	llm         SchemaGeneratorLLM
+	settings      *config.Settings
+	externalTools []*config.ExternalTool // Their discovery limits apply to their commands
+	schemaCache   *config.SchemaCache
+	llm           SchemaGeneratorLLM
+	recorder      CommandRecorder   // Optional callback when help commands finish
+	observer      DiscoveryObserver // Optional callback when discovery steps finish
+	limiter       *ExecLimiter      // Shared with the shell tool (nil = unlimited)
+	redactor      *Redactor         // Masks secrets in help output (nil = disabled)
+	shell         shellBinary
 }
 
 // NewGetCommandSchemaTool creates a new get command schema tool
@@ -125,7 +139,53 @@ func NewGetCommandSchemaTool(settings *config.Settings, cache *config.SchemaCach
 		settings:    settings,
 		schemaCache: cache,
 		llm:         llm,
+		redactor:    NewRedactor(settings.Redaction, nil),
+		shell:       resolveShell(settings),
+	}
+}
+
+// SetCommandRecorder sets a callback that's invoked with the outcome of every help command run for discovery
+func (t *GetCommandSchemaTool) SetCommandRecorder(recorder CommandRecorder) {
+	t.recorder = recorder
+}
+
+// SetExternalTools sets the external tools whose discovery limits apply when discovering their commands,
+// and whose env values are masked in help output
+func (t *GetCommandSchemaTool) SetExternalTools(externalTools []*config.ExternalTool) {
+	t.externalTools = externalTools
+	t.redactor = NewRedactor(t.settings.Redaction, externalTools)
+}
+
+// discoveryLimits returns the discovery limits for a base command: the external tool's own, or the defaults
+func (t *GetCommandSchemaTool) discoveryLimits(baseCommand string) config.ToolDiscovery {
+	for _, tool := range t.externalTools {
+		fields := strings.Fields(tool.Access.Command)
+		if tool.Name == baseCommand || (len(fields) > 0 && fields[0] == baseCommand) {
+			return tool.Discovery
+		}
 	}
+	return config.ToolDiscovery{}
+}
+
+// SetDiscoveryObserver sets a callback that's invoked as each discovery step finishes, to report progress
+func (t *GetCommandSchemaTool) SetDiscoveryObserver(observer DiscoveryObserver) {
+	t.observer = observer
+}
+
+// observe reports a finished discovery step, if anyone is listening
+func (t *GetCommandSchemaTool) observe(hooks Hooks, command, summary string) {
+	observer := t.observer
+	if hooks.Discovery != nil {
+		observer = hooks.Discovery
+	}
+	if observer != nil {
+		observer(DiscoveryStep{Command: command, Summary: summary})
+	}
+}
+
+// SetExecLimiter sets a limiter shared with other tools that run commands
+func (t *GetCommandSchemaTool) SetExecLimiter(limiter *ExecLimiter) {
+	t.limiter = limiter
 }
 
 func (t *GetCommandSchemaTool) Name() string {
@@ -160,6 +220,12 @@ func (t *GetCommandSchemaTool) Parameters() map[string]any {
 }
 
 func (t *GetCommandSchemaTool) Execute(args map[string]any) (string, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext discovers the schema like Execute, reporting to the Hooks carried by ctx
+func (t *GetCommandSchemaTool) ExecuteContext(ctx context.Context, args map[string]any) (string, error) {
+	hooks := hooksFrom(ctx)
 	commandRaw, ok := args["command"]
 	if !ok {
 		return "", fmt.Errorf("missing required parameter: command")
@@ -169,63 +235,131 @@ func (t *GetCommandSchemaTool) Execute(args map[string]any) (string, error) {
 		return "", fmt.Errorf("command must be a string")
 	}
 	command = strings.TrimSpace(command)
+	if command == "" {
+		return "", fmt.Errorf("command must not be empty")
+	}
 
 	// Note: caching disabled during development
 	// TODO: re-enable caching once schema generation is stable
 
+	// The help command runs through the shell, so operators could smuggle in another command
+	// after an allowed base command. Discovery only ever names a command and its subcommands.
+	if err := checkDeniedPatterns(t.settings, command); err != nil {
+		return "", err
+	}
+	if strings.ContainsAny(command, "\r\n") {
+		return "", fmt.Errorf("command must be a single line")
+	}
+
 	// Validate base command is allowed (first word)
 	baseCommand := strings.Fields(command)[0]
 	if !t.isCommandAllowed(baseCommand) {
 		return "", fmt.Errorf("command not in allowlist: %s", baseCommand)
 	}
 
+	limits := t.discoveryLimits(baseCommand)
+
 	// Get help text
-	helpText, err := t.getHelpText(command)
+	helpText, err := t.getHelpText(command, limits, hooks)
 	if err != nil {
+		t.observe(hooks, command+" --help", err.Error())
 		return "", fmt.Errorf("failed to get help for %s: %w", command, err)
 	}
+	t.observe(hooks, command+" --help", fmt.Sprintf("%d lines of help", strings.Count(strings.TrimSpace(helpText), "\n")+1))
 
 	// Generate schema using LLM
-	schema, err := t.generateSchema(command, helpText)
+	schema, err := t.generateSchema(command, helpText, limits)
 	if err != nil {
-		// Fall back to returning raw help if LLM fails
+		t.observe(hooks, "", fmt.Sprintf("couldn't generate a schema for %s, using raw help: %v", command, err))
+		// Fall back to returning raw help if LLM fails, plus any subcommands found without it
+		var parsed strings.Builder
+		for _, sub := range parseSubcommands(helpText) {
+			parsed.WriteString(fmt.Sprintf("- `%s %s`: %s\n", command, sub.Name, sub.Description))
+		}
+		if parsed.Len() > 0 {
+			return fmt.Sprintf("# %s Help\n\nCould not generate schema: %v\n\n## Subcommands\n%s\nRaw help:\n```\n%s\n```",
+				command, err, parsed.String(), helpText), nil
+		}
 		return fmt.Sprintf("# %s Help\n\nCould not generate schema: %v\n\nRaw help:\n```\n%s\n```",
 			command, err, helpText), nil
 	}
 
+	fillSubcommands(schema, helpText)
+	t.observe(hooks, "", schemaSummary(command, schema))
+
 	return t.formatSchema(command, schema, helpText), nil
 }
 
+// schemaSummary describes a generated schema in one line, e.g. "schema for git: 12 flags, 20 subcommands"
+func schemaSummary(command string, schema map[string]any) string {
+	return "schema for " + command + ": " + SchemaContents(schema)
+}
+
+// SchemaContents counts what a schema describes, e.g. "12 flags, 20 subcommands"
+func SchemaContents(schema map[string]any) string {
+	var parts []string
+	for _, key := range []string{"flags", "subcommands", "arguments"} {
+		if items, ok := schema[key].([]any); ok && len(items) > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", len(items), key))
+		}
+	}
+	if len(parts) == 0 {
+		return "no flags or subcommands"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// fillSubcommands adds subcommands parsed from the help text when the LLM schema lists none
+func fillSubcommands(schema map[string]any, helpText string) {
+	if subs, ok := schema["subcommands"].([]any); ok && len(subs) > 0 {
+		return
+	}
+	parsed := parseSubcommands(helpText)
+	if len(parsed) == 0 {
+		return
+	}
+	subs := make([]any, 0, len(parsed))
+	for _, sub := range parsed {
+		subs = append(subs, map[string]any{"name": sub.Name, "description": sub.Description})
+	}
+	schema["subcommands"] = subs
+}
+
 func (t *GetCommandSchemaTool) isCommandAllowed(command string) bool {
 	// Check settings allowlist
 	if t.settings.IsCommandAllowed(command) {
 		return true
 	}
 
-	// Always allow discovering common system commands
-	safeCommands := map[string]bool{
-		"git": true, "docker": true, "kubectl": true, "npm": true,
-		"yarn": true, "pnpm": true, "cargo": true, "go": true,
-		"python": true, "pip": true, "node": true, "ruby": true,
-		"make": true, "cmake": true, "gradle": true, "mvn": true,
-	}
-
-	return safeCommands[command]
+	// Always allow discovering well-known commands (built-ins adjusted by settings),
+	// unless the shell denylist forbids them
+	return t.settings.WellKnownCommands()[command] && !t.settings.IsCommandDenied(command)
 }
 
-func (t *GetCommandSchemaTool) getHelpText(command string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (t *GetCommandSchemaTool) getHelpText(command string, limits config.ToolDiscovery, hooks Hooks) (string, error) {
+	// Discovery counts toward the same concurrency budget as the shell tool
+	release, err := t.limiter.Acquire()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), limits.HelpTimeout())
 	defer cancel()
 
 	// Build help command
 	cmdStr := fmt.Sprintf("%s --help", command)
 
-	cmd := exec.CommandContext(ctx, "sh", "-c", cmdStr)
+	cmd, err := t.shell.command(ctx, cmdStr)
+	if err != nil {
+		return "", err
+	}
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	_ = cmd.Run() // Ignore error - help often exits non-zero
+	start := time.Now()
+	err = cmd.Run() // Ignore error - help often exits non-zero
 
 	output := stdout.String()
 	if stderr.Len() > 0 {
@@ -234,25 +368,47 @@ func (t *GetCommandSchemaTool) getHelpText(command string) (string, error) {
 		}
 		output += stderr.String()
 	}
+	output = t.redactor.Redact(output)
+
+	recorder := t.recorder
+	if hooks.Recorder != nil {
+		recorder = hooks.Recorder
+	}
+	if recorder != nil {
+		exitCode := 0
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		} else if err != nil {
+			exitCode = -1
+		}
+		recorder(CommandRecord{
+			Command:     cmdStr,
+			IsDiscovery: true,
+			ExitCode:    exitCode,
+			Duration:    time.Since(start),
+			Output:      output,
+		})
+	}
 
 	if len(output) < 20 {
 		return "", fmt.Errorf("no help output available")
 	}
 
 	// Truncate if too long
-	if len(output) > 8000 {
-		output = output[:8000] + "\n... (truncated)"
+	if limit := limits.HelpLimit(); len(output) > limit {
+		output = output[:limit] + "\n... (truncated)"
 	}
 
 	return output, nil
 }
 
-func (t *GetCommandSchemaTool) generateSchema(command, helpText string) (map[string]any, error) {
+func (t *GetCommandSchemaTool) generateSchema(command, helpText string, limits config.ToolDiscovery) (map[string]any, error) {
 	if t.llm == nil {
 		return nil, fmt.Errorf("no LLM available for schema generation")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), discoverySchemaTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), limits.SchemaTimeout())
 	defer cancel()
 
 	systemPrompt := `# Role
@@ -2,6 +2,10 @@ package tools
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/marciniwanicki/craby/internal/config"
@@ -112,6 +116,23 @@ func TestGetCommandSchemaTool_Execute_DisallowedCommand(t *testing.T) {
 	}
 }
 
+func TestGetCommandSchemaTool_Execute_DeniedPatterns(t *testing.T) {
+	var ran []string
+	tool := NewGetCommandSchemaTool(config.DefaultSettings(), nil, nil)
+	tool.SetCommandRecorder(func(record CommandRecord) {
+		ran = append(ran, record.Command)
+	})
+
+	for _, command := range []string{"git && rm -rf ~", "git; rm -rf ~", "git status | sh", "git $(rm -rf ~)", "git\nrm -rf ~", "  "} {
+		if _, err := tool.Execute(map[string]any{"command": command}); err == nil {
+			t.Errorf("expected %q to be rejected", command)
+		}
+	}
+	if len(ran) != 0 {
+		t.Errorf("expected no help command to run, ran %v", ran)
+	}
+}
+
 func TestGetCommandSchemaTool_Execute_AllowedCommand_NoLLM(t *testing.T) {
 	settings := config.DefaultSettings()
 	tool := NewGetCommandSchemaTool(settings, nil, nil)
@@ -166,6 +187,122 @@ func TestGetCommandSchemaTool_Execute_WithMockLLM(t *testing.T) {
 	}
 }
 
+func TestGetCommandSchemaTool_Execute_ReportsDiscoverySteps(t *testing.T) {
+	settings := config.DefaultSettings()
+	mockLLM := &mockSchemaLLM{
+		response: `{"name": "ls", "flags": [{"name": "-l"}, {"name": "-a"}], "examples": ["ls -la"]}`,
+	}
+	tool := NewGetCommandSchemaTool(settings, nil, mockLLM)
+
+	var steps []DiscoveryStep
+	tool.SetDiscoveryObserver(func(step DiscoveryStep) {
+		steps = append(steps, step)
+	})
+
+	if _, err := tool.Execute(map[string]any{"command": "ls"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(steps) != 2 {
+		t.Fatalf("expected help and schema steps, got %v", steps)
+	}
+	if steps[0].Command != "ls --help" || !strings.HasSuffix(steps[0].Summary, "lines of help") {
+		t.Errorf("unexpected help step: %+v", steps[0])
+	}
+	if steps[1].Command != "" || steps[1].Summary != "schema for ls: 2 flags" {
+		t.Errorf("unexpected schema step: %+v", steps[1])
+	}
+
+	// A failed schema generation is reported before falling back to raw help
+	steps = nil
+	mockLLM.err = errors.New("model offline")
+	if _, err := tool.Execute(map[string]any{"command": "ls"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(steps) != 2 || !strings.Contains(steps[1].Summary, "model offline") {
+		t.Errorf("expected the failed schema step to be reported, got %v", steps)
+	}
+
+	// Hooks in the context take the steps of that call instead
+	steps = nil
+	var hooked []DiscoveryStep
+	ctx := WithHooks(context.Background(), Hooks{Discovery: func(step DiscoveryStep) {
+		hooked = append(hooked, step)
+	}})
+	if _, err := tool.ExecuteContext(ctx, map[string]any{"command": "ls"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hooked) != 2 || len(steps) != 0 {
+		t.Errorf("expected steps reported to the hook only, got %v and %v", hooked, steps)
+	}
+}
+
+// recordingSchemaLLM records the help text it's asked to convert
+type recordingSchemaLLM struct {
+	userMessage string
+}
+
+func (m *recordingSchemaLLM) SimpleChat(_ context.Context, _, userMessage string) (string, error) {
+	m.userMessage = userMessage
+	return `{"name": "ls"}`, nil
+}
+
+func TestGetCommandSchemaTool_Execute_ExternalToolDiscoveryLimits(t *testing.T) {
+	settings := config.DefaultSettings()
+	llm := &recordingSchemaLLM{}
+	tool := NewGetCommandSchemaTool(settings, nil, llm)
+
+	// Default limit keeps short help intact
+	if _, err := tool.Execute(map[string]any{"command": "ls"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(llm.userMessage, "(truncated)") {
+		t.Fatal("expected help under the default limit not to be truncated")
+	}
+
+	tool.SetExternalTools([]*config.ExternalTool{{
+		Name:      "files",
+		Access:    config.ToolAccess{Type: "shell", Command: "ls"},
+		Discovery: config.ToolDiscovery{MaxHelpChars: 30},
+	}})
+	if _, err := tool.Execute(map[string]any{"command": "ls"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(llm.userMessage, "(truncated)") {
+		t.Errorf("expected help to be truncated to the tool's limit, got %q", llm.userMessage)
+	}
+}
+
+func TestGetCommandSchemaTool_Execute_RedactsHelp(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"Usage: helper --token tool-secret-abcdef\"\necho \"  default: $CRABY_TEST_TOKEN\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "helper"), []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("CRABY_TEST_TOKEN", "env-secret-abcdef")
+
+	settings := config.DefaultSettings()
+	settings.Tools.Shell.Allowlist = append(settings.Tools.Shell.Allowlist, "helper")
+	llm := &recordingSchemaLLM{}
+	tool := NewGetCommandSchemaTool(settings, nil, llm)
+	ext := &config.ExternalTool{Name: "helper", Access: config.ToolAccess{Type: "shell", Command: "helper"}}
+	ext.Env.Set = map[string]string{"HELPER_TOKEN": "tool-secret-abcdef"}
+	tool.SetExternalTools([]*config.ExternalTool{ext})
+
+	var record CommandRecord
+	tool.SetCommandRecorder(func(r CommandRecord) { record = r })
+	if _, err := tool.Execute(map[string]any{"command": "helper"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, text := range map[string]string{"help sent to the model": llm.userMessage, "recorded output": record.Output} {
+		if strings.Contains(text, "secret-abcdef") || !strings.Contains(text, "[REDACTED]") {
+			t.Errorf("expected secrets redacted from the %s, got %q", name, text)
+		}
+	}
+}
+
 func TestGetCommandSchemaTool_isCommandAllowed(t *testing.T) {
 	settings := config.DefaultSettings()
 	tool := NewGetCommandSchemaTool(settings, nil, nil)
@@ -192,6 +329,23 @@ func TestGetCommandSchemaTool_isCommandAllowed(t *testing.T) {
 	}
 }
 
+func TestGetCommandSchemaTool_isCommandAllowed_ConfiguredWellKnown(t *testing.T) {
+	settings := config.DefaultSettings()
+	settings.Tools.Discovery.AddCommands = []string{"housetool"}
+	settings.Tools.Discovery.RemoveCommands = []string{"docker"}
+	tool := NewGetCommandSchemaTool(settings, nil, nil)
+
+	if !tool.isCommandAllowed("housetool") {
+		t.Error("expected user-added command to be discoverable")
+	}
+	if tool.isCommandAllowed("docker") {
+		t.Error("expected user-removed command to be refused")
+	}
+	if !tool.isCommandAllowed("git") {
+		t.Error("expected other built-in commands to stay discoverable")
+	}
+}
+
 // =============================================================================
 // TFL CLI Tests - Tests for get_command_schema using the tfl CLI as an example
 // =============================================================================
@@ -0,0 +1,220 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/marciniwanicki/craby/internal/config"
+)
+
+var (
+	scriptStylePattern = regexp.MustCompile(`(?is)<(script|style|noscript)[^>]*>.*?</(script|style|noscript)>`)
+	tagPattern         = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLinesPattern  = regexp.MustCompile(`\n\s*\n+`)
+	spacesPattern      = regexp.MustCompile(`[ \t\r\f\v]+`)
+)
+
+// errPrivateAddress is returned when a request resolves to a blocked address
+var errPrivateAddress = errors.New("address is private or loopback")
+
+// HTTPFetchTool retrieves the contents of web pages
+type HTTPFetchTool struct {
+	settings *config.Settings
+	client   *http.Client
+}
+
+// NewHTTPFetchTool creates a new http_fetch tool
+func NewHTTPFetchTool(settings *config.Settings) *HTTPFetchTool {
+	t := &HTTPFetchTool{settings: settings}
+
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: t.checkAddress,
+	}
+
+	t.client = &http.Client{
+		Timeout: time.Duration(settings.Tools.Fetch.TimeoutSeconds) * time.Second,
+		Transport: &http.Transport{
+			// No proxy: checkAddress would only vet the proxy's address, not the target's
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > settings.Tools.Fetch.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", settings.Tools.Fetch.MaxRedirects)
+			}
+			return t.checkURL(req.URL)
+		},
+	}
+
+	return t
+}
+
+func (t *HTTPFetchTool) Name() string {
+	return "http_fetch"
+}
+
+func (t *HTTPFetchTool) Description() string {
+	desc := "Fetch a web page or other resource over HTTP(S) with a GET request and return its body. " +
+		"Prefer this over curl."
+	if len(t.settings.Tools.Fetch.AllowedHosts) > 0 {
+		desc += " Allowed hosts: " + strings.Join(t.settings.Tools.Fetch.AllowedHosts, ", ")
+	}
+	return desc
+}
+
+func (t *HTTPFetchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"url": map[string]any{
+				"type":        "string",
+				"description": "The http or https URL to fetch",
+			},
+			"text": map[string]any{
+				"type":        "boolean",
+				"description": "If true, strip HTML markup and return only the readable text (default: false)",
+			},
+		},
+		"required": []string{"url"},
+	}
+}
+
+func (t *HTTPFetchTool) Execute(args map[string]any) (string, error) {
+	urlRaw, ok := args["url"]
+	if !ok {
+		return "", fmt.Errorf("missing required parameter: url")
+	}
+	rawURL, ok := urlRaw.(string)
+	if !ok {
+		return "", fmt.Errorf("url must be a string")
+	}
+
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid url: %w", err)
+	}
+	if err := t.checkURL(target); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.client.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "craby")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read one byte past the cap so we know whether the body was truncated
+	maxBytes := t.settings.Tools.Fetch.MaxBytes
+	var reader io.Reader = resp.Body
+	if maxBytes > 0 {
+		reader = io.LimitReader(resp.Body, int64(maxBytes)+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	truncated := maxBytes > 0 && len(data) > maxBytes
+	if truncated {
+		data = data[:maxBytes]
+	}
+
+	body := string(data)
+	if boolArg(args, "text") {
+		body = htmlToText(body)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Status: %s\n", resp.Status))
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		sb.WriteString(fmt.Sprintf("Content-Type: %s\n", contentType))
+	}
+	sb.WriteString("\n")
+	sb.WriteString(body)
+	if truncated {
+		sb.WriteString(fmt.Sprintf("\n... (truncated at %d bytes)", maxBytes))
+	}
+
+	return sb.String(), nil
+}
+
+// checkURL validates the scheme and host of a URL against the settings
+func (t *HTTPFetchTool) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("fetch not allowed: unsupported scheme %q", u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("fetch not allowed: missing host")
+	}
+
+	if err := t.settings.Tools.Network.CheckHost(host); err != nil {
+		return fmt.Errorf("fetch not allowed: %w", err)
+	}
+
+	allowed := t.settings.Tools.Fetch.AllowedHosts
+	if len(allowed) == 0 || config.MatchesHost(allowed, host) {
+		return nil
+	}
+	return fmt.Errorf("fetch not allowed: host %s is not in allowed hosts", host)
+}
+
+// checkAddress rejects connections to private addresses after DNS resolution,
+// so hostnames pointing at internal services are caught too
+func (t *HTTPFetchTool) checkAddress(_, address string, _ syscall.RawConn) error {
+	if !t.settings.Tools.Fetch.BlockPrivate {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("fetch not allowed: unresolved address %s", host)
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("fetch not allowed: %s: %w", ip, errPrivateAddress)
+	}
+	return nil
+}
+
+// htmlToText strips markup from an HTML document, leaving readable text
+func htmlToText(s string) string {
+	s = scriptStylePattern.ReplaceAllString(s, "")
+	s = tagPattern.ReplaceAllString(s, "\n")
+	s = html.UnescapeString(s)
+	s = spacesPattern.ReplaceAllString(s, " ")
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	s = strings.Join(lines, "\n")
+	s = blankLinesPattern.ReplaceAllString(s, "\n")
+
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,133 @@
+package tools
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/marciniwanicki/craby/internal/config"
+)
+
+func fetchTestSettings() *config.Settings {
+	return &config.Settings{
+		Tools: config.ToolsSettings{
+			Fetch: config.FetchSettings{
+				Enabled:        true,
+				MaxBytes:       1024,
+				MaxRedirects:   2,
+				TimeoutSeconds: 5,
+			},
+		},
+	}
+}
+
+func TestHTTPFetchTool_Name(t *testing.T) {
+	tool := NewHTTPFetchTool(fetchTestSettings())
+	if tool.Name() != "http_fetch" {
+		t.Errorf("expected name 'http_fetch', got %q", tool.Name())
+	}
+}
+
+func TestHTTPFetchTool_Execute(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><head><style>body{}</style></head><body><h1>Title</h1><p>Hello &amp; welcome</p></body></html>"))
+	}))
+	defer server.Close()
+
+	tool := NewHTTPFetchTool(fetchTestSettings())
+
+	got, err := tool.Execute(map[string]any{"url": server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(got, "Status: 200 OK") || !strings.Contains(got, "<h1>Title</h1>") {
+		t.Errorf("expected status and raw body, got %q", got)
+	}
+
+	got, err = tool.Execute(map[string]any{"url": server.URL, "text": true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(got, "\n\nTitle\nHello & welcome") {
+		t.Errorf("expected stripped text, got %q", got)
+	}
+}
+
+func TestHTTPFetchTool_Execute_Truncates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 2000)))
+	}))
+	defer server.Close()
+
+	tool := NewHTTPFetchTool(fetchTestSettings())
+	got, err := tool.Execute(map[string]any{"url": server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(got, "(truncated at 1024 bytes)") {
+		t.Errorf("expected truncation marker, got %q", got)
+	}
+}
+
+func TestHTTPFetchTool_IgnoresProxy(t *testing.T) {
+	// A proxy would dial the target itself, past the private address check
+	t.Setenv("HTTP_PROXY", "http://proxy.example.com:3128")
+	t.Setenv("HTTPS_PROXY", "http://proxy.example.com:3128")
+	tool := NewHTTPFetchTool(fetchTestSettings())
+	if transport, ok := tool.client.Transport.(*http.Transport); !ok || transport.Proxy != nil {
+		t.Error("expected requests to bypass any configured proxy")
+	}
+}
+
+func TestHTTPFetchTool_Execute_Redirects(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, server.URL+r.URL.Path+"x", http.StatusFound)
+	}))
+	defer server.Close()
+
+	tool := NewHTTPFetchTool(fetchTestSettings())
+	_, err := tool.Execute(map[string]any{"url": server.URL + "/"})
+	if err == nil || !strings.Contains(err.Error(), "redirects") {
+		t.Errorf("expected redirect limit error, got %v", err)
+	}
+}
+
+func TestHTTPFetchTool_Execute_Rejects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+
+	settings := fetchTestSettings()
+	settings.Tools.Fetch.BlockPrivate = true
+	tool := NewHTTPFetchTool(settings)
+
+	_, err := tool.Execute(map[string]any{"url": server.URL})
+	if !errors.Is(err, errPrivateAddress) {
+		t.Errorf("expected private address error, got %v", err)
+	}
+
+	_, err = tool.Execute(map[string]any{"url": "file:///etc/passwd"})
+	if err == nil || !strings.Contains(err.Error(), "unsupported scheme") {
+		t.Errorf("expected scheme error, got %v", err)
+	}
+
+	settings.Tools.Fetch.AllowedHosts = []string{"example.com"}
+	_, err = tool.Execute(map[string]any{"url": "https://evil.test/"})
+	if err == nil || !strings.Contains(err.Error(), "not in allowed hosts") {
+		t.Errorf("expected host allowlist error, got %v", err)
+	}
+	if err := tool.checkURL(&url.URL{Scheme: "https", Host: "docs.example.com"}); err != nil {
+		t.Errorf("expected subdomain to be allowed, got %v", err)
+	}
+	// The shared egress policy applies on top of the fetch allowlist
+	settings.Tools.Network.DeniedHosts = []string{"docs.example.com"}
+	if err := tool.checkURL(&url.URL{Scheme: "https", Host: "docs.example.com"}); err == nil || !strings.Contains(err.Error(), "denied_hosts") {
+		t.Errorf("expected a denied host to be refused, got %v", err)
+	}
+}
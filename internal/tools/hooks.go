@@ -0,0 +1,29 @@
+package tools
+
+import "context"
+
+// Hooks are callbacks for one chat's tool calls. They travel in the context rather than being
+// set on the tools, which are shared by all chats running at the same time.
+// A hook that's set overrides the tool's own callback.
+type Hooks struct {
+	Command   CommandObserver
+	Output    OutputObserver
+	Recorder  CommandRecorder
+	Discovery DiscoveryObserver
+
+	// ConfirmWrite stands in for the write tool's confirmer, so it's only asked when the tool has one
+	ConfirmWrite WriteConfirmer
+}
+
+type hooksKey struct{}
+
+// WithHooks returns a context carrying hooks for the tool calls made within it
+func WithHooks(ctx context.Context, hooks Hooks) context.Context {
+	return context.WithValue(ctx, hooksKey{}, hooks)
+}
+
+// hooksFrom returns the hooks carried by ctx, if any
+func hooksFrom(ctx context.Context) Hooks {
+	hooks, _ := ctx.Value(hooksKey{}).(Hooks)
+	return hooks
+}
@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ExecLimiter bounds how many commands run at once across all sessions.
+// A nil limiter imposes no limit.
+type ExecLimiter struct {
+	slots    chan struct{}
+	wait     time.Duration
+	noun     string // What is limited, for the busy error
+	inFlight atomic.Int32
+	waiting  atomic.Int32
+}
+
+// NewExecLimiter creates a limiter allowing maxConcurrent commands, where callers queue for at most wait.
+// Returns nil (unlimited) when maxConcurrent is 0 or less.
+func NewExecLimiter(maxConcurrent int, wait time.Duration) *ExecLimiter {
+	return newLimiter(maxConcurrent, wait, "commands")
+}
+
+// NewToolCallLimiter creates a limiter allowing maxConcurrent tool calls of any tool,
+// where callers queue for at most wait. Returns nil (unlimited) when maxConcurrent is 0 or less.
+func NewToolCallLimiter(maxConcurrent int, wait time.Duration) *ExecLimiter {
+	return newLimiter(maxConcurrent, wait, "tool calls")
+}
+
+func newLimiter(maxConcurrent int, wait time.Duration, noun string) *ExecLimiter {
+	if maxConcurrent <= 0 {
+		return nil
+	}
+	return &ExecLimiter{
+		slots: make(chan struct{}, maxConcurrent),
+		wait:  wait,
+		noun:  noun,
+	}
+}
+
+// Acquire waits for a free slot and returns a function that releases it.
+// Returns an error if no slot frees up within the wait time.
+func (l *ExecLimiter) Acquire() (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+
+	l.waiting.Add(1)
+	select {
+	case l.slots <- struct{}{}:
+		l.waiting.Add(-1)
+	case <-timer.C:
+		l.waiting.Add(-1)
+		return nil, fmt.Errorf("busy: %d %s already running, try again later", cap(l.slots), l.noun)
+	}
+
+	l.inFlight.Add(1)
+	return func() {
+		l.inFlight.Add(-1)
+		<-l.slots
+	}, nil
+}
+
+// InFlight returns the number of commands currently running
+func (l *ExecLimiter) InFlight() int {
+	if l == nil {
+		return 0
+	}
+	return int(l.inFlight.Load())
+}
+
+// Waiting returns the number of callers queued for a free slot
+func (l *ExecLimiter) Waiting() int {
+	if l == nil {
+		return 0
+	}
+	return int(l.waiting.Load())
+}
+
+// Capacity returns how many may run at once, 0 when unlimited
+func (l *ExecLimiter) Capacity() int {
+	if l == nil {
+		return 0
+	}
+	return cap(l.slots)
+}
+
+// LimitConcurrency is a Middleware running tool calls within the limiter's budget.
+// A call that gets no slot within the wait fails with a busy error instead of running.
+func LimitConcurrency(l *ExecLimiter) Middleware {
+	return func(next ToolFunc) ToolFunc {
+		return func(name string, args map[string]any) (string, error) {
+			release, err := l.Acquire()
+			if err != nil {
+				return "", fmt.Errorf("%s not run: %w", name, err)
+			}
+			defer release()
+			return next(name, args)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecLimiter_Unlimited(t *testing.T) {
+	limiter := NewExecLimiter(0, time.Second)
+	if limiter != nil {
+		t.Fatal("expected nil limiter for unlimited concurrency")
+	}
+
+	release, err := limiter.Acquire()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release()
+	if limiter.InFlight() != 0 {
+		t.Errorf("expected 0 in flight, got %d", limiter.InFlight())
+	}
+}
+
+func TestExecLimiter_BusyAfterWait(t *testing.T) {
+	limiter := NewExecLimiter(1, 20*time.Millisecond)
+
+	release, err := limiter.Acquire()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if limiter.InFlight() != 1 {
+		t.Errorf("expected 1 in flight, got %d", limiter.InFlight())
+	}
+
+	if _, err := limiter.Acquire(); err == nil || !strings.Contains(err.Error(), "busy") {
+		t.Errorf("expected busy error, got %v", err)
+	}
+
+	release()
+	release2, err := limiter.Acquire()
+	if err != nil {
+		t.Fatalf("expected slot after release, got %v", err)
+	}
+	release2()
+	if limiter.InFlight() != 0 {
+		t.Errorf("expected 0 in flight, got %d", limiter.InFlight())
+	}
+}
+
+func TestExecLimiter_QueuedCallerGetsSlot(t *testing.T) {
+	limiter := NewExecLimiter(1, time.Second)
+
+	release, _ := limiter.Acquire()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		release()
+	}()
+
+	release2, err := limiter.Acquire()
+	if err != nil {
+		t.Fatalf("expected queued caller to get a slot, got %v", err)
+	}
+	release2()
+}
+
+func TestShellTool_Execute_SharesLimiter(t *testing.T) {
+	limiter := NewExecLimiter(1, 20*time.Millisecond)
+	tool := NewShellTool(testSettings())
+	tool.SetExecLimiter(limiter)
+
+	// Hold the only slot, as a discovery command would
+	release, _ := limiter.Acquire()
+	_, err := tool.Execute(map[string]any{"command": "echo hi"})
+	if err == nil || !strings.Contains(err.Error(), "busy") {
+		t.Errorf("expected busy error, got %v", err)
+	}
+	release()
+
+	if _, err := tool.Execute(map[string]any{"command": "echo hi"}); err != nil {
+		t.Errorf("unexpected error after release: %v", err)
+	}
+}
+
+func TestLimitConcurrency(t *testing.T) {
+	limiter := NewToolCallLimiter(1, 20*time.Millisecond)
+	registry := NewRegistry()
+	registry.Register(NewCalcTool())
+	registry.Use(LimitConcurrency(limiter))
+
+	if limiter.Capacity() != 1 {
+		t.Errorf("expected capacity 1, got %d", limiter.Capacity())
+	}
+
+	// Hold the only slot, as another session's tool call would
+	release, _ := limiter.Acquire()
+	_, err := registry.Execute("calculator", map[string]any{"expression": "1+1"})
+	if err == nil || !strings.Contains(err.Error(), "busy: 1 tool calls already running") {
+		t.Errorf("expected a busy error, got %v", err)
+	}
+	if limiter.Waiting() != 0 {
+		t.Errorf("expected no waiting calls after the timeout, got %d", limiter.Waiting())
+	}
+	release()
+
+	if _, err := registry.Execute("calculator", map[string]any{"expression": "1+1"}); err != nil {
+		t.Errorf("unexpected error after release: %v", err)
+	}
+	if limiter.InFlight() != 0 {
+		t.Errorf("expected the slot released after the call, got %d in flight", limiter.InFlight())
+	}
+}
@@ -0,0 +1,199 @@
+package tools
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/marciniwanicki/craby/internal/config"
+)
+
+// bareHostCommands take the host to connect to as a plain argument, e.g. "ping example.com"
+var bareHostCommands = map[string]bool{
+	"ping": true, "ping6": true, "traceroute": true, "mtr": true,
+	"ssh": true, "sftp": true, "ftp": true,
+	"nc": true, "ncat": true, "netcat": true, "telnet": true,
+	"dig": true, "nslookup": true, "host": true, "whois": true,
+}
+
+// remoteCopyCommands name remote files as host:path
+var remoteCopyCommands = map[string]bool{
+	"scp": true, "rsync": true, "sftp": true,
+}
+
+// redirectFlags make a network command connect somewhere other than the hosts in its arguments,
+// e.g. through a proxy, or read more arguments from a file the policy can't see
+var redirectFlags = []string{
+	"--proxy", "--preproxy", "--socks4", "--socks4a", "--socks5", "--socks5-hostname",
+	"--resolve", "--connect-to", "--config", "--execute", "--input-file",
+}
+
+// networkFlags describes the flags of common network commands that matter to the egress policy
+type networkFlags struct {
+	redirect    string   // Short flags that redirect the connection, like redirectFlags
+	shortValues string   // Short flags taking a value that isn't a host, e.g. curl -o out.html
+	longValues  []string // Long flags taking a value that isn't a host
+}
+
+var commandFlags = map[string]networkFlags{
+	"curl": {
+		redirect:    "xK",
+		shortValues: "oDcbTdHAeuwECrmXFYyzQtU",
+		longValues: []string{"--output", "--dump-header", "--cookie-jar", "--cookie", "--upload-file",
+			"--data", "--data-raw", "--data-binary", "--data-urlencode", "--header", "--user-agent",
+			"--referer", "--user", "--write-out", "--request", "--form", "--max-time", "--connect-timeout"},
+	},
+	"wget": {
+		redirect:    "ei",
+		shortValues: "OoaPUTtwQ",
+		longValues: []string{"--output-document", "--output-file", "--append-output", "--directory-prefix",
+			"--header", "--user-agent", "--timeout", "--tries", "--wait", "--user", "--password"},
+	},
+	"nc":     {redirect: "xXe"},
+	"ncat":   {redirect: "e"},
+	"netcat": {redirect: "xXe"},
+}
+
+// checkNetworkTargets rejects a network command targeting a host the egress policy doesn't allow.
+// With allowed hosts set, a command whose target can't be told from its arguments is rejected too.
+func checkNetworkTargets(settings *config.Settings, baseCmd string, args []string) error {
+	policy := settings.Tools.Network
+	if !policy.Restricted() || !slices.Contains(policy.NetworkCommandList(), baseCmd) {
+		return nil
+	}
+
+	targets, candidates, err := networkTargets(baseCmd, args)
+	if err != nil {
+		return fmt.Errorf("network access not allowed: %w", err)
+	}
+	// Arguments that only might be hosts, e.g. a URL without a scheme, are checked like targets,
+	// so one allowed target can't carry another host along
+	for _, host := range append(targets, candidates...) {
+		if err := policy.CheckHost(host); err != nil {
+			return fmt.Errorf("network access not allowed: %w", err)
+		}
+	}
+	if len(targets) == 0 && len(policy.AllowedHosts) > 0 {
+		return fmt.Errorf("network access not allowed: could not tell which host %s connects to, name it as a URL with a scheme, e.g. https://%s/",
+			baseCmd, policy.AllowedHosts[0])
+	}
+	return nil
+}
+
+// networkTargets extracts the hosts a network command connects to from its arguments: URLs,
+// user@host, host:path for remote copies, and host names or IPs for commands taking a bare host.
+// Candidates are host-like arguments that may also be something else, such as a file name.
+// Flags that redirect the connection are an error, the values of known flags aren't hosts.
+func networkTargets(baseCmd string, args []string) (targets, candidates []string, err error) {
+	flags := commandFlags[baseCmd]
+	skipValue := false
+	for _, arg := range args {
+		arg = strings.Trim(arg, `"'`)
+		if skipValue {
+			skipValue = false
+			continue
+		}
+		if strings.HasPrefix(arg, "--") {
+			name, value, hasValue := strings.Cut(arg, "=")
+			if slices.Contains(redirectFlags, name) {
+				return nil, nil, fmt.Errorf("%s can't be checked against the network policy", name)
+			}
+			// Only a URL given as a flag value, e.g. --url=https://example.com, is a target
+			if host, isURL := urlHost(value); hasValue && isURL {
+				targets = append(targets, host)
+			}
+			skipValue = !hasValue && slices.Contains(flags.longValues, name)
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			for i, c := range arg[1:] {
+				if strings.ContainsRune(flags.redirect, c) {
+					return nil, nil, fmt.Errorf("-%c can't be checked against the network policy", c)
+				}
+				if strings.ContainsRune(flags.shortValues, c) {
+					// The value is the rest of the argument, or the next one
+					skipValue = i == len(arg)-2
+					break
+				}
+			}
+			continue
+		}
+
+		if host, ok := urlHost(arg); ok {
+			targets = append(targets, host)
+			continue
+		}
+		if _, rest, ok := strings.Cut(arg, "@"); ok {
+			targets = append(targets, hostPart(rest))
+			continue
+		}
+		if remoteCopyCommands[baseCmd] {
+			if host, _, ok := strings.Cut(arg, ":"); ok && looksLikeHost(host) {
+				targets = append(targets, host)
+				continue
+			}
+		}
+
+		host := hostPart(arg)
+		if !looksLikeHost(host) {
+			continue
+		}
+		if bareHostCommands[baseCmd] {
+			targets = append(targets, host)
+		} else {
+			candidates = append(candidates, host)
+		}
+	}
+	return targets, candidates, nil
+}
+
+// urlHost returns the host of an argument written as a URL with a scheme
+func urlHost(arg string) (string, bool) {
+	if !strings.Contains(arg, "://") {
+		return "", false
+	}
+	u, err := url.Parse(arg)
+	if err != nil {
+		return "", true // Checked as a missing host
+	}
+	return u.Hostname(), true
+}
+
+// hostPart strips a path, port or remote path from a host, e.g. "example.com:22/x" becomes "example.com"
+func hostPart(s string) string {
+	s, _, _ = strings.Cut(s, "/")
+	if strings.HasPrefix(s, "[") {
+		// Bracketed IPv6 address, optionally with a port
+		if end := strings.Index(s, "]"); end > 0 {
+			return s[1:end]
+		}
+	}
+	if net.ParseIP(s) != nil {
+		return s
+	}
+	s, _, _ = strings.Cut(s, ":")
+	return s
+}
+
+// looksLikeHost reports whether s is an IP address, localhost, or a dotted host name
+func looksLikeHost(s string) bool {
+	if net.ParseIP(s) != nil || strings.EqualFold(s, "localhost") {
+		return true
+	}
+	if !strings.Contains(s, ".") || strings.HasPrefix(s, ".") || strings.HasSuffix(s, ".") {
+		return false
+	}
+	letters := false
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+			letters = true
+		case c >= '0' && c <= '9', c == '-', c == '.':
+		default:
+			return false
+		}
+	}
+	return letters
+}
@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNetworkTargets(t *testing.T) {
+	tests := []struct {
+		command    string
+		targets    []string
+		candidates []string
+	}{
+		{"curl -s https://api.example.com/v1?q=1", []string{"api.example.com"}, nil},
+		{"curl -o out.html https://Example.com", []string{"Example.com"}, nil},
+		{"curl -so out.html --header Host:x.test https://Example.com evil.test/x", []string{"Example.com"}, []string{"evil.test"}},
+		{"wget -O page.html --output-file=log.txt https://example.com", []string{"example.com"}, nil},
+		{"curl --url=http://[::1]:8080/x", []string{"::1"}, nil},
+		{"wget example.com/file.tar.gz", nil, []string{"example.com"}},
+		{"ping -c 3 10.0.0.1", []string{"10.0.0.1"}, nil},
+		{"ssh -p 2222 deploy@build.example.com uptime", []string{"build.example.com"}, nil},
+		{"scp notes.txt backup.example.com:/srv/", []string{"backup.example.com"}, []string{"notes.txt"}},
+		{"dig @1.1.1.1 example.org", []string{"1.1.1.1", "example.org"}, nil},
+		{"nc localhost 8080", []string{"localhost"}, nil},
+		{"ssh myalias", nil, nil},
+	}
+	for _, tt := range tests {
+		parts := strings.Fields(tt.command)
+		targets, candidates, err := networkTargets(parts[0], parts[1:])
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.command, err)
+		}
+		if !slices.Equal(targets, tt.targets) || !slices.Equal(candidates, tt.candidates) {
+			t.Errorf("%s: got targets %q and candidates %q, want %q and %q", tt.command, targets, candidates, tt.targets, tt.candidates)
+		}
+	}
+}
+
+func TestShellTool_ValidateCommand_NetworkPolicy(t *testing.T) {
+	settings := testSettings()
+	settings.Tools.Shell.Allowlist = append(settings.Tools.Shell.Allowlist, "curl", "wget", "ping")
+	tool := NewShellTool(settings)
+
+	// Without a policy, network commands are only subject to the allowlist
+	if err := tool.validateCommand("curl https://anywhere.test/", false); err != nil {
+		t.Fatalf("expected no egress policy by default, got %v", err)
+	}
+
+	settings.Tools.Network.DeniedHosts = []string{"internal.corp"}
+	for _, command := range []string{"curl https://wiki.internal.corp/", "wget -q wiki.internal.corp/page", "ping internal.corp"} {
+		if err := tool.validateCommand(command, false); err == nil {
+			t.Errorf("expected %q to be denied", command)
+		}
+	}
+	if err := tool.validateCommand("curl https://example.com/", false); err != nil {
+		t.Errorf("expected a host that isn't denied to pass, got %v", err)
+	}
+
+	settings.Tools.Network.AllowedHosts = []string{"example.com"}
+	for command, want := range map[string]string{
+		"curl https://docs.example.com/":        "",
+		"ping -c 1 example.com":                 "",
+		"echo https://evil.test/":               "", // Not a network command
+		"curl https://evil.test/":               "not in tools.network.allowed_hosts",
+		"curl example.com":                      "could not tell which host",
+		"ping myalias":                          "could not tell which host",
+		"curl https://x.internal.corp/":         "denied_hosts",
+		"curl -o evil.test https://example.com": "",
+		// A second, schemeless host can't ride along with an allowed one
+		"curl https://example.com/ evil.test/x": "host evil.test is not in",
+		"wget https://example.com evil.test":    "host evil.test is not in",
+		// Nor can a proxy or an address override
+		"curl -x proxy.evil.test:8080 https://example.com/":                    "-x can't be checked",
+		"curl -sx proxy.evil.test:8080 https://example.com/":                   "-x can't be checked",
+		"curl --proxy=http://proxy.evil.test https://example.com/":             "--proxy can't be checked",
+		"curl --preproxy socks5://evil.test https://example.com/":              "--preproxy can't be checked",
+		"curl --resolve example.com:443:6.6.6.6 https://example.com/":          "--resolve can't be checked",
+		"curl --connect-to example.com:443:evil.test:443 https://example.com/": "--connect-to can't be checked",
+		"wget -e use_proxy=on https://example.com/":                            "-e can't be checked",
+	} {
+		err := tool.validateCommand(command, false)
+		if want == "" && err != nil {
+			t.Errorf("expected %q to pass, got %v", command, err)
+		}
+		if want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("expected %q to fail with %q, got %v", command, want, err)
+		}
+	}
+}
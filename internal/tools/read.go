@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/marciniwanicki/craby/internal/config"
+)
+
+// binarySniffLen is how many leading bytes are inspected to detect binary files
+const binarySniffLen = 8000
+
+// ReadFileTool reads file contents from within a configured root directory
+type ReadFileTool struct {
+	settings *config.Settings
+}
+
+// NewReadFileTool creates a new read_file tool
+func NewReadFileTool(settings *config.Settings) *ReadFileTool {
+	return &ReadFileTool{
+		settings: settings,
+	}
+}
+
+func (t *ReadFileTool) Name() string {
+	return "read_file"
+}
+
+func (t *ReadFileTool) Description() string {
+	return "Read the contents of a text file. Prefer this over shell commands like cat. " +
+		"Paths must be inside: " + t.settings.Tools.Read.Root
+}
+
+func (t *ReadFileTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "The file path to read (absolute, ~-prefixed, or relative to the root directory)",
+			},
+		},
+		"required": []string{"path"},
+	}
+}
+
+func (t *ReadFileTool) Execute(args map[string]any) (string, error) {
+	pathRaw, ok := args["path"]
+	if !ok {
+		return "", fmt.Errorf("missing required parameter: path")
+	}
+	path, ok := pathRaw.(string)
+	if !ok {
+		return "", fmt.Errorf("path must be a string")
+	}
+
+	absPath, err := t.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+
+	file, err := os.Open(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	// Read one byte past the cap so we know whether the file was truncated
+	maxBytes := t.settings.Tools.Read.MaxBytes
+	var reader io.Reader = file
+	if maxBytes > 0 {
+		reader = io.LimitReader(file, int64(maxBytes)+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if isBinary(data) {
+		return "", fmt.Errorf("%s appears to be a binary file", path)
+	}
+
+	if maxBytes > 0 && len(data) > maxBytes {
+		// Don't cut a multi-byte character in half
+		cut := maxBytes
+		for cut > 0 && !utf8.RuneStart(data[cut]) {
+			cut--
+		}
+		return string(data[:cut]) + fmt.Sprintf("\n... (truncated, file is %d bytes)", info.Size()), nil
+	}
+
+	return string(data), nil
+}
+
+// resolvePath resolves path against the read root and rejects paths that escape it,
+// including through symlinks, or that are blocked
+func (t *ReadFileTool) resolvePath(path string) (string, error) {
+	_, resolved, err := resolveInRoot(t.settings.Tools.Read.Root, path, "read")
+	if err != nil {
+		return "", err
+	}
+	if blocked, reason := t.settings.IsReadPathBlocked(resolved); blocked {
+		return "", fmt.Errorf("read not allowed: %s", reason)
+	}
+	return resolved, nil
+}
+
+// resolveInRoot resolves path against rootSetting, returning both with symlinks evaluated.
+// Paths escaping the root are rejected with an error naming the action, e.g. "read not allowed".
+func resolveInRoot(rootSetting, path, action string) (root, resolved string, err error) {
+	root, err = filepath.Abs(config.ExpandPath(rootSetting))
+	if err != nil {
+		return "", "", fmt.Errorf("invalid root directory: %w", err)
+	}
+	if evaluated, err := filepath.EvalSymlinks(root); err == nil {
+		root = evaluated
+	}
+
+	target := config.ExpandPath(path)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(root, target)
+	}
+	target = filepath.Clean(target)
+
+	resolved, err = filepath.EvalSymlinks(target)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	if resolved != root && !strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("%s not allowed: path is outside %s", action, rootSetting)
+	}
+
+	return root, resolved, nil
+}
+
+// isBinary reports whether data looks like binary content
+func isBinary(data []byte) bool {
+	sniff := data
+	if len(sniff) > binarySniffLen {
+		sniff = sniff[:binarySniffLen]
+	}
+	return bytes.IndexByte(sniff, 0) >= 0
+}
@@ -0,0 +1,148 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marciniwanicki/craby/internal/config"
+)
+
+func readTestSettings(root string, maxBytes int) *config.Settings {
+	return &config.Settings{
+		Tools: config.ToolsSettings{
+			Read: config.ReadSettings{
+				Enabled:  true,
+				Root:     root,
+				MaxBytes: maxBytes,
+			},
+		},
+	}
+}
+
+func TestReadFileTool_Name(t *testing.T) {
+	tool := NewReadFileTool(readTestSettings("/tmp", 0))
+	if tool.Name() != "read_file" {
+		t.Errorf("expected name 'read_file', got %q", tool.Name())
+	}
+}
+
+func TestReadFileTool_Execute(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "notes.txt"), []byte("hello world"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tool := NewReadFileTool(readTestSettings(root, 0))
+
+	// Relative to root
+	got, err := tool.Execute(map[string]any{"path": "notes.txt"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "hello world" {
+		t.Errorf("expected file contents, got %q", got)
+	}
+
+	// Absolute path inside root
+	got, err = tool.Execute(map[string]any{"path": filepath.Join(root, "notes.txt")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "hello world" {
+		t.Errorf("expected file contents, got %q", got)
+	}
+}
+
+func TestReadFileTool_Execute_Truncates(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "big.txt"), []byte(strings.Repeat("a", 100)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tool := NewReadFileTool(readTestSettings(root, 10))
+	got, err := tool.Execute(map[string]any{"path": "big.txt"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(got, strings.Repeat("a", 10)+"\n") {
+		t.Errorf("expected first 10 bytes, got %q", got)
+	}
+	if !strings.Contains(got, "truncated, file is 100 bytes") {
+		t.Errorf("expected truncation marker, got %q", got)
+	}
+}
+
+func TestReadFileTool_Execute_Rejects(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "image.bin"), []byte{0x89, 'P', 'N', 'G', 0x00, 0x01}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	tool := NewReadFileTool(readTestSettings(root, 0))
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"outside root", filepath.Join(outside, "secret.txt"), "outside"},
+		{"dot dot escape", "../" + filepath.Base(outside) + "/secret.txt", "outside"},
+		{"symlink escape", "link.txt", "outside"},
+		{"binary", "image.bin", "binary"},
+		{"directory", ".", "directory"},
+		{"missing", "missing.txt", "failed to resolve"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tool.Execute(map[string]any{"path": tt.path})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestReadFileTool_Execute_Blocked(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, name := range []string{".ssh/id_ed25519", ".aws/credentials", "notes.txt", "private/key.txt"} {
+		path := filepath.Join(home, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("secret"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settings := config.DefaultSettings()
+	settings.Tools.Read.Enabled = true
+	settings.Tools.Write.BlockedPaths = append(settings.Tools.Write.BlockedPaths, "~/private")
+	tool := NewReadFileTool(settings)
+
+	for _, path := range []string{"~/.ssh/id_ed25519", ".aws/credentials", "private/key.txt"} {
+		if _, err := tool.Execute(map[string]any{"path": path}); err == nil || !strings.Contains(err.Error(), "path is blocked") {
+			t.Errorf("expected %s blocked, got %v", path, err)
+		}
+	}
+	if got, err := tool.Execute(map[string]any{"path": "notes.txt"}); err != nil || got != "secret" {
+		t.Errorf("expected notes.txt readable, got %q, %v", got, err)
+	}
+}
+
+func TestReadFileTool_Execute_MissingPath(t *testing.T) {
+	tool := NewReadFileTool(readTestSettings("/tmp", 0))
+	if _, err := tool.Execute(map[string]any{}); err == nil {
+		t.Error("expected error for missing path")
+	}
+}
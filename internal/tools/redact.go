@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/marciniwanicki/craby/internal/config"
+)
+
+const redactedPlaceholder = "[REDACTED]"
+
+// minSecretLength is the shortest env var value that will be masked, to avoid mangling output with short values
+const minSecretLength = 6
+
+// tokenPatterns match common credential formats regardless of where they came from
+var tokenPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`gh[pousr]_[A-Za-z0-9]{36,}`),                                       // GitHub tokens
+	regexp.MustCompile(`github_pat_[A-Za-z0-9_]{22,}`),                                     // GitHub fine-grained tokens
+	regexp.MustCompile(`sk-[A-Za-z0-9_\-]{20,}`),                                           // OpenAI-style keys
+	regexp.MustCompile(`AKIA[0-9A-Z]{16}`),                                                 // AWS access key IDs
+	regexp.MustCompile(`xox[abprs]-[A-Za-z0-9\-]{10,}`),                                    // Slack tokens
+	regexp.MustCompile(`eyJ[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}`), // JWTs
+}
+
+// assignmentPattern matches key=value or key: value pairs with a sensitive-looking key
+var assignmentPattern = regexp.MustCompile(`(?i)\b([A-Za-z0-9_\-]*(?:token|secret|password|passwd|api[_\-]?key)[A-Za-z0-9_\-]*)(\s*[:=]\s*)("[^"]*"|'[^']*'|[^\s"']+)`)
+
+// bearerPattern matches HTTP bearer credentials
+var bearerPattern = regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9\-._~+/]+=*`)
+
+// Redactor masks secrets in text before it leaves the daemon
+type Redactor struct {
+	values []string // Known secret values, longest first
+}
+
+// NewRedactor creates a redactor that masks the values of sensitive env vars from the
+// process environment and from external tool configurations.
+// Returns nil if redaction is disabled.
+func NewRedactor(settings config.RedactionSettings, externalTools []*config.ExternalTool) *Redactor {
+	if !settings.Enabled {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var values []string
+	add := func(name, value string) {
+		if len(value) < minSecretLength || seen[value] || !isSensitiveKey(name, settings.SensitiveKeys) {
+			return
+		}
+		seen[value] = true
+		values = append(values, value)
+	}
+
+	for _, entry := range os.Environ() {
+		if name, value, ok := strings.Cut(entry, "="); ok {
+			add(name, value)
+		}
+	}
+
+	// Values as the tools' commands see them, with ${NAME} references expanded. A tool whose
+	// environment can't be built never runs, so it has no values to mask.
+	for _, ext := range externalTools {
+		env, err := ext.BuildEnv()
+		if err != nil {
+			continue
+		}
+		for _, entry := range env {
+			if name, value, ok := strings.Cut(entry, "="); ok {
+				add(name, value)
+			}
+		}
+	}
+
+	// Replace longer values first so a secret containing another isn't partially masked
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+
+	return &Redactor{values: values}
+}
+
+// isSensitiveKey reports whether an env var name contains any of the sensitive key fragments
+func isSensitiveKey(name string, keys []string) bool {
+	upper := strings.ToUpper(name)
+	for _, key := range keys {
+		if key != "" && strings.Contains(upper, strings.ToUpper(key)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact masks known secret values and common token patterns in s.
+// A nil redactor returns s unchanged.
+func (r *Redactor) Redact(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+
+	for _, value := range r.values {
+		s = strings.ReplaceAll(s, value, redactedPlaceholder)
+	}
+
+	for _, pattern := range tokenPatterns {
+		s = pattern.ReplaceAllString(s, redactedPlaceholder)
+	}
+
+	s = bearerPattern.ReplaceAllString(s, "${1}"+redactedPlaceholder)
+	s = assignmentPattern.ReplaceAllString(s, "${1}${2}"+redactedPlaceholder)
+
+	return s
+}
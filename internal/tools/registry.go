@@ -1,48 +1,130 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"sync"
 )
 
+// ToolFunc executes a tool by name with the given arguments
+type ToolFunc func(name string, args map[string]any) (string, error)
+
+// Middleware wraps tool execution with cross-cutting behavior such as timing or logging
+type Middleware func(next ToolFunc) ToolFunc
+
 // Registry manages available tools
 type Registry struct {
-	mu    sync.RWMutex
-	tools map[string]Tool
+	mu          sync.RWMutex
+	tools       map[string]Tool
+	aliases     map[string]string // alias -> canonical tool name
+	middlewares []Middleware
 }
 
 // NewRegistry creates a new tool registry
 func NewRegistry() *Registry {
 	return &Registry{
-		tools: make(map[string]Tool),
+		tools:   make(map[string]Tool),
+		aliases: make(map[string]string),
 	}
 }
 
-// Register adds a tool to the registry
+// Register adds a tool to the registry. A tool name takes precedence over an alias of the same name.
 func (r *Registry) Register(t Tool) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.tools[t.Name()] = t
+	delete(r.aliases, t.Name())
+}
+
+// RegisterAlias makes alias refer to the tool registered as name.
+// Aliasing an alias points at its tool, so aliases never chain or form cycles.
+func (r *Registry) RegisterAlias(alias, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tools[alias]; ok {
+		return fmt.Errorf("alias %s collides with a tool name", alias)
+	}
+	canonical := r.resolve(name)
+	if _, ok := r.tools[canonical]; !ok {
+		return fmt.Errorf("unknown tool: %s", name)
+	}
+	if existing, ok := r.aliases[alias]; ok && existing != canonical {
+		return fmt.Errorf("alias %s already refers to %s", alias, existing)
+	}
+
+	r.aliases[alias] = canonical
+	return nil
+}
+
+// Resolve returns the canonical tool name for a name or alias
+func (r *Registry) Resolve(name string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.resolve(name)
+}
+
+func (r *Registry) resolve(name string) string {
+	if canonical, ok := r.aliases[name]; ok {
+		return canonical
+	}
+	return name
 }
 
-// Get retrieves a tool by name
+// Get retrieves a tool by name or alias
 func (r *Registry) Get(name string) (Tool, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	t, ok := r.tools[name]
+	t, ok := r.tools[r.resolve(name)]
 	return t, ok
 }
 
-// Execute runs a tool by name with the given arguments
+// Use adds middlewares around Execute. Middlewares run in the order they were added,
+// the first one being the outermost.
+func (r *Registry) Use(middlewares ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// Execute runs a tool by name or alias with the given arguments, passing through all middlewares.
+// Middlewares see the canonical tool name. An unknown tool fails before reaching them.
 func (r *Registry) Execute(name string, args map[string]any) (string, error) {
+	return r.ExecuteContext(context.Background(), name, args)
+}
+
+// ExecuteContext runs a tool like Execute, passing ctx to tools that take one, e.g. for per-run hooks
+func (r *Registry) ExecuteContext(ctx context.Context, name string, args map[string]any) (string, error) {
+	r.mu.RLock()
+	name = r.resolve(name)
+	if _, ok := r.tools[name]; !ok {
+		r.mu.RUnlock()
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+	run := ToolFunc(func(name string, args map[string]any) (string, error) {
+		return r.execute(ctx, name, args)
+	})
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		run = r.middlewares[i](run)
+	}
+	r.mu.RUnlock()
+
+	return run(name, args)
+}
+
+// execute runs the tool itself, without middlewares
+func (r *Registry) execute(ctx context.Context, name string, args map[string]any) (string, error) {
 	t, ok := r.Get(name)
 	if !ok {
 		return "", fmt.Errorf("unknown tool: %s", name)
 	}
+	if ct, ok := t.(ContextTool); ok {
+		return ct.ExecuteContext(ctx, args)
+	}
 	return t.Execute(args)
 }
 
-// List returns all registered tools
+// List returns all registered tools, without aliases
 func (r *Registry) List() []Tool {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
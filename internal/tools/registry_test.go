@@ -2,7 +2,9 @@ package tools
 
 import (
 	"errors"
+	"slices"
 	"testing"
+	"time"
 )
 
 func newTestTool(name string, execFunc func(args map[string]any) (string, error)) *mockTool {
@@ -81,6 +83,151 @@ func TestRegistry_Execute_ToolError(t *testing.T) {
 	}
 }
 
+// timingMiddleware is an example middleware recording how long each tool call took
+func timingMiddleware(durations map[string]time.Duration) Middleware {
+	return func(next ToolFunc) ToolFunc {
+		return func(name string, args map[string]any) (string, error) {
+			start := time.Now()
+			result, err := next(name, args)
+			durations[name] = time.Since(start)
+			return result, err
+		}
+	}
+}
+
+func TestRegistry_Use_Timing(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(newTestTool("slow_tool", func(args map[string]any) (string, error) {
+		time.Sleep(10 * time.Millisecond)
+		return "done", nil
+	}))
+
+	durations := make(map[string]time.Duration)
+	registry.Use(timingMiddleware(durations))
+
+	result, err := registry.Execute("slow_tool", nil)
+	if err != nil || result != "done" {
+		t.Fatalf("unexpected result %q, err: %v", result, err)
+	}
+	if durations["slow_tool"] < 10*time.Millisecond {
+		t.Errorf("expected recorded duration of at least 10ms, got %v", durations["slow_tool"])
+	}
+}
+
+func TestRegistry_Use_Order(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(newTestTool("my_tool", func(args map[string]any) (string, error) {
+		return "tool", nil
+	}))
+
+	var calls []string
+	trace := func(label string) Middleware {
+		return func(next ToolFunc) ToolFunc {
+			return func(name string, args map[string]any) (string, error) {
+				calls = append(calls, label+" before")
+				result, err := next(name, args)
+				calls = append(calls, label+" after")
+				return result, err
+			}
+		}
+	}
+	registry.Use(trace("first"), trace("second"))
+
+	if _, err := registry.Execute("my_tool", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"first before", "second before", "second after", "first after"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("expected %v, got %v", want, calls)
+	}
+}
+
+func TestRegistry_Use_ShortCircuit(t *testing.T) {
+	registry := NewRegistry()
+	executed := false
+	registry.Register(newTestTool("my_tool", func(args map[string]any) (string, error) {
+		executed = true
+		return "tool", nil
+	}))
+
+	blocked := errors.New("blocked")
+	registry.Use(func(next ToolFunc) ToolFunc {
+		return func(name string, args map[string]any) (string, error) {
+			return "", blocked
+		}
+	})
+
+	if _, err := registry.Execute("my_tool", nil); !errors.Is(err, blocked) {
+		t.Errorf("expected blocked error, got %v", err)
+	}
+	if executed {
+		t.Error("expected tool not to run when middleware short-circuits")
+	}
+}
+
+func TestRegistry_RegisterAlias(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(newTestTool("kubernetes_pods", func(args map[string]any) (string, error) {
+		return "pods", nil
+	}))
+
+	if err := registry.RegisterAlias("pods", "kubernetes_pods"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, ok := registry.Get("pods")
+	if !ok || got.Name() != "kubernetes_pods" {
+		t.Fatalf("expected alias to resolve to kubernetes_pods, got %v", got)
+	}
+
+	var seen string
+	registry.Use(func(next ToolFunc) ToolFunc {
+		return func(name string, args map[string]any) (string, error) {
+			seen = name
+			return next(name, args)
+		}
+	})
+	result, err := registry.Execute("pods", nil)
+	if err != nil || result != "pods" {
+		t.Fatalf("unexpected result %q, err: %v", result, err)
+	}
+	if seen != "kubernetes_pods" {
+		t.Errorf("expected middleware to see canonical name, got %q", seen)
+	}
+
+	if len(registry.List()) != 1 || len(registry.Definitions()) != 1 {
+		t.Error("expected aliases not to be listed")
+	}
+}
+
+func TestRegistry_RegisterAlias_Errors(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(newTestTool("a", nil))
+	registry.Register(newTestTool("b", nil))
+
+	if err := registry.RegisterAlias("b", "a"); err == nil {
+		t.Error("expected error for alias colliding with a tool name")
+	}
+	if err := registry.RegisterAlias("x", "missing"); err == nil {
+		t.Error("expected error for alias of unknown tool")
+	}
+	if err := registry.RegisterAlias("x", "a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := registry.RegisterAlias("x", "b"); err == nil {
+		t.Error("expected error for alias already referring to another tool")
+	}
+
+	// Aliasing an alias points at the tool, so no chains or cycles form
+	if err := registry.RegisterAlias("y", "x"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := registry.Resolve("y"); got != "a" {
+		t.Errorf("expected y to resolve to a, got %q", got)
+	}
+}
+
 func TestRegistry_List(t *testing.T) {
 	registry := NewRegistry()
 
@@ -157,3 +304,31 @@ func TestRegistry_ConcurrentAccess(t *testing.T) {
 		<-done
 	}
 }
+
+func TestToolStats(t *testing.T) {
+	stats := NewToolStats()
+	registry := NewRegistry()
+	registry.Register(NewCalcTool())
+	registry.Register(NewTimeTool())
+	registry.Use(stats.Middleware())
+
+	_, _ = registry.Execute("calculator", map[string]any{"expression": "1+1"})
+	_, _ = registry.Execute("calculator", map[string]any{"expression": "1+"})
+	_, _ = registry.Execute("current_time", map[string]any{})
+	_, _ = registry.Execute("made_up_tool", map[string]any{})
+
+	snapshot := stats.Snapshot()
+	if len(snapshot) != 2 {
+		t.Fatalf("expected stats for the 2 registered tools only, got %+v", snapshot)
+	}
+	calc := snapshot[0]
+	if calc.Name != "calculator" || calc.Calls != 2 || calc.Errors != 1 {
+		t.Errorf("unexpected calculator stats: %+v", calc)
+	}
+	if calc.MaxDuration > calc.TotalDuration || calc.AverageDuration() > calc.MaxDuration {
+		t.Errorf("inconsistent durations: %+v", calc)
+	}
+	if snapshot[1].Calls != 1 || snapshot[1].Errors != 0 {
+		t.Errorf("unexpected time stats: %+v", snapshot[1])
+	}
+}
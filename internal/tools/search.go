@@ -0,0 +1,295 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/marciniwanicki/craby/internal/config"
+)
+
+const (
+	// searchMaxFiles bounds how many files one search visits, so searching a large tree still returns
+	searchMaxFiles = 20000
+	// searchMaxFileSize skips larger files when matching content
+	searchMaxFileSize = 1024 * 1024
+	// searchMatchesPerFile is how many matching lines are shown for each file
+	searchMatchesPerFile = 5
+	// searchSnippetLen is the longest line snippet shown for a match
+	searchSnippetLen = 200
+)
+
+// errSearchLimit stops the walk once a search has enough results
+var errSearchLimit = errors.New("search limit reached")
+
+// SearchTool finds files by name and content within the read root
+type SearchTool struct {
+	settings *config.Settings
+	redactor *Redactor // Masks secrets in matching lines (nil = disabled)
+}
+
+// NewSearchTool creates a new search_files tool
+func NewSearchTool(settings *config.Settings) *SearchTool {
+	return &SearchTool{
+		settings: settings,
+		redactor: NewRedactor(settings.Redaction, nil),
+	}
+}
+
+func (t *SearchTool) Name() string {
+	return "search_files"
+}
+
+func (t *SearchTool) Description() string {
+	return "Find files by name and/or search their contents, returning matching paths with line snippets. " +
+		"Prefer this over shell commands like find or grep. Hidden and blocked files are skipped. " +
+		"Searches must be inside: " + t.settings.Tools.Read.Root
+}
+
+func (t *SearchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Directory to search (absolute, ~-prefixed, or relative to the root directory; default: the root)",
+			},
+			"name": map[string]any{
+				"type":        "string",
+				"description": "Glob matched against file names, e.g. \"*.go\" or \"README*\"",
+			},
+			"content": map[string]any{
+				"type":        "string",
+				"description": "Regular expression matched against each line, e.g. \"func main\" or \"(?i)todo\"",
+			},
+		},
+	}
+}
+
+// searchQuery is a validated search_files call
+type searchQuery struct {
+	root    string // Read root, resolved
+	dir     string // Directory to search, resolved
+	name    string
+	content *regexp.Regexp
+}
+
+func (t *SearchTool) Execute(args map[string]any) (string, error) {
+	query, err := t.parseQuery(args)
+	if err != nil {
+		return "", err
+	}
+
+	s := &searcher{query: query, settings: t.settings, maxResults: t.settings.Tools.Search.MaxResults}
+	err = filepath.WalkDir(query.dir, s.visit)
+	if err != nil && !errors.Is(err, errSearchLimit) {
+		return "", fmt.Errorf("search failed: %w", err)
+	}
+
+	if len(s.results) == 0 {
+		return "No matches found.", nil
+	}
+	output := strings.Join(s.results, "\n")
+	if errors.Is(err, errSearchLimit) {
+		output += fmt.Sprintf("\n... (stopped after %s, narrow the search for more)", s.limitReason())
+	}
+	return truncateOutput(t.redactor.Redact(output), t.settings.Tools.Search.MaxBytes, 0), nil
+}
+
+// parseQuery validates the arguments and resolves the directory within the read root
+func (t *SearchTool) parseQuery(args map[string]any) (*searchQuery, error) {
+	str := func(key string) (string, error) {
+		raw, ok := args[key]
+		if !ok || raw == nil {
+			return "", nil
+		}
+		s, ok := raw.(string)
+		if !ok {
+			return "", fmt.Errorf("%s must be a string", key)
+		}
+		return s, nil
+	}
+	path, err := str("path")
+	if err != nil {
+		return nil, err
+	}
+	name, err := str("name")
+	if err != nil {
+		return nil, err
+	}
+	content, err := str("content")
+	if err != nil {
+		return nil, err
+	}
+	if name == "" && content == "" {
+		return nil, fmt.Errorf("give a name glob, a content pattern, or both")
+	}
+
+	query := &searchQuery{name: name}
+	if name != "" {
+		if _, err := filepath.Match(name, ""); err != nil {
+			return nil, fmt.Errorf("invalid name glob %q: %w", name, err)
+		}
+	}
+	if content != "" {
+		if query.content, err = regexp.Compile(content); err != nil {
+			return nil, fmt.Errorf("invalid content pattern: %w", err)
+		}
+	}
+
+	if path == "" {
+		path = "."
+	}
+	query.root, query.dir, err = resolveInRoot(t.settings.Tools.Read.Root, path, "search")
+	if err != nil {
+		return nil, err
+	}
+	if rel, err := filepath.Rel(query.root, query.dir); err == nil && hasHiddenPart(rel) {
+		return nil, fmt.Errorf("search not allowed: %s is hidden", path)
+	}
+	if blocked, reason := t.settings.IsReadPathBlocked(query.dir); blocked {
+		return nil, fmt.Errorf("search not allowed: %s", reason)
+	}
+	if info, err := os.Stat(query.dir); err != nil {
+		return nil, fmt.Errorf("failed to stat directory: %w", err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", path)
+	}
+	return query, nil
+}
+
+// searcher collects results while walking the searched directory
+type searcher struct {
+	query      *searchQuery
+	settings   *config.Settings
+	maxResults int // 0 = unlimited
+	results    []string
+	files      int
+}
+
+// visit matches one walked entry, returning errSearchLimit once enough is found
+func (s *searcher) visit(path string, d fs.DirEntry, err error) error {
+	if err != nil {
+		// Unreadable directories are skipped rather than failing the search
+		if d != nil && d.IsDir() && path != s.query.dir {
+			return fs.SkipDir
+		}
+		return nil
+	}
+	if path != s.query.dir && s.skipped(path, d) {
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	}
+	// Symlinks could point outside the root
+	if d.IsDir() || !d.Type().IsRegular() {
+		return nil
+	}
+
+	s.files++
+	if s.files > searchMaxFiles {
+		return errSearchLimit
+	}
+	if s.query.name != "" {
+		if ok, _ := filepath.Match(s.query.name, d.Name()); !ok {
+			return nil
+		}
+	}
+
+	rel, err := filepath.Rel(s.query.root, path)
+	if err != nil {
+		rel = path
+	}
+	if s.query.content == nil {
+		return s.add(rel)
+	}
+	return s.matchContent(path, rel, d)
+}
+
+// skipped reports whether an entry is hidden, e.g. ~/.netrc, or under a blocked path
+func (s *searcher) skipped(path string, d fs.DirEntry) bool {
+	if strings.HasPrefix(d.Name(), ".") {
+		return true
+	}
+	blocked, _ := s.settings.IsReadPathBlocked(path)
+	return blocked
+}
+
+// matchContent adds the lines of a file matching the content pattern
+func (s *searcher) matchContent(path, rel string, d fs.DirEntry) error {
+	info, err := d.Info()
+	if err != nil || info.Size() > searchMaxFileSize {
+		return nil
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // G304: path is confined to the read root
+	if err != nil || isBinary(data) {
+		return nil
+	}
+
+	matches := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), searchMaxFileSize)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if !s.query.content.MatchString(text) {
+			continue
+		}
+		matches++
+		if matches > searchMatchesPerFile {
+			s.results[len(s.results)-1] += "\n... (more matches in " + rel + ")"
+			return nil
+		}
+		if err := s.add(fmt.Sprintf("%s:%d: %s", rel, line, snippet(text))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add records a result, returning errSearchLimit once the maximum is reached
+func (s *searcher) add(result string) error {
+	s.results = append(s.results, result)
+	if s.maxResults > 0 && len(s.results) >= s.maxResults {
+		return errSearchLimit
+	}
+	return nil
+}
+
+// limitReason says which bound stopped the search
+func (s *searcher) limitReason() string {
+	if s.files > searchMaxFiles {
+		return fmt.Sprintf("visiting %d files", searchMaxFiles)
+	}
+	return fmt.Sprintf("%d results", len(s.results))
+}
+
+// hasHiddenPart reports whether any element of a relative path starts with a dot
+func hasHiddenPart(rel string) bool {
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if part != "." && part != ".." && strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// snippet trims a matching line for display
+func snippet(line string) string {
+	line = strings.TrimSpace(line)
+	if len(line) <= searchSnippetLen {
+		return line
+	}
+	cut := searchSnippetLen
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return line[:cut] + "..."
+}
@@ -3,30 +3,64 @@ package tools
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os/exec"
+	"slices"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/marciniwanicki/craby/internal/config"
+	"github.com/marciniwanicki/craby/internal/procgroup"
 )
 
 const shellTimeout = 30 * time.Second
 
+// shellWaitDelay bounds waiting for output after the shell exits or is killed, in case a
+// background process it started still holds the output open
+const shellWaitDelay = time.Second
+
 // CommandObserver is called when a shell command is executed
 type CommandObserver func(command string)
 
+// CommandRecord describes a finished command, for auditing
+type CommandRecord struct {
+	Command     string // Redacted command line
+	IsDiscovery bool   // True for help lookups made during command discovery
+	ExitCode    int    // -1 if the command didn't exit normally, e.g. it timed out
+	Duration    time.Duration
+	Output      string // Output as returned to the model
+}
+
+// CommandRecorder is called after a command finishes
+type CommandRecorder func(record CommandRecord)
+
+// OutputObserver is called with each line of output while a shell command runs
+type OutputObserver func(line string)
+
 // ShellTool executes shell commands from an allowlist
 type ShellTool struct {
 	settings      *config.Settings
 	externalTools []*config.ExternalTool
 	observer      CommandObserver // Optional callback when commands are executed
+	outputObs     OutputObserver  // Optional callback for output lines as they are produced
+	recorder      CommandRecorder // Optional callback when commands finish
+	limiter       *ExecLimiter    // Bounds concurrent commands (nil = unlimited)
+	redactor      *Redactor       // Masks secrets in commands and output (nil = disabled)
+	shell         shellBinary
+	timeout       time.Duration // How long a command may run (0 = shellTimeout)
 }
 
 // NewShellTool creates a new shell tool
 func NewShellTool(settings *config.Settings) *ShellTool {
 	return &ShellTool{
 		settings: settings,
+		redactor: NewRedactor(settings.Redaction, nil),
+		shell:    resolveShell(settings),
 	}
 }
 
@@ -35,7 +69,37 @@ func NewShellToolWithExternalTools(settings *config.Settings, externalTools []*c
 	return &ShellTool{
 		settings:      settings,
 		externalTools: externalTools,
+		redactor:      NewRedactor(settings.Redaction, externalTools),
+		shell:         resolveShell(settings),
+	}
+}
+
+// shellBinary is the shell commands run through, resolved once when a tool is created
+type shellBinary struct {
+	path string
+	err  error // Why the shell can't be used, returned instead of running any command
+}
+
+// resolveShell looks up the configured shell
+func resolveShell(settings *config.Settings) shellBinary {
+	path, err := settings.Tools.Shell.ResolveShell()
+	return shellBinary{path: path, err: err}
+}
+
+// command creates a command running script through the shell
+func (s shellBinary) command(ctx context.Context, script string) (*exec.Cmd, error) {
+	if s.err != nil {
+		return nil, s.err
 	}
+	cmd := exec.CommandContext(ctx, s.path, "-c", script)
+	cmd.WaitDelay = shellWaitDelay
+	procgroup.KillOnCancel(cmd)
+	return cmd, nil
+}
+
+// ShellError returns why the configured shell can't be used, or nil if it's available
+func (t *ShellTool) ShellError() error {
+	return t.shell.err
 }
 
 // SetCommandObserver sets a callback that's invoked when any shell command is executed
@@ -43,6 +107,21 @@ func (t *ShellTool) SetCommandObserver(observer CommandObserver) {
 	t.observer = observer
 }
 
+// SetCommandRecorder sets a callback that's invoked with the outcome of every executed command
+func (t *ShellTool) SetCommandRecorder(recorder CommandRecorder) {
+	t.recorder = recorder
+}
+
+// SetExecLimiter sets a limiter shared with other tools that run commands
+func (t *ShellTool) SetExecLimiter(limiter *ExecLimiter) {
+	t.limiter = limiter
+}
+
+// SetOutputObserver sets a callback that receives command output line by line while it runs
+func (t *ShellTool) SetOutputObserver(observer OutputObserver) {
+	t.outputObs = observer
+}
+
 func (t *ShellTool) Name() string {
 	return "shell"
 }
@@ -87,6 +166,13 @@ func (t *ShellTool) GetExternalToolsPrompt() string {
 		if ext.Access.Details != "" {
 			sb.WriteString(fmt.Sprintf("  - **Important:** %s\n", ext.Access.Details))
 		}
+		if ext.Unverified != "" {
+			sb.WriteString(fmt.Sprintf("  - **Note:** could not be verified (%s). If its commands fail, tell the user.\n", ext.Unverified))
+		}
+		if ext.Access.Template != "" {
+			sb.WriteString(fmt.Sprintf("  - **Usage:** set command to `%s` and args to {%s}\n",
+				ext.Name, strings.Join(ext.TemplateParams(), ", ")))
+		}
 	}
 
 	return sb.String()
@@ -98,7 +184,11 @@ func (t *ShellTool) Parameters() map[string]any {
 		"properties": map[string]any{
 			"command": map[string]any{
 				"type":        "string",
-				"description": "The shell command to execute",
+				"description": "The shell command to execute, or the name of a templated external tool",
+			},
+			"args": map[string]any{
+				"type":        "object",
+				"description": "Arguments for a templated external tool, e.g. {\"namespace\": \"default\"}",
 			},
 		},
 		"required": []string{"command"},
@@ -106,6 +196,26 @@ func (t *ShellTool) Parameters() map[string]any {
 }
 
 func (t *ShellTool) Execute(args map[string]any) (string, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext runs the command like Execute, reporting to the Hooks carried by ctx
+func (t *ShellTool) ExecuteContext(ctx context.Context, args map[string]any) (string, error) {
+	onLine := t.outputObs
+	if hooks := hooksFrom(ctx); hooks.Output != nil {
+		onLine = hooks.Output
+	}
+	return t.execute(ctx, args, onLine)
+}
+
+// ExecuteStreaming runs the command like Execute, additionally passing each output line to onLine
+// as it is produced. Streaming stops once the output size cap is reached. A nil onLine streams nothing.
+func (t *ShellTool) ExecuteStreaming(args map[string]any, onLine OutputObserver) (string, error) {
+	return t.execute(context.Background(), args, onLine)
+}
+
+func (t *ShellTool) execute(runCtx context.Context, args map[string]any, onLine OutputObserver) (string, error) {
+	hooks := hooksFrom(runCtx)
 	commandRaw, ok := args["command"]
 	if !ok {
 		return "", fmt.Errorf("missing required parameter: command")
@@ -116,59 +226,255 @@ func (t *ShellTool) Execute(args map[string]any) (string, error) {
 		return "", fmt.Errorf("command must be a string")
 	}
 
+	command, templated, err := t.renderTemplate(command, args)
+	if err != nil {
+		return "", err
+	}
+
 	// Validate command against allowlist
-	if err := t.validateCommand(command); err != nil {
+	if err := t.validateCommand(command, templated); err != nil {
 		return "", err
 	}
 
+	// Fail clearly up front rather than with an exec error for every command
+	if t.shell.err != nil {
+		return "", t.shell.err
+	}
+
+	// Wait for a free slot so heavy commands from many sessions don't run all at once
+	release, err := t.limiter.Acquire()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
 	// Notify observer of command execution
-	if t.observer != nil {
-		t.observer(command)
+	observer := t.observer
+	if hooks.Command != nil {
+		observer = hooks.Command
+	}
+	if observer != nil {
+		observer(t.redactor.Redact(command))
 	}
 
 	// Execute with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), shellTimeout)
+	timeout := t.timeout
+	if timeout <= 0 {
+		timeout = shellTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd, err := t.shell.command(ctx, command)
+	if err != nil {
+		return "", err
+	}
 
 	// Set environment variables if this is an external tool
-	if env := t.getExternalToolEnv(command); env != nil {
+	env, err := t.getExternalToolEnv(command)
+	if err != nil {
+		return "", err
+	}
+	if env != nil {
 		cmd.Env = env
 	}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	// Output beyond the cap is only counted, so a command printing without end can't exhaust memory.
+	// One byte more than the cap is kept, for truncation to tell the output went over it.
+	captureLimit := t.settings.Tools.Shell.MaxOutputBytes
+	if captureLimit > 0 {
+		captureLimit++
+	}
+	stdout, stderr := newCappedBuffer(captureLimit), newCappedBuffer(captureLimit)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	var stream *outputStream
+	if onLine != nil {
+		stream = newOutputStream(onLine, t.redactor, t.settings.Tools.Shell.MaxOutputBytes)
+		cmd.Stdout = io.MultiWriter(stdout, stream.writer())
+		cmd.Stderr = io.MultiWriter(stderr, stream.writer())
+	}
+
+	start := time.Now()
+	err = cmd.Run()
+	duration := time.Since(start)
+	stream.flush()
+
+	exitCode := 0
+	timedOut := false
+	var runErr error
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		// Whatever was printed before the kill is returned, marked so the model knows it's partial
+		exitCode = -1
+		timedOut = true
+		runErr = fmt.Errorf("command timed out after %v", timeout)
+	case errors.As(err, &exitErr):
+		// Surface the exit code so the model can tell e.g. "not found" (127) from a plain failure (1)
+		exitCode = exitErr.ExitCode()
+		runErr = fmt.Errorf("command failed with exit code %d", exitCode)
+	case errors.Is(err, exec.ErrWaitDelay):
+		// The shell exited successfully but left a background process holding its output
+	case err != nil:
+		exitCode = -1
+		runErr = fmt.Errorf("command failed: %w", err)
+	}
 
-	err := cmd.Run()
+	var output string
+	if t.settings.Tools.Shell.OutputFormat == config.ShellOutputJSON {
+		output = t.structuredOutput(stdout, stderr, exitCode, timedOut, duration)
+	} else {
+		// Combine output
+		output = stdout.String()
+		if stderr.Len() > 0 {
+			if output != "" {
+				output += "\n"
+			}
+			output += stderr.String()
+		}
 
-	// Combine output
-	output := stdout.String()
-	if stderr.Len() > 0 {
-		if output != "" {
-			output += "\n"
+		output = truncateOutput(t.redactor.Redact(output), t.settings.Tools.Shell.MaxOutputBytes, stdout.dropped+stderr.dropped)
+		switch {
+		case timedOut:
+			output = appendNote(output, fmt.Sprintf("[command timed out after %v; output may be incomplete]", timeout))
+		case exitErr != nil:
+			output = appendExitCode(output, exitCode)
 		}
-		output += stderr.String()
 	}
 
-	if ctx.Err() == context.DeadlineExceeded {
-		return output, fmt.Errorf("command timed out after %v", shellTimeout)
+	recorder := t.recorder
+	if hooks.Recorder != nil {
+		recorder = hooks.Recorder
+	}
+	if recorder != nil {
+		recorder(CommandRecord{
+			Command:  t.redactor.Redact(command),
+			ExitCode: exitCode,
+			Duration: duration,
+			Output:   output,
+		})
 	}
 
-	if err != nil {
-		return output, fmt.Errorf("command failed: %w", err)
+	return output, runErr
+}
+
+// shellResult is a command's outcome in the json output format
+type shellResult struct {
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	ExitCode   int    `json:"exit_code"` // -1 if the command didn't exit normally
+	DurationMs int64  `json:"duration_ms"`
+	TimedOut   bool   `json:"timed_out,omitempty"` // Killed at the timeout, the output may be incomplete
+}
+
+// structuredOutput returns a command's outcome as a JSON object, keeping stdout and stderr apart.
+// Stderr gets at most half of the output cap when there is stdout, stdout the rest.
+func (t *ShellTool) structuredOutput(stdoutBuf, stderrBuf *cappedBuffer, exitCode int, timedOut bool, duration time.Duration) string {
+	stdout, stderr := t.redactor.Redact(stdoutBuf.String()), t.redactor.Redact(stderrBuf.String())
+	if limit := t.settings.Tools.Shell.MaxOutputBytes; limit > 0 {
+		stderrLimit := limit
+		if stdout != "" {
+			stderrLimit = limit / 2
+		}
+		stderr = truncateOutput(stderr, stderrLimit, stderrBuf.dropped)
+		stdout = truncateOutput(stdout, max(limit-len(stderr), 1), stdoutBuf.dropped)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // Keep <, > and & readable for the model
+	_ = enc.Encode(shellResult{
+		Stdout:     stdout,
+		Stderr:     stderr,
+		ExitCode:   exitCode,
+		DurationMs: duration.Milliseconds(),
+		TimedOut:   timedOut,
+	})
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// appendExitCode adds a trailing "[exit code: N]" line to output
+func appendExitCode(output string, code int) string {
+	return appendNote(output, fmt.Sprintf("[exit code: %d]", code))
+}
+
+// appendNote adds note to output on a line of its own
+func appendNote(output, note string) string {
+	if output != "" && !strings.HasSuffix(output, "\n") {
+		output += "\n"
 	}
+	return output + note
+}
+
+// truncateOutput limits output to maxBytes, cutting at a line boundary where possible and never
+// within a character. The note on what was cut counts dropped, bytes that never made it into output.
+// A maxBytes of 0 or less means unlimited.
+func truncateOutput(output string, maxBytes, dropped int) string {
+	if maxBytes <= 0 || (len(output) <= maxBytes && dropped == 0) {
+		return output
+	}
+
+	cut := len(output)
+	if cut > maxBytes {
+		cut = maxBytes
+		// Prefer ending on a complete line, unless that would discard most of the output
+		if idx := strings.LastIndexByte(output[:maxBytes], '\n'); idx >= maxBytes/2 {
+			cut = idx + 1
+		}
+		for cut > 0 && !utf8.RuneStart(output[cut]) {
+			cut--
+		}
+	}
+
+	omitted := len(output) - cut + dropped
+	result := output[:cut]
+	if !strings.HasSuffix(result, "\n") {
+		result += "\n"
+	}
+	return result + fmt.Sprintf("... (truncated, %d bytes omitted)", omitted)
+}
 
-	return output, nil
+// cappedBuffer keeps the first limit bytes written to it and counts the rest as dropped.
+// A limit of 0 or less keeps everything.
+type cappedBuffer struct {
+	buf     bytes.Buffer
+	limit   int
+	dropped int
+}
+
+func newCappedBuffer(limit int) *cappedBuffer {
+	return &cappedBuffer{limit: limit}
+}
+
+// Write keeps what fits under the limit, always reporting all of p as written
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	keep := len(p)
+	if b.limit > 0 {
+		keep = min(keep, max(b.limit-b.buf.Len(), 0))
+	}
+	b.buf.Write(p[:keep])
+	b.dropped += len(p) - keep
+	return len(p), nil
+}
+
+// String returns the kept bytes
+func (b *cappedBuffer) String() string {
+	return b.buf.String()
+}
+
+// Len returns the number of kept bytes
+func (b *cappedBuffer) Len() int {
+	return b.buf.Len()
 }
 
 // getExternalToolEnv returns the environment variables for an external tool command.
 // Returns nil if no external tool matches or no env config is set.
-func (t *ShellTool) getExternalToolEnv(command string) []string {
+func (t *ShellTool) getExternalToolEnv(command string) ([]string, error) {
 	parts := strings.Fields(command)
 	if len(parts) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	baseCmd := parts[0]
@@ -179,16 +485,14 @@ func (t *ShellTool) getExternalToolEnv(command string) []string {
 		}
 	}
 
-	return nil
+	return nil, nil
 }
 
-func (t *ShellTool) validateCommand(command string) error {
-	// Check for shell operators that could be used to chain commands
-	dangerousPatterns := []string{"&&", "||", ";", "|", "`", "$(", "${", ">", "<"}
-	for _, pattern := range dangerousPatterns {
-		if strings.Contains(command, pattern) {
-			return fmt.Errorf("command contains disallowed pattern: %s", pattern)
-		}
+// validateCommand checks a command against the shell settings. A templated command was rendered
+// from a templated external tool, whose command may only run through its template.
+func (t *ShellTool) validateCommand(command string, templated bool) error {
+	if err := checkDeniedPatterns(t.settings, command); err != nil {
+		return err
 	}
 
 	// Extract the base command (first word)
@@ -199,18 +503,214 @@ func (t *ShellTool) validateCommand(command string) error {
 
 	baseCmd := parts[0]
 
-	// Check if base command is in settings allowlist
-	if t.settings.IsCommandAllowed(baseCmd) {
+	// The denylist beats both the allowlist and external tools
+	if t.settings.IsCommandDenied(baseCmd) {
+		return fmt.Errorf("command is denylisted: %s", baseCmd)
+	}
+
+	// Commands that need a terminal would only block until the timeout
+	if err := t.checkInteractive(baseCmd, parts[1:]); err != nil {
+		return err
+	}
+
+	// Check if base command is in settings allowlist or is an external tool
+	if !t.settings.IsCommandAllowed(baseCmd) && !templated && !t.isExternalTool(baseCmd) {
+		return fmt.Errorf("command not in allowlist: %s (allowed: %s)",
+			baseCmd, strings.Join(t.settings.Tools.Shell.Allowlist, ", "))
+	}
+
+	// Apply per-command argument rules
+	if allowed, reason := t.settings.AreCommandArgumentsAllowed(baseCmd, parts[1:]); !allowed {
+		return fmt.Errorf("arguments not allowed for %s: %s", baseCmd, reason)
+	}
+
+	return checkNetworkTargets(t.settings, baseCmd, parts[1:])
+}
+
+// checkDeniedPatterns rejects commands containing shell operators that could be used to chain commands
+func checkDeniedPatterns(settings *config.Settings, command string) error {
+	for _, pattern := range settings.Tools.Shell.DeniedPatternList() {
+		if pattern != "" && strings.Contains(command, pattern) {
+			return fmt.Errorf("command contains disallowed pattern: %s", pattern)
+		}
+	}
+	return nil
+}
+
+// interactiveAlternatives suggests non-interactive replacements for common interactive commands
+var interactiveAlternatives = map[string]string{
+	"less":  "cat, head or tail",
+	"more":  "cat, head or tail",
+	"most":  "cat, head or tail",
+	"man":   "<command> --help",
+	"vi":    "cat to read the file, or the write tool to change it",
+	"vim":   "cat to read the file, or the write tool to change it",
+	"nvim":  "cat to read the file, or the write tool to change it",
+	"nano":  "cat to read the file, or the write tool to change it",
+	"emacs": "cat to read the file, or the write tool to change it",
+	"pico":  "cat to read the file, or the write tool to change it",
+	"top":   "ps aux, or top -b -n 1 for a single snapshot",
+	"htop":  "ps aux",
+	"btop":  "ps aux",
+	"atop":  "ps aux",
+	"watch": "running the command once",
+}
+
+// batchFlags lets otherwise interactive commands through when they're run in batch mode
+var batchFlags = map[string][]string{
+	"top": {"-b", "-l"}, // -l is the macOS equivalent of -b -n
+}
+
+// checkInteractive rejects commands that need a TTY, since they would hang until the timeout
+func (t *ShellTool) checkInteractive(baseCmd string, args []string) error {
+	if !slices.Contains(t.settings.Tools.Shell.InteractiveCommandList(), baseCmd) {
 		return nil
 	}
+	for _, arg := range args {
+		if slices.Contains(batchFlags[baseCmd], arg) {
+			return nil
+		}
+	}
+
+	msg := fmt.Sprintf("%s is interactive and needs a terminal, which the shell tool doesn't have", baseCmd)
+	if alt, ok := interactiveAlternatives[baseCmd]; ok {
+		msg += "; use " + alt + " instead"
+	}
+	return errors.New(msg)
+}
 
-	// Check if it's an external tool
+// renderTemplate expands a templated external tool's name into its command line.
+// Commands that don't name a templated tool are returned unchanged, with templated false.
+func (t *ShellTool) renderTemplate(command string, args map[string]any) (string, bool, error) {
+	name := strings.TrimSpace(command)
 	for _, ext := range t.externalTools {
-		if ext.Access.Type == "shell" && ext.Access.Command == baseCmd {
-			return nil
+		if ext.Access.Template == "" || ext.Name != name {
+			continue
+		}
+		templateArgs, _ := args["args"].(map[string]any)
+		rendered, err := ext.RenderCommand(templateArgs)
+		if err != nil {
+			return "", false, fmt.Errorf("%s: %w", ext.Name, err)
+		}
+		return rendered, true, nil
+	}
+	return command, false, nil
+}
+
+// isExternalTool reports whether baseCmd is the command of a shell external tool that may be run
+// with any arguments. Templated tools only run through their template.
+func (t *ShellTool) isExternalTool(baseCmd string) bool {
+	for _, ext := range t.externalTools {
+		if ext.Access.Type == "shell" && ext.Access.Template == "" && ext.Access.Command == baseCmd {
+			return true
+		}
+	}
+	return false
+}
+
+// outputStream splits stdout and stderr into lines and passes them to an observer,
+// stopping once maxBytes of output has been streamed
+type outputStream struct {
+	mu        sync.Mutex
+	onLine    OutputObserver
+	redactor  *Redactor
+	remaining int // Bytes left before the cap (ignored when unlimited)
+	unlimited bool
+	truncated bool
+	lineLimit int // Longest partial line kept while waiting for its newline
+	writers   []*lineWriter
+}
+
+// streamLineLimit caps a partial line waiting for its newline when output is unlimited
+const streamLineLimit = 64 * 1024
+
+func newOutputStream(onLine OutputObserver, redactor *Redactor, maxBytes int) *outputStream {
+	lineLimit := streamLineLimit
+	if maxBytes > 0 {
+		lineLimit = min(lineLimit, maxBytes)
+	}
+	return &outputStream{
+		onLine:    onLine,
+		redactor:  redactor,
+		remaining: maxBytes,
+		unlimited: maxBytes <= 0,
+		lineLimit: lineLimit,
+	}
+}
+
+// writer returns a new writer for one output stream; partial lines are kept per writer
+func (s *outputStream) writer() io.Writer {
+	w := &lineWriter{stream: s}
+	s.writers = append(s.writers, w)
+	return w
+}
+
+// done reports whether the cap was reached, after which output is no longer streamed
+func (s *outputStream) done() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.truncated
+}
+
+// emit sends a complete line to the observer, respecting the output cap
+func (s *outputStream) emit(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.truncated {
+		return
+	}
+	if !s.unlimited {
+		if len(line)+1 > s.remaining {
+			s.truncated = true
+			s.onLine("... (output truncated)")
+			return
 		}
+		s.remaining -= len(line) + 1
 	}
+	s.onLine(s.redactor.Redact(line))
+}
 
-	return fmt.Errorf("command not in allowlist: %s (allowed: %s)",
-		baseCmd, strings.Join(t.settings.Tools.Shell.Allowlist, ", "))
+// flush emits any trailing partial lines. Safe to call on a nil stream.
+func (s *outputStream) flush() {
+	if s == nil {
+		return
+	}
+	for _, w := range s.writers {
+		if len(w.partial) > 0 {
+			s.emit(string(w.partial))
+			w.partial = nil
+		}
+	}
+}
+
+// lineWriter buffers writes until a newline and emits whole lines
+type lineWriter struct {
+	stream  *outputStream
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	if w.stream.done() {
+		w.partial = nil
+		return len(p), nil
+	}
+	w.partial = append(w.partial, p...)
+	for {
+		idx := bytes.IndexByte(w.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		w.stream.emit(strings.TrimSuffix(string(w.partial[:idx]), "\r"))
+		w.partial = w.partial[idx+1:]
+	}
+	// Output without newlines is emitted in pieces, rather than held until the command ends
+	for len(w.partial) > w.stream.lineLimit && !w.stream.done() {
+		cut := w.stream.lineLimit
+		for cut > 1 && !utf8.RuneStart(w.partial[cut]) {
+			cut--
+		}
+		w.stream.emit(string(w.partial[:cut]))
+		w.partial = w.partial[cut:]
+	}
+	return len(p), nil
 }
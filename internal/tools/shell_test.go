@@ -1,8 +1,12 @@
 package tools
 
 import (
+	"context"
+	"encoding/json"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/marciniwanicki/craby/internal/config"
 )
@@ -209,6 +213,20 @@ func TestShellTool_Execute_CapturesOutput(t *testing.T) {
 	}
 }
 
+func TestShellTool_Execute_MissingShell(t *testing.T) {
+	settings := testSettings()
+	settings.Tools.Shell.Binary = "no-such-shell"
+	tool := NewShellTool(settings)
+
+	if err := tool.ShellError(); err == nil {
+		t.Fatal("expected the missing shell to be detected when the tool is created")
+	}
+	_, err := tool.Execute(map[string]any{"command": "echo hi"})
+	if err == nil || !strings.Contains(err.Error(), `shell "no-such-shell" is not available`) {
+		t.Errorf("expected a missing shell error, got %v", err)
+	}
+}
+
 func TestShellTool_Execute_CapturesStderr(t *testing.T) {
 	tool := NewShellTool(testSettings())
 
@@ -225,3 +243,494 @@ func TestShellTool_Execute_CapturesStderr(t *testing.T) {
 		t.Error("expected stderr to be captured in result")
 	}
 }
+
+func TestTruncateOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		maxBytes int
+		dropped  int
+		want     string
+	}{
+		{"unlimited", "abcdef", 0, 0, "abcdef"},
+		{"within limit", "abc\n", 10, 0, "abc\n"},
+		{"line boundary", "line1\nline2\nline3\n", 14, 0, "line1\nline2\n... (truncated, 6 bytes omitted)"},
+		{"no newline", "abcdefghij", 4, 0, "abcd\n... (truncated, 6 bytes omitted)"},
+		{"newline too early", "a\nbcdefghijkl", 8, 0, "a\nbcdefg\n... (truncated, 5 bytes omitted)"},
+		{"multibyte character", "abcdéé", 5, 0, "abcd\n... (truncated, 4 bytes omitted)"},
+		{"dropped bytes", "abcdefghij", 4, 100, "abcd\n... (truncated, 106 bytes omitted)"},
+		{"dropped within limit", "abc", 10, 5, "abc\n... (truncated, 5 bytes omitted)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateOutput(tt.output, tt.maxBytes, tt.dropped); got != tt.want {
+				t.Errorf("truncateOutput() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCappedBuffer(t *testing.T) {
+	b := newCappedBuffer(5)
+	for _, chunk := range []string{"abc", "defg", "hij"} {
+		if n, err := b.Write([]byte(chunk)); n != len(chunk) || err != nil {
+			t.Fatalf("expected the whole chunk reported written, got %d, %v", n, err)
+		}
+	}
+	if b.String() != "abcde" || b.dropped != 5 {
+		t.Errorf("expected 5 bytes kept and 5 dropped, got %q and %d", b.String(), b.dropped)
+	}
+
+	unlimited := newCappedBuffer(0)
+	_, _ = unlimited.Write([]byte("abcdefgh"))
+	if unlimited.String() != "abcdefgh" || unlimited.dropped != 0 {
+		t.Errorf("expected everything kept without a limit, got %q", unlimited.String())
+	}
+}
+
+func TestShellTool_Execute_TruncatesOutput(t *testing.T) {
+	settings := testSettings()
+	settings.Tools.Shell.MaxOutputBytes = 10
+	tool := NewShellTool(settings)
+
+	output, err := tool.Execute(map[string]any{"command": "echo 0123456789abcdefghij"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(output, "truncated, 11 bytes omitted") {
+		t.Errorf("expected truncation marker, got %q", output)
+	}
+}
+
+func TestShellTool_Execute_DenylistOverridesAllowlist(t *testing.T) {
+	settings := testSettings()
+	settings.Tools.Shell.Denylist = []string{"echo"}
+	tool := NewShellTool(settings)
+
+	_, err := tool.Execute(map[string]any{"command": "echo hello"})
+	if err == nil || !strings.Contains(err.Error(), "denylisted") {
+		t.Errorf("expected denylisted error, got: %v", err)
+	}
+}
+
+func TestShellTool_Execute_DenylistOverridesExternalTools(t *testing.T) {
+	settings := testSettings()
+	settings.Tools.Shell.Denylist = []string{"mytool"}
+	tool := NewShellToolWithExternalTools(settings, []*config.ExternalTool{
+		{Name: "mytool", Access: config.ToolAccess{Type: "shell", Command: "mytool"}},
+	})
+
+	_, err := tool.Execute(map[string]any{"command": "mytool run"})
+	if err == nil || !strings.Contains(err.Error(), "denylisted") {
+		t.Errorf("expected denylisted error, got: %v", err)
+	}
+}
+
+func TestShellTool_Execute_Template(t *testing.T) {
+	settings := testSettings()
+	tool := NewShellToolWithExternalTools(settings, []*config.ExternalTool{
+		{Name: "greet", Access: config.ToolAccess{Type: "shell", Command: "echo", Template: "echo hello {{.name}}"}},
+	})
+
+	output, err := tool.Execute(map[string]any{"command": "greet", "args": map[string]any{"name": "world"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "hello world") {
+		t.Errorf("expected rendered command output, got %q", output)
+	}
+
+	_, err = tool.Execute(map[string]any{"command": "greet", "args": map[string]any{"name": "x; id"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid value for name") {
+		t.Errorf("expected injection to be rejected, got: %v", err)
+	}
+}
+
+func TestShellTool_Execute_TemplateOnly(t *testing.T) {
+	tool := NewShellToolWithExternalTools(testSettings(), []*config.ExternalTool{
+		{Name: "hello", Access: config.ToolAccess{Type: "shell", Command: "printf", Template: "printf hello-{{.name}}"}},
+	})
+
+	output, err := tool.Execute(map[string]any{"command": "hello", "args": map[string]any{"name": "world"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "hello-world") {
+		t.Errorf("expected rendered command output, got %q", output)
+	}
+
+	// The command of a templated tool can't be run with other arguments
+	_, err = tool.Execute(map[string]any{"command": "printf anything"})
+	if err == nil || !strings.Contains(err.Error(), "not in allowlist") {
+		t.Errorf("expected bare command to be rejected, got: %v", err)
+	}
+}
+
+func TestShellTool_Execute_CustomDeniedPatterns(t *testing.T) {
+	settings := testSettings()
+	settings.Tools.Shell.DeniedPatterns = []string{"&&", "--force"}
+	tool := NewShellTool(settings)
+
+	// Pipes are no longer denied once the patterns are overridden
+	output, err := tool.Execute(map[string]any{"command": "echo hello | cat"})
+	if err != nil {
+		t.Fatalf("expected pipe to be allowed, got: %v", err)
+	}
+	if !strings.Contains(output, "hello") {
+		t.Errorf("expected output to contain hello, got %q", output)
+	}
+
+	_, err = tool.Execute(map[string]any{"command": "ls --force"})
+	if err == nil || !strings.Contains(err.Error(), "disallowed pattern: --force") {
+		t.Errorf("expected custom pattern to be denied, got: %v", err)
+	}
+}
+
+func TestShellTool_Execute_ArgumentRules(t *testing.T) {
+	settings := testSettings()
+	settings.Tools.Shell.ArgumentRules = map[string]config.ArgumentRule{
+		"ls": {ForbiddenSubstrings: []string{"/root"}},
+	}
+	tool := NewShellTool(settings)
+
+	_, err := tool.Execute(map[string]any{"command": "ls /root"})
+	if err == nil || !strings.Contains(err.Error(), "arguments not allowed for ls") {
+		t.Errorf("expected argument rule error, got: %v", err)
+	}
+
+	if _, err := tool.Execute(map[string]any{"command": "ls ."}); err != nil {
+		t.Errorf("expected ls . to be allowed, got: %v", err)
+	}
+}
+
+func TestShellTool_Execute_ReportsExitCode(t *testing.T) {
+	tool := NewShellTool(testSettings())
+
+	output, err := tool.Execute(map[string]any{"command": "ls /nonexistent-craby-path"})
+	if err == nil {
+		t.Fatal("expected error for failing command")
+	}
+	if !strings.Contains(err.Error(), "exit code") {
+		t.Errorf("expected exit code in error, got: %v", err)
+	}
+	if !strings.HasSuffix(output, "]") || !strings.Contains(output, "\n[exit code: ") {
+		t.Errorf("expected trailing exit code line, got %q", output)
+	}
+
+	output, err = tool.Execute(map[string]any{"command": "echo ok"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(output, "exit code") {
+		t.Errorf("expected no exit code on success, got %q", output)
+	}
+}
+
+func TestShellTool_Execute_StructuredOutput(t *testing.T) {
+	settings := testSettings()
+	settings.Tools.Shell.OutputFormat = config.ShellOutputJSON
+	tool := NewShellTool(settings)
+
+	output, err := tool.Execute(map[string]any{"command": "ls -d / /nonexistent-craby-path"})
+	if err == nil {
+		t.Fatal("expected error for failing command")
+	}
+	var result shellResult
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		t.Fatalf("expected a JSON object, got %q: %v", output, err)
+	}
+	if result.Stdout != "/\n" || !strings.Contains(result.Stderr, "nonexistent-craby-path") {
+		t.Errorf("expected stdout and stderr kept apart, got %+v", result)
+	}
+	if result.ExitCode == 0 || result.TimedOut {
+		t.Errorf("expected a non-zero exit code, got %+v", result)
+	}
+
+	output, err = tool.Execute(map[string]any{"command": "echo 'a &b'"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(output, `"stdout":"a &b\n","stderr":"","exit_code":0,"duration_ms":`) {
+		t.Errorf("unexpected output: %q", output)
+	}
+}
+
+func TestShellTool_StructuredOutput_SharesCap(t *testing.T) {
+	settings := testSettings()
+	settings.Tools.Shell.MaxOutputBytes = 100
+	tool := NewShellTool(settings)
+
+	var result shellResult
+	captured := func(s string) *cappedBuffer {
+		b := newCappedBuffer(0)
+		_, _ = b.Write([]byte(s))
+		return b
+	}
+	output := tool.structuredOutput(captured(strings.Repeat("out\n", 100)), captured(strings.Repeat("err\n", 100)), 1, false, time.Second)
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		t.Fatalf("expected a JSON object, got %q: %v", output, err)
+	}
+	if !strings.HasPrefix(result.Stdout, "out\n") || !strings.HasPrefix(result.Stderr, "err\n") {
+		t.Errorf("expected both streams kept, got %+v", result)
+	}
+	if len(result.Stderr) > 100 || len(result.Stdout) > 150 || !strings.Contains(result.Stderr, "truncated") {
+		t.Errorf("expected both streams truncated to share the cap, got %+v", result)
+	}
+	if result.DurationMs != 1000 {
+		t.Errorf("expected the duration in milliseconds, got %d", result.DurationMs)
+	}
+}
+
+func TestAppendExitCode(t *testing.T) {
+	if got := appendExitCode("", 127); got != "[exit code: 127]" {
+		t.Errorf("unexpected output for empty input: %q", got)
+	}
+	if got := appendExitCode("boom", 1); got != "boom\n[exit code: 1]" {
+		t.Errorf("unexpected output: %q", got)
+	}
+	if got := appendExitCode("boom\n", 2); got != "boom\n[exit code: 2]" {
+		t.Errorf("unexpected output: %q", got)
+	}
+}
+
+func TestShellTool_ExecuteStreaming(t *testing.T) {
+	tool := NewShellTool(testSettings())
+
+	var lines []string
+	output, err := tool.ExecuteStreaming(map[string]any{"command": "echo hello"}, func(line string) {
+		lines = append(lines, line)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output != "hello\n" {
+		t.Errorf("expected aggregate output, got %q", output)
+	}
+	if len(lines) != 1 || lines[0] != "hello" {
+		t.Errorf("expected streamed line hello, got %v", lines)
+	}
+}
+
+func TestShellTool_Execute_UsesOutputObserver(t *testing.T) {
+	tool := NewShellTool(testSettings())
+
+	var lines []string
+	tool.SetOutputObserver(func(line string) {
+		lines = append(lines, line)
+	})
+	if _, err := tool.Execute(map[string]any{"command": "echo hi"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lines) != 1 || lines[0] != "hi" {
+		t.Errorf("expected observer to receive hi, got %v", lines)
+	}
+}
+
+func TestOutputStream_RespectsCap(t *testing.T) {
+	var lines []string
+	stream := newOutputStream(func(line string) { lines = append(lines, line) }, nil, 10)
+	w := stream.writer()
+
+	_, _ = w.Write([]byte("abcd\nef"))
+	_, _ = w.Write([]byte("gh\n0123456789\nmore\n"))
+	stream.flush()
+
+	expected := []string{"abcd", "efgh", "... (output truncated)"}
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %v, got %v", expected, lines)
+	}
+}
+
+func TestOutputStream_FlushesPartialLine(t *testing.T) {
+	var lines []string
+	stream := newOutputStream(func(line string) { lines = append(lines, line) }, nil, 0)
+	_, _ = stream.writer().Write([]byte("no newline"))
+	stream.flush()
+
+	if len(lines) != 1 || lines[0] != "no newline" {
+		t.Errorf("expected partial line to be flushed, got %v", lines)
+	}
+}
+
+func TestOutputStream_BoundsPartialLine(t *testing.T) {
+	var lines []string
+	stream := newOutputStream(func(line string) { lines = append(lines, line) }, nil, 0)
+	w := stream.writer().(*lineWriter)
+
+	// Without newlines output is streamed in pieces of at most the line limit
+	chunk := []byte(strings.Repeat("x", 1000))
+	for i := 0; i < 3*streamLineLimit/len(chunk); i++ {
+		_, _ = w.Write(chunk)
+		if len(w.partial) > streamLineLimit {
+			t.Fatalf("expected the partial line bounded, got %d bytes", len(w.partial))
+		}
+	}
+	if len(lines) < 2 || len(lines[0]) != streamLineLimit {
+		t.Errorf("expected pieces of %d bytes, got %d lines", streamLineLimit, len(lines))
+	}
+
+	// Once the cap is reached, further output isn't kept at all
+	lines = nil
+	stream = newOutputStream(func(line string) { lines = append(lines, line) }, nil, 10)
+	w = stream.writer().(*lineWriter)
+	_, _ = w.Write(chunk)
+	_, _ = w.Write(chunk)
+	if len(w.partial) != 0 || strings.Join(lines, "|") != "... (output truncated)" {
+		t.Errorf("expected output dropped after the cap, got %d bytes kept and %v", len(w.partial), lines)
+	}
+}
+
+func TestShellTool_Execute_RejectsInteractiveCommands(t *testing.T) {
+	settings := testSettings()
+	settings.Tools.Shell.Allowlist = append(settings.Tools.Shell.Allowlist, "less", "top", "vim")
+	tool := NewShellTool(settings)
+
+	tests := []struct {
+		command    string
+		suggestion string
+	}{
+		{"less /etc/hosts", "cat"},
+		{"top", "ps aux"},
+		{"vim notes.txt", "write tool"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			_, err := tool.Execute(map[string]any{"command": tt.command})
+			if err == nil || !strings.Contains(err.Error(), "interactive") {
+				t.Fatalf("expected interactive error, got: %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.suggestion) {
+				t.Errorf("expected suggestion %q, got: %v", tt.suggestion, err)
+			}
+		})
+	}
+}
+
+func TestShellTool_checkInteractive(t *testing.T) {
+	settings := testSettings()
+	tool := NewShellTool(settings)
+
+	if err := tool.checkInteractive("top", []string{"-b", "-n", "1"}); err != nil {
+		t.Errorf("expected top in batch mode to be allowed, got: %v", err)
+	}
+	if err := tool.checkInteractive("ls", nil); err != nil {
+		t.Errorf("expected ls to be allowed, got: %v", err)
+	}
+
+	settings.Tools.Shell.InteractiveCommands = []string{"mytui"}
+	if err := tool.checkInteractive("mytui", nil); err == nil {
+		t.Error("expected configured interactive command to be rejected")
+	}
+	if err := tool.checkInteractive("less", nil); err != nil {
+		t.Errorf("expected less to be allowed once the list is overridden, got: %v", err)
+	}
+}
+
+func TestShellTool_Execute_RecordsCommand(t *testing.T) {
+	tool := NewShellTool(testSettings())
+
+	var records []CommandRecord
+	tool.SetCommandRecorder(func(record CommandRecord) {
+		records = append(records, record)
+	})
+
+	_, _ = tool.Execute(map[string]any{"command": "echo hi"})
+	_, _ = tool.Execute(map[string]any{"command": "ls /nonexistent-craby-path"})
+	_, _ = tool.Execute(map[string]any{"command": "rm -rf /"}) // Rejected before running
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if records[0].Command != "echo hi" || records[0].ExitCode != 0 || records[0].Output != "hi\n" {
+		t.Errorf("unexpected record %+v", records[0])
+	}
+	if records[1].ExitCode == 0 || records[1].IsDiscovery {
+		t.Errorf("expected non-zero exit code, got %+v", records[1])
+	}
+}
+
+func TestShellTool_ExecuteContext_Hooks(t *testing.T) {
+	tool := NewShellTool(testSettings())
+	registry := NewRegistry()
+	registry.Register(tool)
+
+	var shared []CommandRecord
+	tool.SetCommandRecorder(func(record CommandRecord) {
+		shared = append(shared, record)
+	})
+
+	// Concurrent chats each record to their own recorder, overriding the tool's
+	var wg sync.WaitGroup
+	records := make([][]CommandRecord, 2)
+	for i, word := range []string{"one", "two"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := WithHooks(context.Background(), Hooks{Recorder: func(record CommandRecord) {
+				records[i] = append(records[i], record)
+			}})
+			_, _ = registry.ExecuteContext(ctx, "shell", map[string]any{"command": "echo " + word})
+		}()
+	}
+	wg.Wait()
+
+	for i, want := range []string{"echo one", "echo two"} {
+		if len(records[i]) != 1 || records[i][0].Command != want {
+			t.Errorf("expected only %q recorded for chat %d, got %+v", want, i, records[i])
+		}
+	}
+	if len(shared) != 0 {
+		t.Errorf("expected the tool's recorder overridden, got %+v", shared)
+	}
+
+	// Without hooks the tool's own recorder is used
+	_, _ = registry.Execute("shell", map[string]any{"command": "echo three"})
+	if len(shared) != 1 {
+		t.Errorf("expected the tool's recorder used without hooks, got %+v", shared)
+	}
+}
+
+func TestShellTool_Execute_TimeoutReturnsPartialOutput(t *testing.T) {
+	settings := testSettings()
+	settings.Tools.Shell.Allowlist = []string{"sh"}
+	settings.Tools.Shell.DeniedPatterns = []string{}
+	tool := NewShellTool(settings)
+	tool.timeout = 300 * time.Millisecond
+
+	start := time.Now()
+	output, err := tool.Execute(map[string]any{"command": `sh -c "echo partial; sleep 5"`})
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected the timeout to stop the command, took %v", elapsed)
+	}
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+	if !strings.HasPrefix(output, "partial\n") {
+		t.Errorf("expected output printed before the timeout, got %q", output)
+	}
+	if !strings.HasSuffix(output, "[command timed out after 300ms; output may be incomplete]") {
+		t.Errorf("expected a timeout marker, got %q", output)
+	}
+}
+
+func TestShellTool_Execute_BackgroundProcessHoldingOutput(t *testing.T) {
+	settings := testSettings()
+	settings.Tools.Shell.Allowlist = []string{"sh"}
+	settings.Tools.Shell.DeniedPatterns = []string{}
+	tool := NewShellTool(settings)
+
+	start := time.Now()
+	output, err := tool.Execute(map[string]any{"command": `sh -c "echo done; sleep 5 &"`})
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected not to wait for the background process, took %v", elapsed)
+	}
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if output != "done\n" {
+		t.Errorf("unexpected output %q", output)
+	}
+}
@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ToolStat summarizes the calls of one tool
+type ToolStat struct {
+	Name          string
+	Calls         int64
+	Errors        int64
+	TotalDuration time.Duration
+	MaxDuration   time.Duration
+}
+
+// AverageDuration returns the mean duration of a call, 0 before the first one
+func (s ToolStat) AverageDuration() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.TotalDuration / time.Duration(s.Calls)
+}
+
+// ToolStats counts calls, errors and latency per tool. Counters live in memory only.
+type ToolStats struct {
+	mu    sync.Mutex
+	stats map[string]*ToolStat
+}
+
+// NewToolStats creates empty tool statistics
+func NewToolStats() *ToolStats {
+	return &ToolStats{stats: make(map[string]*ToolStat)}
+}
+
+// Middleware records every tool call passing through it
+func (s *ToolStats) Middleware() Middleware {
+	return func(next ToolFunc) ToolFunc {
+		return func(name string, args map[string]any) (string, error) {
+			start := time.Now()
+			output, err := next(name, args)
+			s.record(name, time.Since(start), err)
+			return output, err
+		}
+	}
+}
+
+func (s *ToolStats) record(name string, duration time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stat, ok := s.stats[name]
+	if !ok {
+		stat = &ToolStat{Name: name}
+		s.stats[name] = stat
+	}
+	stat.Calls++
+	if err != nil {
+		stat.Errors++
+	}
+	stat.TotalDuration += duration
+	stat.MaxDuration = max(stat.MaxDuration, duration)
+}
+
+// Snapshot returns the statistics of every tool called so far, sorted by name
+func (s *ToolStats) Snapshot() []ToolStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]ToolStat, 0, len(s.stats))
+	for _, stat := range s.stats {
+		result = append(result, *stat)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Subcommand is a subcommand extracted from help text
+type Subcommand struct {
+	Name        string
+	Description string
+}
+
+var (
+	// subcommandHeader matches section headers like "Commands:", "Available Commands:" or "subcommands:"
+	subcommandHeader = regexp.MustCompile(`(?i)^(\s*)((available|additional|core)\s+)?(sub)?commands\s*:?\s*$`)
+	// subcommandEntry matches an indented "name  description" line
+	subcommandEntry = regexp.MustCompile(`^(\s+)([A-Za-z0-9][\w:.-]*)(?:,\s*[\w:.-]+)*(?:\s{2,}(.*))?$`)
+	// braceChoices matches argparse-style "{start,stop,status}" choice lists
+	braceChoices = regexp.MustCompile(`\{([\w:.-]+(?:,[\w:.-]+)+)\}`)
+)
+
+// parseSubcommands extracts subcommands from --help output without an LLM.
+// It understands "Commands:"-style sections (Cobra, Click, git-like tools) and
+// argparse's brace-enclosed choices, whose descriptions are listed under positional arguments.
+func parseSubcommands(helpText string) []Subcommand {
+	lines := strings.Split(strings.ReplaceAll(helpText, "\t", "    "), "\n")
+
+	var subs []Subcommand
+	seen := make(map[string]int) // Name -> index in subs
+
+	add := func(name, desc string) {
+		if i, ok := seen[name]; ok {
+			if subs[i].Description == "" {
+				subs[i].Description = desc
+			}
+			return
+		}
+		seen[name] = len(subs)
+		subs = append(subs, Subcommand{Name: name, Description: desc})
+	}
+
+	// Sections introduced by a commands header
+	for i := 0; i < len(lines); i++ {
+		m := subcommandHeader.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		headerIndent := len(m[1])
+		entryIndent := -1
+		last := ""
+
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				if entryIndent >= 0 {
+					break
+				}
+				continue
+			}
+			indent := len(line) - len(strings.TrimLeft(line, " "))
+			if indent <= headerIndent {
+				i--
+				break
+			}
+			// Deeper indentation continues the previous entry's description
+			if entryIndent >= 0 && indent > entryIndent && last != "" {
+				idx := seen[last]
+				subs[idx].Description = strings.TrimSpace(subs[idx].Description + " " + strings.TrimSpace(line))
+				continue
+			}
+			e := subcommandEntry.FindStringSubmatch(line)
+			if e == nil {
+				continue
+			}
+			if entryIndent < 0 {
+				entryIndent = len(e[1])
+			}
+			last = e[2]
+			add(e[2], strings.TrimSpace(e[3]))
+		}
+	}
+
+	// argparse choices: names come from the brace list, descriptions from indented lines below it
+	for _, line := range lines {
+		for _, idx := range braceChoices.FindAllStringSubmatchIndex(line, -1) {
+			// "--mode {fast,slow}" is an option's choices, not subcommands
+			fields := strings.Fields(line[:idx[0]])
+			if len(fields) > 0 && strings.HasPrefix(strings.TrimLeft(fields[len(fields)-1], "["), "-") {
+				continue
+			}
+			for _, name := range strings.Split(line[idx[2]:idx[3]], ",") {
+				add(name, choiceDescription(lines, name))
+			}
+		}
+	}
+
+	return subs
+}
+
+// choiceDescription finds the description argparse prints for a subcommand choice, e.g. "    start     Start the service"
+func choiceDescription(lines []string, name string) string {
+	for _, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		if len(trimmed) == len(line) || !strings.HasPrefix(trimmed, name) {
+			continue
+		}
+		rest := trimmed[len(name):]
+		if strings.HasPrefix(rest, "  ") {
+			return strings.TrimSpace(rest)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,149 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+const argparseHelp = `usage: svc [-h] [--verbose] {start,stop,status} ...
+
+Manage the service.
+
+positional arguments:
+  {start,stop,status}
+    start              Start the service
+    stop               Stop the service
+    status             Show service status
+
+options:
+  -h, --help           show this help message and exit
+  --verbose            Enable verbose output
+`
+
+const argparseChoiceOptionHelp = `usage: build [-h] [--mode {fast,slow}] target
+
+positional arguments:
+  target              What to build
+
+options:
+  -h, --help          show this help message and exit
+  --mode {fast,slow}  Build mode
+`
+
+const clickHelp = `Usage: deploy [OPTIONS] COMMAND [ARGS]...
+
+  Deploy applications.
+
+Options:
+  --version  Show the version and exit.
+  --help     Show this message and exit.
+
+Commands:
+  init      Initialize a new project.
+  push      Push the current build to the
+            configured environment.
+  rollback  Roll back to the previous release.
+`
+
+const cobraHelp = `A tool for managing things
+
+Usage:
+  thing [command]
+
+Available Commands:
+  completion  Generate the autocompletion script for the specified shell
+  help        Help about any command
+  list        List things
+
+Flags:
+  -h, --help   help for thing
+
+Use "thing [command] --help" for more information about a command.
+`
+
+const gitStyleHelp = `usage: tool <command> [<args>]
+
+subcommands:
+    add       Add files
+    remove    Remove files
+`
+
+func TestParseSubcommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		help     string
+		expected []Subcommand
+	}{
+		{
+			name: "argparse",
+			help: argparseHelp,
+			expected: []Subcommand{
+				{"start", "Start the service"},
+				{"stop", "Stop the service"},
+				{"status", "Show service status"},
+			},
+		},
+		{
+			name:     "argparse option choices are not subcommands",
+			help:     argparseChoiceOptionHelp,
+			expected: nil,
+		},
+		{
+			name: "click",
+			help: clickHelp,
+			expected: []Subcommand{
+				{"init", "Initialize a new project."},
+				{"push", "Push the current build to the configured environment."},
+				{"rollback", "Roll back to the previous release."},
+			},
+		},
+		{
+			name: "cobra",
+			help: cobraHelp,
+			expected: []Subcommand{
+				{"completion", "Generate the autocompletion script for the specified shell"},
+				{"help", "Help about any command"},
+				{"list", "List things"},
+			},
+		},
+		{
+			name: "subcommands header",
+			help: gitStyleHelp,
+			expected: []Subcommand{
+				{"add", "Add files"},
+				{"remove", "Remove files"},
+			},
+		},
+		{
+			name:     "no subcommands",
+			help:     "usage: cat [-u] [file ...]\n",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseSubcommands(tt.help)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseSubcommands() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFillSubcommands(t *testing.T) {
+	schema := map[string]any{"description": "Deploy applications"}
+	fillSubcommands(schema, clickHelp)
+
+	subs, ok := schema["subcommands"].([]any)
+	if !ok || len(subs) != 3 {
+		t.Fatalf("expected 3 parsed subcommands, got %v", schema["subcommands"])
+	}
+
+	existing := []any{map[string]any{"name": "only"}}
+	schema = map[string]any{"subcommands": existing}
+	fillSubcommands(schema, clickHelp)
+	if len(schema["subcommands"].([]any)) != 1 {
+		t.Error("expected subcommands from the LLM to be kept")
+	}
+}
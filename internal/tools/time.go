@@ -0,0 +1,219 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeFormats are the named layouts the time tool accepts in addition to Go layouts
+var timeFormats = map[string]string{
+	"rfc3339":  time.RFC3339,
+	"iso8601":  time.RFC3339,
+	"rfc1123":  time.RFC1123Z,
+	"date":     time.DateOnly,
+	"time":     time.TimeOnly,
+	"datetime": time.DateTime,
+	"kitchen":  time.Kitchen,
+}
+
+// strftimeDirectives maps strftime directives to Go layout elements
+var strftimeDirectives = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2",
+	'H': "15", 'I': "03", 'M': "04", 'S': "05", 'p': "PM",
+	'B': "January", 'b': "Jan", 'A': "Monday", 'a': "Mon",
+	'Z': "MST", 'z': "-0700", 'j': "002", '%': "%",
+}
+
+// relativePart matches one component of a relative date, e.g. "3 days" or "-2h"
+var relativePart = regexp.MustCompile(`^([+-]?\d+)\s*(seconds?|secs?|s|minutes?|mins?|m|hours?|hrs?|h|days?|d|weeks?|w|months?|years?|y)$`)
+
+// TimeTool reports the current date and time so the model doesn't have to guess it
+type TimeTool struct {
+	now func() time.Time
+}
+
+// NewTimeTool creates a new time tool
+func NewTimeTool() *TimeTool {
+	return &TimeTool{now: time.Now}
+}
+
+func (t *TimeTool) Name() string {
+	return "current_time"
+}
+
+func (t *TimeTool) Description() string {
+	return "Get the current date, time and timezone. Always use this instead of guessing today's date. " +
+		"Optionally shift the time by a relative offset (e.g. \"3 days from now\", \"2 weeks ago\", \"tomorrow\") " +
+		"and format the result."
+}
+
+func (t *TimeTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"format": map[string]any{
+				"type": "string",
+				"description": "Optional output format: rfc3339, date, time, datetime, unix, a strftime pattern " +
+					"like %Y-%m-%d, or a Go layout like 2006-01-02",
+			},
+			"offset": map[string]any{
+				"type":        "string",
+				"description": "Optional relative time, e.g. \"3 days from now\", \"in 2 hours\", \"1 week ago\", \"tomorrow\"",
+			},
+		},
+	}
+}
+
+func (t *TimeTool) Execute(args map[string]any) (string, error) {
+	format, err := optionalString(args, "format")
+	if err != nil {
+		return "", err
+	}
+	offset, err := optionalString(args, "offset")
+	if err != nil {
+		return "", err
+	}
+
+	now := t.now()
+	var sb strings.Builder
+	name, _ := now.Zone()
+	fmt.Fprintf(&sb, "Local: %s\n", now.Format("Monday, 2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(&sb, "UTC: %s\n", now.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&sb, "Timezone: %s (UTC%s)", timezoneName(now, name), now.Format("-07:00"))
+
+	target := now
+	if offset != "" {
+		target, err = applyRelative(now, offset)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "\n%s: %s", offset, target.Format("Monday, 2006-01-02 15:04:05 MST"))
+	}
+
+	if format != "" {
+		fmt.Fprintf(&sb, "\nFormatted: %s", formatTime(target, format))
+	}
+
+	return sb.String(), nil
+}
+
+// optionalString returns a string argument, or empty if it wasn't given
+func optionalString(args map[string]any, name string) (string, error) {
+	raw, ok := args[name]
+	if !ok || raw == nil {
+		return "", nil
+	}
+	value, ok := raw.(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string", name)
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// timezoneName returns the IANA name of the time's location when known, or its abbreviation
+func timezoneName(t time.Time, abbreviation string) string {
+	if name := t.Location().String(); name != "Local" && name != "" {
+		return name
+	}
+	return abbreviation
+}
+
+// formatTime formats a time using a named format, a strftime pattern or a Go layout
+func formatTime(t time.Time, format string) string {
+	lower := strings.ToLower(format)
+	if lower == "unix" {
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+	if layout, ok := timeFormats[lower]; ok {
+		return t.Format(layout)
+	}
+	if strings.Contains(format, "%") {
+		return t.Format(strftimeLayout(format))
+	}
+	return t.Format(format)
+}
+
+// strftimeLayout converts a strftime pattern to a Go layout, leaving unknown directives as they are
+func strftimeLayout(format string) string {
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] == '%' && i+1 < len(format) {
+			if layout, ok := strftimeDirectives[format[i+1]]; ok {
+				sb.WriteString(layout)
+				i++
+				continue
+			}
+		}
+		sb.WriteByte(format[i])
+	}
+	return sb.String()
+}
+
+// applyRelative shifts a time by a relative description such as "3 days from now" or "1 week, 2 hours ago"
+func applyRelative(now time.Time, offset string) (time.Time, error) {
+	text := strings.ToLower(strings.TrimSpace(offset))
+	switch text {
+	case "now", "today":
+		return now, nil
+	case "tomorrow":
+		return now.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	}
+
+	sign := 1
+	text = strings.TrimPrefix(text, "in ")
+	for _, suffix := range []string{" from now", " later", " ahead"} {
+		text = strings.TrimSuffix(text, suffix)
+	}
+	if trimmed, ok := strings.CutSuffix(text, " ago"); ok {
+		text = trimmed
+		sign = -1
+	}
+
+	// Components are separated by commas, spaces or "and": "1 week, 2 days and 3 hours"
+	fields := strings.Fields(strings.NewReplacer(",", " ", " and ", " ").Replace(text))
+	if len(fields) == 0 {
+		return time.Time{}, fmt.Errorf("invalid offset %q", offset)
+	}
+
+	result := now
+	for i := 0; i < len(fields); i++ {
+		part := fields[i]
+		// Allow "3 days" as well as "3days"
+		if i+1 < len(fields) && !relativePart.MatchString(part) {
+			part += " " + fields[i+1]
+			i++
+		}
+		match := relativePart.FindStringSubmatch(part)
+		if match == nil {
+			return time.Time{}, fmt.Errorf("invalid offset %q: expected something like \"3 days from now\" or \"2 hours ago\"", offset)
+		}
+		n, err := strconv.Atoi(match[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid offset %q: %w", offset, err)
+		}
+		n *= sign
+
+		switch unit := match[2]; {
+		case strings.HasPrefix(unit, "y"):
+			result = result.AddDate(n, 0, 0)
+		case strings.HasPrefix(unit, "mo"):
+			result = result.AddDate(0, n, 0)
+		case strings.HasPrefix(unit, "w"):
+			result = result.AddDate(0, 0, 7*n)
+		case strings.HasPrefix(unit, "d"):
+			result = result.AddDate(0, 0, n)
+		case strings.HasPrefix(unit, "h"):
+			result = result.Add(time.Duration(n) * time.Hour)
+		case strings.HasPrefix(unit, "m"):
+			result = result.Add(time.Duration(n) * time.Minute)
+		default:
+			result = result.Add(time.Duration(n) * time.Second)
+		}
+	}
+	return result, nil
+}
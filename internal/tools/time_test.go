@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func fixedTimeTool() *TimeTool {
+	loc := time.FixedZone("CEST", 2*60*60)
+	return &TimeTool{now: func() time.Time { return time.Date(2026, 3, 31, 14, 30, 0, 0, loc) }}
+}
+
+func TestTimeTool_Name(t *testing.T) {
+	tool := NewTimeTool()
+	if tool.Name() != "current_time" {
+		t.Errorf("expected name 'current_time', got %q", tool.Name())
+	}
+}
+
+func TestTimeTool_Execute(t *testing.T) {
+	got, err := fixedTimeTool().Execute(map[string]any{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"Local: Tuesday, 2026-03-31 14:30:00 CEST",
+		"UTC: 2026-03-31T12:30:00Z",
+		"Timezone: CEST (UTC+02:00)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestTimeTool_Execute_Format(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"date", "2026-03-31"},
+		{"RFC3339", "2026-03-31T14:30:00+02:00"},
+		{"unix", "1774960200"},
+		{"%d/%m/%Y %H:%M", "31/03/2026 14:30"},
+		{"Jan 2, 2006", "Mar 31, 2026"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := fixedTimeTool().Execute(map[string]any{"format": tt.format})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.HasSuffix(got, "Formatted: "+tt.want) {
+				t.Errorf("expected formatted %q, got:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTimeTool_Execute_Offset(t *testing.T) {
+	tests := []struct {
+		offset string
+		want   string
+	}{
+		{"3 days from now", "2026-04-03 14:30"},
+		{"in 2 hours", "2026-03-31 16:30"},
+		{"1 week ago", "2026-03-24 14:30"},
+		{"tomorrow", "2026-04-01 14:30"},
+		{"2 years", "2028-03-31 14:30"},
+		{"1 day, 2 hours and 30 minutes ago", "2026-03-30 12:00"},
+		{"-90m", "2026-03-31 13:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.offset, func(t *testing.T) {
+			got, err := fixedTimeTool().Execute(map[string]any{"offset": tt.offset, "format": "2006-01-02 15:04"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.HasSuffix(got, "Formatted: "+tt.want) {
+				t.Errorf("expected %q, got:\n%s", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := fixedTimeTool().Execute(map[string]any{"offset": "next full moon"}); err == nil {
+		t.Error("expected error for an unparseable offset")
+	}
+}
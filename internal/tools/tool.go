@@ -1,5 +1,7 @@
 package tools
 
+import "context"
+
 // Tool represents a callable tool
 type Tool interface {
 	// Name returns the tool name
@@ -15,6 +17,14 @@ type Tool interface {
 	Execute(args map[string]any) (string, error)
 }
 
+// ContextTool is a tool that also runs with the context of the chat it serves, e.g. to find its Hooks
+type ContextTool interface {
+	Tool
+
+	// ExecuteContext runs the tool like Execute, within ctx
+	ExecuteContext(ctx context.Context, args map[string]any) (string, error)
+}
+
 // Definition returns the Ollama tool definition format
 func Definition(t Tool) map[string]any {
 	return map[string]any{
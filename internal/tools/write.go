@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,9 +10,14 @@ import (
 	"github.com/marciniwanicki/craby/internal/config"
 )
 
+// WriteConfirmer is asked to approve a write before it happens.
+// It receives the resolved path and a short description of the change.
+type WriteConfirmer func(path, description string) bool
+
 // WriteTool writes content to files
 type WriteTool struct {
-	settings *config.Settings
+	settings  *config.Settings
+	confirmer WriteConfirmer // Optional approval hook (nil = no confirmation)
 }
 
 // NewWriteTool creates a new write tool
@@ -21,12 +27,17 @@ func NewWriteTool(settings *config.Settings) *WriteTool {
 	}
 }
 
+// SetConfirmer sets a callback that must approve every write before it is performed
+func (t *WriteTool) SetConfirmer(confirmer WriteConfirmer) {
+	t.confirmer = confirmer
+}
+
 func (t *WriteTool) Name() string {
 	return "write"
 }
 
 func (t *WriteTool) Description() string {
-	return "Write content to a file. Can create new files or overwrite/append to existing ones. " +
+	return "Write content to a file. Can create new files or append to existing ones; replacing an existing file requires overwrite=true. " +
 		"Allowed paths: " + strings.Join(t.settings.Tools.Write.AllowedPaths, ", ")
 }
 
@@ -46,12 +57,21 @@ func (t *WriteTool) Parameters() map[string]any {
 				"type":        "boolean",
 				"description": "If true, append to the file instead of overwriting (default: false)",
 			},
+			"overwrite": map[string]any{
+				"type":        "boolean",
+				"description": "Must be true to replace an existing file (default: false)",
+			},
 		},
 		"required": []string{"path", "content"},
 	}
 }
 
 func (t *WriteTool) Execute(args map[string]any) (string, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext writes like Execute, asking the confirmer from the Hooks carried by ctx
+func (t *WriteTool) ExecuteContext(ctx context.Context, args map[string]any) (string, error) {
 	// Extract path parameter
 	pathRaw, ok := args["path"]
 	if !ok {
@@ -72,13 +92,9 @@ func (t *WriteTool) Execute(args map[string]any) (string, error) {
 		return "", fmt.Errorf("content must be a string")
 	}
 
-	// Extract append parameter (optional, defaults to false)
-	appendMode := false
-	if appendRaw, ok := args["append"]; ok {
-		if appendBool, ok := appendRaw.(bool); ok {
-			appendMode = appendBool
-		}
-	}
+	// Extract optional flags (default to false)
+	appendMode := boolArg(args, "append")
+	overwrite := boolArg(args, "overwrite")
 
 	// Validate path
 	allowed, reason := t.settings.IsWritePathAllowed(path)
@@ -93,12 +109,59 @@ func (t *WriteTool) Execute(args map[string]any) (string, error) {
 		}
 	}
 
-	// Expand and resolve path
+	// Expand and resolve path. Symlinks are followed, so a link inside an allowed path
+	// can't lead the write elsewhere, and the file it points to is updated in place of it.
 	expandedPath := config.ExpandPath(path)
 	absPath, err := filepath.Abs(expandedPath)
 	if err != nil {
 		return "", fmt.Errorf("invalid path: %w", err)
 	}
+	absPath, err = resolveWritePath(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+	if allowed, reason := t.settings.IsWritePathAllowed(absPath); !allowed {
+		return "", fmt.Errorf("write not allowed: %s (%s resolves to %s)", reason, path, absPath)
+	}
+
+	// Load existing content when appending; refuse to clobber files unless asked to
+	var existing []byte
+	perm := os.FileMode(0600) // New files are private, existing ones keep their permissions
+	info, err := os.Stat(absPath)
+	if err == nil {
+		perm = info.Mode().Perm()
+	}
+	switch {
+	case err == nil && info.IsDir():
+		return "", fmt.Errorf("%s is a directory", path)
+	case err == nil && appendMode:
+		existing, err = os.ReadFile(absPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read existing file: %w", err)
+		}
+	case err == nil && !overwrite:
+		return "", fmt.Errorf("%s already exists; set overwrite to true to replace it", path)
+	case err != nil && !os.IsNotExist(err):
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	if t.settings.Tools.Write.MaxFileSize > 0 && int64(len(existing)+len(content)) > t.settings.Tools.Write.MaxFileSize {
+		return "", fmt.Errorf("resulting file exceeds maximum file size (%d bytes)", t.settings.Tools.Write.MaxFileSize)
+	}
+
+	request, action := "write", "wrote"
+	if appendMode {
+		request, action = "append", "appended"
+	}
+
+	if confirmer := t.confirmer; confirmer != nil {
+		if hooks := hooksFrom(ctx); hooks.ConfirmWrite != nil {
+			confirmer = hooks.ConfirmWrite
+		}
+		if !confirmer(absPath, fmt.Sprintf("%s %d bytes", request, len(content))) {
+			return "", fmt.Errorf("write to %s was not approved", path)
+		}
+	}
 
 	// Create parent directories if needed
 	dir := filepath.Dir(absPath)
@@ -106,31 +169,70 @@ func (t *WriteTool) Execute(args map[string]any) (string, error) {
 		return "", fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	// Determine file flags
-	flags := os.O_WRONLY | os.O_CREATE
-	if appendMode {
-		flags |= os.O_APPEND
-	} else {
-		flags |= os.O_TRUNC
+	if err := writeFileAtomic(absPath, append(existing, content...), perm); err != nil {
+		return "", err
 	}
 
-	// Open/create file with secure permissions
-	file, err := os.OpenFile(absPath, flags, 0600)
-	if err != nil {
-		return "", fmt.Errorf("failed to open file: %w", err)
+	return fmt.Sprintf("Successfully %s %d bytes to %s", action, len(content), absPath), nil
+}
+
+// boolArg returns a boolean argument, treating missing or non-boolean values as false
+func boolArg(args map[string]any, name string) bool {
+	value, _ := args[name].(bool)
+	return value
+}
+
+// resolveWritePath evaluates symlinks in path, including one at the file itself.
+// Trailing components that don't exist yet are kept as they are.
+func resolveWritePath(path string) (string, error) {
+	existing := path
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return path, nil
+		}
+		missing = append([]string{filepath.Base(existing)}, missing...)
+		existing = parent
 	}
-	defer file.Close()
+}
 
-	// Write content
-	n, err := file.WriteString(content)
+// writeFileAtomic writes data to a temp file in the target directory and renames it into place,
+// so readers never observe a partially written file. The file gets perm.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
 	if err != nil {
-		return "", fmt.Errorf("failed to write content: %w", err)
+		return fmt.Errorf("failed to create temp file: %w", err)
 	}
+	tmpPath := tmp.Name()
 
-	action := "wrote"
-	if appendMode {
-		action = "appended"
+	// CreateTemp uses 0600; an existing file's permissions carry over to its replacement
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to write content: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to write content: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace file: %w", err)
 	}
 
-	return fmt.Sprintf("Successfully %s %d bytes to %s", action, n, path), nil
+	return nil
 }
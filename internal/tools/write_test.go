@@ -1,8 +1,10 @@
 package tools
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/marciniwanicki/craby/internal/config"
@@ -58,6 +60,9 @@ func TestWriteTool_Parameters(t *testing.T) {
 	if _, ok := props["append"]; !ok {
 		t.Error("expected 'append' property")
 	}
+	if _, ok := props["overwrite"]; !ok {
+		t.Error("expected 'overwrite' property")
+	}
 }
 
 func TestWriteTool_Execute_CreateFile(t *testing.T) {
@@ -104,11 +109,21 @@ func TestWriteTool_Execute_OverwriteFile(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	// Overwrite
+	// Overwrite without the flag is refused
 	_, err = tool.Execute(map[string]any{
 		"path":    filePath,
 		"content": "New content",
 	})
+	if err == nil {
+		t.Fatal("expected error when overwriting without overwrite flag")
+	}
+
+	// Overwrite
+	_, err = tool.Execute(map[string]any{
+		"path":      filePath,
+		"content":   "New content",
+		"overwrite": true,
+	})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -260,3 +275,176 @@ func TestWriteTool_Execute_Disabled(t *testing.T) {
 		t.Error("expected error when tool is disabled")
 	}
 }
+
+func TestWriteTool_Execute_Confirmer(t *testing.T) {
+	tmpDir := t.TempDir()
+	tool := NewWriteTool(writeTestSettings([]string{tmpDir}, nil))
+	filePath := filepath.Join(tmpDir, "test.txt")
+
+	var confirmedPath string
+	tool.SetConfirmer(func(path, description string) bool {
+		confirmedPath = path
+		return false
+	})
+
+	_, err := tool.Execute(map[string]any{
+		"path":    filePath,
+		"content": "test",
+	})
+	if err == nil {
+		t.Fatal("expected error when write is not approved")
+	}
+	if confirmedPath != filePath {
+		t.Errorf("expected confirmer to receive %q, got %q", filePath, confirmedPath)
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Error("expected file not to be created")
+	}
+
+	tool.SetConfirmer(func(path, description string) bool { return true })
+	result, err := tool.Execute(map[string]any{
+		"path":    filePath,
+		"content": "test",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "Successfully wrote 4 bytes to "+filePath {
+		t.Errorf("unexpected result: %q", result)
+	}
+}
+
+func TestWriteTool_ExecuteContext_ConfirmHook(t *testing.T) {
+	tmpDir := t.TempDir()
+	tool := NewWriteTool(writeTestSettings([]string{tmpDir}, nil))
+
+	asked := 0
+	ctx := WithHooks(context.Background(), Hooks{ConfirmWrite: func(path, description string) bool {
+		asked++
+		return description == "write 2 bytes"
+	}})
+
+	// Without a confirmer of its own the tool doesn't need approval
+	if _, err := tool.ExecuteContext(ctx, map[string]any{"path": filepath.Join(tmpDir, "a.txt"), "content": "hi"}); err != nil || asked != 0 {
+		t.Fatalf("expected the write to go unconfirmed, got %v after %d questions", err, asked)
+	}
+
+	// With one, the chat's confirmer answers in its place
+	tool.SetConfirmer(func(path, description string) bool { return false })
+	if _, err := tool.ExecuteContext(ctx, map[string]any{"path": filepath.Join(tmpDir, "b.txt"), "content": "hi"}); err != nil || asked != 1 {
+		t.Fatalf("expected the hook to approve, got %v after %d questions", err, asked)
+	}
+	if _, err := tool.Execute(map[string]any{"path": filepath.Join(tmpDir, "c.txt"), "content": "hi"}); err == nil {
+		t.Error("expected the tool's confirmer to refuse without hooks")
+	}
+}
+
+func TestWriteTool_Execute_KeepsMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	tool := NewWriteTool(writeTestSettings([]string{tmpDir}, nil))
+	filePath := filepath.Join(tmpDir, "script.sh")
+	if err := os.WriteFile(filePath, []byte("#!/bin/sh\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filePath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tool.Execute(map[string]any{"path": filePath, "content": "echo hi\n", "append": true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("expected mode 0755 kept, got %v", info.Mode().Perm())
+	}
+
+	// New files stay private
+	newPath := filepath.Join(tmpDir, "new.txt")
+	if _, err := tool.Execute(map[string]any{"path": newPath, "content": "x"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, err := os.Stat(newPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected a new file with mode 0600, got %v, %v", info.Mode().Perm(), err)
+	}
+}
+
+func TestWriteTool_Execute_Symlinks(t *testing.T) {
+	allowed := t.TempDir()
+	outside := t.TempDir()
+	tool := NewWriteTool(writeTestSettings([]string{allowed}, []string{filepath.Join(allowed, "blocked")}))
+
+	// A link out of the allowed path is refused, whether it's a directory or the file itself
+	if err := os.Symlink(outside, filepath.Join(allowed, "out")); err != nil {
+		t.Fatal(err)
+	}
+	outsideFile := filepath.Join(outside, "target.txt")
+	if err := os.WriteFile(outsideFile, []byte("original"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outsideFile, filepath.Join(allowed, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"out/new.txt", "out/deeper/new.txt", "link.txt"} {
+		_, err := tool.Execute(map[string]any{"path": filepath.Join(allowed, path), "content": "x", "overwrite": true})
+		if err == nil || !strings.Contains(err.Error(), "write not allowed") {
+			t.Errorf("expected writing %s refused, got %v", path, err)
+		}
+	}
+	if data, _ := os.ReadFile(outsideFile); string(data) != "original" {
+		t.Errorf("expected the file outside untouched, got %q", data)
+	}
+
+	// So is a link into a blocked path
+	if err := os.MkdirAll(filepath.Join(allowed, "blocked"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(allowed, "blocked"), filepath.Join(allowed, "innocent")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tool.Execute(map[string]any{"path": filepath.Join(allowed, "innocent", "x.txt"), "content": "x"}); err == nil {
+		t.Error("expected writing through a link into a blocked path refused")
+	}
+
+	// A link within the allowed path updates its target and stays a link
+	target := filepath.Join(allowed, "real.txt")
+	if err := os.WriteFile(target, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(allowed, "alias.txt")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tool.Execute(map[string]any{"path": link, "content": "new", "overwrite": true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "new" {
+		t.Errorf("expected the link's target written, got %q", data)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("expected the link kept, got %v, %v", info, err)
+	}
+}
+
+func TestWriteTool_Execute_NoTempFilesLeft(t *testing.T) {
+	tmpDir := t.TempDir()
+	tool := NewWriteTool(writeTestSettings([]string{tmpDir}, nil))
+
+	_, err := tool.Execute(map[string]any{
+		"path":    filepath.Join(tmpDir, "test.txt"),
+		"content": "test",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the written file, got %d entries", len(entries))
+	}
+}
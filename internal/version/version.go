@@ -0,0 +1,118 @@
+// Package version reports which build of craby is running.
+//
+// Release builds stamp the variables with -ldflags, e.g.
+//
+//	go build -ldflags "-X github.com/marciniwanicki/craby/internal/version.Version=1.2.0" ./cmd/craby
+//
+// Builds that aren't stamped fall back to the VCS information Go embeds in the binary.
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Protocol is the revision of the messages exchanged by the client and the daemon.
+// Bump it when a change makes older clients or daemons misread them.
+const Protocol = 1
+
+var (
+	// Version is the release version
+	Version = "0.1.0"
+	// Commit is the git commit the binary was built from
+	Commit = ""
+	// Date is when the binary was built, in RFC 3339
+	Date = ""
+)
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	commit, date, modified := fromBuildSettings(info.Settings)
+	if Commit == "" && commit != "" {
+		Commit = commit
+		if modified {
+			Commit += "-dirty"
+		}
+	}
+	if Date == "" {
+		Date = date
+	}
+}
+
+// fromBuildSettings extracts the commit, its time and whether the tree was modified from Go's build settings
+func fromBuildSettings(settings []debug.BuildSetting) (commit, date string, modified bool) {
+	for _, s := range settings {
+		switch s.Key {
+		case "vcs.revision":
+			commit = s.Value
+			if len(commit) > 12 {
+				commit = commit[:12]
+			}
+		case "vcs.time":
+			date = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	return commit, date, modified
+}
+
+// String describes the build, e.g. "1.2.0 (commit 3f2a9c1d0b7e, built 2026-10-16T10:00:00Z)"
+func String() string {
+	return Format(Version, Commit, Date)
+}
+
+// Info describes the running build, with the same fields as the daemon's /version endpoint
+// plus the toolchain and platform
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`     // Empty if unknown
+	BuildDate string `json:"build_date"` // RFC 3339, empty if unknown
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"` // GOOS/GOARCH
+}
+
+// Get returns the running build's Info
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+}
+
+// String describes the build including its toolchain,
+// e.g. "1.2.0 (commit 3f2a9c1d0b7e, built 2026-10-16 10:00 UTC), go1.24.0 darwin/arm64"
+func (i Info) String() string {
+	return fmt.Sprintf("%s, %s %s", Format(i.Version, i.Commit, i.BuildDate), i.GoVersion, i.Platform)
+}
+
+// Format describes a build from its version, commit and date, leaving out whatever is unknown
+func Format(version, commit, date string) string {
+	s := version
+	switch {
+	case commit != "" && date != "":
+		s += fmt.Sprintf(" (commit %s, built %s)", commit, formatDate(date))
+	case commit != "":
+		s += fmt.Sprintf(" (commit %s)", commit)
+	case date != "":
+		s += fmt.Sprintf(" (built %s)", formatDate(date))
+	}
+	return s
+}
+
+// formatDate shortens an RFC 3339 date to UTC minutes, leaving other formats as they are
+func formatDate(date string) string {
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return date
+	}
+	return t.UTC().Format("2006-01-02 15:04 UTC")
+}
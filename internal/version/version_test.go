@@ -0,0 +1,40 @@
+package version
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		commit, date, want string
+	}{
+		{"abc123", "2026-10-16T10:30:00Z", "1.2.0 (commit abc123, built 2026-10-16 10:30 UTC)"},
+		{"abc123", "", "1.2.0 (commit abc123)"},
+		{"", "2026-10-16", "1.2.0 (built 2026-10-16)"},
+		{"", "", "1.2.0"},
+	}
+	for _, tt := range tests {
+		if got := Format("1.2.0", tt.commit, tt.date); got != tt.want {
+			t.Errorf("Format(%q, %q) = %q, want %q", tt.commit, tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestFromBuildSettings(t *testing.T) {
+	commit, date, modified := fromBuildSettings([]debug.BuildSetting{
+		{Key: "vcs.revision", Value: "0123456789abcdef0123"},
+		{Key: "vcs.time", Value: "2026-10-16T10:30:00Z"},
+		{Key: "vcs.modified", Value: "true"},
+	})
+	if commit != "0123456789ab" || date != "2026-10-16T10:30:00Z" || !modified {
+		t.Errorf("unexpected build settings: %q %q %v", commit, date, modified)
+	}
+}
+
+func TestInfo_String(t *testing.T) {
+	info := Info{Version: "1.2.0", Commit: "abc123", GoVersion: "go1.24.0", Platform: "darwin/arm64"}
+	if got, want := info.String(), "1.2.0 (commit abc123), go1.24.0 darwin/arm64"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}